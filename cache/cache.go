@@ -0,0 +1,205 @@
+// Package cache is an in-process LRU cache of query results, with a TTL per result.
+// Results are tagged with what they were read from (eg: the IDs of the rows), so a write invalidates
+// exactly the results it changes
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache is an LRU cache, safe for concurrent use. A Cache of size 0 is disabled: nothing is cached
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	entries *list.List               // of *entry, the most recently used first
+	keys    map[string]*list.Element // key -> entry
+	tags    map[string]map[*list.Element]struct{}
+
+	// invalidated is when each tag was last invalidated, for the last retention. A result read before
+	// then is not stored (it may be stale). Older invalidations are forgotten: results read before
+	// forgotten are not stored either
+	retention   time.Duration
+	invalidated map[string]time.Time
+	forgotten   time.Time
+	pruned      time.Time
+
+	stats Stats
+	now   func() time.Time
+}
+
+// entry is a cached result
+type entry struct {
+	key     string
+	value   interface{}
+	tags    []string
+	expires time.Time
+}
+
+// Stats are the counters of a Cache
+type Stats struct {
+	Entries       int    `json:"entries"`
+	Size          int    `json:"size"`
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Evictions     uint64 `json:"evictions"`     // least recently used entries removed to make room
+	Invalidations uint64 `json:"invalidations"` // entries removed by Invalidate
+	Rejected      uint64 `json:"rejected"`      // results not stored, as they were invalidated while being read
+}
+
+// New creates a Cache of at most size entries. Invalidations are remembered for retention: it bounds
+// how long a result may take to be read (and stored)
+func New(size int, retention time.Duration) *Cache {
+	return &Cache{
+		size:        size,
+		entries:     list.New(),
+		keys:        make(map[string]*list.Element),
+		tags:        make(map[string]map[*list.Element]struct{}),
+		retention:   retention,
+		invalidated: make(map[string]time.Time),
+		now:         time.Now,
+	}
+}
+
+// Enabled tells whether anything is cached
+func (c *Cache) Enabled() bool {
+	return c.size > 0
+}
+
+// Now is the time to pass to Set: take it before reading the result
+func (c *Cache) Now() time.Time {
+	return c.now()
+}
+
+// Get returns the value of key, unless it is missing or expired
+func (c *Cache) Get(key string) (interface{}, bool) {
+	if !c.Enabled() {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.keys[key]
+	if ok && !c.now().Before(element.Value.(*entry).expires) {
+		c.remove(element)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+	c.entries.MoveToFront(element)
+	return element.Value.(*entry).value, true
+}
+
+// Set stores the value of key for ttl, tagged with tags, unless any of them was invalidated since the value
+// was read (at since, see Now). It tells whether the value was stored
+func (c *Cache) Set(key string, value interface{}, ttl time.Duration, since time.Time, tags ...string) bool {
+	if !c.Enabled() || ttl <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !since.After(c.forgotten) {
+		c.stats.Rejected++
+		return false
+	}
+	for _, tag := range tags {
+		if at, ok := c.invalidated[tag]; ok && !since.After(at) {
+			c.stats.Rejected++
+			return false
+		}
+	}
+
+	if element, ok := c.keys[key]; ok {
+		c.remove(element)
+	}
+	element := c.entries.PushFront(&entry{key: key, value: value, tags: tags, expires: c.now().Add(ttl)})
+	c.keys[key] = element
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = make(map[*list.Element]struct{})
+		}
+		c.tags[tag][element] = struct{}{}
+	}
+
+	for c.entries.Len() > c.size {
+		c.remove(c.entries.Back())
+		c.stats.Evictions++
+	}
+	return true
+}
+
+// Invalidate removes every entry tagged with any of tags, and prevents storing the results read before now
+// with any of them (see Set)
+func (c *Cache) Invalidate(tags ...string) {
+	if !c.Enabled() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for _, tag := range tags {
+		c.invalidated[tag] = now
+		for element := range c.tags[tag] {
+			c.remove(element)
+			c.stats.Invalidations++
+		}
+	}
+	c.prune(now)
+}
+
+// prune forgets the invalidations older than retention (checked at most once per retention)
+func (c *Cache) prune(now time.Time) {
+	if now.Sub(c.pruned) < c.retention {
+		return
+	}
+	c.pruned = now
+
+	for tag, at := range c.invalidated {
+		if now.Sub(at) > c.retention {
+			delete(c.invalidated, tag)
+			if at.After(c.forgotten) {
+				c.forgotten = at
+			}
+		}
+	}
+}
+
+// Purge removes every entry (the invalidations are kept)
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries.Init()
+	c.keys = make(map[string]*list.Element)
+	c.tags = make(map[string]map[*list.Element]struct{})
+}
+
+func (c *Cache) remove(element *list.Element) {
+	e := element.Value.(*entry)
+	c.entries.Remove(element)
+	delete(c.keys, e.key)
+	for _, tag := range e.tags {
+		delete(c.tags[tag], element)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
+
+// Stats are the counters of the cache, and how many entries it has
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.entries.Len()
+	stats.Size = c.size
+	return stats
+}
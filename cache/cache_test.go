@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clock is a fake time.Now, moved by hand
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func (c *clock) add(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestCache(size int) (*Cache, *clock) {
+	clk := &clock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := New(size, time.Minute)
+	c.now = clk.now
+	clk.add(time.Second)
+	return c, clk
+}
+
+func TestGetSet(t *testing.T) {
+	c, clk := newTestCache(10)
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	assert.True(t, c.Set("a", 1, time.Minute, c.Now()))
+	value, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	assert.True(t, c.Set("a", 2, time.Minute, c.Now()), "replaced")
+	value, _ = c.Get("a")
+	assert.Equal(t, 2, value)
+
+	clk.add(time.Minute)
+	_, ok = c.Get("a")
+	assert.False(t, ok, "expired")
+
+	assert.False(t, c.Set("b", 1, 0, c.Now()), "no TTL")
+
+	assert.Equal(t, Stats{Size: 10, Hits: 2, Misses: 2}, c.Stats())
+}
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	c, _ := newTestCache(2)
+
+	c.Set("a", 1, time.Minute, c.Now())
+	c.Set("b", 2, time.Minute, c.Now())
+	c.Get("a")
+	c.Set("c", 3, time.Minute, c.Now())
+
+	_, ok := c.Get("b")
+	assert.False(t, ok, "b was the least recently used")
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+
+	stats := c.Stats()
+	assert.Equal(t, 2, stats.Entries)
+	assert.Equal(t, uint64(1), stats.Evictions)
+}
+
+func TestInvalidate(t *testing.T) {
+	c, clk := newTestCache(10)
+
+	c.Set("super:a", "a", time.Minute, c.Now(), "super:1", "group:1")
+	c.Set("super:b", "b", time.Minute, c.Now(), "super:2", "group:1")
+	c.Set("super:c", "c", time.Minute, c.Now(), "super:3")
+
+	c.Invalidate("group:1")
+	for key, cached := range map[string]bool{"super:a": false, "super:b": false, "super:c": true} {
+		_, ok := c.Get(key)
+		assert.Equal(t, cached, ok, key)
+	}
+	assert.Equal(t, uint64(2), c.Stats().Invalidations)
+
+	// read before the invalidation: it may be stale
+	since := c.Now()
+	clk.add(time.Millisecond)
+	c.Invalidate("super:1")
+	assert.False(t, c.Set("super:a", "a", time.Minute, since, "super:1"))
+	assert.False(t, c.Set("super:a", "a", time.Minute, c.Now(), "super:1"), "read at the same time")
+	assert.True(t, c.Set("super:b", "b", time.Minute, since, "super:2"), "not invalidated")
+
+	clk.add(time.Millisecond)
+	assert.True(t, c.Set("super:a", "a", time.Minute, c.Now(), "super:1"), "read after the invalidation")
+	assert.Equal(t, uint64(2), c.Stats().Rejected)
+}
+
+func TestForgetInvalidations(t *testing.T) {
+	c, clk := newTestCache(10)
+
+	c.Invalidate("super:1")
+	since := c.Now()
+	clk.add(2 * time.Minute)
+	c.Invalidate("super:2")
+
+	assert.Len(t, c.invalidated, 1, "super:1 is forgotten")
+	assert.False(t, c.Set("super:3", 3, time.Minute, since), "read before the forgotten invalidations")
+
+	clk.add(time.Millisecond)
+	assert.True(t, c.Set("super:1", 1, time.Minute, c.Now(), "super:1"))
+}
+
+func TestDisabled(t *testing.T) {
+	c, _ := newTestCache(0)
+
+	assert.False(t, c.Enabled())
+	assert.False(t, c.Set("a", 1, time.Minute, c.Now()))
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	c.Invalidate("a")
+	assert.Equal(t, Stats{}, c.Stats())
+}
+
+func TestPurge(t *testing.T) {
+	c, _ := newTestCache(10)
+
+	c.Set("a", 1, time.Minute, c.Now(), "t")
+	c.Purge()
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Empty(t, c.tags)
+}
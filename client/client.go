@@ -0,0 +1,294 @@
+// Package client is a Go client of the superhero REST API (/api/v1).
+// It returns the same models.Super and models.Group types as the server, and typed errors (see errors.go)
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/tcarreira/superhero/models"
+)
+
+// Default Client settings
+const (
+	DefaultTimeout      = 30 * time.Second
+	DefaultMaxRetries   = 3
+	DefaultRetryWaitMin = 100 * time.Millisecond
+	DefaultRetryWaitMax = 5 * time.Second
+	DefaultPageSize     = 100
+)
+
+// apiPrefix is where the API is served
+const apiPrefix = "/api/v1"
+
+// readPrimaryUntil is returned by the writes, and sent back, so the server reads from the primary
+// (not from a read replica, which may not have the write yet)
+const readPrimaryUntil = "X-Read-Primary-Until"
+
+// Client calls the superhero API. Its fields may be changed before it is used
+type Client struct {
+	BaseURL      string // eg: http://localhost:8080
+	HTTPClient   *http.Client
+	Actor        string // sent as X-Actor (who is making the changes, for the audit log)
+	MaxRetries   int    // retries of failed idempotent requests (0 disables retries)
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+	PageSize     int // how many Supers/Groups are requested at once by the iterators
+
+	mu               sync.Mutex
+	readPrimaryUntil string // of the last write, sent back so the reads see it (see the server's read replicas)
+}
+
+// New creates a Client with the default settings
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		HTTPClient:   &http.Client{Timeout: DefaultTimeout},
+		MaxRetries:   DefaultMaxRetries,
+		RetryWaitMin: DefaultRetryWaitMin,
+		RetryWaitMax: DefaultRetryWaitMax,
+		PageSize:     DefaultPageSize,
+	}
+}
+
+// request is an API call
+type request struct {
+	method  string
+	path    string // relative to /api/v1
+	query   url.Values
+	body    interface{}
+	header  http.Header
+	entity  string // "super" or "group", for mapping errors
+	version int64  // sent as If-Match (models.AnyVersion: "*") when ifMatch is set
+	ifMatch bool
+}
+
+// response is a successful API response
+type response struct {
+	StatusCode int
+	Header     http.Header
+}
+
+// ifMatch formats an expected version as If-Match header value
+func ifMatch(version int64) string {
+	if version == models.AnyVersion {
+		return "*"
+	}
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// versionFromETag gets the version from an ETag header value ("version" or "version-hash"), 0 if missing or invalid
+func versionFromETag(etag string) int64 {
+	tag := strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+	if i := strings.Index(tag, "-"); i >= 0 {
+		tag = tag[:i]
+	}
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return version
+}
+
+// newIdempotencyKey generates a random Idempotency-Key, so a POST is safe to retry
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// connectionErrors are the errors of a connection lost or refused (the request may succeed on another one)
+var connectionErrors = []error{
+	syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.ECONNABORTED, syscall.EPIPE, io.EOF, io.ErrUnexpectedEOF,
+}
+
+// retryableError tells if err is a timeout, a temporary or a connection error. Others (eg: a malformed URL,
+// TLS and certificate errors) would fail the same way again
+func retryableError(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if netErr, ok := err.(net.Error); ok && (netErr.Timeout() || netErr.Temporary()) {
+		return true
+	}
+	for _, connErr := range connectionErrors {
+		if errors.Is(err, connErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryable tells if a request may be sent again after it failed with status (or err)
+func retryable(method string, header http.Header, status int, err error) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+	case http.MethodPost:
+		if header.Get("Idempotency-Key") == "" {
+			return false
+		}
+	default:
+		return false
+	}
+
+	if err != nil {
+		return retryableError(err)
+	}
+
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff is how long to wait before the retry-th retry (exponential, up to RetryWaitMax, or Retry-After)
+func (c *Client) backoff(retry int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			wait := time.Duration(seconds) * time.Second
+			if wait > c.RetryWaitMax {
+				wait = c.RetryWaitMax
+			}
+			return wait
+		}
+	}
+
+	wait := c.RetryWaitMin
+	for i := 1; i < retry && wait < c.RetryWaitMax; i++ {
+		wait *= 2
+	}
+	if wait > c.RetryWaitMax {
+		wait = c.RetryWaitMax
+	}
+	return wait
+}
+
+// do sends the request (retrying it, if it is safe) and decodes the JSON response into out (if not nil)
+func (c *Client) do(ctx context.Context, req *request, out interface{}) (*response, error) {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, err
+		}
+	}
+
+	u := c.BaseURL + apiPrefix + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+
+	header := http.Header{}
+	for name, values := range req.header {
+		header[name] = values
+	}
+	header.Set("Accept", "application/json")
+	if body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+	if c.Actor != "" {
+		header.Set("X-Actor", c.Actor)
+	}
+	if req.ifMatch {
+		header.Set("If-Match", ifMatch(req.version))
+	}
+	if req.method == http.MethodPost && header.Get("Idempotency-Key") == "" {
+		header.Set("Idempotency-Key", newIdempotencyKey())
+	}
+	c.mu.Lock()
+	if c.readPrimaryUntil != "" {
+		header.Set(readPrimaryUntil, c.readPrimaryUntil)
+	}
+	c.mu.Unlock()
+
+	for retry := 0; ; retry++ {
+		httpReq, err := http.NewRequest(req.method, u, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		httpReq = httpReq.WithContext(ctx)
+		httpReq.Header = header.Clone()
+		// the trace context of ctx, with the propagator of the application (eg: W3C traceparent)
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+		httpResp, err := c.HTTPClient.Do(httpReq)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		status := 0
+		if httpResp != nil {
+			status = httpResp.StatusCode
+		}
+		if retry < c.MaxRetries && retryable(req.method, header, status, err) {
+			if httpResp != nil {
+				io.Copy(ioutil.Discard, httpResp.Body)
+				httpResp.Body.Close()
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(c.backoff(retry+1, httpResp)):
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if until := httpResp.Header.Get(readPrimaryUntil); until != "" {
+			c.mu.Lock()
+			c.readPrimaryUntil = until
+			c.mu.Unlock()
+		}
+		return c.handleResponse(httpResp, req.entity, out)
+	}
+}
+
+// handleResponse decodes a successful response into out, or returns the (typed) error of an error response
+func (c *Client) handleResponse(httpResp *http.Response, entity string, out interface{}) (*response, error) {
+	defer httpResp.Body.Close()
+
+	data, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if httpResp.StatusCode >= 400 {
+		apiErr := &APIError{StatusCode: httpResp.StatusCode}
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = http.StatusText(httpResp.StatusCode)
+		}
+		return nil, typedError(entity, apiErr)
+	}
+
+	if out != nil && httpResp.StatusCode != http.StatusNoContent && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return nil, fmt.Errorf("invalid response from %s: %w", httpResp.Request.URL.Path, err)
+		}
+	}
+
+	return &response{StatusCode: httpResp.StatusCode, Header: httpResp.Header}, nil
+}
@@ -0,0 +1,172 @@
+// +build sql
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/models"
+	"github.com/tcarreira/superhero/server"
+)
+
+func newServerClient(t *testing.T) *Client {
+	gin.SetMode(gin.TestMode)
+	return newTestClient(t, server.SetupRouter(models.SetupEmptyTestDatabase()))
+}
+
+func TestClientSupers(t *testing.T) {
+	c := newServerClient(t)
+	ctx := context.Background()
+
+	batman, err := c.CreateHero(ctx, "Batman")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "HERO", batman.Type)
+	assert.NotEmpty(t, batman.UUID)
+
+	_, err = c.CreateHero(ctx, "Batman")
+	assert.IsType(t, &ErrorSuperAlreadyExists{}, err)
+
+	_, err = c.CreateSuper(ctx, &models.Super{Type: "SIDEKICK", Name: "Robin"})
+	assert.IsType(t, &ErrorSuperInvalidFields{}, err)
+
+	_, err = c.CreateVilan(ctx, "Joker")
+	assert.NoError(t, err)
+
+	got, err := c.GetSuper(ctx, batman.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Batman", got.Name)
+	assert.Equal(t, int64(1), got.Version)
+
+	_, err = c.GetSuper(ctx, "Superman")
+	assert.IsType(t, &ErrorSuperNotFound{}, err)
+
+	got.Occupation = "Detective"
+	updated, err := c.UpdateSuper(ctx, "Batman", got, got.Version)
+	assert.NoError(t, err)
+	assert.Equal(t, "Detective", updated.Occupation)
+	assert.Equal(t, got.Version+1, updated.Version)
+
+	_, err = c.PatchSuper(ctx, "Batman", map[string]interface{}{"occupation": "CEO"}, got.Version)
+	assert.IsType(t, &ErrorSuperVersionMismatch{}, err)
+
+	patched, err := c.PatchSuper(ctx, "Batman", map[string]interface{}{"occupation": "CEO"}, updated.Version)
+	assert.NoError(t, err)
+	assert.Equal(t, "CEO", patched.Occupation)
+
+	reverted, err := c.RevertSuper(ctx, "Batman", updated.Version, models.AnyVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, "Detective", reverted.Occupation)
+
+	history, err := c.SuperHistory(ctx, "Batman")
+	assert.NoError(t, err)
+	assert.Len(t, history, 4)
+
+	vilans, err := c.ListSupers(ctx, ListSupersOptions{Type: "vilan"})
+	assert.NoError(t, err)
+	if assert.Len(t, vilans, 1) {
+		assert.Equal(t, "Joker", vilans[0].Name)
+	}
+
+	assert.NoError(t, c.DeleteSuper(ctx, "Joker", models.AnyVersion))
+	deleted, err := c.ListSupers(ctx, ListSupersOptions{Deleted: true})
+	assert.NoError(t, err)
+	assert.Len(t, deleted, 1)
+
+	restored, err := c.RestoreSuper(ctx, "Joker")
+	assert.NoError(t, err)
+	assert.Equal(t, "VILAN", restored.Type)
+}
+
+func TestClientPutKeepsMissingFields(t *testing.T) {
+	c := newServerClient(t)
+	ctx := context.Background()
+
+	_, err := c.CreateSuper(ctx, &models.Super{Type: "HERO", Name: "Batman", Strength: 26, Publisher: "DC Comics"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// as sent by a client written before the powerstats and profile
+	updated, err := c.getSuper(ctx, &request{
+		method:  http.MethodPut,
+		path:    superPath("Batman"),
+		body:    map[string]interface{}{"type": "HERO", "name": "Batman", "occupation": "Detective"},
+		version: models.AnyVersion,
+		ifMatch: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Detective", updated.Occupation)
+	assert.EqualValues(t, 26, updated.Strength)
+	assert.Equal(t, "DC Comics", updated.Publisher)
+}
+func TestClientGroups(t *testing.T) {
+	c := newServerClient(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"Batman", "Robin"} {
+		_, err := c.CreateHero(ctx, name)
+		assert.NoError(t, err)
+	}
+
+	_, err := c.CreateGroup(ctx, &models.Group{Name: "Gotham", Supers: []models.Super{{Name: "Batman"}, {Name: "Robin"}}})
+	assert.NoError(t, err)
+
+	_, err = c.CreateGroup(ctx, &models.Group{Name: "Gotham"})
+	assert.IsType(t, &ErrorGroupAlreadyExists{}, err)
+
+	group, err := c.GetGroup(ctx, "Gotham")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Batman", "Robin"}, group.SupersList)
+
+	updated, err := c.UpdateGroup(ctx, "Gotham", &models.Group{Name: "Gotham City", SupersList: []string{"Batman"}}, group.Version)
+	assert.NoError(t, err)
+	assert.Equal(t, group.Version+1, updated.Version)
+
+	err = c.DeleteGroup(ctx, "Gotham City", group.Version)
+	assert.IsType(t, &ErrorGroupVersionMismatch{}, err)
+
+	_, err = c.GetGroup(ctx, "Gotham")
+	assert.IsType(t, &ErrorGroupNotFound{}, err)
+
+	assert.NoError(t, c.DeleteGroup(ctx, "Gotham City", models.AnyVersion))
+}
+
+func TestClientIterators(t *testing.T) {
+	c := newServerClient(t)
+	c.PageSize = 7
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		_, err := c.CreateHero(ctx, fmt.Sprintf("hero%02d", i))
+		assert.NoError(t, err)
+		_, err = c.CreateGroup(ctx, &models.Group{Name: fmt.Sprintf("group%02d", i), Supers: []models.Super{{Name: fmt.Sprintf("hero%02d", i)}}})
+		assert.NoError(t, err)
+	}
+
+	names := make([]string, 0)
+	it := c.Supers(ctx, ListSupersOptions{Type: "HERO", Offset: 2, Limit: 15})
+	for it.Next() {
+		names = append(names, it.Super().Name)
+	}
+	assert.NoError(t, it.Err())
+	if assert.Len(t, names, 15) {
+		assert.Equal(t, "hero02", names[0])
+	}
+
+	groups := 0
+	git := c.Groups(ctx)
+	for git.Next() {
+		assert.Len(t, git.Group().SupersList, 1)
+		groups++
+	}
+	assert.NoError(t, git.Err())
+	assert.Equal(t, 20, groups)
+}
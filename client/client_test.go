@@ -0,0 +1,323 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tcarreira/superhero/models"
+	"github.com/tcarreira/superhero/server"
+)
+
+// newTestClient creates a Client of handler, retrying without waiting
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	c := New(ts.URL)
+	c.RetryWaitMin = time.Millisecond
+	c.RetryWaitMax = time.Millisecond
+	return c
+}
+
+// flakyHandler fails with status the first failures requests, then responds with a Super
+type flakyHandler struct {
+	mu              sync.Mutex
+	status          int
+	failures        int
+	requests        int
+	idempotencyKeys []string
+}
+
+func (h *flakyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.requests++
+	h.idempotencyKeys = append(h.idempotencyKeys, r.Header.Get("Idempotency-Key"))
+	if h.requests <= h.failures {
+		w.WriteHeader(h.status)
+		return
+	}
+
+	w.Header().Set("ETag", `"3"`)
+	json.NewEncoder(w).Encode(models.Super{Type: "HERO", Name: "Batman"})
+}
+
+func TestRetries(t *testing.T) {
+	h := &flakyHandler{status: http.StatusServiceUnavailable, failures: 2}
+	c := newTestClient(t, h)
+
+	super, err := c.GetSuper(context.Background(), "Batman")
+	assert.NoError(t, err)
+	assert.Equal(t, "Batman", super.Name)
+	assert.Equal(t, int64(3), super.Version, "version is read from the ETag")
+	assert.Equal(t, int64(3), versionFromETag(`"3-5d41402abc4b2a76"`))
+	assert.Equal(t, 3, h.requests)
+
+	// POST is retried with the same Idempotency-Key
+	h = &flakyHandler{status: http.StatusBadGateway, failures: 1}
+	c = newTestClient(t, h)
+	_, err = c.CreateHero(context.Background(), "Batman")
+	assert.NoError(t, err)
+	if assert.Len(t, h.idempotencyKeys, 2) {
+		assert.NotEmpty(t, h.idempotencyKeys[0])
+		assert.Equal(t, h.idempotencyKeys[0], h.idempotencyKeys[1])
+	}
+
+	// PATCH is not retried
+	h = &flakyHandler{status: http.StatusServiceUnavailable, failures: 1}
+	c = newTestClient(t, h)
+	_, err = c.PatchSuper(context.Background(), "Batman", map[string]interface{}{"power": "10"}, models.AnyVersion)
+	assert.Error(t, err)
+	assert.Equal(t, 1, h.requests)
+
+	// errors are not retried, and retries are limited
+	h = &flakyHandler{status: http.StatusInternalServerError, failures: 1}
+	c = newTestClient(t, h)
+	_, err = c.GetSuper(context.Background(), "Batman")
+	assert.Error(t, err)
+	assert.Equal(t, 1, h.requests)
+
+	h = &flakyHandler{status: http.StatusServiceUnavailable, failures: 100}
+	c = newTestClient(t, h)
+	c.MaxRetries = 2
+	_, err = c.GetSuper(context.Background(), "Batman")
+	if assert.IsType(t, &APIError{}, err) {
+		assert.Equal(t, http.StatusServiceUnavailable, err.(*APIError).StatusCode)
+	}
+	assert.Equal(t, 3, h.requests)
+}
+
+func TestRetryableError(t *testing.T) {
+	refused := &url.Error{Op: "Get", URL: "http://localhost:1", Err: &net.OpError{
+		Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED),
+	}}
+	assert.True(t, retryableError(refused))
+	assert.True(t, retryableError(&url.Error{Op: "Get", URL: "http://localhost", Err: io.EOF}))
+	assert.True(t, retryableError(&url.Error{Op: "Get", URL: "http://localhost", Err: &net.DNSError{IsTimeout: true}}))
+
+	assert.False(t, retryableError(&url.Error{Op: "parse", URL: "http://%zz", Err: url.EscapeError("%zz")}))
+	assert.False(t, retryableError(&url.Error{Op: "Get", URL: "https://localhost", Err: x509.UnknownAuthorityError{}}))
+	assert.False(t, retryableError(&url.Error{Op: "Get", URL: "https://localhost", Err: x509.HostnameError{}}))
+	assert.False(t, retryableError(errors.New("unsupported protocol scheme")))
+}
+
+func TestCertificateErrorsAreNotRetried(t *testing.T) {
+	var handshakes int32
+	ts := httptest.NewUnstartedServer(&flakyHandler{})
+	ts.TLS = &tls.Config{GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		atomic.AddInt32(&handshakes, 1)
+		return nil, nil
+	}}
+	ts.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	c := New(ts.URL) // without the certificate of the server
+	c.RetryWaitMin = time.Millisecond
+	c.RetryWaitMax = time.Millisecond
+	_, err := c.GetSuper(context.Background(), "Batman")
+	assert.Error(t, err)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&handshakes))
+}
+
+func TestContextCancel(t *testing.T) {
+	h := &flakyHandler{status: http.StatusServiceUnavailable, failures: 100}
+	c := newTestClient(t, h)
+	c.RetryWaitMin = time.Minute
+	c.RetryWaitMax = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.GetSuper(ctx, "Batman")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < 10*time.Second)
+}
+
+func TestTraceContext(t *testing.T) {
+	var traceparent string
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		json.NewEncoder(w).Encode(models.Super{Type: "HERO", Name: "Batman"})
+	}))
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	_, err := c.GetSuper(ctx, "Batman")
+	assert.NoError(t, err)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", traceparent)
+}
+
+func TestReadYourWrites(t *testing.T) {
+	var sent []string
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.Header.Get(readPrimaryUntil))
+		if r.Method == http.MethodPost {
+			w.Header().Set(readPrimaryUntil, "1589000000000")
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(models.Super{Type: "HERO", Name: "Batman"})
+	}))
+	ctx := context.Background()
+
+	_, err := c.GetSuper(ctx, "Batman")
+	assert.NoError(t, err)
+	_, err = c.CreateHero(ctx, "Batman")
+	assert.NoError(t, err)
+	_, err = c.GetSuper(ctx, "Batman")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"", "", "1589000000000"}, sent, "the reads after a write are sent to the primary")
+}
+
+func TestTypedErrors(t *testing.T) {
+	respond := func(status int, message string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{"message": message, "error": "details"})
+		})
+	}
+	ctx := context.Background()
+
+	_, err := newTestClient(t, respond(http.StatusNotFound, "Super Not Found")).GetSuper(ctx, "x")
+	assert.IsType(t, &ErrorSuperNotFound{}, err)
+	assert.Contains(t, err.Error(), "404 Super Not Found: details")
+
+	_, err = newTestClient(t, respond(http.StatusConflict, "Super already exists")).CreateVilan(ctx, "x")
+	assert.IsType(t, &ErrorSuperAlreadyExists{}, err)
+
+	_, err = newTestClient(t, respond(http.StatusBadRequest, "Invalid Super fields")).CreateSuper(ctx, &models.Super{})
+	assert.IsType(t, &ErrorSuperInvalidFields{}, err)
+
+	err = newTestClient(t, respond(http.StatusPreconditionFailed, "modified")).DeleteSuper(ctx, "x", 2)
+	assert.IsType(t, &ErrorSuperVersionMismatch{}, err)
+
+	_, err = newTestClient(t, respond(http.StatusNotFound, "Group not found")).GetGroup(ctx, "x")
+	assert.IsType(t, &ErrorGroupNotFound{}, err)
+
+	_, err = newTestClient(t, respond(http.StatusConflict, "Group already exists")).CreateGroup(ctx, &models.Group{Name: "x"})
+	assert.IsType(t, &ErrorGroupAlreadyExists{}, err)
+
+	err = newTestClient(t, respond(http.StatusPreconditionFailed, "modified")).DeleteGroup(ctx, "x", 2)
+	assert.IsType(t, &ErrorGroupVersionMismatch{}, err)
+
+	_, err = newTestClient(t, respond(http.StatusConflict, "A request with this Idempotency-Key is still being processed")).CreateHero(ctx, "x")
+	assert.IsType(t, &APIError{}, err)
+}
+
+func TestIterators(t *testing.T) {
+	const total = 250
+
+	requests := 0
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+		var page []interface{}
+		for i := offset; i < total && (limit == 0 || i < offset+limit); i++ {
+			if r.URL.Path == "/api/v1/supers" {
+				page = append(page, models.Super{Type: "HERO", Name: fmt.Sprintf("hero%03d", i)})
+			} else {
+				page = append(page, map[string]interface{}{"name": fmt.Sprintf("group%03d", i), "supers": []string{"hero"}})
+			}
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+	c := newTestClient(t, h)
+	c.PageSize = 100
+	ctx := context.Background()
+
+	names := make([]string, 0)
+	it := c.Supers(ctx, ListSupersOptions{})
+	for it.Next() {
+		names = append(names, it.Super().Name)
+	}
+	assert.NoError(t, it.Err())
+	assert.Len(t, names, total)
+	assert.Equal(t, "hero249", names[total-1])
+	assert.Equal(t, 3, requests)
+
+	requests = 0
+	names = names[:0]
+	it = c.Supers(ctx, ListSupersOptions{Limit: 120, Offset: 10})
+	for it.Next() {
+		names = append(names, it.Super().Name)
+	}
+	assert.NoError(t, it.Err())
+	if assert.Len(t, names, 120) {
+		assert.Equal(t, "hero010", names[0])
+		assert.Equal(t, "hero129", names[119])
+	}
+	assert.Equal(t, 2, requests)
+
+	groups := 0
+	git := c.Groups(ctx)
+	for git.Next() {
+		assert.Equal(t, []string{"hero"}, git.Group().SupersList)
+		groups++
+	}
+	assert.NoError(t, git.Err())
+	assert.Equal(t, total, groups)
+}
+
+func TestListSupersOptionsQuery(t *testing.T) {
+	opts := ListSupersOptions{
+		Type:          "HERO",
+		Universe:      "dc",
+		Publisher:     "DC Comics",
+		Aliases:       []string{"Insider", "Matches Malone"},
+		MinPowerstats: models.PowerstatsFilter{Strength: 20, Combat: 90},
+		Limit:         10,
+	}
+	assert.Equal(t, url.Values{
+		"type":         {"HERO"},
+		"universe":     {"dc"},
+		"publisher":    {"DC Comics"},
+		"alias":        {"Insider", "Matches Malone"},
+		"min_strength": {"20"},
+		"min_combat":   {"90"},
+		"limit":        {"10"},
+	}, opts.query())
+}
+
+func TestSetupRouterWithoutDatabase(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := newTestClient(t, server.SetupRouter(nil))
+
+	// rejected before reaching the database
+	_, err := c.UpdateSuper(context.Background(), "Batman", &models.Super{Type: "HERO", Name: "Batman"}, -1)
+	if assert.IsType(t, &APIError{}, err) {
+		assert.Equal(t, http.StatusBadRequest, err.(*APIError).StatusCode)
+	}
+}
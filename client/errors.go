@@ -0,0 +1,89 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError is an error response of the API. The typed errors below embed it
+type APIError struct {
+	StatusCode int    `json:"-"`
+	Message    string `json:"message"`
+	Detail     string `json:"error,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("superhero API: %d %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("superhero API: %d %s: %s", e.StatusCode, e.Message, e.Detail)
+}
+
+// ErrorSuperNotFound Super Not Found (mirrors models.ErrorSuperNotFound)
+type ErrorSuperNotFound struct {
+	*APIError
+}
+
+// ErrorSuperAlreadyExists Super Already Exists (mirrors models.ErrorSuperAlreadyExists)
+type ErrorSuperAlreadyExists struct {
+	*APIError
+}
+
+// ErrorSuperInvalidFields Super has invalid fields (mirrors models.ErrorSuperInvalidFields)
+type ErrorSuperInvalidFields struct {
+	*APIError
+}
+
+// ErrorSuperVersionMismatch Super was modified meanwhile (mirrors models.ErrorSuperVersionMismatch)
+type ErrorSuperVersionMismatch struct {
+	*APIError
+}
+
+// ErrorGroupNotFound Group Not Found (mirrors models.ErrorGroupNotFound)
+type ErrorGroupNotFound struct {
+	*APIError
+}
+
+// ErrorGroupAlreadyExists Group Already Exists (mirrors models.ErrorGroupAlreadyExists)
+type ErrorGroupAlreadyExists struct {
+	*APIError
+}
+
+// ErrorGroupVersionMismatch Group was modified meanwhile (mirrors models.ErrorGroupVersionMismatch)
+type ErrorGroupVersionMismatch struct {
+	*APIError
+}
+
+// typedError maps an error response of an entity ("super" or "group") to its typed error
+func typedError(entity string, err *APIError) error {
+	if strings.Contains(err.Message, "Idempotency-Key") {
+		return err // the request itself was rejected
+	}
+
+	switch entity {
+	case "super":
+		switch err.StatusCode {
+		case http.StatusNotFound:
+			return &ErrorSuperNotFound{err}
+		case http.StatusConflict:
+			return &ErrorSuperAlreadyExists{err}
+		case http.StatusPreconditionFailed:
+			return &ErrorSuperVersionMismatch{err}
+		case http.StatusBadRequest:
+			if err.Message == "Invalid Super fields" {
+				return &ErrorSuperInvalidFields{err}
+			}
+		}
+	case "group":
+		switch err.StatusCode {
+		case http.StatusNotFound:
+			return &ErrorGroupNotFound{err}
+		case http.StatusConflict:
+			return &ErrorGroupAlreadyExists{err}
+		case http.StatusPreconditionFailed:
+			return &ErrorGroupVersionMismatch{err}
+		}
+	}
+	return err
+}
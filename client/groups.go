@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/tcarreira/superhero/models"
+)
+
+// groupPath is the path of a Group, by name
+func groupPath(name string) string {
+	return "/groups/" + url.PathEscape(name)
+}
+
+// groupPayload is the Group to be sent: its Supers (by name) are sent in SupersList
+func groupPayload(group *models.Group) *models.Group {
+	payload := *group
+	if len(payload.SupersList) == 0 {
+		payload.SupersList = make([]string, 0, len(group.Supers))
+		for _, super := range group.Supers {
+			payload.SupersList = append(payload.SupersList, super.Name)
+		}
+	}
+	return &payload
+}
+
+// fillSupersList sets SupersList from the Supers of a received Group (only their names are known)
+func fillSupersList(group *models.Group) {
+	group.SupersList = make([]string, 0, len(group.Supers))
+	for _, super := range group.Supers {
+		group.SupersList = append(group.SupersList, super.Name)
+	}
+}
+
+// getGroup sends a request which returns a Group
+func (c *Client) getGroup(ctx context.Context, req *request) (*models.Group, error) {
+	req.entity = "group"
+	group := &models.Group{}
+	resp, err := c.do(ctx, req, group)
+	if err != nil {
+		return nil, err
+	}
+	fillSupersList(group)
+	group.Version = versionFromETag(resp.Header.Get("ETag"))
+	return group, nil
+}
+
+// CreateGroup creates a Group of Supers (by name). Supers which do not exist are ignored
+func (c *Client) CreateGroup(ctx context.Context, group *models.Group) (*models.Group, error) {
+	return c.getGroup(ctx, &request{method: http.MethodPost, path: "/groups/", body: groupPayload(group)})
+}
+
+// GetGroup gets a Group by name
+func (c *Client) GetGroup(ctx context.Context, name string) (*models.Group, error) {
+	return c.getGroup(ctx, &request{method: http.MethodGet, path: groupPath(name)})
+}
+
+// UpdateGroup renames a Group and replaces its Supers, if it is still at version. Supers which do not exist are ignored
+func (c *Client) UpdateGroup(ctx context.Context, name string, group *models.Group, version int64) (*models.Group, error) {
+	return c.getGroup(ctx, &request{
+		method:  http.MethodPut,
+		path:    groupPath(name),
+		body:    groupPayload(group),
+		version: version,
+		ifMatch: true,
+	})
+}
+
+// DeleteGroup deletes a Group (Supers are kept), if it is still at version
+func (c *Client) DeleteGroup(ctx context.Context, name string, version int64) error {
+	_, err := c.do(ctx, &request{
+		method:  http.MethodDelete,
+		path:    groupPath(name),
+		entity:  "group",
+		version: version,
+		ifMatch: true,
+	}, nil)
+	return err
+}
+
+// ListGroups lists the Groups by name (one page, if limit is set. 0 for every Group)
+func (c *Client) ListGroups(ctx context.Context, limit, offset int) ([]models.Group, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+
+	groups := make([]models.Group, 0)
+	if _, err := c.do(ctx, &request{method: http.MethodGet, path: "/groups/", query: query, entity: "group"}, &groups); err != nil {
+		return groups, err
+	}
+	for i := range groups {
+		fillSupersList(&groups[i])
+	}
+	return groups, nil
+}
+
+// GroupIterator iterates over the list of Groups, requesting a page at a time (see SuperIterator)
+type GroupIterator struct {
+	client  *Client
+	ctx     context.Context
+	offset  int
+	page    []models.Group
+	current int
+	last    bool
+	err     error
+}
+
+// Groups iterates over every Group, by name
+func (c *Client) Groups(ctx context.Context) *GroupIterator {
+	return &GroupIterator{client: c, ctx: ctx, current: -1}
+}
+
+// Next advances to the next Group. It returns false when there are no more Groups, or on error
+func (it *GroupIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.current++
+	if it.current < len(it.page) {
+		return true
+	}
+	if it.last {
+		return false
+	}
+
+	pageSize := it.client.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	it.page, it.err = it.client.ListGroups(it.ctx, pageSize, it.offset)
+	if it.err != nil {
+		return false
+	}
+	it.current = 0
+	it.last = len(it.page) < pageSize
+	it.offset += len(it.page)
+
+	return len(it.page) > 0
+}
+
+// Group is the current Group
+func (it *GroupIterator) Group() *models.Group {
+	return &it.page[it.current]
+}
+
+// Err is the error which stopped the iteration (nil at the end of the list)
+func (it *GroupIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,244 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tcarreira/superhero/models"
+)
+
+// The Version of the returned Supers is read from the ETag (when the API sends it), so it can be used
+// as the expected version of the next update. models.AnyVersion skips the check
+
+// superPath is the path of a Super, by name (universe:name, see models.Super.Ref) or uuid
+func superPath(id string) string {
+	return "/supers/" + url.PathEscape(id)
+}
+
+// getSuper sends a request which returns a Super
+func (c *Client) getSuper(ctx context.Context, req *request) (*models.Super, error) {
+	req.entity = "super"
+	super := &models.Super{}
+	resp, err := c.do(ctx, req, super)
+	if err != nil {
+		return nil, err
+	}
+	super.Version = versionFromETag(resp.Header.Get("ETag"))
+	return super, nil
+}
+
+// CreateSuper creates a Super (mandatory: Name and Type)
+func (c *Client) CreateSuper(ctx context.Context, super *models.Super) (*models.Super, error) {
+	return c.getSuper(ctx, &request{method: http.MethodPost, path: "/supers", body: super})
+}
+
+// CreateHero creates a SuperHero by name
+func (c *Client) CreateHero(ctx context.Context, name string) (*models.Super, error) {
+	return c.getSuper(ctx, &request{method: http.MethodPost, path: "/super-hero", body: map[string]string{"name": name}})
+}
+
+// CreateVilan creates a SuperVilan by name
+func (c *Client) CreateVilan(ctx context.Context, name string) (*models.Super, error) {
+	return c.getSuper(ctx, &request{method: http.MethodPost, path: "/super-vilan", body: map[string]string{"name": name}})
+}
+
+// GetSuper gets a Super by name or uuid
+func (c *Client) GetSuper(ctx context.Context, id string) (*models.Super, error) {
+	return c.getSuper(ctx, &request{method: http.MethodGet, path: superPath(id)})
+}
+
+// GetSuperAsOf gets a Super (by name or uuid) as it was at asOf
+func (c *Client) GetSuperAsOf(ctx context.Context, id string, asOf time.Time) (*models.Super, error) {
+	query := url.Values{"as_of": {asOf.UTC().Format(time.RFC3339)}}
+	return c.getSuper(ctx, &request{method: http.MethodGet, path: superPath(id), query: query})
+}
+
+// UpdateSuper replaces every field of a Super (by name or uuid), if it is still at version
+func (c *Client) UpdateSuper(ctx context.Context, id string, super *models.Super, version int64) (*models.Super, error) {
+	return c.getSuper(ctx, &request{
+		method:  http.MethodPut,
+		path:    superPath(id),
+		body:    super,
+		version: version,
+		ifMatch: true,
+	})
+}
+
+// PatchSuper updates only the given fields of a Super (by name or uuid, eg: {"occupation": "Detective"}),
+// if it is still at version
+func (c *Client) PatchSuper(ctx context.Context, id string, fields map[string]interface{}, version int64) (*models.Super, error) {
+	return c.getSuper(ctx, &request{
+		method:  http.MethodPatch,
+		path:    superPath(id),
+		body:    fields,
+		version: version,
+		ifMatch: true,
+	})
+}
+
+// DeleteSuper (soft) deletes a Super (by name or uuid), if it is still at version
+func (c *Client) DeleteSuper(ctx context.Context, id string, version int64) error {
+	_, err := c.do(ctx, &request{
+		method:  http.MethodDelete,
+		path:    superPath(id),
+		entity:  "super",
+		version: version,
+		ifMatch: true,
+	}, nil)
+	return err
+}
+
+// RestoreSuper restores a deleted Super (by name or uuid)
+func (c *Client) RestoreSuper(ctx context.Context, id string) (*models.Super, error) {
+	return c.getSuper(ctx, &request{method: http.MethodPost, path: superPath(id) + "/restore"})
+}
+
+// RevertSuper sets every field of a Super (by name or uuid) back to how it was at toVersion, if it is still at version
+func (c *Client) RevertSuper(ctx context.Context, id string, toVersion, version int64) (*models.Super, error) {
+	return c.getSuper(ctx, &request{
+		method:  http.MethodPost,
+		path:    superPath(id) + "/revert",
+		query:   url.Values{"to_version": {strconv.FormatInt(toVersion, 10)}},
+		version: version,
+		ifMatch: version != models.AnyVersion,
+	})
+}
+
+// SuperHistory gets every change to a Super (by name or uuid), oldest first
+func (c *Client) SuperHistory(ctx context.Context, id string) ([]models.AuditEntry, error) {
+	entries := make([]models.AuditEntry, 0)
+	_, err := c.do(ctx, &request{method: http.MethodGet, path: superPath(id) + "/history", entity: "super"}, &entries)
+	return entries, err
+}
+
+// ListSupersOptions are the filters (ANDed) and the page of ListSupers
+type ListSupersOptions struct {
+	Type          string // HERO or VILAN (case-insensitive)
+	Name          string // case-sensitive
+	UUID          string
+	Universe      string // case-insensitive
+	Publisher     string // the profile is case-insensitive
+	Alignment     string // good, bad or neutral
+	Gender        string
+	Race          string
+	Aliases       []string // having every one of them
+	MinPowerstats models.PowerstatsFilter
+	Deleted       bool // list only the deleted Supers (trash)
+	Limit         int  // 0 for every Super
+	Offset        int
+}
+
+func (o *ListSupersOptions) query() url.Values {
+	query := url.Values{}
+	for name, value := range map[string]string{
+		"type": o.Type, "name": o.Name, "uuid": o.UUID, "universe": o.Universe,
+		"publisher": o.Publisher, "alignment": o.Alignment, "gender": o.Gender, "race": o.Race,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	for _, alias := range o.Aliases {
+		query.Add("alias", alias)
+	}
+	for name, value := range map[string]int64{
+		"min_intelligence": o.MinPowerstats.Intelligence, "min_strength": o.MinPowerstats.Strength,
+		"min_speed": o.MinPowerstats.Speed, "min_durability": o.MinPowerstats.Durability,
+		"min_power": o.MinPowerstats.Power, "min_combat": o.MinPowerstats.Combat,
+	} {
+		if value > 0 {
+			query.Set(name, strconv.FormatInt(value, 10))
+		}
+	}
+	if o.Deleted {
+		query.Set("deleted", "only")
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	return query
+}
+
+// ListSupers lists the Supers (one page, if opts.Limit is set)
+func (c *Client) ListSupers(ctx context.Context, opts ListSupersOptions) ([]models.Super, error) {
+	supers := make([]models.Super, 0)
+	_, err := c.do(ctx, &request{method: http.MethodGet, path: "/supers", query: opts.query(), entity: "super"}, &supers)
+	return supers, err
+}
+
+// SuperIterator iterates over a list of Supers, requesting a page at a time:
+//
+//	it := c.Supers(ctx, client.ListSupersOptions{Type: "HERO"})
+//	for it.Next() {
+//		super := it.Super()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type SuperIterator struct {
+	client  *Client
+	ctx     context.Context
+	opts    ListSupersOptions
+	page    []models.Super
+	current int
+	last    bool
+	err     error
+}
+
+// Supers iterates over the Supers matching opts.
+// opts.Limit is the total number of Supers (0 for all) and opts.Offset where to start
+func (c *Client) Supers(ctx context.Context, opts ListSupersOptions) *SuperIterator {
+	return &SuperIterator{client: c, ctx: ctx, opts: opts, current: -1}
+}
+
+// Next advances to the next Super. It returns false when there are no more Supers, or on error
+func (it *SuperIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.current++
+	if it.current < len(it.page) {
+		return true
+	}
+	if it.last {
+		return false
+	}
+
+	pageSize := it.client.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	opts := it.opts
+	opts.Limit = pageSize
+	if it.opts.Limit > 0 && it.opts.Limit < pageSize {
+		opts.Limit = it.opts.Limit
+	}
+
+	it.page, it.err = it.client.ListSupers(it.ctx, opts)
+	if it.err != nil {
+		return false
+	}
+	it.current = 0
+	it.last = len(it.page) < opts.Limit || opts.Limit == it.opts.Limit
+	it.opts.Offset += len(it.page)
+	if it.opts.Limit > 0 {
+		it.opts.Limit -= len(it.page)
+	}
+
+	return len(it.page) > 0
+}
+
+// Super is the current Super
+func (it *SuperIterator) Super() *models.Super {
+	return &it.page[it.current]
+}
+
+// Err is the error which stopped the iteration (nil at the end of the list)
+func (it *SuperIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,123 @@
+package commandline
+
+import (
+	"context"
+
+	"github.com/go-pg/pg/v9"
+
+	"github.com/tcarreira/superhero/client"
+	"github.com/tcarreira/superhero/logging"
+	"github.com/tcarreira/superhero/models"
+)
+
+// cliActor identifies changes made by the supers and groups commands (eg: in the audit log)
+const cliActor = "cli"
+
+// backend is where the supers and groups commands act: directly on the database (dbBackend)
+// or on a remote API (*client.Client)
+type backend interface {
+	ListSupers(ctx context.Context, opts client.ListSupersOptions) ([]models.Super, error)
+	GetSuper(ctx context.Context, id string) (*models.Super, error)
+	CreateSuper(ctx context.Context, super *models.Super) (*models.Super, error)
+	DeleteSuper(ctx context.Context, id string, version int64) error
+
+	ListGroups(ctx context.Context, limit, offset int) ([]models.Group, error)
+	GetGroup(ctx context.Context, name string) (*models.Group, error)
+	CreateGroup(ctx context.Context, group *models.Group) (*models.Group, error)
+	UpdateGroup(ctx context.Context, name string, group *models.Group, version int64) (*models.Group, error)
+	DeleteGroup(ctx context.Context, name string, version int64) error
+}
+
+// dbBackend acts directly on the database, with the same behaviour as the API (/api/v1, which the remote
+// backend calls: the types are named as models.V1Type does)
+type dbBackend struct {
+	db *pg.DB
+}
+
+// callDB binds the database to the context, with the CLI as the actor
+func (b *dbBackend) callDB(ctx context.Context) *pg.DB {
+	return b.db.WithContext(models.WithActor(ctx, cliActor))
+}
+
+// groupSupers sets the Supers of a Group from its SupersList (the Super names), as the API does
+func groupSupers(group *models.Group) *models.Group {
+	g := *group
+	if len(g.Supers) == 0 {
+		for _, name := range g.SupersList {
+			g.Supers = append(g.Supers, models.Super{Name: name})
+		}
+	}
+	return &g
+}
+
+// ignoreSuperRelation ignores the (logged) errors about Supers which could not be added to a Group, like the API
+func ignoreSuperRelation(err error) error {
+	if _, ok := err.(*models.ErrorGroupSuperRelation); ok {
+		logging.Logger().Warn().Err(err).Msg("Group saved without some of its Supers")
+		return nil
+	}
+	return err
+}
+
+// v1Super names the type of the Super as /api/v1 does
+func v1Super(super *models.Super, err error) (*models.Super, error) {
+	if super != nil {
+		super.Type = models.V1Type(super.Type)
+	}
+	return super, err
+}
+
+func (b *dbBackend) ListSupers(ctx context.Context, opts client.ListSupersOptions) ([]models.Super, error) {
+	filter := &models.Super{Type: opts.Type, Name: opts.Name, UUID: opts.UUID, Universe: opts.Universe}
+	filter.FilterByV1Type()
+	supers := filter.ReadPage(b.callDB(ctx), opts.Deleted, opts.Limit, opts.Offset)
+	for i := range supers {
+		v1Super(&supers[i], nil)
+	}
+	return supers, nil
+}
+
+func (b *dbBackend) GetSuper(ctx context.Context, id string) (*models.Super, error) {
+	return v1Super(new(models.Super).GetByNameOrUUID(b.callDB(ctx), id))
+}
+
+func (b *dbBackend) CreateSuper(ctx context.Context, super *models.Super) (*models.Super, error) {
+	t, err := models.FromV1Type(super.Type, "")
+	if err != nil {
+		return super, err
+	}
+	super.Type = t
+	return v1Super(super.Create(b.callDB(ctx)))
+}
+
+func (b *dbBackend) DeleteSuper(ctx context.Context, id string, version int64) error {
+	return new(models.Super).DeleteByNameOrUUIDAtVersion(b.callDB(ctx), id, version)
+}
+
+func (b *dbBackend) ListGroups(ctx context.Context, limit, offset int) ([]models.Group, error) {
+	return new(models.Group).ReadPageWithSupers(b.callDB(ctx), limit, offset)
+}
+
+func (b *dbBackend) GetGroup(ctx context.Context, name string) (*models.Group, error) {
+	return new(models.Group).GetByName(b.callDB(ctx), name)
+}
+
+func (b *dbBackend) CreateGroup(ctx context.Context, group *models.Group) (*models.Group, error) {
+	created, err := groupSupers(group).Create(b.callDB(ctx))
+	if err := ignoreSuperRelation(err); err != nil {
+		return nil, err
+	}
+	return b.GetGroup(ctx, created.Name)
+}
+
+func (b *dbBackend) UpdateGroup(ctx context.Context, name string, group *models.Group, version int64) (*models.Group, error) {
+	updated, err := groupSupers(group).UpdateByName(b.callDB(ctx), name, version)
+	if err := ignoreSuperRelation(err); err != nil {
+		return nil, err
+	}
+	return b.GetGroup(ctx, updated.Name)
+}
+
+func (b *dbBackend) DeleteGroup(ctx context.Context, name string, version int64) error {
+	return new(models.Group).DeleteByName(b.callDB(ctx), name, version)
+}
@@ -1,121 +1,628 @@
 package commandline
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/go-pg/pg/v9"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+
+	"github.com/tcarreira/superhero/config"
+	"github.com/tcarreira/superhero/grpcapi"
+	"github.com/tcarreira/superhero/logging"
 	db "github.com/tcarreira/superhero/models"
+	"github.com/tcarreira/superhero/publisher"
 	"github.com/tcarreira/superhero/server"
+	"github.com/tcarreira/superhero/tracing"
+	"github.com/tcarreira/superhero/webhooks"
 )
 
-// CommandLiner is an interface for methods used by ParseCommandLine
-type CommandLiner interface {
-	printUsage(logger *log.Logger)
-	printServeUsage(logger *log.Logger)
-	printAdminUsage(logger *log.Logger)
-	exit(ret int)
-	getArg(idx int) string
-	lenArgs() int
-}
+// adminActor identifies changes made by admin commands (eg: in the audit log)
+const adminActor = "admin"
 
-// CommandLine is an instance of CommandLiner
-type CommandLine struct{}
+// App is the superhero command line. The database is only connected to when a command needs it
+type App struct {
+	OpenDB    func(cfg config.Database) (*pg.DB, error) // connects to the database (default: models.OpenDatabase)
+	LookupEnv func(key string) (string, bool)           // reads the environment (default: os.LookupEnv)
+	Stdout    io.Writer
+	Stderr    io.Writer
 
-// printUsage prints usage
-func (c CommandLine) printUsage(logger *log.Logger) {
-	logger.Println("Usage:", filepath.Base(os.Args[0]), "COMMAND")
-	logger.Println("")
-	logger.Println("COMMAND:")
-	logger.Println("	admin: call admin actions")
-	logger.Println("	serve: start HTTP server")
+	cfg *config.Config
+	db  *pg.DB
 }
 
-// printServeUsage prints usage for admin sub-command
-func (c CommandLine) printServeUsage(logger *log.Logger) {
-	logger.Println("Usage:", filepath.Base(os.Args[0]), "serve", "COMMAND")
-	logger.Println("")
-	logger.Println("COMMAND:")
-	logger.Println("	swagger: run server with /swagger endpoint active")
+// usageError is an error in the command line arguments. The usage of the command is printed with it
+type usageError struct {
+	s string
 }
 
-// printAdminUsage prints usage for admin sub-command
-func (c CommandLine) printAdminUsage(logger *log.Logger) {
-	logger.Println("Usage:", filepath.Base(os.Args[0]), "admin", "COMMAND")
-	logger.Println("")
-	logger.Println("COMMAND:")
-	logger.Println("	schema: create database schema")
-	logger.Println("	migrate: perform database migrations (not implemented)")
+func (e *usageError) Error() string {
+	return e.s
 }
 
-// exit just calls os.Exit()
-func (c CommandLine) exit(ret int) {
-	os.Exit(ret)
+// configFlag is the config file (YAML, or TOML if its extension is .toml)
+var configFlag = cli.StringFlag{
+	Name:   "config, c",
+	Usage:  "read the configuration from `FILE` (YAML or TOML)",
+	EnvVar: "SUPERHERO_CONFIG",
 }
 
-// getArg gets os.Args[idx]
-func (c CommandLine) getArg(idx int) string {
-	return os.Args[idx]
+// configFlags are the flags of the commands which accept every setting (see config.Flags)
+func configFlags() []cli.Flag {
+	flags := []cli.Flag{configFlag}
+	for _, f := range config.Flags() {
+		if f.Bool {
+			flags = append(flags, cli.BoolFlag{Name: f.Name, Usage: f.Usage})
+		} else {
+			flags = append(flags, cli.StringFlag{Name: f.Name, Usage: f.Usage, Value: f.Value})
+		}
+	}
+	return flags
 }
 
-// getArg gets os.Args[idx]
-func (c CommandLine) lenArgs() int {
-	return len(os.Args)
+// config loads (on first use) and validates the configuration: file, environment and the flags of the command
+func (a *App) config(c *cli.Context) (*config.Config, error) {
+	if a.cfg != nil {
+		return a.cfg, nil
+	}
+
+	file := c.String("config")
+	if file == "" {
+		file = c.GlobalString("config")
+	}
+
+	flags := make(map[string]string)
+	for _, f := range config.Flags() {
+		if c.IsSet(f.Name) {
+			if f.Bool {
+				flags[f.Name] = strconv.FormatBool(c.Bool(f.Name))
+			} else {
+				flags[f.Name] = c.String(f.Name)
+			}
+		}
+	}
+
+	cfg, err := config.Load(file, a.LookupEnv, flags)
+	if err != nil {
+		return nil, err
+	}
+	logging.Setup(cfg.Log)
+	a.cfg = cfg
+	return cfg, nil
 }
 
-func parseCommandLine(comm CommandLiner, d *pg.DB) {
-	logger := log.New(os.Stdout, "", 0)
+// database connects to the database, on first use
+func (a *App) database(c *cli.Context) (*pg.DB, error) {
+	if a.db == nil {
+		cfg, err := a.config(c)
+		if err != nil {
+			return nil, err
+		}
+		if a.db, err = a.OpenDB(cfg.Database); err != nil {
+			return nil, err
+		}
+	}
+	return a.db, nil
+}
 
-	if comm.lenArgs() < 2 {
-		comm.printUsage(logger)
-		comm.exit(1)
+// close closes the database, if it was used
+func (a *App) close() {
+	if a.db != nil {
+		a.db.Close()
+		a.db = nil
+	}
+}
+
+// showUsage is an Action which prints the usage and fails (for commands which require a sub-command)
+func showUsage(c *cli.Context) error {
+	if c.Command.Name == "" {
+		cli.ShowAppHelp(c)
 	} else {
-		switch comm.getArg(1) {
+		cli.ShowSubcommandHelp(c)
+	}
+	if c.NArg() > 0 {
+		return &usageError{fmt.Sprintf("unknown command %q", c.Args().First())}
+	}
+	return &usageError{"a command is required"}
+}
 
-		case "serve":
-			if comm.lenArgs() < 3 {
-				server.RunHTTPServer(d)
-			} else {
-				switch comm.getArg(2) {
-				case "swagger":
-					server.RunHTTPServerWithSwagger(d)
-				default:
-					comm.printServeUsage(logger)
-					comm.exit(1)
-				}
-			}
+// requireArgs fails with usage, unless the command has between min and max arguments (max < 0: no maximum)
+func requireArgs(c *cli.Context, min, max int) error {
+	if c.NArg() < min || (max >= 0 && c.NArg() > max) {
+		cli.ShowCommandHelp(c, c.Command.Name)
+		return &usageError{fmt.Sprintf("wrong number of arguments for %q", c.Command.Name)}
+	}
+	return nil
+}
 
-		case "admin":
-			if comm.lenArgs() < 3 {
-				comm.printAdminUsage(logger)
-				comm.exit(1)
-			} else {
+// newCLI builds the commands
+func (a *App) newCLI() *cli.App {
+	app := cli.NewApp()
+	app.Name = filepath.Base(os.Args[0])
+	app.Usage = "SuperHeroes and SuperVilans API"
+	app.HideVersion = true
+	app.Writer = a.Stdout
+	app.ErrWriter = a.Stderr
+	app.Action = showUsage
+	app.ExitErrHandler = func(*cli.Context, error) {} // errors are handled by Run
+	app.Flags = []cli.Flag{configFlag}
 
-				switch comm.getArg(2) {
-				case "schema":
-					db.CreateSchema(d)
-				case "drop":
-					db.DropSchema(d)
-				case "migrate":
-					db.Migrate(d)
-				default:
-					comm.printAdminUsage(logger)
-					comm.exit(1)
-				}
+	app.Commands = []cli.Command{
+		a.serveCommand(),
+		a.adminCommand(),
+		a.supersCommand(),
+		a.groupsCommand(),
+		a.configCommand(),
+		a.healthcheckCommand(),
+	}
+
+	return app
+}
+
+// Run runs the command line (args includes the program name) and returns the exit code
+func (a *App) Run(args []string) int {
+	if a.OpenDB == nil {
+		a.OpenDB = db.OpenDatabase
+	}
+	if a.LookupEnv == nil {
+		a.LookupEnv = os.LookupEnv
+	}
+	if a.Stdout == nil {
+		a.Stdout = os.Stdout
+	}
+	if a.Stderr == nil {
+		a.Stderr = os.Stderr
+	}
+	defer a.close()
+
+	if err := a.newCLI().Run(args); err != nil {
+		fmt.Fprintln(a.Stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
+
+// Run runs the superhero command line with os.Args and exits
+func Run() {
+	os.Exit((&App{}).Run(os.Args))
+}
+
+//     _____
+//    / ____|
+//   | (___   ___ _ ____   _____
+//    \___ \ / _ \ '__\ \ / / _ \
+//    ____) |  __/ |   \ V /  __/
+//   |_____/ \___|_|    \_/ \___|
+//
+
+// startWorkers starts the background workers of the server, until ctx is done. Wait for them to stop with the WaitGroup
+func startWorkers(ctx context.Context, d *pg.DB) *sync.WaitGroup {
+	workers := &sync.WaitGroup{}
+	run := func(name string, worker func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			server.BackgroundWorkers.Run(ctx, name, worker) // tracked by /readyz
+		}()
+	}
+
+	run("webhooks", webhooks.NewDispatcher(d).Run)
+
+	if db.Replicas.Len() > 0 {
+		run("replicas", db.Replicas.Run) // health checks: unhealthy replicas are not read from
+	}
+
+	if p := publisher.FromEnv(d); p != nil {
+		logging.Logger().Info().Str("exchange", p.Exchange).Msg("Publishing events to AMQP")
+		run("amqp_publisher", p.Run)
+	}
+
+	return workers
+}
+
+// shutdownSignals stops the servers
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// untilSignal is a context which is done on the first SIGINT or SIGTERM (a second one kills the process)
+func untilSignal() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, shutdownSignals...)
+	go func() {
+		select {
+		case sig := <-signals:
+			logging.Logger().Info().Stringer("signal", sig).Msg("Shutting down")
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+		cancel()
+	}()
+
+	return ctx, cancel
+}
+
+// runServers runs the HTTP and gRPC servers and the workers until ctx is done (or a server fails).
+// Then, the servers drain their in-flight requests, and only then the workers are stopped
+func runServers(ctx context.Context, d *pg.DB, cfg *config.Config) error {
+	serversCtx, stopServers := context.WithCancel(ctx)
+	defer stopServers()
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	workers := startWorkers(workersCtx, d)
+
+	grpcErr := make(chan error, 1)
+	go func() {
+		err := grpcapi.RunServer(serversCtx, d, cfg.Server.GRPCAddr, cfg.Server.ShutdownTimeout.Duration)
+		stopServers() // a failed server stops the other one
+		grpcErr <- err
+	}()
+
+	err := server.RunHTTPServer(serversCtx, d, cfg.Server)
+	stopServers()
+	if gErr := <-grpcErr; err == nil {
+		err = gErr
+	}
+
+	logging.Logger().Info().Msg("Stopping background workers")
+	stopWorkers()
+	workers.Wait()
+
+	return err
+}
+
+// traceFlushTimeout bounds the export of the pending spans, on exit
+const traceFlushTimeout = 5 * time.Second
+
+// tracing installs the OTLP exporter, if configured by the environment. The returned function flushes the spans
+func (a *App) tracing() (func(), error) {
+	cfg, err := tracing.ConfigFromEnv(a.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	shutdown, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Enabled() {
+		logging.Logger().Info().Str("endpoint", cfg.Endpoint).Str("protocol", cfg.Protocol).Msg("Exporting traces")
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			logging.Logger().Error().Err(err).Msg("Could not flush the traces")
+		}
+	}, nil
+}
+
+// serve starts the servers and the workers (swagger: also serve /swagger, whatever the configuration)
+func (a *App) serve(swagger bool) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if c.NArg() > 0 {
+			return showUsage(c)
+		}
+
+		cfg, err := a.config(c)
+		if err != nil {
+			return err
+		}
+		if swagger {
+			cfg.Server.Swagger = true
+		}
+		if cfg.Log.Level == config.LevelDebug {
+			gin.SetMode(gin.DebugMode)
+		} else {
+			gin.SetMode(gin.ReleaseMode)
+		}
+
+		shutdownTracing, err := a.tracing()
+		if err != nil {
+			return err
+		}
+		defer shutdownTracing()
+
+		d, err := a.database(c)
+		if err != nil {
+			return err
+		}
+		if len(cfg.Database.Replicas) > 0 {
+			options, err := cfg.Database.ReplicaPGOptions()
+			if err != nil {
+				return err
 			}
+			db.Replicas = db.ConnectReplicas(options, cfg.Database.ReplicaMaxLag.Duration, cfg.Database.ReplicaCheckInterval.Duration)
+			defer db.Replicas.Close()
+		}
+		db.Cache = db.NewReadCache(cfg.Cache)
+		if allow, exists := a.LookupEnv("WEBHOOKS_ALLOW_PRIVATE_TARGETS"); exists {
+			db.AllowPrivateWebhookTargets, _ = strconv.ParseBool(allow)
+		}
+
+		ctx, stop := untilSignal()
+		defer stop()
+		return runServers(ctx, d, cfg) // then, the database is closed by Run
+	}
+}
+
+func (a *App) serveCommand() cli.Command {
+	return cli.Command{
+		Name:   "serve",
+		Usage:  "start HTTP server (and gRPC server)",
+		Flags:  configFlags(),
+		Action: a.serve(false),
+		Subcommands: []cli.Command{
+			{
+				Name:   "swagger",
+				Usage:  "run server with /swagger endpoint active",
+				Flags:  configFlags(),
+				Action: a.serve(true),
+			},
+		},
+	}
+}
+
+//             _           _
+//       /\   | |         (_)
+//      /  \__| |_ __ ___  _ _ __
+//     / /\ \/ _` | '_ ` _ \| | '_ \
+//    / ____ \ (_| | | | | | | | | | |
+//   /_/    \_\__,_|_| |_| |_|_|_| |_|
+//
+
+// parseAge parses a duration (as time.ParseDuration), also accepting days (eg: "30d")
+func parseAge(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", value)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// parseOlderThan parses the --older-than flag of "admin purge"
+func parseOlderThan(olderThan string) (time.Duration, error) {
+	if olderThan == "" {
+		return 0, errors.New("--older-than is required")
+	}
+
+	age, err := parseAge(olderThan)
+	if err != nil {
+		return 0, err
+	}
+	if age < 0 {
+		return 0, errors.New("--older-than must not be negative")
+	}
+	return age, nil
+}
 
-		default:
-			comm.printUsage(logger)
-			comm.exit(1)
+// adminPurge permanently removes old soft deleted data
+func adminPurge(d *pg.DB, olderThan time.Duration, logger *log.Logger) error {
+	d = d.WithContext(db.WithActor(context.Background(), adminActor))
+
+	purged, err := db.PurgeDeletedSupers(d, time.Now().Add(-olderThan))
+	if err != nil {
+		return err
+	}
+	logger.Println("Purged", purged, "deleted Supers")
+
+	purged, err = db.PurgeExpiredIdempotencyKeys(d)
+	if err != nil {
+		return err
+	}
+	logger.Println("Purged", purged, "expired Idempotency Keys")
+
+	purged, err = db.PurgeOutbox(d, time.Now().Add(-olderThan))
+	if err != nil {
+		return err
+	}
+	logger.Println("Purged", purged, "outbox events")
+	return nil
+}
+
+// adminRecount recomputes the precomputed relatives_count of every Super
+func adminRecount(d *pg.DB, logger *log.Logger) error {
+	counted, err := db.RecountRelatives(d)
+	if err != nil {
+		return err
+	}
+	logger.Println("Recounted the relatives of", counted, "Supers")
+	return nil
+}
+
+func (a *App) adminCommand() cli.Command {
+	withDB := func(action func(d *pg.DB)) func(c *cli.Context) error {
+		return func(c *cli.Context) error {
+			if err := requireArgs(c, 0, 0); err != nil {
+				return err
+			}
+			d, err := a.database(c)
+			if err != nil {
+				return err
+			}
+			action(d)
+			return nil
 		}
 	}
+
+	return cli.Command{
+		Name:   "admin",
+		Usage:  "call admin actions",
+		Action: showUsage,
+		Subcommands: []cli.Command{
+			{
+				Name:   "schema",
+				Usage:  "create database schema",
+				Action: withDB(db.CreateSchema),
+			},
+			{
+				Name:   "drop",
+				Usage:  "drop database schema",
+				Hidden: true,
+				Action: withDB(db.DropSchema),
+			},
+			{
+				Name:   "migrate",
+				Usage:  "perform database migrations",
+				Action: withDB(db.Migrate),
+			},
+			{
+				Name:  "purge",
+				Usage: "permanently remove Supers deleted (and outbox events created) more than AGE ago",
+				Flags: []cli.Flag{
+					cli.StringFlag{Name: "older-than", Usage: "`AGE` (eg: 72h, 30d)"},
+				},
+				Action: func(c *cli.Context) error {
+					if err := requireArgs(c, 0, 0); err != nil {
+						return err
+					}
+					olderThan, err := parseOlderThan(c.String("older-than"))
+					if err != nil {
+						cli.ShowCommandHelp(c, c.Command.Name)
+						return &usageError{err.Error()}
+					}
+					d, err := a.database(c)
+					if err != nil {
+						return err
+					}
+					return adminPurge(d, olderThan, log.New(a.Stdout, "", 0))
+				},
+			},
+			{
+				Name:  "recount",
+				Usage: "recompute the relatives_count of every Super (kept current by the database, on every write)",
+				Action: func(c *cli.Context) error {
+					if err := requireArgs(c, 0, 0); err != nil {
+						return err
+					}
+					d, err := a.database(c)
+					if err != nil {
+						return err
+					}
+					return adminRecount(d, log.New(a.Stdout, "", 0))
+				},
+			},
+		},
+	}
 }
 
-// Parse will parse command line arguments/flags and return a callable function
-func Parse(db *pg.DB) {
-	var comm CommandLine
-	comm = CommandLine{}
+//     _____             __ _
+//    / ____|           / _(_)
+//   | |     ___  _ __ | |_ _  __ _
+//   | |    / _ \| '_ \|  _| |/ _` |
+//   | |___| (_) | | | | | | | (_| |
+//    \_____\___/|_| |_|_| |_|\__, |
+//                             __/ |
+//                            |___/
+
+func (a *App) configCommand() cli.Command {
+	return cli.Command{
+		Name:   "config",
+		Usage:  "show the configuration",
+		Action: showUsage,
+		Subcommands: []cli.Command{
+			{
+				Name:  "show",
+				Usage: "validate and print the effective configuration (file, environment and flags), without secrets",
+				Flags: configFlags(),
+				Action: func(c *cli.Context) error {
+					if err := requireArgs(c, 0, 0); err != nil {
+						return err
+					}
+					cfg, err := a.config(c)
+					if err != nil {
+						return err
+					}
 
-	parseCommandLine(comm, db)
+					out, err := yaml.Marshal(cfg.Redacted())
+					if err != nil {
+						return err
+					}
+					_, err = a.Stdout.Write(out)
+					return err
+				},
+			},
+		},
+	}
+}
+
+// probeURL is the readiness probe of the configured HTTP server, on the loopback interface
+func probeURL(cfg config.Server) (string, error) {
+	host, port, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return "", err
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+
+	scheme := "http"
+	if cfg.TLS() {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/readyz", scheme, net.JoinHostPort(host, port)), nil
+}
+
+func (a *App) healthcheckCommand() cli.Command {
+	return cli.Command{
+		Name:  "healthcheck",
+		Usage: "check whether the server is ready (exit code 0) or not (eg: for a docker HEALTHCHECK)",
+		Flags: append(configFlags(),
+			cli.StringFlag{Name: "url", Usage: "probe `URL` (default: /readyz of the configured address)"},
+			cli.DurationFlag{Name: "timeout", Usage: "`DURATION` to wait for the response", Value: 5 * time.Second},
+		),
+		Action: func(c *cli.Context) error {
+			if err := requireArgs(c, 0, 0); err != nil {
+				return err
+			}
+
+			httpClient := &http.Client{Timeout: c.Duration("timeout")}
+			url := c.String("url")
+			if url == "" {
+				cfg, err := a.config(c)
+				if err != nil {
+					return err
+				}
+				if url, err = probeURL(cfg.Server); err != nil {
+					return err
+				}
+				// the certificate is not issued for the loopback address
+				httpClient.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+			}
+
+			resp, err := httpClient.Get(url)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+			fmt.Fprintln(a.Stdout, strings.TrimSpace(string(body)))
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("%s: %s", url, resp.Status)
+			}
+			return nil
+		},
+	}
 }
@@ -3,91 +3,201 @@ package commandline
 import (
 	"bytes"
 	"fmt"
-	"log"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/go-pg/pg/v9"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
+
+	"github.com/tcarreira/superhero/config"
 )
 
-// Mocks
-type testCommandLine struct {
-	mock.Mock
-	exitRetCode int
-	osArgs      []string
+// runApp runs the command line with args, failing the test if the database is used
+func runApp(t *testing.T, args ...string) (int, string, string) {
+	return runAppWithEnv(t, nil, args...)
 }
 
-func (c *testCommandLine) printUsage(logger *log.Logger)      { c.Called() }
-func (c *testCommandLine) printServeUsage(logger *log.Logger) { c.Called() }
-func (c *testCommandLine) printAdminUsage(logger *log.Logger) { c.Called() }
-func (c *testCommandLine) exit(ret int)                       { c.Called(ret) }
-func (c *testCommandLine) getArg(idx int) string {
-	c.Called(idx)
-	return c.osArgs[idx]
-}
-func (c *testCommandLine) lenArgs() int {
-	c.Called()
-	return len(c.osArgs)
+// runAppWithEnv is runApp with only the environment variables in env
+func runAppWithEnv(t *testing.T, env map[string]string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	app := &App{
+		OpenDB: func(config.Database) (*pg.DB, error) {
+			t.Fatal("the database must not be used")
+			return nil, nil
+		},
+		LookupEnv: func(key string) (string, bool) {
+			value, ok := env[key]
+			return value, ok
+		},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+
+	code := app.Run(append([]string{"programName"}, args...))
+	return code, stdout.String(), stderr.String()
 }
 
 func TestExecutingCommandWithoutArguments(t *testing.T) {
-	testComm := testCommandLine{
-		exitRetCode: 1,
-		osArgs: []string{
-			"programName",
-		},
-	}
+	code, stdout, stderr := runApp(t)
 
-	// setup expectations
-	testComm.On("lenArgs").Return(1)
-	testComm.On("printUsage")
-	testComm.On("exit", 1)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, fmt.Sprintf("%s [global options] command", filepath.Base(os.Args[0])))
+	assert.Contains(t, stdout, "serve")
+	assert.Contains(t, stdout, "supers")
+	assert.Contains(t, stderr, "a command is required")
+}
 
-	// call the code we are testing
-	parseCommandLine(&testComm, nil)
+func TestExecutingUnknownCommand(t *testing.T) {
+	code, _, stderr := runApp(t, "fly")
 
-	testComm.AssertExpectations(t)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `unknown command "fly"`)
 }
 
 func TestExecutingCommandAdmin(t *testing.T) {
-	testComm := testCommandLine{
-		exitRetCode: 1,
-		osArgs: []string{
-			"programName",
-			"admin",
-		},
-	}
+	code, stdout, _ := runApp(t, "admin")
+
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "admin [global options] command [command options]")
+	assert.Contains(t, stdout, "migrate")
+	assert.Contains(t, stdout, "recount")
+	assert.NotContains(t, stdout, "drop", "drop is hidden")
+}
+
+func TestExecutingCommandAdminPurgeWithoutOlderThan(t *testing.T) {
+	code, stdout, stderr := runApp(t, "admin", "purge")
+
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "--older-than AGE")
+	assert.Contains(t, stderr, "--older-than is required")
+}
 
-	// setup expectations
-	testComm.On("lenArgs").Return(2)
-	testComm.On("getArg", 1).Return("admin")
-	testComm.On("printAdminUsage")
-	testComm.On("exit", 1)
+func TestParseAge(t *testing.T) {
+	age, err := parseAge("30d")
+	assert.NoError(t, err)
+	assert.Equal(t, 30*24*time.Hour, age)
 
-	// call the code we are testing
-	parseCommandLine(&testComm, nil)
+	age, err = parseAge("90m")
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, age)
 
-	testComm.AssertExpectations(t)
+	_, err = parseAge("xd")
+	assert.Error(t, err)
+
+	_, err = parseAge("forever")
+	assert.Error(t, err)
+}
+
+func TestParseOlderThan(t *testing.T) {
+	age, err := parseOlderThan("7d")
+	assert.NoError(t, err)
+	assert.Equal(t, 7*24*time.Hour, age)
+
+	age, err = parseOlderThan("0s")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), age)
+
+	_, err = parseOlderThan("")
+	assert.Error(t, err)
+
+	_, err = parseOlderThan("-1h")
+	assert.Error(t, err)
 }
 
-func TestCommandLine_printUsage(t *testing.T) {
-	var buf bytes.Buffer
-	logger := log.New(&buf, "", 0)
-	testComm := CommandLine{}
+// writeTempFile writes content to a new temporary file, removed at the end of the test
+func writeTempFile(t *testing.T, pattern, content string) string {
+	f, err := ioutil.TempFile("", pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(f.Name()) })
+
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
 
-	testComm.printUsage(logger)
+func TestConfigShow(t *testing.T) {
+	file := writeTempFile(t, "superhero-*.yaml", "server:\n  addr: :7000\n  swagger: true\ndatabase:\n  pool_size: 5\n")
+	env := map[string]string{
+		"DATABASE_URL": "postgres://hero:s3cret@db:5432/heroes",
+		"DB_POOL_SIZE": "20",
+	}
 
-	assert.Contains(t, buf.String(), fmt.Sprintf("Usage: %s COMMAND", filepath.Base(os.Args[0])))
+	code, stdout, stderr := runAppWithEnv(t, env, "config", "show", "--config", file, "--grpc-addr", ":7001", "--log-level", "debug")
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "addr: :7000\n")
+	assert.Contains(t, stdout, "grpc_addr: :7001\n")
+	assert.Contains(t, stdout, "swagger: true\n")
+	assert.Contains(t, stdout, "pool_size: 20\n", "the environment overrides the file")
+	assert.Contains(t, stdout, "level: debug\n")
+	assert.Contains(t, stdout, "dsn: postgres://hero:REDACTED@db:5432/heroes\n")
+	assert.Contains(t, stdout, "password: REDACTED\n")
+	assert.NotContains(t, stdout, "s3cret")
+
+	code, _, stderr = runAppWithEnv(t, nil, "config", "show", "--addr", "nowhere", "--db-pool-size", "-1")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `server.addr: invalid address "nowhere"`)
+	assert.Contains(t, stderr, "database.pool_size must not be negative")
 }
 
-func TestCommandLine_printAdminUsage(t *testing.T) {
-	var buf bytes.Buffer
-	logger := log.New(&buf, "", 0)
-	testComm := CommandLine{}
+func TestDatabaseUnavailable(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	app := &App{
+		LookupEnv: func(key string) (string, bool) {
+			value, ok := map[string]string{
+				"DB_HOST":            "127.0.0.1",
+				"DB_PORT":            "1", // nothing listens there
+				"DB_STARTUP_TIMEOUT": "300ms",
+				"LOG_LEVEL":          "error",
+			}[key]
+			return value, ok
+		},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+
+	start := time.Now()
+	code := app.Run([]string{"programName", "admin", "migrate"})
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "Error: database 127.0.0.1:1 is not available after 300ms")
+	assert.Less(t, int64(time.Since(start)), int64(3*time.Second))
+}
 
-	testComm.printAdminUsage(logger)
+func TestHealthcheck(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"status":"ok"}`)
+	}))
+	defer srv.Close()
+
+	code, stdout, stderr := runApp(t, "healthcheck", "--url", srv.URL+"/readyz")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, "{\"status\":\"ok\"}\n", stdout)
+
+	status = http.StatusServiceUnavailable
+	code, _, stderr = runApp(t, "healthcheck", "--url", srv.URL+"/readyz")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "503 Service Unavailable")
+}
 
-	assert.Contains(t, buf.String(), fmt.Sprintf("Usage: %s admin COMMAND", filepath.Base(os.Args[0])))
+func TestProbeURL(t *testing.T) {
+	cfg := config.Default().Server
+	url, err := probeURL(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:8080/readyz", url)
+
+	cfg.Addr = "localhost:8443"
+	cfg.TLSCertFile, cfg.TLSKeyFile = "cert.pem", "key.pem"
+	url, err = probeURL(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://localhost:8443/readyz", url)
 }
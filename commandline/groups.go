@@ -0,0 +1,232 @@
+package commandline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urfave/cli"
+
+	"github.com/tcarreira/superhero/models"
+)
+
+//     _____
+//    / ____|
+//   | |  __ _ __ ___  _   _ _ __  ___
+//   | | |_ | '__/ _ \| | | | '_ \/ __|
+//   | |__| | | | (_) | |_| | |_) \__ \
+//    \_____|_|  \___/ \__,_| .__/|___/
+//                          | |
+//                          |_|
+
+func (a *App) groupsCommand() cli.Command {
+	return cli.Command{
+		Name:   "groups",
+		Usage:  "list, get, create and delete Groups, and manage their members",
+		Action: showUsage,
+		Subcommands: []cli.Command{
+			{
+				Name:  "list",
+				Usage: "list the Groups, by name",
+				Flags: withBackendFlags(
+					cli.IntFlag{Name: "limit", Usage: "list at most `N` Groups (0 for every Group)"},
+					cli.IntFlag{Name: "offset", Usage: "skip the first `N` Groups"},
+				),
+				Action: a.groupsList,
+			},
+			{
+				Name:      "get",
+				Usage:     "get a Group",
+				ArgsUsage: "NAME",
+				Flags:     withBackendFlags(),
+				Action:    a.groupsGet,
+			},
+			{
+				Name:      "create",
+				Usage:     "create a Group of Supers",
+				ArgsUsage: "NAME [SUPER...]",
+				Flags:     withBackendFlags(),
+				Action:    a.groupsCreate,
+			},
+			{
+				Name:      "add-member",
+				Usage:     "add Supers (by name or uuid) to a Group",
+				ArgsUsage: "GROUP SUPER...",
+				Flags:     withBackendFlags(),
+				Action:    a.groupsAddMember,
+			},
+			{
+				Name:      "remove-member",
+				Usage:     "remove Supers (by name) from a Group",
+				ArgsUsage: "GROUP SUPER...",
+				Flags:     withBackendFlags(),
+				Action:    a.groupsRemoveMember,
+			},
+			{
+				Name:      "delete",
+				Usage:     "delete a Group (its Supers are kept)",
+				ArgsUsage: "NAME",
+				Flags: withBackendFlags(
+					cli.Int64Flag{Name: "version", Usage: "delete only if the Group is still at `VERSION` (0 for any)"},
+				),
+				Action: a.groupsDelete,
+			},
+		},
+	}
+}
+
+func (a *App) groupsList(c *cli.Context) error {
+	if err := requireArgs(c, 0, 0); err != nil {
+		return err
+	}
+	if c.Int("limit") < 0 || c.Int("offset") < 0 {
+		return &usageError{"--limit and --offset must not be negative"}
+	}
+	b, format, err := a.backend(c)
+	if err != nil {
+		return err
+	}
+
+	groups, err := b.ListGroups(context.Background(), c.Int("limit"), c.Int("offset"))
+	if err != nil {
+		return err
+	}
+	return writeGroups(a.Stdout, format, groups)
+}
+
+func (a *App) groupsGet(c *cli.Context) error {
+	if err := requireArgs(c, 1, 1); err != nil {
+		return err
+	}
+	b, format, err := a.backend(c)
+	if err != nil {
+		return err
+	}
+
+	group, err := b.GetGroup(context.Background(), c.Args().First())
+	if err != nil {
+		return err
+	}
+	return writeGroup(a.Stdout, format, group)
+}
+
+// superNames gets the names of Supers (by name or uuid), failing if any of them does not exist
+func superNames(ctx context.Context, b backend, ids []string) ([]string, error) {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		super, err := b.GetSuper(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("Super %q: %v", id, err)
+		}
+		names = append(names, super.Name)
+	}
+	return names, nil
+}
+
+func (a *App) groupsCreate(c *cli.Context) error {
+	if err := requireArgs(c, 1, -1); err != nil {
+		return err
+	}
+	b, format, err := a.backend(c)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	members, err := superNames(ctx, b, c.Args().Tail())
+	if err != nil {
+		return err
+	}
+
+	group, err := b.CreateGroup(ctx, &models.Group{Name: c.Args().First(), SupersList: members})
+	if err != nil {
+		return err
+	}
+	return writeGroup(a.Stdout, format, group)
+}
+
+// updateMembers replaces the Supers of a Group with change(current members), at the version which was read
+func (a *App) updateMembers(c *cli.Context, change func(ctx context.Context, b backend, members []string) ([]string, error)) error {
+	if err := requireArgs(c, 2, -1); err != nil {
+		return err
+	}
+	b, format, err := a.backend(c)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	group, err := b.GetGroup(ctx, c.Args().First())
+	if err != nil {
+		return err
+	}
+	members, err := change(ctx, b, group.SupersList)
+	if err != nil {
+		return err
+	}
+
+	updated, err := b.UpdateGroup(ctx, group.Name, &models.Group{Name: group.Name, SupersList: members}, group.Version)
+	if err != nil {
+		return err
+	}
+	return writeGroup(a.Stdout, format, updated)
+}
+
+func (a *App) groupsAddMember(c *cli.Context) error {
+	return a.updateMembers(c, func(ctx context.Context, b backend, members []string) ([]string, error) {
+		added, err := superNames(ctx, b, c.Args().Tail())
+		if err != nil {
+			return nil, err
+		}
+
+		known := make(map[string]bool, len(members))
+		for _, name := range members {
+			known[name] = true
+		}
+		for _, name := range added {
+			if !known[name] {
+				members = append(members, name)
+				known[name] = true
+			}
+		}
+		return members, nil
+	})
+}
+
+func (a *App) groupsRemoveMember(c *cli.Context) error {
+	return a.updateMembers(c, func(_ context.Context, _ backend, members []string) ([]string, error) {
+		removed := make(map[string]bool)
+		for _, name := range c.Args().Tail() {
+			removed[name] = true
+		}
+
+		kept := make([]string, 0, len(members))
+		for _, name := range members {
+			if removed[name] {
+				delete(removed, name)
+			} else {
+				kept = append(kept, name)
+			}
+		}
+		for name := range removed {
+			return nil, fmt.Errorf("Super %q is not a member of the Group", name)
+		}
+		return kept, nil
+	})
+}
+
+func (a *App) groupsDelete(c *cli.Context) error {
+	if err := requireArgs(c, 1, 1); err != nil {
+		return err
+	}
+	b, _, err := a.backend(c)
+	if err != nil {
+		return err
+	}
+
+	name := c.Args().First()
+	if err := b.DeleteGroup(context.Background(), name, c.Int64("version")); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.Stdout, "Deleted Group %s\n", name)
+	return nil
+}
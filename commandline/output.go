@@ -0,0 +1,144 @@
+package commandline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/tcarreira/superhero/models"
+)
+
+// Output formats of the supers and groups commands
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// checkOutputFormat validates the --output flag
+func checkOutputFormat(format string) error {
+	switch format {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	}
+	return &usageError{fmt.Sprintf("invalid output format %q (table, json or yaml)", format)}
+}
+
+// writeJSON writes v as the API would send it
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// writeYAML writes v with the same fields (and order) as its JSON
+func writeYAML(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	// JSON is YAML: decoding it into MapSlices keeps the order of the fields
+	var doc interface{}
+	if len(data) > 0 && data[0] == '[' {
+		list := make([]yaml.MapSlice, 0)
+		err = yaml.Unmarshal(data, &list)
+		doc = list
+	} else {
+		fields := yaml.MapSlice{}
+		err = yaml.Unmarshal(data, &fields)
+		doc = fields
+	}
+	if err != nil {
+		return err
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// writeTable writes a header and rows in aligned columns
+func writeTable(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// writeSupers writes a list of Supers in format
+func writeSupers(w io.Writer, format string, supers []models.Super) error {
+	switch format {
+	case outputJSON:
+		return writeJSON(w, supers)
+	case outputYAML:
+		return writeYAML(w, supers)
+	}
+
+	rows := make([][]string, 0, len(supers))
+	for _, s := range supers {
+		rows = append(rows, []string{
+			s.Ref(), // as "supers get" finds it
+			s.Type,
+			s.UUID,
+			s.FullName,
+			strconv.FormatInt(s.Intelligence, 10),
+			strconv.FormatInt(s.Power, 10),
+			s.Occupation,
+			strings.Join(s.GroupsList, ","),
+			strconv.Itoa(s.RelativesCount),
+		})
+	}
+	return writeTable(w, []string{"NAME", "TYPE", "UUID", "FULLNAME", "INTELLIGENCE", "POWER", "OCCUPATION", "GROUPS", "RELATIVES"}, rows)
+}
+
+// writeSuper writes one Super in format
+func writeSuper(w io.Writer, format string, super *models.Super) error {
+	switch format {
+	case outputJSON:
+		return writeJSON(w, super)
+	case outputYAML:
+		return writeYAML(w, super)
+	}
+	return writeSupers(w, format, []models.Super{*super})
+}
+
+// writeGroups writes a list of Groups (with their SupersList) in format
+func writeGroups(w io.Writer, format string, groups []models.Group) error {
+	switch format {
+	case outputJSON:
+		return writeJSON(w, groups)
+	case outputYAML:
+		return writeYAML(w, groups)
+	}
+
+	rows := make([][]string, 0, len(groups))
+	for _, g := range groups {
+		rows = append(rows, []string{g.Name, strings.Join(g.SupersList, ",")})
+	}
+	return writeTable(w, []string{"NAME", "SUPERS"}, rows)
+}
+
+// writeGroup writes one Group in format
+func writeGroup(w io.Writer, format string, group *models.Group) error {
+	switch format {
+	case outputJSON:
+		return writeJSON(w, group)
+	case outputYAML:
+		return writeYAML(w, group)
+	}
+	return writeGroups(w, format, []models.Group{*group})
+}
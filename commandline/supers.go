@@ -0,0 +1,232 @@
+package commandline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/tcarreira/superhero/client"
+	"github.com/tcarreira/superhero/models"
+)
+
+// backendFlags select where the supers and groups commands act, and how the results are written
+var backendFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:   "api-url",
+		Usage:  "act on the API at `URL` (eg: http://localhost:8080), instead of directly on the database",
+		EnvVar: "SUPERHERO_API_URL",
+	},
+	cli.StringFlag{
+		Name:  "output, o",
+		Usage: "output `FORMAT`: table, json or yaml",
+		Value: outputTable,
+	},
+}
+
+// withBackendFlags adds backendFlags to the flags of a command
+func withBackendFlags(flags ...cli.Flag) []cli.Flag {
+	return append(flags, backendFlags...)
+}
+
+// backend gets the backend selected by --api-url (the database, by default)
+func (a *App) backend(c *cli.Context) (backend, string, error) {
+	format := c.String("output")
+	if err := checkOutputFormat(format); err != nil {
+		return nil, "", err
+	}
+
+	if apiURL := c.String("api-url"); apiURL != "" {
+		api := client.New(apiURL)
+		api.Actor = cliActor
+		return api, format, nil
+	}
+	d, err := a.database(c)
+	if err != nil {
+		return nil, "", err
+	}
+	return &dbBackend{d}, format, nil
+}
+
+//     _____
+//    / ____|
+//   | (___  _   _ _ __   ___ _ __ ___
+//    \___ \| | | | '_ \ / _ \ '__/ __|
+//    ____) | |_| | |_) |  __/ |  \__ \
+//   |_____/ \__,_| .__/ \___|_|  |___/
+//                | |
+//                |_|
+
+func (a *App) supersCommand() cli.Command {
+	return cli.Command{
+		Name:   "supers",
+		Usage:  "list, get, create and delete Supers",
+		Action: showUsage,
+		Subcommands: []cli.Command{
+			{
+				Name:  "list",
+				Usage: "list the Supers (by ANDing the filters)",
+				Flags: withBackendFlags(
+					cli.StringFlag{Name: "type", Usage: "HERO or VILAN"},
+					cli.StringFlag{Name: "name"},
+					cli.StringFlag{Name: "uuid"},
+					cli.StringFlag{Name: "universe"},
+					cli.BoolFlag{Name: "deleted", Usage: "list only the deleted Supers"},
+					cli.IntFlag{Name: "limit", Usage: "list at most `N` Supers (0 for every Super)"},
+					cli.IntFlag{Name: "offset", Usage: "skip the first `N` Supers"},
+				),
+				Action: a.supersList,
+			},
+			{
+				Name:      "get",
+				Usage:     "get a Super",
+				ArgsUsage: "[UNIVERSE:]NAME|UUID",
+				Flags:     withBackendFlags(),
+				Action:    a.supersGet,
+			},
+			{
+				Name:  "create",
+				Usage: "create a Super (--hero NAME, --vilan NAME or --type and --name)",
+				Flags: withBackendFlags(
+					cli.StringFlag{Name: "hero", Usage: "create a SuperHero named `NAME`"},
+					cli.StringFlag{Name: "vilan", Usage: "create a SuperVilan named `NAME`"},
+					cli.StringFlag{Name: "type", Usage: "HERO or VILAN"},
+					cli.StringFlag{Name: "name"},
+					cli.StringFlag{Name: "universe", Usage: "create it in `UNIVERSE` (default: " + models.DefaultUniverse + ")"},
+					cli.StringFlag{Name: "fullname"},
+					cli.Int64Flag{Name: "intelligence"},
+					cli.Int64Flag{Name: "power"},
+					cli.StringFlag{Name: "occupation"},
+					cli.StringFlag{Name: "image-url"},
+				),
+				Action: a.supersCreate,
+			},
+			{
+				Name:      "delete",
+				Usage:     "(soft) delete a Super",
+				ArgsUsage: "[UNIVERSE:]NAME|UUID",
+				Flags: withBackendFlags(
+					cli.Int64Flag{Name: "version", Usage: "delete only if the Super is still at `VERSION` (0 for any)"},
+				),
+				Action: a.supersDelete,
+			},
+		},
+	}
+}
+
+func (a *App) supersList(c *cli.Context) error {
+	if err := requireArgs(c, 0, 0); err != nil {
+		return err
+	}
+	if c.Int("limit") < 0 || c.Int("offset") < 0 {
+		return &usageError{"--limit and --offset must not be negative"}
+	}
+	b, format, err := a.backend(c)
+	if err != nil {
+		return err
+	}
+
+	supers, err := b.ListSupers(context.Background(), client.ListSupersOptions{
+		Type:     c.String("type"),
+		Name:     c.String("name"),
+		UUID:     c.String("uuid"),
+		Universe: c.String("universe"),
+		Deleted:  c.Bool("deleted"),
+		Limit:    c.Int("limit"),
+		Offset:   c.Int("offset"),
+	})
+	if err != nil {
+		return err
+	}
+	return writeSupers(a.Stdout, format, supers)
+}
+
+func (a *App) supersGet(c *cli.Context) error {
+	if err := requireArgs(c, 1, 1); err != nil {
+		return err
+	}
+	b, format, err := a.backend(c)
+	if err != nil {
+		return err
+	}
+
+	super, err := b.GetSuper(context.Background(), c.Args().First())
+	if err != nil {
+		return err
+	}
+	return writeSuper(a.Stdout, format, super)
+}
+
+// superFromFlags builds the Super to be created by "supers create"
+func superFromFlags(c *cli.Context) (*models.Super, error) {
+	super := &models.Super{
+		Type:         strings.ToUpper(c.String("type")),
+		Name:         c.String("name"),
+		Universe:     c.String("universe"),
+		FullName:     c.String("fullname"),
+		Intelligence: c.Int64("intelligence"),
+		Power:        c.Int64("power"),
+		Occupation:   c.String("occupation"),
+		ImageURL:     c.String("image-url"),
+	}
+
+	kinds := 0
+	if c.IsSet("hero") {
+		super.Type, super.Name = "HERO", c.String("hero")
+		kinds++
+	}
+	if c.IsSet("vilan") {
+		super.Type, super.Name = "VILAN", c.String("vilan")
+		kinds++
+	}
+	if c.IsSet("type") || c.IsSet("name") {
+		kinds++
+	}
+
+	if kinds != 1 {
+		return nil, &usageError{"exactly one of --hero, --vilan or --type and --name is required"}
+	}
+	if super.Name == "" || super.Type == "" {
+		return nil, &usageError{"the Super type and name are required"}
+	}
+	return super, nil
+}
+
+func (a *App) supersCreate(c *cli.Context) error {
+	if err := requireArgs(c, 0, 0); err != nil {
+		return err
+	}
+	super, err := superFromFlags(c)
+	if err != nil {
+		cli.ShowCommandHelp(c, c.Command.Name)
+		return err
+	}
+	b, format, err := a.backend(c)
+	if err != nil {
+		return err
+	}
+
+	created, err := b.CreateSuper(context.Background(), super)
+	if err != nil {
+		return err
+	}
+	return writeSuper(a.Stdout, format, created)
+}
+
+func (a *App) supersDelete(c *cli.Context) error {
+	if err := requireArgs(c, 1, 1); err != nil {
+		return err
+	}
+	b, _, err := a.backend(c)
+	if err != nil {
+		return err
+	}
+
+	id := c.Args().First()
+	if err := b.DeleteSuper(context.Background(), id, c.Int64("version")); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.Stdout, "Deleted Super %s\n", id)
+	return nil
+}
@@ -0,0 +1,172 @@
+package commandline
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/models"
+)
+
+// fakeAPI is a minimal in-memory superhero API
+type fakeAPI struct {
+	mu      sync.Mutex
+	supers  []models.Super
+	groups  map[string][]string
+	ifMatch []string
+}
+
+func newFakeAPI(t *testing.T) (*fakeAPI, string) {
+	api := &fakeAPI{
+		supers: []models.Super{
+			{UUID: "47c0df01-a47d-497f-808d-181021f01c76", Type: "HERO", Name: "Batman", Power: 80, GroupsList: []string{"Gotham"}},
+			{UUID: "b8f6d1a0-8b4e-4b36-9c53-1d2f2a1c9e01", Type: "HERO", Name: "Robin"},
+			{UUID: "0f9d8c7b-6a5e-4d3c-2b1a-0e9f8d7c6b5a", Type: "VILAN", Name: "Joker"},
+		},
+		groups: map[string][]string{"Gotham": {"Batman"}},
+	}
+	ts := httptest.NewServer(api)
+	t.Cleanup(ts.Close)
+	return api, ts.URL
+}
+
+func (api *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1")
+	notFound := func() {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Not Found"})
+	}
+
+	switch {
+	case r.Method == http.MethodGet && path == "/supers":
+		supers := make([]models.Super, 0)
+		for _, s := range api.supers {
+			if t := r.URL.Query().Get("type"); t == "" || strings.EqualFold(t, s.Type) {
+				supers = append(supers, s)
+			}
+		}
+		json.NewEncoder(w).Encode(supers)
+
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/supers/"):
+		id := strings.TrimPrefix(path, "/supers/")
+		for _, s := range api.supers {
+			if s.Name == id || s.UUID == id {
+				w.Header().Set("ETag", `"1"`)
+				json.NewEncoder(w).Encode(s)
+				return
+			}
+		}
+		notFound()
+
+	case r.Method == http.MethodPost && path == "/supers":
+		super := models.Super{}
+		json.NewDecoder(r.Body).Decode(&super)
+		super.UUID = "5e2b7c1d-0000-4000-8000-000000000000"
+		api.supers = append(api.supers, super)
+		w.Header().Set("ETag", `"1"`)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(super)
+
+	case strings.HasPrefix(path, "/groups/"):
+		name := strings.TrimPrefix(path, "/groups/")
+		members, ok := api.groups[name]
+		if !ok {
+			notFound()
+			return
+		}
+		if r.Method == http.MethodPut {
+			api.ifMatch = append(api.ifMatch, r.Header.Get("If-Match"))
+			group := struct {
+				Supers []string `json:"supers"`
+			}{}
+			json.NewDecoder(r.Body).Decode(&group)
+			members = group.Supers
+			api.groups[name] = members
+		}
+		w.Header().Set("ETag", `"4"`)
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": name, "supers": members})
+
+	default:
+		notFound()
+	}
+}
+
+func TestSupersList(t *testing.T) {
+	_, url := newFakeAPI(t)
+
+	code, stdout, stderr := runApp(t, "supers", "list", "--api-url", url, "--type", "hero")
+	assert.Equal(t, 0, code, stderr)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if assert.Len(t, lines, 3) {
+		assert.Regexp(t, `^NAME\s+TYPE\s+UUID\s+FULLNAME\s+INTELLIGENCE\s+POWER\s+OCCUPATION\s+GROUPS\s+RELATIVES$`, lines[0])
+		assert.Regexp(t, `^Batman\s+HERO\s+47c0df01-a47d-497f-808d-181021f01c76\s+0\s+80\s+Gotham\s+0$`, lines[1])
+		assert.Regexp(t, `^Robin\s+HERO\s+`, lines[2])
+	}
+
+	code, stdout, _ = runApp(t, "supers", "list", "--api-url", url, "-o", "json")
+	assert.Equal(t, 0, code)
+	supers := make([]models.Super, 0)
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &supers))
+	assert.Len(t, supers, 3)
+
+	code, _, stderr = runApp(t, "supers", "list", "--api-url", url, "-o", "xml")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `invalid output format "xml"`)
+}
+
+func TestSupersGetAndCreate(t *testing.T) {
+	api, url := newFakeAPI(t)
+
+	code, stdout, stderr := runApp(t, "supers", "get", "Joker", "--api-url", url, "-o", "yaml")
+	assert.Equal(t, 0, code, stderr)
+	assert.True(t, strings.HasPrefix(stdout, "uuid: 0f9d8c7b-6a5e-4d3c-2b1a-0e9f8d7c6b5a\ntype: VILAN\nname: Joker\n"), stdout)
+
+	code, _, stderr = runApp(t, "supers", "get", "Superman", "--api-url", url)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "404 Not Found")
+
+	code, stdout, stderr = runApp(t, "supers", "create", "--hero", "Superman", "--api-url", url, "-o", "json")
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, `"name": "Superman"`)
+	if assert.Len(t, api.supers, 4) {
+		assert.Equal(t, "HERO", api.supers[3].Type)
+	}
+
+	code, _, stderr = runApp(t, "supers", "create", "--hero", "Superman", "--vilan", "Lex Luthor", "--api-url", url)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "exactly one of --hero, --vilan or --type and --name is required")
+}
+
+func TestGroupsMembers(t *testing.T) {
+	api, url := newFakeAPI(t)
+
+	code, stdout, stderr := runApp(t, "groups", "add-member", "Gotham", "Robin", "Batman", "Joker", "--api-url", url)
+	assert.Equal(t, 0, code, stderr)
+	assert.Regexp(t, `Gotham\s+Batman,Robin,Joker`, stdout)
+	assert.Equal(t, []string{"Batman", "Robin", "Joker"}, api.groups["Gotham"], "members are not duplicated")
+	assert.Equal(t, []string{`"4"`}, api.ifMatch, "the Group is updated at the version which was read")
+
+	code, _, stderr = runApp(t, "groups", "add-member", "Gotham", "Superman", "--api-url", url)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `Super "Superman"`)
+	assert.Len(t, api.ifMatch, 1, "the Group is not updated")
+
+	code, _, stderr = runApp(t, "groups", "remove-member", "Gotham", "Joker", "--api-url", url)
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, []string{"Batman", "Robin"}, api.groups["Gotham"])
+
+	code, _, stderr = runApp(t, "groups", "remove-member", "Gotham", "Joker", "--api-url", url)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `Super "Joker" is not a member of the Group`)
+
+	code, _, _ = runApp(t, "groups", "add-member", "Gotham", "--api-url", url)
+	assert.Equal(t, 1, code, "at least one Super is required")
+}
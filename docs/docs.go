@@ -1,6 +1,6 @@
 // GENERATED BY THE COMMAND ABOVE; DO NOT EDIT
 // This file was generated by swaggo/swag at
-// 2020-04-10 06:13:15.513417382 -0300 -03 m=+0.034212371
+// 2026-10-19 14:39:10.591719807 +0000 UTC m=+0.095005697
 
 package docs
 
@@ -19,49 +19,62 @@ var doc = `{
     "info": {
         "description": "{{.Description}}",
         "title": "{{.Title}}",
-        "contact": {
-            "name": "Tiago Carreira",
-            "url": "https://github.com/tcarreira/superhero"
-        },
-        "license": {
-            "name": "MIT License",
-            "url": "https://raw.githubusercontent.com/tcarreira/superhero/master/LICENSE"
-        },
+        "contact": {},
+        "license": {},
         "version": "{{.Version}}"
     },
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/groups": {
-            "post": {
-                "description": "Create new Group of Supers",
-                "consumes": [
-                    "application/json"
-                ],
+        "/audit": {
+            "get": {
+                "description": "Get the changes to Supers and Groups (oldest first), optionally filtered by entity and id, a page at a time",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Create new Group of Supers",
+                "summary": "Get the audit log",
                 "parameters": [
                     {
-                        "description": "Group definition. Supers is a list os their names",
-                        "name": "super",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.Group"
-                        }
+                        "enum": [
+                            "super",
+                            "group"
+                        ],
+                        "type": "string",
+                        "description": "Entity type",
+                        "name": "entity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Entity id (Super UUID or Group ID) or name at the time of the change",
+                        "name": "id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of entries (default: 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Skip this number of entries",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Group was created",
+                    "200": {
+                        "description": "Audit log entries",
                         "schema": {
-                            "$ref": "#/definitions/models.Group"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.AuditEntry"
+                            }
                         }
                     },
-                    "409": {
-                        "description": "Group name already exists",
+                    "400": {
+                        "description": "Invalid filters, limit or offset",
                         "schema": {
                             "$ref": "#/definitions/server.errorResponseJSON"
                         }
@@ -75,37 +88,52 @@ var doc = `{
                 }
             }
         },
-        "/groups/{name}": {
+        "/events": {
             "get": {
-                "description": "Get Group by name",
+                "description": "Stream every committed change to Supers and Groups, as Server-Sent Events (event type as the SSE event).\nReconnecting with the Last-Event-ID header replays the events missed meanwhile, while they are kept in the replay buffer.\nOtherwise (eg: after a restart of the server), a \"reset\" event is sent first: reload, as events may have been missed",
                 "produces": [
-                    "application/json"
+                    "text/event-stream"
                 ],
-                "summary": "Get Group",
+                "summary": "Stream of changes (Server-Sent Events)",
                 "parameters": [
                     {
+                        "enum": [
+                            "super",
+                            "group"
+                        ],
                         "type": "string",
-                        "description": "Group Name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
+                        "description": "Only these entities (comma separated)",
+                        "name": "entity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only these event types (comma separated)",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Resume after this event (its SSE id: epoch-id)",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Resume after this event (when the header cannot be set)",
+                        "name": "last_event_id",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Group",
-                        "schema": {
-                            "$ref": "#/definitions/models.Group"
-                        }
-                    },
-                    "404": {
-                        "description": "Group Not Found",
+                        "description": "Stream of events",
                         "schema": {
-                            "$ref": "#/definitions/server.errorResponseJSON"
+                            "$ref": "#/definitions/events.Event"
                         }
                     },
-                    "500": {
-                        "description": "Unexpected Error",
+                    "400": {
+                        "description": "Invalid filters or Last-Event-ID",
                         "schema": {
                             "$ref": "#/definitions/server.errorResponseJSON"
                         }
@@ -113,42 +141,43 @@ var doc = `{
                 }
             }
         },
-        "/super-hero": {
-            "post": {
-                "description": "Create new Super Hero by name",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "summary": "Create new Super Hero",
+        "/events/ws": {
+            "get": {
+                "description": "Stream every committed change to Supers and Groups, as a JSON message per event.\nReconnecting with last_event_id replays the events missed meanwhile, while they are kept in the replay buffer.\nOtherwise (eg: after a restart of the server), a \"reset\" event is sent first: reload, as events may have been missed",
+                "summary": "Stream of changes (WebSocket)",
                 "parameters": [
                     {
-                        "description": "super hero name",
-                        "name": "super",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/server.exampleSuperHeroVilanJSON"
-                        }
+                        "enum": [
+                            "super",
+                            "group"
+                        ],
+                        "type": "string",
+                        "description": "Only these entities (comma separated)",
+                        "name": "entity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only these event types (comma separated)",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Resume after this event (its epoch-id)",
+                        "name": "last_event_id",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Super was created",
-                        "schema": {
-                            "$ref": "#/definitions/models.Super"
-                        }
-                    },
-                    "409": {
-                        "description": "Super already exists",
+                    "101": {
+                        "description": "Switching to WebSocket. Each message is an event",
                         "schema": {
-                            "$ref": "#/definitions/server.errorResponseJSON"
+                            "$ref": "#/definitions/events.Event"
                         }
                     },
-                    "500": {
-                        "description": "Unexpected error",
+                    "400": {
+                        "description": "Invalid filters or last_event_id",
                         "schema": {
                             "$ref": "#/definitions/server.errorResponseJSON"
                         }
@@ -156,36 +185,59 @@ var doc = `{
                 }
             }
         },
-        "/super-vilan": {
+        "/graphql": {
             "post": {
-                "description": "Create new Super Vilan by name",
+                "description": "Query Supers, Groups and their memberships (and create/delete them) with GraphQL.\nMutations are only accepted with POST. Errors have a code in their extensions (NOT_FOUND, ALREADY_EXISTS, INVALID_ARGUMENT, FAILED_PRECONDITION)",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Create new Super Vilan",
+                "summary": "GraphQL API",
                 "parameters": [
                     {
-                        "description": "super vilan name",
-                        "name": "super",
+                        "description": "GraphQL request (POST)",
+                        "name": "request",
                         "in": "body",
-                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/server.exampleSuperHeroVilanJSON"
+                            "$ref": "#/definitions/server.graphQLRequestJSON"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "GraphQL query (GET)",
+                        "name": "query",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "JSON encoded variables (GET)",
+                        "name": "variables",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Operation to execute (GET)",
+                        "name": "operationName",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Super was created",
+                    "200": {
+                        "description": "GraphQL response (may include errors)",
                         "schema": {
-                            "$ref": "#/definitions/models.Super"
+                            "$ref": "#/definitions/server.graphQLResponseJSON"
                         }
                     },
-                    "409": {
-                        "description": "Super already exists",
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "405": {
+                        "description": "Mutations require POST",
                         "schema": {
                             "$ref": "#/definitions/server.errorResponseJSON"
                         }
@@ -193,45 +245,45 @@ var doc = `{
                 }
             }
         },
-        "/supers": {
+        "/groups": {
             "get": {
-                "description": "Get list of Supers by filtering by name, uuid or type",
+                "description": "Get list of Groups (ordered by name), with the names of their Supers",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get list of Supers",
+                "summary": "Get list of Groups",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Super(hero/vilan) Name (case-sensitive)",
-                        "name": "name",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Super(hero/vilan) UUID (case-insensitive)",
-                        "name": "uuid",
+                        "type": "integer",
+                        "description": "Maximum number of Groups (default: all)",
+                        "name": "limit",
                         "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "Super(hero/vilan) Type (HERO / VILAN) (case-insensitive)",
-                        "name": "type",
+                        "type": "integer",
+                        "description": "Skip this number of Groups",
+                        "name": "offset",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "List of Supers",
+                        "description": "List of Groups",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.Super"
+                                "$ref": "#/definitions/models.Group"
                             }
                         }
                     },
                     "400": {
-                        "description": "Error parsing payload",
+                        "description": "Invalid limit or offset",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected Error",
                         "schema": {
                             "$ref": "#/definitions/server.errorResponseJSON"
                         }
@@ -239,34 +291,52 @@ var doc = `{
                 }
             },
             "post": {
-                "description": "Create new Super",
+                "description": "Create new Group of Supers",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Create new Super (hero/vilan)",
+                "summary": "Create new Group of Supers",
                 "parameters": [
                     {
-                        "description": "super hero (mandatory: name and type)",
+                        "description": "Group definition. Supers is a list os their names",
                         "name": "super",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/server.exampleSuperJSON"
+                            "$ref": "#/definitions/models.Group"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key, so the request can be safely retried",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "201": {
-                        "description": "Super was created",
+                        "description": "Group was created",
                         "schema": {
-                            "$ref": "#/definitions/models.Super"
+                            "$ref": "#/definitions/models.Group"
                         }
                     },
                     "409": {
-                        "description": "Super already exists",
+                        "description": "Group name already exists",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency-Key was used with a different request",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected Error",
                         "schema": {
                             "$ref": "#/definitions/server.errorResponseJSON"
                         }
@@ -274,31 +344,40 @@ var doc = `{
                 }
             }
         },
-        "/supers/{id}": {
+        "/groups/{name}": {
             "get": {
-                "description": "Get a Super by name or uuid",
+                "description": "Get Group by name. The ETag header holds the Group version and a hash of the response (If-None-Match is supported)",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get Super",
+                "summary": "Get Group",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Super's Name or UUID",
-                        "name": "id",
+                        "description": "Group Name",
+                        "name": "name",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Super",
+                        "description": "Group",
                         "schema": {
-                            "$ref": "#/definitions/models.Super"
+                            "$ref": "#/definitions/models.Group"
                         }
                     },
+                    "304": {
+                        "description": "Not Modified"
+                    },
                     "404": {
-                        "description": "Super Not Found",
+                        "description": "Group Not Found",
                         "schema": {
                             "$ref": "#/definitions/server.errorResponseJSON"
                         }
@@ -311,42 +390,1186 @@ var doc = `{
                     }
                 }
             },
-            "delete": {
-                "description": "Delete a by name or uuid",
+            "put": {
+                "description": "Rename a Group and replace its list of Supers. Requires If-Match with the current ETag (or *)",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Delete a Super",
+                "summary": "Update a Group",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Super's Name or UUID",
-                        "name": "id",
+                        "description": "Group Name",
+                        "name": "name",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the Group being replaced (or *)",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Group definition. Supers is a list os their names",
+                        "name": "group",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Group"
+                        }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "Successfully deleted"
+                    "200": {
+                        "description": "Group was updated",
+                        "schema": {
+                            "$ref": "#/definitions/models.Group"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid payload",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
                     },
                     "404": {
-                        "description": "Super Not Found",
+                        "description": "Group Not Found",
                         "schema": {
                             "$ref": "#/definitions/server.errorResponseJSON"
                         }
                     },
-                    "500": {
-                        "description": "Unexpected Error",
+                    "409": {
+                        "description": "Another Group already exists with this name",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "412": {
+                        "description": "Group was modified meanwhile",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is missing",
                         "schema": {
                             "$ref": "#/definitions/server.errorResponseJSON"
                         }
                     }
                 }
-            }
-        }
+            },
+            "delete": {
+                "description": "Delete a Group by name (Supers are kept). Requires If-Match with the current ETag (or *)",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Delete a Group",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Group Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the Group being deleted (or *)",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Successfully deleted"
+                    },
+                    "404": {
+                        "description": "Group Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "412": {
+                        "description": "Group was modified meanwhile",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is missing",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/super-hero": {
+            "post": {
+                "description": "Create new Super Hero by name",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create new Super Hero",
+                "parameters": [
+                    {
+                        "description": "super hero name",
+                        "name": "super",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.exampleSuperHeroVilanJSON"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key, so the request can be safely retried",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Super was created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Super"
+                        }
+                    },
+                    "409": {
+                        "description": "Super already exists",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency-Key was used with a different request",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected error",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/super-vilan": {
+            "post": {
+                "description": "Create new Super Vilan by name",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create new Super Vilan",
+                "parameters": [
+                    {
+                        "description": "super vilan name",
+                        "name": "super",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.exampleSuperHeroVilanJSON"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key, so the request can be safely retried",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Super was created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Super"
+                        }
+                    },
+                    "409": {
+                        "description": "Super already exists",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency-Key was used with a different request",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/supers": {
+            "get": {
+                "description": "Get list of Supers by filtering by name, uuid, type, universe, profile or minimum powerstats. Use deleted=only to list the deleted Supers (trash)",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get list of Supers",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Super(hero/vilan) Name (case-sensitive)",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Super(hero/vilan) UUID (case-insensitive)",
+                        "name": "uuid",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Super(hero/vilan) Type (HERO / VILAN) (case-insensitive)",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Universe (case-insensitive)",
+                        "name": "universe",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Publisher (case-insensitive)",
+                        "name": "publisher",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Alignment (good / bad / neutral) (case-insensitive)",
+                        "name": "alignment",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Gender (case-insensitive)",
+                        "name": "gender",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Race (case-insensitive)",
+                        "name": "race",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "One of the aliases (case-insensitive). Repeat it to require every one",
+                        "name": "alias",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum intelligence",
+                        "name": "min_intelligence",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum strength",
+                        "name": "min_strength",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum speed",
+                        "name": "min_speed",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum durability",
+                        "name": "min_durability",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum power",
+                        "name": "min_power",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum combat",
+                        "name": "min_combat",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "only"
+                        ],
+                        "type": "string",
+                        "description": "only: list only deleted Supers",
+                        "name": "deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of Supers (default: all)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Skip this number of Supers",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of Supers",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Super"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Error parsing payload",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create new Super",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create new Super (hero/vilan)",
+                "parameters": [
+                    {
+                        "description": "super hero (mandatory: name and type)",
+                        "name": "super",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.exampleSuperJSON"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key, so the request can be safely retried",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Super was created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Super"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid Super fields",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "409": {
+                        "description": "Super already exists",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency-Key was used with a different request",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/supers/{id}": {
+            "get": {
+                "description": "Get a Super by name or uuid. The ETag header holds the Super version and a hash of the response (If-None-Match is supported)",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get Super",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Super's Name (universe:name) or UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Get the Super as it was at this moment (RFC3339). Groups are not versioned",
+                        "name": "as_of",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Super",
+                        "schema": {
+                            "$ref": "#/definitions/models.Super"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "400": {
+                        "description": "Invalid as_of",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "404": {
+                        "description": "Super Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace the fields of a Super (by name or uuid). Fields missing from the payload are kept, so clients\nwithout the powerstats and profile do not erase them. Requires If-Match with the current ETag (or *)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Update a Super",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Super's Name (universe:name) or UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the Super being replaced (or *)",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "super (missing fields are kept)",
+                        "name": "super",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Super"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Super was updated",
+                        "schema": {
+                            "$ref": "#/definitions/models.Super"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid payload",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "404": {
+                        "description": "Super Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "409": {
+                        "description": "Another Super already exists with this name",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "412": {
+                        "description": "Super was modified meanwhile",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is missing",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a by name or uuid. Requires If-Match with the current ETag (or *)",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Delete a Super",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Super's Name (universe:name) or UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the Super being deleted (or *)",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Successfully deleted"
+                    },
+                    "404": {
+                        "description": "Super Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "412": {
+                        "description": "Super was modified meanwhile",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is missing",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update only the given fields of a Super (by name or uuid). Requires If-Match with the current ETag (or *)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Partially update a Super",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Super's Name (universe:name) or UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the Super being updated (or *)",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "fields to update",
+                        "name": "super",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Super"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Super was updated",
+                        "schema": {
+                            "$ref": "#/definitions/models.Super"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid payload",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "404": {
+                        "description": "Super Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "409": {
+                        "description": "Another Super already exists with this name",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "412": {
+                        "description": "Super was modified meanwhile",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match is missing",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/supers/{id}/history": {
+            "get": {
+                "description": "Get every change to a Super (oldest first), by name or uuid. Deleted Supers are included",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get the history of a Super",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Super's Name (universe:name) or UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Audit log entries",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.AuditEntry"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Super Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/supers/{id}/restore": {
+            "post": {
+                "description": "Restore a deleted Super by name or uuid (the most recently deleted, if many have the same name)",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Restore a deleted Super",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Super's Name (universe:name) or UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Super was restored",
+                        "schema": {
+                            "$ref": "#/definitions/models.Super"
+                        }
+                    },
+                    "404": {
+                        "description": "Deleted Super Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "409": {
+                        "description": "Another Super already exists with this name",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/supers/{id}/revert": {
+            "post": {
+                "description": "Set every field of a Super (by name or uuid) back to how it was at to_version. The revert is a new version. If-Match is optional",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Revert a Super to a previous version",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Super's Name (universe:name) or UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Version to revert to (see history)",
+                        "name": "to_version",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the Super being reverted",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Super was reverted",
+                        "schema": {
+                            "$ref": "#/definitions/models.Super"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid to_version",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "404": {
+                        "description": "Super (or version) Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "409": {
+                        "description": "Another Super already exists with the old name",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "412": {
+                        "description": "Super was modified meanwhile",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "description": "List every Webhook (without secrets)",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List Webhooks",
+                "responses": {
+                    "200": {
+                        "description": "Webhooks",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Webhook"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Subscribe an endpoint to the events of Supers and Groups. Each event is POSTed as JSON, signed with\nX-Superhero-Signature: \"sha256=\" + hex(HMAC-SHA256(secret, X-Superhero-Timestamp + \".\" + body)).\nThe secret is only returned here (generated when not given), and not when replayed for an Idempotency-Key.\nThe payloads name the types of Supers as the version of the API it is registered with (api_version)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create a Webhook",
+                "parameters": [
+                    {
+                        "description": "Webhook definition",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.webhookRequestJSON"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Unique key, so the request can be safely retried",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Webhook was created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid payload",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "get": {
+                "description": "Get a Webhook (without secret)",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get a Webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Webhook",
+                        "schema": {
+                            "$ref": "#/definitions/models.Webhook"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace the url, filters and enabled flag of a Webhook (and its secret, when given).\nEnabling a Webhook which was disabled after repeated failures resumes its pending deliveries",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Update a Webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Webhook definition",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.webhookRequestJSON"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Webhook was updated",
+                        "schema": {
+                            "$ref": "#/definitions/models.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid payload",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a Webhook, with its deliveries",
+                "summary": "Delete a Webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Successfully deleted"
+                    },
+                    "404": {
+                        "description": "Webhook Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries": {
+            "get": {
+                "description": "List the most recent deliveries of a Webhook (newest first), with every attempt",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List the deliveries of a Webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deliveries",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/server.webhookDeliveryJSON"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    },
+                    "500": {
+                        "description": "Unexpected Error",
+                        "schema": {
+                            "$ref": "#/definitions/server.errorResponseJSON"
+                        }
+                    }
+                }
+            }
+        }
     },
     "definitions": {
+        "events.Event": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "description": "X-Actor, advisory (not authenticated), or the subject of a verified client certificate",
+                    "type": "string"
+                },
+                "api_version": {
+                    "description": "how the types in data are named (v1: VILAN, and HERO for NEUTRAL and ANTIHERO)",
+                    "type": "string",
+                    "example": "v2"
+                },
+                "data": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "entity": {
+                    "type": "string",
+                    "example": "super"
+                },
+                "entity_id": {
+                    "type": "string",
+                    "example": "47c0df01-a47d-497f-808d-181021f01c76"
+                },
+                "epoch": {
+                    "description": "boot of the server which streamed it: IDs restart on each boot",
+                    "type": "string",
+                    "example": "kf3p9x1a"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "super.created"
+                }
+            }
+        },
+        "models.AuditChange": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "object"
+                },
+                "to": {
+                    "type": "object"
+                }
+            }
+        },
+        "models.AuditEntry": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "create",
+                        "update",
+                        "delete",
+                        "restore",
+                        "revert",
+                        "purge"
+                    ],
+                    "example": "update"
+                },
+                "actor": {
+                    "description": "X-Actor, advisory (not authenticated), or the subject of a verified client certificate",
+                    "type": "string",
+                    "example": "anonymous"
+                },
+                "after": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "before": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "diff": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/models.AuditChange"
+                    }
+                },
+                "entity": {
+                    "type": "string",
+                    "enum": [
+                        "super",
+                        "group"
+                    ],
+                    "example": "super"
+                },
+                "entity_id": {
+                    "type": "string",
+                    "example": "47c0df01-a47d-497f-808d-181021f01c76"
+                },
+                "entity_name": {
+                    "type": "string",
+                    "example": "SuperHero1"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "request_id": {
+                    "type": "string",
+                    "example": "5f0c2b9e1a7d4c3b"
+                }
+            }
+        },
         "models.Group": {
             "type": "object",
             "properties": {
@@ -368,10 +1591,53 @@ var doc = `{
         "models.Super": {
             "type": "object",
             "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "Insider",
+                        "Matches Malone"
+                    ]
+                },
+                "alignment": {
+                    "type": "string",
+                    "enum": [
+                        "good",
+                        "bad",
+                        "neutral"
+                    ],
+                    "example": "good"
+                },
+                "base": {
+                    "type": "string",
+                    "example": "Batcave, Gotham City"
+                },
+                "combat": {
+                    "type": "integer",
+                    "example": 100
+                },
+                "durability": {
+                    "type": "integer",
+                    "example": 50
+                },
+                "first_appearance": {
+                    "type": "string",
+                    "example": "Detective Comics #27"
+                },
                 "fullname": {
                     "type": "string",
                     "example": "SuperHero1's Full Name"
                 },
+                "gender": {
+                    "type": "string",
+                    "example": "Male"
+                },
+                "group_affiliation": {
+                    "type": "string",
+                    "example": "Batman Family, Justice League"
+                },
                 "groups": {
                     "type": "array",
                     "items": {
@@ -382,6 +1648,10 @@ var doc = `{
                         "group2"
                     ]
                 },
+                "height_cm": {
+                    "type": "integer",
+                    "example": 188
+                },
                 "image_url": {
                     "type": "string",
                     "example": "https://http.cat/200"
@@ -391,6 +1661,7 @@ var doc = `{
                     "example": 90
                 },
                 "name": {
+                    "description": "unique among non deleted (see migrations)",
                     "type": "string",
                     "example": "SuperHero1"
                 },
@@ -402,9 +1673,30 @@ var doc = `{
                     "type": "integer",
                     "example": 80
                 },
+                "publisher": {
+                    "type": "string",
+                    "example": "DC Comics"
+                },
+                "race": {
+                    "type": "string",
+                    "example": "Human"
+                },
+                "relatives": {
+                    "description": "as told by the SuperHeroAPI (not relatives_count)",
+                    "type": "string",
+                    "example": "Thomas Wayne (father, deceased)"
+                },
                 "relatives_count": {
                     "type": "integer"
                 },
+                "speed": {
+                    "type": "integer",
+                    "example": 27
+                },
+                "strength": {
+                    "type": "integer",
+                    "example": 26
+                },
                 "type": {
                     "type": "string",
                     "enum": [
@@ -413,9 +1705,101 @@ var doc = `{
                     ],
                     "example": "HERO"
                 },
+                "universe": {
+                    "type": "string",
+                    "example": "default"
+                },
                 "uuid": {
                     "type": "string",
                     "example": "47c0df01-a47d-497f-808d-181021f01c76"
+                },
+                "weight_kg": {
+                    "type": "integer",
+                    "example": 95
+                }
+            }
+        },
+        "models.Webhook": {
+            "type": "object",
+            "properties": {
+                "api_version": {
+                    "description": "the API it was registered with: its payloads name the types of Supers as it does",
+                    "type": "string",
+                    "example": "v2"
+                },
+                "consecutive_failures": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "disabled_at": {
+                    "description": "set when disabled after repeated failures",
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "entities": {
+                    "description": "empty for every entity",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "super"
+                    ]
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "secret": {
+                    "type": "string",
+                    "example": "3f2a9c..."
+                },
+                "types": {
+                    "description": "empty for every type",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "super.created"
+                    ]
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://example.com/hooks/superhero"
+                }
+            }
+        },
+        "models.WebhookAttempt": {
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivery_id": {
+                    "type": "integer"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "status_code": {
+                    "description": "0 when no response was received",
+                    "type": "integer"
+                },
+                "webhook_id": {
+                    "type": "integer"
                 }
             }
         },
@@ -451,6 +1835,130 @@ var doc = `{
                     "example": "HERO"
                 }
             }
+        },
+        "server.graphQLRequestJSON": {
+            "type": "object",
+            "properties": {
+                "operationName": {
+                    "type": "string"
+                },
+                "query": {
+                    "type": "string",
+                    "example": "{ supers(type: \"HERO\") { name groups { name } } }"
+                },
+                "variables": {
+                    "type": "object",
+                    "additionalProperties": true
+                }
+            }
+        },
+        "server.graphQLResponseJSON": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "object"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "type": "object"
+                    }
+                }
+            }
+        },
+        "server.webhookDeliveryJSON": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "attempts_log": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.WebhookAttempt"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivered_at": {
+                    "type": "string"
+                },
+                "event_id": {
+                    "description": "OutboxEvent ID",
+                    "type": "integer"
+                },
+                "event_type": {
+                    "type": "string",
+                    "example": "super.created"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_status_code": {
+                    "type": "integer"
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "pending",
+                        "delivered",
+                        "failed"
+                    ],
+                    "example": "pending"
+                },
+                "webhook_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "server.webhookRequestJSON": {
+            "type": "object",
+            "required": [
+                "url"
+            ],
+            "properties": {
+                "enabled": {
+                    "description": "default: true",
+                    "type": "boolean",
+                    "example": true
+                },
+                "entities": {
+                    "description": "empty for every entity",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "super"
+                    ]
+                },
+                "secret": {
+                    "description": "generated when empty (on create)",
+                    "type": "string",
+                    "example": "my-shared-secret"
+                },
+                "types": {
+                    "description": "empty for every type",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "super.created"
+                    ]
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://example.com/hooks/superhero"
+                }
+            }
         }
     }
 }`
@@ -466,12 +1974,12 @@ type swaggerInfo struct {
 
 // SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = swaggerInfo{
-	Version:     "1.0",
+	Version:     "",
 	Host:        "",
-	BasePath:    "/api/v1",
+	BasePath:    "",
 	Schemes:     []string{},
-	Title:       "Superhero API",
-	Description: "SuperHero API - Go (inspired by superheroapi.com) \\n This is being made in the context of https://github.com/levpay/careers#desafio",
+	Title:       "",
+	Description: "",
 }
 
 type s struct{}
@@ -0,0 +1,250 @@
+// Package events is an in-process event bus for changes to Supers and Groups.
+// Events are kept in a bounded replay buffer, so subscribers can resume after a disconnection
+package events
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	SuperCreated       = "super.created"
+	SuperUpdated       = "super.updated"
+	SuperDeleted       = "super.deleted"
+	SuperRestored      = "super.restored"
+	SuperReverted      = "super.reverted"
+	SuperPurged        = "super.purged"
+	GroupCreated       = "group.created"
+	GroupUpdated       = "group.updated"
+	GroupDeleted       = "group.deleted"
+	GroupMemberAdded   = "group.member_added"
+	GroupMemberRemoved = "group.member_removed"
+)
+
+// Reset is the type of the event sent (first) to a subscriber which can't resume from its last event (it is from a
+// previous boot of the server, or too old to be replayed): it should reload what it keeps, as it may have missed events
+const Reset = "reset"
+
+// Types are every event type
+var Types = []string{
+	SuperCreated, SuperUpdated, SuperDeleted, SuperRestored, SuperReverted, SuperPurged,
+	GroupCreated, GroupUpdated, GroupDeleted, GroupMemberAdded, GroupMemberRemoved,
+}
+
+// DefaultReplaySize is the default number of events kept for resuming subscriptions
+const DefaultReplaySize = 1000
+
+// streamIDSeparator separates the epoch from the ID, in a StreamID
+const streamIDSeparator = "-"
+
+// subscriptionBufferSize is how many events a subscriber may fall behind before being dropped
+const subscriptionBufferSize = 256
+
+// Event is a change to a Super or Group
+type Event struct {
+	ID         uint64                 `json:"id"`
+	Epoch      string                 `json:"epoch,omitempty" example:"kf3p9x1a"` // boot of the server which streamed it: IDs restart on each boot
+	Type       string                 `json:"type" example:"super.created"`
+	Entity     string                 `json:"entity" example:"super"`
+	EntityID   string                 `json:"entity_id" example:"47c0df01-a47d-497f-808d-181021f01c76"`
+	Time       time.Time              `json:"time"`
+	Actor      string                 `json:"actor,omitempty"` // X-Actor, advisory (not authenticated), or the subject of a verified client certificate
+	RequestID  string                 `json:"request_id,omitempty"`
+	APIVersion string                 `json:"api_version,omitempty" example:"v2"` // how the types in data are named (v1: VILAN, and HERO for NEUTRAL and ANTIHERO)
+	Data       map[string]interface{} `json:"data"`
+}
+
+// StreamID is what a subscriber resumes from after receiving the event (see Subscribe): "epoch-id"
+func (e Event) StreamID() string {
+	return e.Epoch + streamIDSeparator + strconv.FormatUint(e.ID, 10)
+}
+
+// ParseStreamID reads the epoch and the ID of a StreamID. A bare ID (from before epochs) has no epoch
+func ParseStreamID(streamID string) (string, uint64, error) {
+	epoch := ""
+	if i := strings.LastIndex(streamID, streamIDSeparator); i >= 0 {
+		epoch, streamID = streamID[:i], streamID[i+len(streamIDSeparator):]
+		if epoch == "" {
+			return "", 0, errors.New("invalid event epoch")
+		}
+	}
+	id, err := strconv.ParseUint(streamID, 10, 64)
+	return epoch, id, err
+}
+
+// Filter selects events by entity and type. Empty lists match everything
+type Filter struct {
+	Entities []string
+	Types    []string
+}
+
+// Match tells if the event is selected by the filter
+func (f Filter) Match(e Event) bool {
+	return matchAny(f.Entities, e.Entity) && matchAny(f.Types, e.Type)
+}
+
+func matchAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription receives the events published after it was created.
+// C is closed when the subscription is closed, or when the subscriber falls too much behind
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	filter Filter
+	bus    *Bus
+}
+
+// Close stops receiving events
+func (s *Subscription) Close() {
+	s.bus.unsubscribe(s)
+}
+
+// Bus publishes events to every subscriber, keeping the last ones for replay
+type Bus struct {
+	mu          sync.Mutex
+	epoch       string // IDs are only unique within a Bus (they restart on each boot)
+	lastID      uint64
+	replay      []Event // ring buffer of the last len(replay) events, the oldest at replayHead
+	replayHead  int     // index of the oldest event in replay
+	replayLen   int     // how many events replay has
+	subscribers map[*Subscription]struct{}
+}
+
+// NewBus creates a Bus which keeps the last replaySize events
+func NewBus(replaySize int) *Bus {
+	return &Bus{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		replay:      make([]Event, replaySize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns an ID (and time, if missing) to the event and sends it to every matching subscriber
+func (b *Bus) Publish(e Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e.ID = b.lastID
+	e.Epoch = b.epoch
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	if size := len(b.replay); size > 0 {
+		b.replay[(b.replayHead+b.replayLen)%size] = e
+		if b.replayLen < size {
+			b.replayLen++
+		} else {
+			b.replayHead = (b.replayHead + 1) % size // overwrote the oldest
+		}
+	}
+
+	for s := range b.subscribers {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			// too slow: drop it, so it can resume from its last event
+			b.removeLocked(s)
+		}
+	}
+
+	return e
+}
+
+// Subscribe creates a subscription for the events matching filter. The events after the last event received
+// (its epoch and ID, see ParseStreamID) which are still in the replay buffer are returned, so no event is lost or
+// repeated (use "" and 0 for none). When they can't be (another epoch, or not all of them are kept), only a Reset
+// event is returned, with the ID to resume from
+func (b *Bus) Subscribe(filter Filter, epoch string, lastEventID uint64) (*Subscription, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	replay := make([]Event, 0)
+	switch {
+	case epoch == "" && lastEventID == 0:
+	case !b.resumableLocked(epoch, lastEventID):
+		replay = append(replay, Event{ID: b.lastID, Epoch: b.epoch, Type: Reset, Time: time.Now()})
+	default:
+		for i := 0; i < b.replayLen; i++ {
+			if e := b.replayedLocked(i); e.ID > lastEventID && filter.Match(e) {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	c := make(chan Event, subscriptionBufferSize)
+	s := &Subscription{C: c, c: c, filter: filter, bus: b}
+	b.subscribers[s] = struct{}{}
+
+	return s, replay
+}
+
+// replayedLocked is the i-th event of the replay buffer (0 is the oldest)
+func (b *Bus) replayedLocked(i int) Event {
+	return b.replay[(b.replayHead+i)%len(b.replay)]
+}
+
+// resumableLocked tells if every event after lastEventID (of epoch) is in the replay buffer
+func (b *Bus) resumableLocked(epoch string, lastEventID uint64) bool {
+	if epoch != b.epoch || lastEventID > b.lastID {
+		return false
+	}
+	oldest := b.lastID + 1
+	if b.replayLen > 0 {
+		oldest = b.replayedLocked(0).ID
+	}
+	return lastEventID+1 >= oldest
+}
+
+// Epoch identifies this Bus (the boot of the server), as the prefix of the StreamID of its events
+func (b *Bus) Epoch() string {
+	return b.epoch
+}
+
+// LastID is the ID of the last published event
+func (b *Bus) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastID
+}
+
+// CloseSubscriptions closes every subscription (eg: on shutdown). Their subscribers may resume later with
+// the ID of the last event they received
+func (b *Bus) CloseSubscriptions() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subscribers {
+		b.removeLocked(s)
+	}
+}
+
+func (b *Bus) unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.removeLocked(s)
+}
+
+func (b *Bus) removeLocked(s *Subscription) {
+	if _, ok := b.subscribers[s]; ok {
+		delete(b.subscribers, s)
+		close(s.c)
+	}
+}
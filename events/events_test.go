@@ -0,0 +1,167 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterMatch(t *testing.T) {
+	e := Event{Type: SuperCreated, Entity: "super"}
+
+	assert.True(t, Filter{}.Match(e))
+	assert.True(t, Filter{Entities: []string{"group", "super"}}.Match(e))
+	assert.True(t, Filter{Types: []string{SuperCreated}}.Match(e))
+	assert.False(t, Filter{Entities: []string{"group"}}.Match(e))
+	assert.False(t, Filter{Entities: []string{"super"}, Types: []string{SuperDeleted}}.Match(e))
+}
+
+func TestParseStreamID(t *testing.T) {
+	epoch, id, err := ParseStreamID((Event{ID: 42, Epoch: "kf3p9x1a"}).StreamID())
+	assert.NoError(t, err)
+	assert.Equal(t, "kf3p9x1a", epoch)
+	assert.Equal(t, uint64(42), id)
+
+	epoch, id, err = ParseStreamID("42")
+	assert.NoError(t, err)
+	assert.Equal(t, "", epoch)
+	assert.Equal(t, uint64(42), id)
+
+	for _, invalid := range []string{"", "last", "-42", "kf3p9x1a-", "kf3p9x1a-last"} {
+		_, _, err = ParseStreamID(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestBusPublishSubscribe(t *testing.T) {
+	bus := NewBus(10)
+
+	supers, replay := bus.Subscribe(Filter{Entities: []string{"super"}}, "", 0)
+	defer supers.Close()
+	assert.Empty(t, replay)
+
+	bus.Publish(Event{Type: GroupCreated, Entity: "group"})
+	published := bus.Publish(Event{Type: SuperCreated, Entity: "super", EntityID: "uuid1"})
+
+	assert.Equal(t, uint64(2), published.ID)
+	assert.Equal(t, bus.Epoch(), published.Epoch)
+	assert.Equal(t, bus.Epoch()+"-2", published.StreamID())
+	assert.False(t, published.Time.IsZero())
+	assert.Equal(t, uint64(2), bus.LastID())
+
+	received := <-supers.C
+	assert.Equal(t, published, received)
+	assert.Len(t, supers.C, 0)
+}
+
+func TestBusReplay(t *testing.T) {
+	bus := NewBus(3)
+	for i := 0; i < 5; i++ {
+		bus.Publish(Event{Type: SuperUpdated, Entity: "super"})
+	}
+
+	t.Run("resumes after the last event", func(t *testing.T) {
+		sub, replay := bus.Subscribe(Filter{}, bus.Epoch(), 3)
+		defer sub.Close()
+
+		if assert.Len(t, replay, 2) {
+			assert.Equal(t, uint64(4), replay[0].ID)
+			assert.Equal(t, uint64(5), replay[1].ID)
+		}
+	})
+
+	t.Run("resumes after the oldest buffered event", func(t *testing.T) {
+		sub, replay := bus.Subscribe(Filter{}, bus.Epoch(), 2)
+		defer sub.Close()
+
+		if assert.Len(t, replay, 3) {
+			assert.Equal(t, uint64(3), replay[0].ID)
+		}
+	})
+
+	reset := Event{ID: 5, Epoch: bus.Epoch(), Type: Reset}
+	for name, last := range map[string]struct {
+		epoch string
+		id    uint64
+	}{
+		"events no longer buffered": {bus.Epoch(), 1},
+		"another epoch":             {"previous", 3},
+		"no epoch":                  {"", 3},
+		"unknown event":             {bus.Epoch(), 6},
+	} {
+		t.Run("resets after "+name, func(t *testing.T) {
+			sub, replay := bus.Subscribe(Filter{}, last.epoch, last.id)
+			defer sub.Close()
+
+			if assert.Len(t, replay, 1) {
+				replay[0].Time = time.Time{}
+				assert.Equal(t, reset, replay[0])
+			}
+		})
+	}
+
+	t.Run("replay is filtered", func(t *testing.T) {
+		sub, replay := bus.Subscribe(Filter{Entities: []string{"group"}}, bus.Epoch(), 2)
+		defer sub.Close()
+
+		assert.Empty(t, replay)
+	})
+}
+
+func TestBusReplayWrapsAround(t *testing.T) {
+	bus := NewBus(3)
+	for i := 0; i < 10; i++ {
+		bus.Publish(Event{Type: SuperUpdated, Entity: "super"})
+	}
+
+	sub, replay := bus.Subscribe(Filter{}, bus.Epoch(), 7)
+	defer sub.Close()
+
+	ids := make([]uint64, 0)
+	for _, e := range replay {
+		ids = append(ids, e.ID)
+	}
+	assert.Equal(t, []uint64{8, 9, 10}, ids)
+}
+
+func TestBusDropsSlowSubscribers(t *testing.T) {
+	bus := NewBus(0)
+	sub, _ := bus.Subscribe(Filter{}, "", 0)
+
+	for i := 0; i <= subscriptionBufferSize; i++ {
+		bus.Publish(Event{Type: SuperUpdated, Entity: "super"})
+	}
+
+	received := 0
+	for range sub.C {
+		received++
+	}
+	assert.Equal(t, subscriptionBufferSize, received)
+
+	sub.Close() // closing again is harmless
+}
+
+func TestBusCloseSubscriptions(t *testing.T) {
+	bus := NewBus(10)
+	first, _ := bus.Subscribe(Filter{}, "", 0)
+	second, _ := bus.Subscribe(Filter{Entities: []string{"group"}}, "", 0)
+
+	bus.Publish(Event{Type: SuperCreated, Entity: "super"})
+	bus.CloseSubscriptions()
+
+	received := 0
+	for range first.C {
+		received++
+	}
+	assert.Equal(t, 1, received, "pending events are still received")
+	_, ok := <-second.C
+	assert.False(t, ok)
+
+	// new subscribers may resume
+	sub, replay := bus.Subscribe(Filter{}, "", 0)
+	defer sub.Close()
+	assert.Empty(t, replay)
+	bus.Publish(Event{Type: SuperDeleted, Entity: "super"})
+	assert.Equal(t, SuperDeleted, (<-sub.C).Type)
+}
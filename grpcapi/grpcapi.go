@@ -0,0 +1,278 @@
+// Package grpcapi serves Supers and Groups over gRPC (see superheropb/superhero.proto),
+// sharing the models (and their errors) with the REST API
+package grpcapi
+
+//go:generate protoc -I superheropb --go_out=superheropb --go_opt=paths=source_relative --go-grpc_out=superheropb --go-grpc_opt=paths=source_relative superhero.proto
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"go.opentelemetry.io/otel"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"github.com/tcarreira/superhero/grpcapi/superheropb"
+	"github.com/tcarreira/superhero/logging"
+	"github.com/tcarreira/superhero/models"
+	"github.com/tcarreira/superhero/tracing"
+)
+
+// streamBatchSize is how many rows are read from the database at once by the streaming methods
+const streamBatchSize = 100
+
+// Metadata read from every call (the same as the REST API headers)
+const (
+	requestIDMetadata = "x-request-id"
+	actorMetadata     = "x-actor"
+	anonymousActor    = "anonymous"
+)
+
+// NewServer creates a gRPC server with the SuperheroService, health checking and reflection
+func NewServer(db *pg.DB) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryInterceptor),
+		grpc.ChainStreamInterceptor(streamInterceptor),
+	)
+
+	superheropb.RegisterSuperheroServiceServer(s, &Service{DB: db})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(superheropb.SuperheroService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
+
+	reflection.Register(s)
+
+	return s
+}
+
+// RunServer starts the gRPC server on addr, until ctx is done. Then, it stops accepting calls and waits
+// (at most drainTimeout) for the pending ones
+func RunServer(ctx context.Context, db *pg.DB, addr string, drainTimeout time.Duration) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return Serve(ctx, NewServer(db), lis, drainTimeout)
+}
+
+// Serve serves s on lis until ctx is done, then stops it gracefully (see RunServer)
+func Serve(ctx context.Context, s *grpc.Server, lis net.Listener, drainTimeout time.Duration) error {
+	served := make(chan error, 1)
+	go func() {
+		logging.Logger().Info().Str("addr", lis.Addr().String()).Msg("Listening and serving gRPC")
+		served <- s.Serve(lis)
+	}()
+
+	select {
+	case err := <-served:
+		return err // failed before shutdown
+	case <-ctx.Done():
+	}
+
+	logging.Logger().Info().Stringer("timeout", drainTimeout).Msg("Shutting down gRPC server: draining calls")
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-time.After(drainTimeout):
+		s.Stop() // cancel the remaining calls
+		return fmt.Errorf("gRPC server shutdown: pending calls after %v", drainTimeout)
+	}
+}
+
+//    _____       _                           _
+//   |_   _|     | |                         | |
+//     | |  _ __ | |_ ___ _ __ ___ ___ _ __ | |_ ___  _ __ ___
+//     | | | '_ \| __/ _ \ '__/ __/ _ \ '_ \| __/ _ \| '__/ __|
+//    _| |_| | | | ||  __/ | | (_|  __/ |_) | || (_) | |  \__ \
+//   |_____|_| |_|\__\___|_|  \___\___| .__/ \__\___/|_|  |___/
+//                                    | |
+//                                    |_|
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// callContext identifies the call (x-request-id, generated if missing) and who is making it (x-actor),
+// so the models can record it (eg: audit log)
+func callContext(ctx context.Context) context.Context {
+	var requestID, actor string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDMetadata); len(values) > 0 && len(values[0]) <= 128 {
+			requestID = values[0]
+		}
+		if values := md.Get(actorMetadata); len(values) > 0 {
+			actor = values[0]
+		}
+	}
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	if actor == "" {
+		actor = anonymousActor
+	}
+
+	ctx = models.WithActor(ctx, actor)
+	ctx = models.WithRequestID(ctx, requestID)
+	return logging.WithRequestID(ctx, requestID)
+}
+
+// metadataCarrier reads the trace context propagated on the metadata of a call (traceparent)
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// startSpan records a span for the call, as a child of the trace context propagated by the client, if any
+func startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+	return tracing.Tracer().Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.RPCSystemGRPC, semconv.RPCMethodKey.String(method)),
+	)
+}
+
+// endCall ends the span of a call, with its status, and logs it (with the request ID of ctx)
+func endCall(ctx context.Context, span trace.Span, method string, start time.Time, err error) {
+	code := status.Code(err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, code.String())
+	}
+	span.End()
+
+	logger := logging.Ctx(ctx)
+	event := logger.Info()
+	switch {
+	case code == codes.Internal || code == codes.Unknown:
+		event = logger.Error().Err(err)
+	case strings.HasPrefix(method, "/grpc.health.v1.Health/"):
+		event = logger.Debug() // polled by probes
+	}
+	event.
+		Str("method", method).
+		Str("code", code.String()).
+		Float64("duration_ms", float64(time.Since(start).Microseconds())/1000).
+		Msg("call")
+}
+
+// recoverError turns a panic (the models panic on unexpected database errors) into an Internal error
+func recoverError(ctx context.Context, err *error) {
+	if r := recover(); r != nil {
+		logging.Ctx(ctx).Error().
+			Interface("panic", r).
+			Str("stack", string(debug.Stack())).
+			Msg("panic serving gRPC call")
+		*err = status.Errorf(codes.Internal, "Unexpected Error: %v", r)
+	}
+}
+
+func unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	start := time.Now()
+	ctx, span := startSpan(ctx, info.FullMethod)
+	defer func() { endCall(ctx, span, info.FullMethod, start, err) }()
+	defer func() { recoverError(ctx, &err) }()
+
+	ctx = callContext(ctx)
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, models.RequestIDFromContext(ctx)))
+
+	resp, err = handler(ctx, req)
+	return resp, toStatus(err)
+}
+
+// contextStream is a ServerStream with a different context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+	ctx, span := startSpan(ss.Context(), info.FullMethod)
+	defer func() { endCall(ctx, span, info.FullMethod, start, err) }()
+	defer func() { recoverError(ctx, &err) }()
+
+	ctx = callContext(ctx)
+	ss.SetHeader(metadata.Pairs(requestIDMetadata, models.RequestIDFromContext(ctx)))
+
+	return toStatus(handler(srv, &contextStream{ss, ctx}))
+}
+
+// toStatus maps the errors of models to gRPC status errors
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch err.(type) {
+	case *models.ErrorSuperNotFound, *models.ErrorGroupNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case *models.ErrorSuperAlreadyExists, *models.ErrorGroupAlreadyExists:
+		return status.Error(codes.AlreadyExists, err.Error())
+	case *models.ErrorSuperInvalidFields:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case *models.ErrorSuperVersionMismatch, *models.ErrorGroupVersionMismatch:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if err == context.Canceled {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	if err == context.DeadlineExceeded {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Internal, fmt.Sprintf("Unexpected Error: %s", err))
+}
@@ -0,0 +1,158 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/tcarreira/superhero/grpcapi/superheropb"
+	"github.com/tcarreira/superhero/models"
+)
+
+// dialTestServer starts a server in memory and returns a connection to it
+func dialTestServer(t *testing.T, db *pg.DB) *grpc.ClientConn {
+	lis := bufconn.Listen(1024 * 1024)
+	s := NewServer(db)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{&models.ErrorSuperNotFound{}, codes.NotFound},
+		{&models.ErrorGroupNotFound{}, codes.NotFound},
+		{&models.ErrorSuperAlreadyExists{}, codes.AlreadyExists},
+		{&models.ErrorGroupAlreadyExists{}, codes.AlreadyExists},
+		{&models.ErrorSuperInvalidFields{}, codes.InvalidArgument},
+		{&models.ErrorSuperVersionMismatch{}, codes.FailedPrecondition},
+		{&models.ErrorGroupVersionMismatch{}, codes.FailedPrecondition},
+		{status.Error(codes.Unavailable, "keep me"), codes.Unavailable},
+		{context.Canceled, codes.Canceled},
+		{errors.New("boom"), codes.Internal},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.code, status.Code(toStatus(tt.err)), "%T", tt.err)
+	}
+	assert.Nil(t, toStatus(nil))
+}
+
+func TestHealthAndReflection(t *testing.T) {
+	conn := dialTestServer(t, nil)
+	ctx := context.Background()
+
+	health := healthpb.NewHealthClient(conn)
+	for _, service := range []string{"", "superhero.v1.SuperheroService"} {
+		resp, err := health.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		assert.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+	}
+
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = stream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+	})
+	assert.NoError(t, err)
+	resp, err := stream.Recv()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	services := make([]string, 0)
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		services = append(services, service.GetName())
+	}
+	assert.Contains(t, services, "superhero.v1.SuperheroService")
+	assert.Contains(t, services, "grpc.health.v1.Health")
+}
+
+func TestInvalidArguments(t *testing.T) {
+	client := superheropb.NewSuperheroServiceClient(dialTestServer(t, nil))
+	ctx := context.Background()
+
+	_, err := client.CreateSuper(ctx, &superheropb.CreateSuperRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.CreateSuper(ctx, &superheropb.CreateSuperRequest{
+		Super: &superheropb.Super{Type: "SIDEKICK", Name: "Robin"},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.UpdateGroup(ctx, &superheropb.UpdateGroupRequest{Name: "group1"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.ListSupers(ctx, &superheropb.ListSupersRequest{Limit: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	stream, err := client.StreamGroups(ctx, &superheropb.ListGroupsRequest{Offset: -1})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUniverseConverters(t *testing.T) {
+	assert.Equal(t, "dc", superToPB(&models.Super{Type: "HERO", Name: "Batman", Universe: "dc"}).GetUniverse())
+
+	super, err := superFromPB(&superheropb.Super{Type: "HERO", Name: "Batman", Universe: "dc"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "dc", super.Universe)
+
+	assert.Equal(t, "dc", superFilter(&superheropb.ListSupersRequest{Universe: "dc"}).Universe)
+}
+
+func TestServeGracefulStop(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, NewServer(nil), lis, time.Second)
+	}()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	cancel()
+	select {
+	case err := <-served:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server did not stop")
+	}
+}
@@ -0,0 +1,374 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/go-pg/pg/v9"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/tcarreira/superhero/grpcapi/superheropb"
+	"github.com/tcarreira/superhero/logging"
+	"github.com/tcarreira/superhero/models"
+)
+
+// Service implements superheropb.SuperheroServiceServer
+type Service struct {
+	superheropb.UnimplementedSuperheroServiceServer
+	DB *pg.DB
+}
+
+// callDB binds the database to the call context (actor, request ID, cancellation)
+func (s *Service) callDB(ctx context.Context) *pg.DB {
+	if s.DB == nil {
+		return nil
+	}
+	return s.DB.WithContext(ctx)
+}
+
+// superToPB converts a models.Super. superhero.v1 names the types as /api/v1 (see models.V1Type)
+func superToPB(super *models.Super) *superheropb.Super {
+	return &superheropb.Super{
+		Uuid:           super.UUID,
+		Type:           models.V1Type(super.Type),
+		Name:           super.Name,
+		FullName:       super.FullName,
+		Intelligence:   super.Intelligence,
+		Power:          super.Power,
+		Occupation:     super.Occupation,
+		ImageUrl:       super.ImageURL,
+		Groups:         super.GroupsList,
+		RelativesCount: int32(super.RelativesCount),
+		Version:        super.Version,
+		Universe:       super.Universe,
+	}
+}
+
+// superFromPB gets the editable fields of a Super, replacing one of type current ("" if new, see models.FromV1Type).
+// Without a universe, it is the default one (if new) or the current one
+func superFromPB(super *superheropb.Super, current string) (*models.Super, error) {
+	t, err := models.FromV1Type(super.GetType(), current)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Super{
+		Type:         t,
+		Universe:     super.GetUniverse(),
+		Name:         super.GetName(),
+		FullName:     super.GetFullName(),
+		Intelligence: super.GetIntelligence(),
+		Power:        super.GetPower(),
+		Occupation:   super.GetOccupation(),
+		ImageURL:     super.GetImageUrl(),
+	}, nil
+}
+
+// keepProfile copies to super the fields of current which superheropb.Super does not have (the powerstats and
+// profile of the SuperHeroAPI), so UpdateSuper does not erase them
+func keepProfile(super, current *models.Super) {
+	super.Strength = current.Strength
+	super.Speed = current.Speed
+	super.Durability = current.Durability
+	super.Combat = current.Combat
+	super.Aliases = current.Aliases
+	super.Publisher = current.Publisher
+	super.Alignment = current.Alignment
+	super.FirstAppearance = current.FirstAppearance
+	super.Gender = current.Gender
+	super.Race = current.Race
+	super.HeightCM = current.HeightCM
+	super.WeightKG = current.WeightKG
+	super.Base = current.Base
+	super.GroupAffiliation = current.GroupAffiliation
+	super.Relatives = current.Relatives
+}
+
+// groupToPB converts a models.Group (with its SupersList)
+func groupToPB(group *models.Group) *superheropb.Group {
+	return &superheropb.Group{
+		Name:    group.Name,
+		Supers:  group.SupersList,
+		Version: group.Version,
+	}
+}
+
+// groupFromPB gets the name and Supers of a Group
+func groupFromPB(group *superheropb.Group) *models.Group {
+	g := &models.Group{Name: group.GetName()}
+	for _, name := range group.GetSupers() {
+		g.Supers = append(g.Supers, models.Super{Name: name})
+	}
+	return g
+}
+
+// ignoreSuperRelation ignores the (logged) errors about Supers which could not be added to a Group, like the REST API
+func ignoreSuperRelation(ctx context.Context, err error) error {
+	if _, ok := err.(*models.ErrorGroupSuperRelation); ok {
+		logging.Ctx(ctx).Warn().Err(err).Msg("Group saved without some of its Supers")
+		return nil
+	}
+	return err
+}
+
+// checkPage validates limit and offset
+func checkPage(limit, offset int32) error {
+	if limit < 0 || offset < 0 {
+		return status.Error(codes.InvalidArgument, "limit and offset must not be negative")
+	}
+	return nil
+}
+
+//     _____
+//    / ____|
+//   | (___  _   _ _ __   ___ _ __ ___
+//    \___ \| | | | '_ \ / _ \ '__/ __|
+//    ____) | |_| | |_) |  __/ |  \__ \
+//   |_____/ \__,_| .__/ \___|_|  |___/
+//                | |
+//                |_|
+
+// CreateSuper creates a Super
+func (s *Service) CreateSuper(ctx context.Context, req *superheropb.CreateSuperRequest) (*superheropb.Super, error) {
+	if req.GetSuper() == nil {
+		return nil, status.Error(codes.InvalidArgument, "super is required")
+	}
+
+	super, err := superFromPB(req.GetSuper(), "")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := super.Create(s.callDB(ctx)); err != nil {
+		return nil, err
+	}
+	return superToPB(super), nil
+}
+
+// GetSuper gets a Super by name or uuid
+func (s *Service) GetSuper(ctx context.Context, req *superheropb.GetSuperRequest) (*superheropb.Super, error) {
+	super, err := new(models.Super).GetByNameOrUUID(s.callDB(ctx), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return superToPB(super), nil
+}
+
+// UpdateSuper replaces every editable field of a Super, if it is still at the expected version
+func (s *Service) UpdateSuper(ctx context.Context, req *superheropb.UpdateSuperRequest) (*superheropb.Super, error) {
+	if req.GetSuper() == nil {
+		return nil, status.Error(codes.InvalidArgument, "super is required")
+	}
+
+	current, err := new(models.Super).GetByNameOrUUID(s.callDB(models.WithoutCache(ctx)), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	super, err := superFromPB(req.GetSuper(), current.Type)
+	if err != nil {
+		return nil, err
+	}
+	keepProfile(super, current)
+	super, err = super.UpdateByNameOrUUID(s.callDB(ctx), req.GetId(), req.GetVersion())
+	if err != nil {
+		return nil, err
+	}
+	return superToPB(super), nil
+}
+
+// DeleteSuper (soft) deletes a Super, if it is still at the expected version
+func (s *Service) DeleteSuper(ctx context.Context, req *superheropb.DeleteSuperRequest) (*superheropb.DeleteSuperResponse, error) {
+	if err := new(models.Super).DeleteByNameOrUUIDAtVersion(s.callDB(ctx), req.GetId(), req.GetVersion()); err != nil {
+		return nil, err
+	}
+	return &superheropb.DeleteSuperResponse{}, nil
+}
+
+// superFilter builds the filter of ListSupers and StreamSupers (type as in /api/v1)
+func superFilter(req *superheropb.ListSupersRequest) *models.Super {
+	filter := &models.Super{
+		Type:     req.GetType(),
+		Name:     req.GetName(),
+		UUID:     req.GetUuid(),
+		Universe: req.GetUniverse(),
+	}
+	filter.FilterByV1Type()
+	return filter
+}
+
+// ListSupers lists the Supers (by ANDing the filters)
+func (s *Service) ListSupers(ctx context.Context, req *superheropb.ListSupersRequest) (*superheropb.ListSupersResponse, error) {
+	if err := checkPage(req.GetLimit(), req.GetOffset()); err != nil {
+		return nil, err
+	}
+
+	supers := superFilter(req).ReadPage(s.callDB(ctx), req.GetDeleted(), int(req.GetLimit()), int(req.GetOffset()))
+
+	resp := &superheropb.ListSupersResponse{Supers: make([]*superheropb.Super, 0, len(supers))}
+	for i := range supers {
+		resp.Supers = append(resp.Supers, superToPB(&supers[i]))
+	}
+	return resp, nil
+}
+
+// StreamSupers is ListSupers, sending one Super at a time
+func (s *Service) StreamSupers(req *superheropb.ListSupersRequest, stream superheropb.SuperheroService_StreamSupersServer) error {
+	if err := checkPage(req.GetLimit(), req.GetOffset()); err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	db := s.callDB(ctx)
+	filter := superFilter(req)
+	remaining := int(req.GetLimit())
+	offset := int(req.GetOffset())
+
+	for {
+		batch := streamBatchSize
+		if req.GetLimit() > 0 && remaining < batch {
+			batch = remaining
+		}
+		if batch == 0 {
+			return nil
+		}
+
+		supers := filter.ReadPage(db, req.GetDeleted(), batch, offset)
+		for i := range supers {
+			if err := stream.Send(superToPB(&supers[i])); err != nil {
+				return err
+			}
+		}
+		if len(supers) < batch {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		offset += len(supers)
+		remaining -= len(supers)
+	}
+}
+
+//     _____
+//    / ____|
+//   | |  __ _ __ ___  _   _ _ __  ___
+//   | | |_ | '__/ _ \| | | | '_ \/ __|
+//   | |__| | | | (_) | |_| | |_) \__ \
+//    \_____|_|  \___/ \__,_| .__/|___/
+//                          | |
+//                          |_|
+
+// CreateGroup creates a Group. Supers which do not exist are ignored
+func (s *Service) CreateGroup(ctx context.Context, req *superheropb.CreateGroupRequest) (*superheropb.Group, error) {
+	if req.GetGroup() == nil {
+		return nil, status.Error(codes.InvalidArgument, "group is required")
+	}
+
+	db := s.callDB(ctx)
+	group, err := groupFromPB(req.GetGroup()).Create(db)
+	if err := ignoreSuperRelation(ctx, err); err != nil {
+		return nil, err
+	}
+
+	return s.GetGroup(ctx, &superheropb.GetGroupRequest{Name: group.Name})
+}
+
+// GetGroup gets a Group by name
+func (s *Service) GetGroup(ctx context.Context, req *superheropb.GetGroupRequest) (*superheropb.Group, error) {
+	group, err := new(models.Group).GetByName(s.callDB(ctx), req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return groupToPB(group), nil
+}
+
+// UpdateGroup renames a Group and replaces its Supers, if it is still at the expected version.
+// Supers which do not exist are ignored
+func (s *Service) UpdateGroup(ctx context.Context, req *superheropb.UpdateGroupRequest) (*superheropb.Group, error) {
+	if req.GetGroup() == nil {
+		return nil, status.Error(codes.InvalidArgument, "group is required")
+	}
+
+	group, err := groupFromPB(req.GetGroup()).UpdateByName(s.callDB(ctx), req.GetName(), req.GetVersion())
+	if err := ignoreSuperRelation(ctx, err); err != nil {
+		return nil, err
+	}
+
+	return s.GetGroup(ctx, &superheropb.GetGroupRequest{Name: group.Name})
+}
+
+// DeleteGroup deletes a Group (Supers are kept), if it is still at the expected version
+func (s *Service) DeleteGroup(ctx context.Context, req *superheropb.DeleteGroupRequest) (*superheropb.DeleteGroupResponse, error) {
+	if err := new(models.Group).DeleteByName(s.callDB(ctx), req.GetName(), req.GetVersion()); err != nil {
+		return nil, err
+	}
+	return &superheropb.DeleteGroupResponse{}, nil
+}
+
+// readGroups reads a page of Groups, with the names of their Supers
+func readGroups(db *pg.DB, limit, offset int) ([]*superheropb.Group, error) {
+	groups, err := new(models.Group).ReadPageWithSupers(db, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*superheropb.Group, 0, len(groups))
+	for i := range groups {
+		result = append(result, groupToPB(&groups[i]))
+	}
+	return result, nil
+}
+
+// ListGroups lists the Groups, by name
+func (s *Service) ListGroups(ctx context.Context, req *superheropb.ListGroupsRequest) (*superheropb.ListGroupsResponse, error) {
+	if err := checkPage(req.GetLimit(), req.GetOffset()); err != nil {
+		return nil, err
+	}
+
+	groups, err := readGroups(s.callDB(ctx), int(req.GetLimit()), int(req.GetOffset()))
+	if err != nil {
+		return nil, err
+	}
+	return &superheropb.ListGroupsResponse{Groups: groups}, nil
+}
+
+// StreamGroups is ListGroups, sending one Group at a time
+func (s *Service) StreamGroups(req *superheropb.ListGroupsRequest, stream superheropb.SuperheroService_StreamGroupsServer) error {
+	if err := checkPage(req.GetLimit(), req.GetOffset()); err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	db := s.callDB(ctx)
+	remaining := int(req.GetLimit())
+	offset := int(req.GetOffset())
+
+	for {
+		batch := streamBatchSize
+		if req.GetLimit() > 0 && remaining < batch {
+			batch = remaining
+		}
+		if batch == 0 {
+			return nil
+		}
+
+		groups, err := readGroups(db, batch, offset)
+		if err != nil {
+			return err
+		}
+		for _, group := range groups {
+			if err := stream.Send(group); err != nil {
+				return err
+			}
+		}
+		if len(groups) < batch {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		offset += len(groups)
+		remaining -= len(groups)
+	}
+}
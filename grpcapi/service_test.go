@@ -0,0 +1,183 @@
+// +build sql
+
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/tcarreira/superhero/grpcapi/superheropb"
+	"github.com/tcarreira/superhero/models"
+)
+
+func TestService(t *testing.T) {
+	client := superheropb.NewSuperheroServiceClient(dialTestServer(t, models.SetupEmptyTestDatabase()))
+	ctx := context.Background()
+
+	created, err := client.CreateSuper(ctx, &superheropb.CreateSuperRequest{
+		Super: &superheropb.Super{Type: "hero", Name: "Batman", Intelligence: 100},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "HERO", created.GetType())
+	assert.NotEmpty(t, created.GetUuid())
+
+	_, err = client.CreateSuper(ctx, &superheropb.CreateSuperRequest{
+		Super: &superheropb.Super{Type: "HERO", Name: "Batman"},
+	})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = client.CreateSuper(ctx, &superheropb.CreateSuperRequest{
+		Super: &superheropb.Super{Type: "VILAN", Name: "Joker"},
+	})
+	assert.NoError(t, err)
+
+	group, err := client.CreateGroup(ctx, &superheropb.CreateGroupRequest{
+		Group: &superheropb.Group{Name: "Gotham", Supers: []string{"Batman", "Joker", "Nobody"}},
+	})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Batman", "Joker"}, group.GetSupers())
+
+	got, err := client.GetSuper(ctx, &superheropb.GetSuperRequest{Id: created.GetUuid()})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Gotham"}, got.GetGroups())
+	assert.Equal(t, int32(1), got.GetRelativesCount())
+
+	_, err = client.UpdateSuper(ctx, &superheropb.UpdateSuperRequest{
+		Id:      "Batman",
+		Super:   &superheropb.Super{Type: "HERO", Name: "Batman", Occupation: "Detective"},
+		Version: got.GetVersion() + 1,
+	})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	updated, err := client.UpdateSuper(ctx, &superheropb.UpdateSuperRequest{
+		Id:      "Batman",
+		Super:   &superheropb.Super{Type: "HERO", Name: "Batman", Occupation: "Detective"},
+		Version: got.GetVersion(),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Detective", updated.GetOccupation())
+
+	list, err := client.ListSupers(ctx, &superheropb.ListSupersRequest{Type: "VILAN"})
+	assert.NoError(t, err)
+	if assert.Len(t, list.GetSupers(), 1) {
+		assert.Equal(t, "Joker", list.GetSupers()[0].GetName())
+	}
+
+	groups, err := client.ListGroups(ctx, &superheropb.ListGroupsRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, groups.GetGroups(), 1) {
+		assert.ElementsMatch(t, []string{"Batman", "Joker"}, groups.GetGroups()[0].GetSupers())
+	}
+
+	_, err = client.DeleteSuper(ctx, &superheropb.DeleteSuperRequest{Id: "Joker"})
+	assert.NoError(t, err)
+	_, err = client.GetSuper(ctx, &superheropb.GetSuperRequest{Id: "Joker"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.DeleteGroup(ctx, &superheropb.DeleteGroupRequest{Name: "Gotham"})
+	assert.NoError(t, err)
+	_, err = client.GetGroup(ctx, &superheropb.GetGroupRequest{Name: "Gotham"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestUpdateSuperKeepsProfile(t *testing.T) {
+	db := models.SetupEmptyTestDatabase()
+	client := superheropb.NewSuperheroServiceClient(dialTestServer(t, db))
+	ctx := context.Background()
+
+	_, err := (&models.Super{Type: "HERO", Name: "Batman", Strength: 26, Aliases: []string{"Insider"}, Publisher: "DC Comics"}).Create(db)
+	assert.NoError(t, err)
+
+	_, err = client.UpdateSuper(ctx, &superheropb.UpdateSuperRequest{
+		Id:    "Batman",
+		Super: &superheropb.Super{Type: "HERO", Name: "Batman", Occupation: "Detective"},
+	})
+	assert.NoError(t, err)
+
+	got, err := new(models.Super).GetByNameOrUUID(db, "Batman")
+	assert.NoError(t, err)
+	assert.Equal(t, "Detective", got.Occupation)
+	assert.EqualValues(t, 26, got.Strength)
+	assert.Equal(t, []string{"Insider"}, got.Aliases)
+	assert.Equal(t, "DC Comics", got.Publisher)
+}
+
+func TestUniverses(t *testing.T) {
+	client := superheropb.NewSuperheroServiceClient(dialTestServer(t, models.SetupEmptyTestDatabase()))
+	ctx := context.Background()
+
+	for _, universe := range []string{"marvel", "dc"} {
+		_, err := client.CreateSuper(ctx, &superheropb.CreateSuperRequest{
+			Super: &superheropb.Super{Type: "HERO", Name: "Captain Marvel", Universe: universe},
+		})
+		assert.NoError(t, err)
+	}
+
+	list, err := client.ListSupers(ctx, &superheropb.ListSupersRequest{Universe: "DC"})
+	if assert.NoError(t, err) && assert.Len(t, list.GetSupers(), 1) {
+		assert.Equal(t, "dc", list.GetSupers()[0].GetUniverse())
+	}
+
+	got, err := client.GetSuper(ctx, &superheropb.GetSuperRequest{Id: "marvel:Captain Marvel"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "marvel", got.GetUniverse())
+
+	updated, err := client.UpdateSuper(ctx, &superheropb.UpdateSuperRequest{
+		Id:    "marvel:Captain Marvel",
+		Super: &superheropb.Super{Type: "HERO", Name: "Captain Marvel", Occupation: "Pilot"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "marvel", updated.GetUniverse())
+
+	_, err = client.GetSuper(ctx, &superheropb.GetSuperRequest{Id: "Captain Marvel"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestStreamSupers(t *testing.T) {
+	client := superheropb.NewSuperheroServiceClient(dialTestServer(t, models.SetupEmptyTestDatabase()))
+	ctx := context.Background()
+
+	total := streamBatchSize*2 + 10
+	for i := 0; i < total; i++ {
+		_, err := client.CreateSuper(ctx, &superheropb.CreateSuperRequest{
+			Super: &superheropb.Super{Type: "HERO", Name: fmt.Sprintf("hero%03d", i)},
+		})
+		assert.NoError(t, err)
+	}
+
+	receive := func(req *superheropb.ListSupersRequest) []string {
+		stream, err := client.StreamSupers(ctx, req)
+		assert.NoError(t, err)
+
+		names := make([]string, 0)
+		for {
+			super, err := stream.Recv()
+			if err == io.EOF {
+				return names
+			}
+			if !assert.NoError(t, err) {
+				return names
+			}
+			names = append(names, super.GetName())
+		}
+	}
+
+	assert.Len(t, receive(&superheropb.ListSupersRequest{}), total)
+
+	names := receive(&superheropb.ListSupersRequest{Limit: streamBatchSize + 5, Offset: 3})
+	if assert.Len(t, names, streamBatchSize+5) {
+		assert.Equal(t, "hero003", names[0])
+	}
+
+	assert.Empty(t, receive(&superheropb.ListSupersRequest{Type: "VILAN"}))
+}
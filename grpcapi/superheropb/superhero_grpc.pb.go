@@ -0,0 +1,556 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package superheropb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// SuperheroServiceClient is the client API for SuperheroService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SuperheroServiceClient interface {
+	CreateSuper(ctx context.Context, in *CreateSuperRequest, opts ...grpc.CallOption) (*Super, error)
+	GetSuper(ctx context.Context, in *GetSuperRequest, opts ...grpc.CallOption) (*Super, error)
+	UpdateSuper(ctx context.Context, in *UpdateSuperRequest, opts ...grpc.CallOption) (*Super, error)
+	DeleteSuper(ctx context.Context, in *DeleteSuperRequest, opts ...grpc.CallOption) (*DeleteSuperResponse, error)
+	ListSupers(ctx context.Context, in *ListSupersRequest, opts ...grpc.CallOption) (*ListSupersResponse, error)
+	// StreamSupers sends the Supers one by one, reading them from the database in batches
+	StreamSupers(ctx context.Context, in *ListSupersRequest, opts ...grpc.CallOption) (SuperheroService_StreamSupersClient, error)
+	CreateGroup(ctx context.Context, in *CreateGroupRequest, opts ...grpc.CallOption) (*Group, error)
+	GetGroup(ctx context.Context, in *GetGroupRequest, opts ...grpc.CallOption) (*Group, error)
+	UpdateGroup(ctx context.Context, in *UpdateGroupRequest, opts ...grpc.CallOption) (*Group, error)
+	DeleteGroup(ctx context.Context, in *DeleteGroupRequest, opts ...grpc.CallOption) (*DeleteGroupResponse, error)
+	ListGroups(ctx context.Context, in *ListGroupsRequest, opts ...grpc.CallOption) (*ListGroupsResponse, error)
+	// StreamGroups sends the Groups one by one, reading them from the database in batches
+	StreamGroups(ctx context.Context, in *ListGroupsRequest, opts ...grpc.CallOption) (SuperheroService_StreamGroupsClient, error)
+}
+
+type superheroServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSuperheroServiceClient(cc grpc.ClientConnInterface) SuperheroServiceClient {
+	return &superheroServiceClient{cc}
+}
+
+func (c *superheroServiceClient) CreateSuper(ctx context.Context, in *CreateSuperRequest, opts ...grpc.CallOption) (*Super, error) {
+	out := new(Super)
+	err := c.cc.Invoke(ctx, "/superhero.v1.SuperheroService/CreateSuper", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *superheroServiceClient) GetSuper(ctx context.Context, in *GetSuperRequest, opts ...grpc.CallOption) (*Super, error) {
+	out := new(Super)
+	err := c.cc.Invoke(ctx, "/superhero.v1.SuperheroService/GetSuper", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *superheroServiceClient) UpdateSuper(ctx context.Context, in *UpdateSuperRequest, opts ...grpc.CallOption) (*Super, error) {
+	out := new(Super)
+	err := c.cc.Invoke(ctx, "/superhero.v1.SuperheroService/UpdateSuper", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *superheroServiceClient) DeleteSuper(ctx context.Context, in *DeleteSuperRequest, opts ...grpc.CallOption) (*DeleteSuperResponse, error) {
+	out := new(DeleteSuperResponse)
+	err := c.cc.Invoke(ctx, "/superhero.v1.SuperheroService/DeleteSuper", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *superheroServiceClient) ListSupers(ctx context.Context, in *ListSupersRequest, opts ...grpc.CallOption) (*ListSupersResponse, error) {
+	out := new(ListSupersResponse)
+	err := c.cc.Invoke(ctx, "/superhero.v1.SuperheroService/ListSupers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *superheroServiceClient) StreamSupers(ctx context.Context, in *ListSupersRequest, opts ...grpc.CallOption) (SuperheroService_StreamSupersClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SuperheroService_ServiceDesc.Streams[0], "/superhero.v1.SuperheroService/StreamSupers", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &superheroServiceStreamSupersClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SuperheroService_StreamSupersClient interface {
+	Recv() (*Super, error)
+	grpc.ClientStream
+}
+
+type superheroServiceStreamSupersClient struct {
+	grpc.ClientStream
+}
+
+func (x *superheroServiceStreamSupersClient) Recv() (*Super, error) {
+	m := new(Super)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *superheroServiceClient) CreateGroup(ctx context.Context, in *CreateGroupRequest, opts ...grpc.CallOption) (*Group, error) {
+	out := new(Group)
+	err := c.cc.Invoke(ctx, "/superhero.v1.SuperheroService/CreateGroup", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *superheroServiceClient) GetGroup(ctx context.Context, in *GetGroupRequest, opts ...grpc.CallOption) (*Group, error) {
+	out := new(Group)
+	err := c.cc.Invoke(ctx, "/superhero.v1.SuperheroService/GetGroup", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *superheroServiceClient) UpdateGroup(ctx context.Context, in *UpdateGroupRequest, opts ...grpc.CallOption) (*Group, error) {
+	out := new(Group)
+	err := c.cc.Invoke(ctx, "/superhero.v1.SuperheroService/UpdateGroup", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *superheroServiceClient) DeleteGroup(ctx context.Context, in *DeleteGroupRequest, opts ...grpc.CallOption) (*DeleteGroupResponse, error) {
+	out := new(DeleteGroupResponse)
+	err := c.cc.Invoke(ctx, "/superhero.v1.SuperheroService/DeleteGroup", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *superheroServiceClient) ListGroups(ctx context.Context, in *ListGroupsRequest, opts ...grpc.CallOption) (*ListGroupsResponse, error) {
+	out := new(ListGroupsResponse)
+	err := c.cc.Invoke(ctx, "/superhero.v1.SuperheroService/ListGroups", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *superheroServiceClient) StreamGroups(ctx context.Context, in *ListGroupsRequest, opts ...grpc.CallOption) (SuperheroService_StreamGroupsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SuperheroService_ServiceDesc.Streams[1], "/superhero.v1.SuperheroService/StreamGroups", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &superheroServiceStreamGroupsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SuperheroService_StreamGroupsClient interface {
+	Recv() (*Group, error)
+	grpc.ClientStream
+}
+
+type superheroServiceStreamGroupsClient struct {
+	grpc.ClientStream
+}
+
+func (x *superheroServiceStreamGroupsClient) Recv() (*Group, error) {
+	m := new(Group)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SuperheroServiceServer is the server API for SuperheroService service.
+// All implementations must embed UnimplementedSuperheroServiceServer
+// for forward compatibility
+type SuperheroServiceServer interface {
+	CreateSuper(context.Context, *CreateSuperRequest) (*Super, error)
+	GetSuper(context.Context, *GetSuperRequest) (*Super, error)
+	UpdateSuper(context.Context, *UpdateSuperRequest) (*Super, error)
+	DeleteSuper(context.Context, *DeleteSuperRequest) (*DeleteSuperResponse, error)
+	ListSupers(context.Context, *ListSupersRequest) (*ListSupersResponse, error)
+	// StreamSupers sends the Supers one by one, reading them from the database in batches
+	StreamSupers(*ListSupersRequest, SuperheroService_StreamSupersServer) error
+	CreateGroup(context.Context, *CreateGroupRequest) (*Group, error)
+	GetGroup(context.Context, *GetGroupRequest) (*Group, error)
+	UpdateGroup(context.Context, *UpdateGroupRequest) (*Group, error)
+	DeleteGroup(context.Context, *DeleteGroupRequest) (*DeleteGroupResponse, error)
+	ListGroups(context.Context, *ListGroupsRequest) (*ListGroupsResponse, error)
+	// StreamGroups sends the Groups one by one, reading them from the database in batches
+	StreamGroups(*ListGroupsRequest, SuperheroService_StreamGroupsServer) error
+	mustEmbedUnimplementedSuperheroServiceServer()
+}
+
+// UnimplementedSuperheroServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedSuperheroServiceServer struct {
+}
+
+func (UnimplementedSuperheroServiceServer) CreateSuper(context.Context, *CreateSuperRequest) (*Super, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSuper not implemented")
+}
+func (UnimplementedSuperheroServiceServer) GetSuper(context.Context, *GetSuperRequest) (*Super, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSuper not implemented")
+}
+func (UnimplementedSuperheroServiceServer) UpdateSuper(context.Context, *UpdateSuperRequest) (*Super, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateSuper not implemented")
+}
+func (UnimplementedSuperheroServiceServer) DeleteSuper(context.Context, *DeleteSuperRequest) (*DeleteSuperResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSuper not implemented")
+}
+func (UnimplementedSuperheroServiceServer) ListSupers(context.Context, *ListSupersRequest) (*ListSupersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSupers not implemented")
+}
+func (UnimplementedSuperheroServiceServer) StreamSupers(*ListSupersRequest, SuperheroService_StreamSupersServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamSupers not implemented")
+}
+func (UnimplementedSuperheroServiceServer) CreateGroup(context.Context, *CreateGroupRequest) (*Group, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateGroup not implemented")
+}
+func (UnimplementedSuperheroServiceServer) GetGroup(context.Context, *GetGroupRequest) (*Group, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGroup not implemented")
+}
+func (UnimplementedSuperheroServiceServer) UpdateGroup(context.Context, *UpdateGroupRequest) (*Group, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateGroup not implemented")
+}
+func (UnimplementedSuperheroServiceServer) DeleteGroup(context.Context, *DeleteGroupRequest) (*DeleteGroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteGroup not implemented")
+}
+func (UnimplementedSuperheroServiceServer) ListGroups(context.Context, *ListGroupsRequest) (*ListGroupsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListGroups not implemented")
+}
+func (UnimplementedSuperheroServiceServer) StreamGroups(*ListGroupsRequest, SuperheroService_StreamGroupsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamGroups not implemented")
+}
+func (UnimplementedSuperheroServiceServer) mustEmbedUnimplementedSuperheroServiceServer() {}
+
+// UnsafeSuperheroServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SuperheroServiceServer will
+// result in compilation errors.
+type UnsafeSuperheroServiceServer interface {
+	mustEmbedUnimplementedSuperheroServiceServer()
+}
+
+func RegisterSuperheroServiceServer(s grpc.ServiceRegistrar, srv SuperheroServiceServer) {
+	s.RegisterService(&SuperheroService_ServiceDesc, srv)
+}
+
+func _SuperheroService_CreateSuper_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSuperRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SuperheroServiceServer).CreateSuper(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/superhero.v1.SuperheroService/CreateSuper",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SuperheroServiceServer).CreateSuper(ctx, req.(*CreateSuperRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SuperheroService_GetSuper_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSuperRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SuperheroServiceServer).GetSuper(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/superhero.v1.SuperheroService/GetSuper",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SuperheroServiceServer).GetSuper(ctx, req.(*GetSuperRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SuperheroService_UpdateSuper_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateSuperRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SuperheroServiceServer).UpdateSuper(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/superhero.v1.SuperheroService/UpdateSuper",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SuperheroServiceServer).UpdateSuper(ctx, req.(*UpdateSuperRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SuperheroService_DeleteSuper_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSuperRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SuperheroServiceServer).DeleteSuper(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/superhero.v1.SuperheroService/DeleteSuper",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SuperheroServiceServer).DeleteSuper(ctx, req.(*DeleteSuperRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SuperheroService_ListSupers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSupersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SuperheroServiceServer).ListSupers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/superhero.v1.SuperheroService/ListSupers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SuperheroServiceServer).ListSupers(ctx, req.(*ListSupersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SuperheroService_StreamSupers_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListSupersRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SuperheroServiceServer).StreamSupers(m, &superheroServiceStreamSupersServer{stream})
+}
+
+type SuperheroService_StreamSupersServer interface {
+	Send(*Super) error
+	grpc.ServerStream
+}
+
+type superheroServiceStreamSupersServer struct {
+	grpc.ServerStream
+}
+
+func (x *superheroServiceStreamSupersServer) Send(m *Super) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SuperheroService_CreateGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SuperheroServiceServer).CreateGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/superhero.v1.SuperheroService/CreateGroup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SuperheroServiceServer).CreateGroup(ctx, req.(*CreateGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SuperheroService_GetGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SuperheroServiceServer).GetGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/superhero.v1.SuperheroService/GetGroup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SuperheroServiceServer).GetGroup(ctx, req.(*GetGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SuperheroService_UpdateGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SuperheroServiceServer).UpdateGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/superhero.v1.SuperheroService/UpdateGroup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SuperheroServiceServer).UpdateGroup(ctx, req.(*UpdateGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SuperheroService_DeleteGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SuperheroServiceServer).DeleteGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/superhero.v1.SuperheroService/DeleteGroup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SuperheroServiceServer).DeleteGroup(ctx, req.(*DeleteGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SuperheroService_ListGroups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListGroupsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SuperheroServiceServer).ListGroups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/superhero.v1.SuperheroService/ListGroups",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SuperheroServiceServer).ListGroups(ctx, req.(*ListGroupsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SuperheroService_StreamGroups_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListGroupsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SuperheroServiceServer).StreamGroups(m, &superheroServiceStreamGroupsServer{stream})
+}
+
+type SuperheroService_StreamGroupsServer interface {
+	Send(*Group) error
+	grpc.ServerStream
+}
+
+type superheroServiceStreamGroupsServer struct {
+	grpc.ServerStream
+}
+
+func (x *superheroServiceStreamGroupsServer) Send(m *Group) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// SuperheroService_ServiceDesc is the grpc.ServiceDesc for SuperheroService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SuperheroService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "superhero.v1.SuperheroService",
+	HandlerType: (*SuperheroServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateSuper",
+			Handler:    _SuperheroService_CreateSuper_Handler,
+		},
+		{
+			MethodName: "GetSuper",
+			Handler:    _SuperheroService_GetSuper_Handler,
+		},
+		{
+			MethodName: "UpdateSuper",
+			Handler:    _SuperheroService_UpdateSuper_Handler,
+		},
+		{
+			MethodName: "DeleteSuper",
+			Handler:    _SuperheroService_DeleteSuper_Handler,
+		},
+		{
+			MethodName: "ListSupers",
+			Handler:    _SuperheroService_ListSupers_Handler,
+		},
+		{
+			MethodName: "CreateGroup",
+			Handler:    _SuperheroService_CreateGroup_Handler,
+		},
+		{
+			MethodName: "GetGroup",
+			Handler:    _SuperheroService_GetGroup_Handler,
+		},
+		{
+			MethodName: "UpdateGroup",
+			Handler:    _SuperheroService_UpdateGroup_Handler,
+		},
+		{
+			MethodName: "DeleteGroup",
+			Handler:    _SuperheroService_DeleteGroup_Handler,
+		},
+		{
+			MethodName: "ListGroups",
+			Handler:    _SuperheroService_ListGroups_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamSupers",
+			Handler:       _SuperheroService_StreamSupers_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamGroups",
+			Handler:       _SuperheroService_StreamGroups_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "superhero.proto",
+}
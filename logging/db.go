@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+)
+
+// SlowQueryHook is a pg.QueryHook which logs (warn) the queries slower than SlowQueryThreshold,
+// with the request ID of their context. The parameters' values are not logged (they may be secrets)
+type SlowQueryHook struct{}
+
+// BeforeQuery does nothing (the query event has its start time)
+func (SlowQueryHook) BeforeQuery(ctx context.Context, event *pg.QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+// AfterQuery logs the query, if it was slow
+func (SlowQueryHook) AfterQuery(ctx context.Context, event *pg.QueryEvent) error {
+	threshold := SlowQueryThreshold()
+	duration := time.Since(event.StartTime)
+	if threshold <= 0 || duration < threshold {
+		return nil
+	}
+
+	query, err := event.UnformattedQuery()
+	if err != nil {
+		query = ""
+	}
+	Ctx(ctx).Warn().
+		Float64("duration_ms", float64(duration.Microseconds())/1000).
+		Str("query", query).
+		Msg("slow query")
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package logging is the structured logger of the application: JSON lines (eg: for a log collector) or pretty
+// lines for humans, in debug mode. The logger of a request or call (see Ctx) adds its request ID to every line
+package logging
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/tcarreira/superhero/config"
+)
+
+// RequestIDField is the field of the request ID in every log line of a request (or gRPC call)
+const RequestIDField = "request_id"
+
+// logger is the logger of the application (JSON on stderr, until Setup)
+var logger = zerolog.New(os.Stderr).With().Timestamp().Logger()
+
+// slowQuery is the threshold of SlowQueryHook, in nanoseconds (0: no query is logged)
+var slowQuery int64
+
+// Setup configures the logger: level, format and the slow query threshold.
+// The standard library logger (eg: of dependencies) is written to it as well
+func Setup(cfg config.Log) {
+	SetupWriter(cfg, os.Stderr)
+}
+
+// SetupWriter is Setup, logging to w instead of stderr
+func SetupWriter(cfg config.Log, w io.Writer) {
+	level, err := zerolog.ParseLevel(cfg.Level)
+	if err != nil {
+		level = zerolog.InfoLevel
+	}
+
+	out := w
+	if cfg.Pretty() {
+		out = zerolog.ConsoleWriter{Out: w, TimeFormat: "15:04:05.000"}
+	}
+	logger = zerolog.New(out).Level(level).With().Timestamp().Logger()
+	atomic.StoreInt64(&slowQuery, int64(cfg.SlowQuery.Duration))
+
+	log.SetFlags(0)
+	log.SetOutput(Writer(zerolog.InfoLevel))
+}
+
+// Logger is the logger of the application, for what is not about a request (eg: startup, workers)
+func Logger() *zerolog.Logger {
+	return &logger
+}
+
+// Ctx is the logger of the request (or gRPC call) of ctx, with its request ID. Otherwise, it is Logger()
+func Ctx(ctx context.Context) *zerolog.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerKey{}).(*zerolog.Logger); ok {
+			return l
+		}
+	}
+	return &logger
+}
+
+type loggerKey struct{}
+
+// WithRequestID is ctx with a logger which adds requestID to every line (see Ctx)
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	l := logger.With().Str(RequestIDField, requestID).Logger()
+	return context.WithValue(ctx, loggerKey{}, &l)
+}
+
+// Writer is an io.Writer which logs every write as a line at level (eg: for the standard library logger)
+func Writer(level zerolog.Level) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		logger.WithLevel(level).Msg(strings.TrimRight(string(p), "\n"))
+		return len(p), nil
+	})
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+// secretHeaders are the headers whose values are never logged
+var secretHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+}
+
+// RedactHeaders are the headers to be logged, with the values of the secret ones (eg: Authorization) redacted
+func RedactHeaders(header http.Header) map[string]string {
+	redacted := make(map[string]string, len(header))
+	for name, values := range header {
+		if secretHeaders[http.CanonicalHeaderKey(name)] {
+			redacted[name] = config.Redacted
+		} else {
+			redacted[name] = strings.Join(values, ", ")
+		}
+	}
+	return redacted
+}
+
+// SlowQueryThreshold is the duration above which queries are logged (0: none)
+func SlowQueryThreshold() time.Duration {
+	return time.Duration(atomic.LoadInt64(&slowQuery))
+}
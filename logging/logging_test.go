@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tcarreira/superhero/config"
+)
+
+// setupBuffer logs (JSON) to a buffer, for the duration of the test
+func setupBuffer(t *testing.T, cfg config.Log) *bytes.Buffer {
+	var buf bytes.Buffer
+	SetupWriter(cfg, &buf)
+	t.Cleanup(func() {
+		SetupWriter(config.Default().Log, os.Stderr)
+	})
+	return &buf
+}
+
+// lines are the JSON log lines of buf
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var result []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var line map[string]interface{}
+		require.NoError(t, dec.Decode(&line))
+		result = append(result, line)
+	}
+	return result
+}
+
+func TestRequestID(t *testing.T) {
+	buf := setupBuffer(t, config.Log{Level: config.LevelInfo, Format: config.FormatJSON})
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	Ctx(ctx).Info().Msg("in a request")
+	Ctx(context.Background()).Info().Msg("not in a request")
+	Ctx(ctx).Debug().Msg("below the level")
+
+	logged := lines(t, buf)
+	require.Len(t, logged, 2)
+	assert.Equal(t, "req-1", logged[0][RequestIDField])
+	assert.Equal(t, "in a request", logged[0]["message"])
+	assert.NotContains(t, logged[1], RequestIDField)
+}
+
+func TestPretty(t *testing.T) {
+	buf := setupBuffer(t, config.Log{Level: config.LevelDebug})
+
+	Logger().Debug().Str("key", "value").Msg("for humans")
+
+	assert.Contains(t, buf.String(), "for humans")
+	assert.Contains(t, buf.String(), "key=")
+	assert.False(t, json.Valid(buf.Bytes()))
+}
+
+func TestStandardLogger(t *testing.T) {
+	buf := setupBuffer(t, config.Log{Level: config.LevelInfo, Format: config.FormatJSON})
+
+	log.Println("from a dependency")
+
+	logged := lines(t, buf)
+	require.Len(t, logged, 1)
+	assert.Equal(t, "from a dependency", logged[0]["message"])
+	assert.Equal(t, zerolog.InfoLevel.String(), logged[0]["level"])
+}
+
+func TestRedactHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret")
+	header.Set("X-API-Key", "secret")
+	header.Set("Cookie", "session=secret")
+	header.Add("Accept", "text/html")
+	header.Add("Accept", "application/json")
+
+	redacted := RedactHeaders(header)
+
+	assert.Equal(t, config.Redacted, redacted["Authorization"])
+	assert.Equal(t, config.Redacted, redacted["X-Api-Key"])
+	assert.Equal(t, config.Redacted, redacted["Cookie"])
+	assert.Equal(t, "text/html, application/json", redacted["Accept"])
+}
+
+func TestSlowQueryHook(t *testing.T) {
+	buf := setupBuffer(t, config.Log{Level: config.LevelInfo, Format: config.FormatJSON, SlowQuery: config.Duration{Duration: 100 * time.Millisecond}})
+	ctx := WithRequestID(context.Background(), "req-1")
+	hook := SlowQueryHook{}
+
+	fast := &pg.QueryEvent{StartTime: time.Now(), Query: "SELECT 1"}
+	slow := &pg.QueryEvent{StartTime: time.Now().Add(-time.Second), Query: "SELECT ?", Params: []interface{}{"secret"}}
+	assert.NoError(t, hook.AfterQuery(ctx, fast))
+	assert.NoError(t, hook.AfterQuery(ctx, slow))
+
+	logged := lines(t, buf)
+	require.Len(t, logged, 1)
+	assert.Equal(t, "slow query", logged[0]["message"])
+	assert.Equal(t, "SELECT ?", logged[0]["query"], "parameters are not logged")
+	assert.Equal(t, "req-1", logged[0][RequestIDField])
+	assert.GreaterOrEqual(t, logged[0]["duration_ms"], 1000.0)
+
+	t.Run("disabled", func(t *testing.T) {
+		setupBuffer(t, config.Log{Level: config.LevelInfo, SlowQuery: config.Duration{}})
+		assert.NoError(t, hook.AfterQuery(ctx, slow))
+		assert.Equal(t, time.Duration(0), SlowQueryThreshold())
+	})
+}
@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// queryOperations are the operations (first keyword of a query) with their own label. The others are "OTHER"
+var queryOperations = map[string]bool{
+	"SELECT": true,
+	"INSERT": true,
+	"UPDATE": true,
+	"DELETE": true,
+	"BEGIN":  true,
+	"COMMIT": true,
+}
+
+// queryOperation is the operation of a query, as labelled in DBQueryDuration
+func queryOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "OTHER"
+	}
+	operation := strings.ToUpper(fields[0])
+	if !queryOperations[operation] {
+		return "OTHER"
+	}
+	return operation
+}
+
+// QueryHook is a pg.QueryHook which observes the duration of every query in DBQueryDuration
+type QueryHook struct{}
+
+// BeforeQuery does nothing (the query event has its start time)
+func (QueryHook) BeforeQuery(ctx context.Context, event *pg.QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+// AfterQuery observes the duration of the query
+func (QueryHook) AfterQuery(ctx context.Context, event *pg.QueryEvent) error {
+	// the unformatted query is enough for the operation, and does not format the params
+	query, err := event.UnformattedQuery()
+	if err != nil {
+		query = ""
+	}
+
+	result := "ok"
+	if event.Err != nil && event.Err != pg.ErrNoRows {
+		result = "error"
+	}
+
+	DBQueryDuration.WithLabelValues(queryOperation(query), result).Observe(time.Since(event.StartTime).Seconds())
+	return nil
+}
+
+// poolCollector collects the connection pool stats of the observed database (see ObserveDB)
+type poolCollector struct {
+	mu sync.Mutex
+	db *pg.DB
+
+	hits, misses, timeouts, staleConns *prometheus.Desc
+	totalConns, idleConns              *prometheus.Desc
+}
+
+var pool = newPoolCollector()
+
+func newPoolCollector() *poolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	return &poolCollector{
+		hits:       desc("hits_total", "Times a free connection was found in the pool."),
+		misses:     desc("misses_total", "Times a free connection was not found in the pool."),
+		timeouts:   desc("timeouts_total", "Times a wait for a connection timed out."),
+		staleConns: desc("stale_connections_total", "Stale connections removed from the pool."),
+		totalConns: desc("connections", "Connections in the pool."),
+		idleConns:  desc("idle_connections", "Idle connections in the pool."),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (p *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{p.hits, p.misses, p.timeouts, p.staleConns, p.totalConns, p.idleConns} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector. There are no pool metrics until a database is observed
+func (p *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	p.mu.Lock()
+	db := p.db
+	p.mu.Unlock()
+	if db == nil {
+		return
+	}
+
+	stats := db.PoolStats()
+	ch <- prometheus.MustNewConstMetric(p.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(p.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(p.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(p.staleConns, prometheus.CounterValue, float64(stats.StaleConns))
+	ch <- prometheus.MustNewConstMetric(p.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(p.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+}
+
+// ObserveDB instruments db: the duration of its queries and its connection pool stats.
+// Only the pool of the last observed database is collected
+func ObserveDB(db *pg.DB) {
+	db.AddQueryHook(QueryHook{})
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.db = db
+}
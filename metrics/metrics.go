@@ -0,0 +1,107 @@
+// Package metrics exposes Prometheus metrics of the HTTP API, the database and the domain (Supers and Groups).
+// Every metric is registered in Registry, which is served by Handler (eg: @ /metrics)
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric name
+const namespace = "superhero"
+
+// Registry has every metric of the application (plus the Go runtime and process metrics)
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests counts the HTTP requests by method, route template (eg: /api/v1/supers/:id) and status code
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests, by method, route template and status code.",
+	}, []string{"method", "route", "code"})
+
+	// HTTPRequestDuration observes the latency of the HTTP requests by method and route template
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency, by method and route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// DBQueryDuration observes the duration of the database queries by operation (SELECT, INSERT, ...) and result
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Database query duration, by operation and result (ok or error).",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "result"})
+
+	// DBReads counts the reads routed to a read replica or to the primary (see models.ReplicaSet), by target
+	DBReads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "reads_total",
+		Help:      "Reads routed to a read replica or to the primary, by target.",
+	}, []string{"target"})
+
+	// DBReplicaHealthy tells whether a read replica is read from (1: answering and not lagging), by its address
+	DBReplicaHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "replica_healthy",
+		Help:      "Whether a read replica is read from (answering and not lagging), by address.",
+	}, []string{"replica"})
+
+	// CacheRequests counts the reads served by the cache (see models.Cache) or not, by kind (super, group or
+	// supers, a list) and result (hit or miss)
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Reads looked up in the cache, by kind and result (hit or miss).",
+	}, []string{"kind", "result"})
+
+	// SupersCreated counts the Supers created, by type
+	SupersCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "supers",
+		Name:      "created_total",
+		Help:      "Supers created, by type.",
+	}, []string{"type"})
+
+	// GroupMembershipFailures counts the Supers which could not be added to a Group (see models.ErrorGroupSuperRelation),
+	// by operation (create or update the Group)
+	GroupMembershipFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "groups",
+		Name:      "membership_failures_total",
+		Help:      "Supers which could not be added to a Group, by operation.",
+	}, []string{"operation"})
+)
+
+func init() {
+	Registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		DBQueryDuration,
+		DBReads,
+		DBReplicaHealthy,
+		CacheRequests,
+		SupersCreated,
+		GroupMembershipFailures,
+		pool,
+	)
+}
+
+// Handler serves the metrics in Registry, in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryOperation(t *testing.T) {
+	assert.Equal(t, "SELECT", queryOperation("SELECT 1"))
+	assert.Equal(t, "INSERT", queryOperation("  insert INTO supers VALUES (1)"))
+	assert.Equal(t, "OTHER", queryOperation("CREATE TABLE x ()"))
+	assert.Equal(t, "OTHER", queryOperation(""))
+}
+
+func TestQueryHook(t *testing.T) {
+	DBQueryDuration.Reset()
+
+	hook := QueryHook{}
+	for _, err := range []error{nil, pg.ErrNoRows, errors.New("connection refused")} {
+		event := &pg.QueryEvent{StartTime: time.Now(), Query: "SELECT 1", Err: err}
+		ctx, hookErr := hook.BeforeQuery(context.Background(), event)
+		assert.NoError(t, hookErr)
+		assert.NoError(t, hook.AfterQuery(ctx, event))
+	}
+
+	assert.Equal(t, 2, testutil.CollectAndCount(DBQueryDuration), "one histogram by operation and result")
+	assert.Equal(t, 2, histogramCount(t, "SELECT", "ok"), "no rows is not an error")
+	assert.Equal(t, 1, histogramCount(t, "SELECT", "error"))
+}
+
+// histogramCount is the number of queries observed in DBQueryDuration with operation and result
+func histogramCount(t *testing.T, operation, result string) int {
+	families, err := Registry.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "superhero_db_query_duration_seconds" {
+			continue
+		}
+		for _, m := range family.Metric {
+			labels := map[string]string{}
+			for _, l := range m.Label {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["operation"] == operation && labels["result"] == result {
+				return int(m.Histogram.GetSampleCount())
+			}
+		}
+	}
+	return 0
+}
+
+func TestHandler(t *testing.T) {
+	SupersCreated.WithLabelValues("HERO").Inc()
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `superhero_supers_created_total{type="HERO"}`)
+	assert.Contains(t, w.Body.String(), "go_goroutines")
+	assert.NotContains(t, w.Body.String(), "superhero_db_pool_connections", "no database is observed")
+}
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v9/orm"
+)
+
+// Audit actions
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionRevert  = "revert"
+	AuditActionPurge   = "purge"
+)
+
+// Audited entities
+const (
+	AuditEntitySuper = "super"
+	AuditEntityGroup = "group"
+)
+
+// AuditChange is the change of a single field
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// AuditEntry records a change to a Super or Group. The audit log is append only
+// swagger:model AuditEntry
+type AuditEntry struct {
+	tableName  struct{}               `json:"-" pg:"superhero_audit_log,alias:a"` // json tag for swaggo bug
+	ID         uint64                 `json:"id" pg:",pk"`
+	CreatedAt  time.Time              `json:"created_at" pg:",notnull,default:now()"`
+	Actor      string                 `json:"actor" example:"anonymous"` // X-Actor, advisory (not authenticated), or the subject of a verified client certificate
+	RequestID  string                 `json:"request_id" example:"5f0c2b9e1a7d4c3b"`
+	Action     string                 `json:"action" example:"update" enums:"create,update,delete,restore,revert,purge"`
+	Entity     string                 `json:"entity" example:"super" enums:"super,group"`
+	EntityID   string                 `json:"entity_id" example:"47c0df01-a47d-497f-808d-181021f01c76" pg:",notnull"`
+	EntityName string                 `json:"entity_name" example:"SuperHero1"`
+	Before     map[string]interface{} `json:"before"`
+	After      map[string]interface{} `json:"after"`
+	Diff       map[string]AuditChange `json:"diff"`
+}
+
+// auditable is implemented by every entity recorded in the audit log
+type auditable interface {
+	auditIdentity() (id string, name string)
+	auditSnapshot() map[string]interface{}
+}
+
+// auditIdentity identifies a Super by its UUID (names may change), named by its ref
+func (s *Super) auditIdentity() (string, string) {
+	return s.UUID, s.Ref()
+}
+
+// auditSnapshot is the Super as in JSON, without the fields computed from other entities
+func (s *Super) auditSnapshot() map[string]interface{} {
+	snapshot := jsonSnapshot(s)
+	delete(snapshot, "groups")
+	delete(snapshot, "relatives_count")
+	snapshot["version"] = s.Version
+	return snapshot
+}
+
+// auditIdentity identifies a Group by its ID (names may change)
+func (g *Group) auditIdentity() (string, string) {
+	return strconv.FormatUint(g.ID, 10), g.Name
+}
+
+// auditSnapshot is the Group as in JSON (with the list of Supers names)
+func (g *Group) auditSnapshot() map[string]interface{} {
+	snapshot := jsonSnapshot(g)
+	snapshot["version"] = g.Version
+	return snapshot
+}
+
+// jsonSnapshot converts v to a generic map, through its JSON representation
+func jsonSnapshot(v interface{}) map[string]interface{} {
+	snapshot := make(map[string]interface{})
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err) // our models can always be marshalled
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		panic(err)
+	}
+
+	return snapshot
+}
+
+// auditDiff returns every field which changed between before and after (both may be nil)
+func auditDiff(before, after map[string]interface{}) map[string]AuditChange {
+	diff := make(map[string]AuditChange)
+
+	for field, from := range before {
+		if to, ok := after[field]; !ok || !reflect.DeepEqual(from, to) {
+			diff[field] = AuditChange{From: from, To: after[field]}
+		}
+	}
+	for field, to := range after {
+		if _, ok := before[field]; !ok {
+			diff[field] = AuditChange{From: nil, To: to}
+		}
+	}
+
+	return diff
+}
+
+// recordAudit appends an entry to the audit log. db should be the transaction making the change,
+// so the entry is only kept if the change is committed. Actor and request ID come from db.Context()
+func recordAudit(db orm.DB, action, entity string, before, after auditable) error {
+	entry := AuditEntry{
+		Actor:     ActorFromContext(db.Context()),
+		RequestID: RequestIDFromContext(db.Context()),
+		Action:    action,
+		Entity:    entity,
+	}
+
+	if before != nil {
+		entry.EntityID, entry.EntityName = before.auditIdentity()
+		entry.Before = before.auditSnapshot()
+	}
+	if after != nil {
+		entry.EntityID, entry.EntityName = after.auditIdentity()
+		entry.After = after.auditSnapshot()
+	}
+	entry.Diff = auditDiff(entry.Before, entry.After)
+
+	return db.Insert(&entry)
+}
+
+// ReadPage reads at most limit entries (0 for no limit) of the audit log, skipping offset (oldest first), filtered by
+// Entity and EntityID, when not empty. EntityID matches either the entity id (Super UUID, Group ID) or its name at the
+// time of the change
+func (a *AuditEntry) ReadPage(db orm.DB, limit, offset int) ([]AuditEntry, error) {
+	db, span := traceORM(db, "AuditEntry.ReadPage")
+	defer span.End()
+
+	entries := make([]AuditEntry, 0)
+
+	q := db.Model(&entries)
+	if a.Entity != "" {
+		q = q.Where("a.entity = ?", strings.ToLower(a.Entity))
+	}
+	if a.EntityID != "" {
+		q = q.WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			return q.Where("upper(a.entity_id) = ?", strings.ToUpper(a.EntityID)).
+				WhereOr("a.entity_name = ?", a.EntityID), nil
+		})
+	}
+
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	if offset > 0 {
+		q = q.Offset(offset)
+	}
+
+	err := q.Order("a.id").Select()
+	return entries, err
+}
+
+// History reads the audit log (oldest first) of the Super (ref OR uuid) == idStr, including deleted Supers
+func (s *Super) History(db orm.DB, idStr string) ([]AuditEntry, error) {
+	db, span := traceORM(db, "Super.History")
+	defer span.End()
+
+	entries := make([]AuditEntry, 0)
+
+	uuids := db.Model((*Super)(nil)).
+		ColumnExpr("s.uuid::text").
+		AllWithDeleted().
+		WhereGroup(whereNameOrUUID(idStr))
+
+	err := db.Model(&entries).
+		Where("a.entity = ?", AuditEntitySuper).
+		WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			return q.Where("a.entity_id IN (?)", uuids).
+				// purged Supers can still be found by uuid
+				WhereOr("upper(a.entity_id) = ?", strings.ToUpper(idStr)), nil
+		}).
+		Order("a.id").
+		Select()
+	if err != nil {
+		return entries, err
+	}
+	if len(entries) == 0 {
+		return entries, &ErrorSuperNotFound{"Super Not Found"}
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,84 @@
+// +build sql
+
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLog(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+	ctx := WithRequestID(WithActor(context.Background(), "tester"), "request-1")
+	dCtx := d.WithContext(ctx)
+
+	super := Super{Type: "HERO", Name: "Thanos", UUID: "40000005-a47d-497f-808d-181021f01c76", Power: 90}
+	_, err := super.Create(dCtx)
+	assert.NoError(t, err)
+
+	update := Super{Type: "VILAN", Name: "Thanos", Power: 100}
+	_, err = update.UpdateByNameOrUUID(dCtx, "Thanos", AnyVersion)
+	assert.NoError(t, err)
+
+	assert.NoError(t, new(Super).DeleteByNameOrUUID(d, "Thanos"))
+
+	group := Group{Name: "g1"}
+	_, err = group.Create(dCtx)
+	assert.NoError(t, err)
+
+	t.Run("TestAuditLog - Super history", func(t *testing.T) {
+		entries, err := new(Super).History(d, "Thanos")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(entries))
+
+		assert.Equal(t, AuditActionCreate, entries[0].Action)
+		assert.Equal(t, "tester", entries[0].Actor)
+		assert.Equal(t, "request-1", entries[0].RequestID)
+		assert.Nil(t, entries[0].Before)
+
+		assert.Equal(t, AuditActionUpdate, entries[1].Action)
+		assert.Equal(t, AuditChange{From: "90", To: "100"}, entries[1].Diff["power"])
+		assert.Equal(t, AuditChange{From: "HERO", To: "VILLAIN"}, entries[1].Diff["type"])
+		assert.NotContains(t, entries[1].Diff, "name")
+
+		assert.Equal(t, AuditActionDelete, entries[2].Action)
+		assert.Equal(t, "", entries[2].Actor) // no actor in context
+		assert.Nil(t, entries[2].After)
+	})
+
+	t.Run("TestAuditLog - ReadPage filtered by entity", func(t *testing.T) {
+		entries, err := (&AuditEntry{Entity: AuditEntityGroup}).ReadPage(d, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(entries))
+		assert.Equal(t, "g1", entries[0].EntityName)
+
+		entries, err = (&AuditEntry{Entity: AuditEntitySuper, EntityID: "40000005-A47D-497F-808D-181021F01C76"}).ReadPage(d, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(entries))
+	})
+
+	t.Run("TestAuditLog - ReadPage", func(t *testing.T) {
+		filter := &AuditEntry{Entity: AuditEntitySuper, EntityID: "40000005-A47D-497F-808D-181021F01C76"}
+		entries, err := filter.ReadPage(d, 2, 1)
+		assert.NoError(t, err)
+		if assert.Equal(t, 2, len(entries)) {
+			assert.Equal(t, AuditActionUpdate, entries[0].Action)
+			assert.Equal(t, AuditActionDelete, entries[1].Action)
+		}
+	})
+
+	t.Run("TestAuditLog - history of unknown Super", func(t *testing.T) {
+		_, err := new(Super).History(d, "nobody")
+		assert.IsType(t, &ErrorSuperNotFound{}, err)
+	})
+
+	t.Run("TestAuditLog - append only", func(t *testing.T) {
+		_, err := d.Exec("DELETE FROM superhero_audit_log")
+		assert.Error(t, err)
+
+		_, err = d.Exec("UPDATE superhero_audit_log SET actor = 'someone else'")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditDiff(t *testing.T) {
+	before := map[string]interface{}{"name": "a", "power": "10", "occupation": "x"}
+	after := map[string]interface{}{"name": "a", "power": "100", "image_url": "url"}
+
+	assert.Equal(t,
+		map[string]AuditChange{
+			"power":      {From: "10", To: "100"},
+			"occupation": {From: "x", To: nil},
+			"image_url":  {From: nil, To: "url"},
+		},
+		auditDiff(before, after),
+	)
+
+	assert.Equal(t, map[string]AuditChange{"name": {From: nil, To: "a"}}, auditDiff(nil, map[string]interface{}{"name": "a"}))
+	assert.Equal(t, map[string]AuditChange{"name": {From: "a", To: nil}}, auditDiff(map[string]interface{}{"name": "a"}, nil))
+	assert.Equal(t, map[string]AuditChange{}, auditDiff(before, before))
+}
+
+func TestSuper_auditSnapshot(t *testing.T) {
+	super := Super{
+		UUID:           "47c0df01-a47d-497f-808d-181021f01c76",
+		Type:           "HERO",
+		Name:           "name1",
+		Power:          80,
+		Version:        3,
+		GroupsList:     []string{"g1"},
+		RelativesCount: 2,
+	}
+
+	snapshot := super.auditSnapshot()
+
+	assert.Equal(t, "name1", snapshot["name"])
+	assert.Equal(t, "80", snapshot["power"])
+	assert.EqualValues(t, 3, snapshot["version"])
+	assert.NotContains(t, snapshot, "groups")
+	assert.NotContains(t, snapshot, "relatives_count")
+
+	id, name := super.auditIdentity()
+	assert.Equal(t, "47c0df01-a47d-497f-808d-181021f01c76", id)
+	assert.Equal(t, "name1", name)
+}
+
+func TestGroup_auditSnapshot(t *testing.T) {
+	group := Group{ID: 7, Name: "group1", SupersList: []string{"s1", "s2"}, Version: 2}
+
+	snapshot := group.auditSnapshot()
+
+	assert.Equal(t, "group1", snapshot["name"])
+	assert.Equal(t, []interface{}{"s1", "s2"}, snapshot["supers"])
+	assert.EqualValues(t, 2, snapshot["version"])
+
+	id, name := group.auditIdentity()
+	assert.Equal(t, "7", id)
+	assert.Equal(t, "group1", name)
+}
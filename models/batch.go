@@ -0,0 +1,88 @@
+package models
+
+import (
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+)
+
+// Batched reads, for loading the relations of many Supers or Groups at once (eg: GraphQL dataloaders)
+
+// GroupsBySuperIDs gets the Groups of each Super (by Super ID), ordered by name
+func GroupsBySuperIDs(db orm.DB, ids []uint64) (map[uint64][]Group, error) {
+	db, span := traceORM(db, "GroupsBySuperIDs")
+	defer span.End()
+
+	result := make(map[uint64][]Group)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	links := make([]GroupSuper, 0)
+	err := db.Model(&links).Where("g2s.super_id IN (?)", pg.In(ids)).Select()
+	if err != nil || len(links) == 0 {
+		return result, err
+	}
+
+	groupIDs := make([]uint64, 0, len(links))
+	supersOf := make(map[uint64][]uint64) // Group ID -> Super IDs
+	for _, link := range links {
+		groupIDs = append(groupIDs, link.GroupID)
+		supersOf[link.GroupID] = append(supersOf[link.GroupID], link.SuperID)
+	}
+
+	groups := make([]Group, 0)
+	err = db.Model(&groups).Where("g.id IN (?)", pg.In(groupIDs)).Order("g.name").Select()
+	if err != nil {
+		return result, err
+	}
+
+	for _, group := range groups {
+		for _, superID := range supersOf[group.ID] {
+			result[superID] = append(result[superID], group)
+		}
+	}
+
+	return result, nil
+}
+
+// SupersByGroupIDs gets the (non deleted) Supers of each Group (by Group ID), with their relatives_count, ordered by name
+func SupersByGroupIDs(db orm.DB, ids []uint64) (map[uint64][]Super, error) {
+	db, span := traceORM(db, "SupersByGroupIDs")
+	defer span.End()
+
+	result := make(map[uint64][]Super)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	links := make([]GroupSuper, 0)
+	err := db.Model(&links).Where("g2s.group_id IN (?)", pg.In(ids)).Select()
+	if err != nil || len(links) == 0 {
+		return result, err
+	}
+
+	superIDs := make([]uint64, 0, len(links))
+	groupsOf := make(map[uint64][]uint64) // Super ID -> Group IDs
+	for _, link := range links {
+		superIDs = append(superIDs, link.SuperID)
+		groupsOf[link.SuperID] = append(groupsOf[link.SuperID], link.GroupID)
+	}
+
+	supers := make([]Super, 0)
+	err = db.Model(&supers).
+		Apply(withRelativesCount).
+		Where("s.id IN (?)", pg.In(superIDs)).
+		Order("s.name").
+		Select()
+	if err != nil {
+		return result, err
+	}
+
+	for _, super := range supers {
+		for _, groupID := range groupsOf[super.ID] {
+			result[groupID] = append(result[groupID], super)
+		}
+	}
+
+	return result, nil
+}
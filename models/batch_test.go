@@ -0,0 +1,47 @@
+// +build sql
+
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchReads(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	supers := []Super{
+		{Type: "HERO", Name: "b1"},
+		{Type: "HERO", Name: "b2"},
+		{Type: "VILAN", Name: "b3"},
+	}
+	for i := range supers {
+		_, err := supers[i].Create(d)
+		assert.NoError(t, err)
+	}
+	g1, err := (&Group{Name: "g1", Supers: supers[:2]}).Create(d)
+	assert.NoError(t, err)
+	g2, err := (&Group{Name: "g2", Supers: supers[1:]}).Create(d)
+	assert.NoError(t, err)
+
+	groups, err := GroupsBySuperIDs(d, []uint64{supers[0].ID, supers[1].ID, supers[2].ID})
+	assert.NoError(t, err)
+	assertGroups(t, []Group{{Name: "g1"}}, groups[supers[0].ID])
+	assertGroups(t, []Group{{Name: "g1"}, {Name: "g2"}}, groups[supers[1].ID])
+	assertGroups(t, []Group{{Name: "g2"}}, groups[supers[2].ID])
+
+	assert.NoError(t, new(Super).DeleteByNameOrUUID(d, "b3"))
+
+	members, err := SupersByGroupIDs(d, []uint64{g1.ID, g2.ID})
+	assert.NoError(t, err)
+	assertSupers(t, supers[:2], members[g1.ID])
+	assertSupers(t, supers[1:2], members[g2.ID]) // deleted Supers are not members
+
+	page := new(Super).ReadPage(d, false, 1, 1)
+	assertSupers(t, supers[1:2], page)
+
+	groupsPage, err := new(Group).ReadPage(d, 1, 0)
+	assert.NoError(t, err)
+	assertGroups(t, []Group{{Name: "g1"}}, groupsPage)
+}
@@ -0,0 +1,231 @@
+package models
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/tcarreira/superhero/cache"
+	"github.com/tcarreira/superhero/config"
+	"github.com/tcarreira/superhero/metrics"
+)
+
+// ReadCache caches the Supers (by name or uuid), the Groups (by name) and the lists of Supers (by filters) read
+// outside of transactions. Every committed write invalidates what it changed (see cacheTags), in this process:
+// other processes serving the same database see the change after the TTL, at most
+type ReadCache struct {
+	*cache.Cache
+	ttl     time.Duration
+	listTTL time.Duration
+}
+
+// Cache is the cache of the reads of the APIs: disabled, unless configured (see serve)
+var Cache = NewReadCache(config.Cache{Disabled: true})
+
+// cacheRetention is how long invalidations are remembered: longer than any read, plus the lag of the replicas
+const cacheRetention = 10 * time.Minute
+
+// Cache tags: every cached result is tagged with what it was read from
+const (
+	superListTag = "supers" // every list of Supers: invalidated by any write
+)
+
+func superTag(id uint64) string {
+	return "super:" + strconv.FormatUint(id, 10)
+}
+
+func groupTag(id uint64) string {
+	return "group:" + strconv.FormatUint(id, 10)
+}
+
+// NewReadCache creates the cache of the reads, as configured
+func NewReadCache(cfg config.Cache) *ReadCache {
+	size := cfg.Size
+	if cfg.Disabled {
+		size = 0
+	}
+	return &ReadCache{
+		Cache:   cache.New(size, cacheRetention),
+		ttl:     cfg.TTL.Duration,
+		listTTL: cfg.ListTTL.Duration,
+	}
+}
+
+const noCacheContextKey contextKey = "no_cache"
+
+// WithoutCache returns a context whose reads are not served by Cache (eg: the current Super, to update it).
+// Use it with db.WithContext(ctx)
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheContextKey, true)
+}
+
+// cacheable tells whether a read on db may be served by (and stored in) the cache: not in a transaction,
+// as it may see its own uncommitted writes
+func (rc *ReadCache) cacheable(db orm.DB) bool {
+	if !rc.Enabled() {
+		return false
+	}
+	pgDB, ok := db.(*pg.DB)
+	if !ok || pgDB == nil {
+		return false
+	}
+	if ctx := pgDB.Context(); ctx != nil {
+		noCache, _ := ctx.Value(noCacheContextKey).(bool)
+		return !noCache
+	}
+	return true
+}
+
+// get returns the cached result of key (for the metrics, kind is what it is)
+func (rc *ReadCache) get(kind, key string) (interface{}, bool) {
+	value, ok := rc.Get(key)
+	if ok {
+		metrics.CacheRequests.WithLabelValues(kind, "hit").Inc()
+	} else {
+		metrics.CacheRequests.WithLabelValues(kind, "miss").Inc()
+	}
+	return value, ok
+}
+
+// since is when a result read on db was (last) current, for Set: the replicas may have missed the writes
+// committed meanwhile
+func (rc *ReadCache) since(db orm.DB) time.Time {
+	now := rc.Now()
+	if pgDB, ok := db.(*pg.DB); ok && pgDB != nil && Replicas.isReplica(pgDB) {
+		return now.Add(-Replicas.ReadYourWritesWindow())
+	}
+	return now
+}
+
+// superKey is the key of a Super read by ref (or uuid)
+func superKey(idStr string) string {
+	return "super?id=" + url.QueryEscape(idStr)
+}
+
+// groupKey is the key of a Group read by name
+func groupKey(name string) string {
+	return "group?name=" + url.QueryEscape(name)
+}
+
+// superListKey is the key of a list of Supers: its filters, normalized as they are compared
+func superListKey(s *Super, deleted bool, limit, offset int) string {
+	filters := url.Values{}
+	if s.Type != "" {
+		filters.Set("type", strings.ToUpper(s.Type))
+	}
+	for _, t := range s.Types {
+		filters.Add("types", t)
+	}
+	sort.Strings(filters["types"])
+	if s.Universe != "" {
+		filters.Set("universe", strings.ToLower(s.Universe))
+	}
+	if s.Name != "" {
+		filters.Set("name", s.Name)
+	}
+	if s.UUID != "" {
+		filters.Set("uuid", strings.ToUpper(s.UUID))
+	}
+	for key, value := range map[string]string{
+		"publisher": s.Publisher, "alignment": s.Alignment, "gender": s.Gender, "race": s.Race,
+	} {
+		if value != "" {
+			filters.Set(key, strings.ToUpper(value))
+		}
+	}
+	for _, alias := range s.Aliases {
+		filters.Add("alias", strings.ToUpper(alias))
+	}
+	sort.Strings(filters["alias"])
+	for _, stat := range s.MinPowerstats.powerstats() {
+		if stat.value > 0 {
+			filters.Set("min_"+stat.name, strconv.FormatInt(stat.value, 10))
+		}
+	}
+	if deleted {
+		filters.Set("deleted", "true")
+	}
+	if limit > 0 {
+		filters.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		filters.Set("offset", strconv.Itoa(offset))
+	}
+	return "supers?" + filters.Encode() // sorted by filter
+}
+
+// superTags are what a Super was read from: itself and its Groups (their memberships set its relatives_count)
+func superTags(s *Super) []string {
+	tags := []string{superTag(s.ID)}
+	for _, group := range s.Groups {
+		tags = append(tags, groupTag(group.ID))
+	}
+	return tags
+}
+
+// cacheTags are the tags of the cached results changed by a write to entity (as it was before, or is after).
+// Every list of Supers, and:
+//   - a Super: itself, and its Groups (their Supers and relatives_count)
+//   - a Group: itself, and its Supers (their Groups and relatives_count)
+func cacheTags(tx *pg.Tx, entity auditable) ([]string, error) {
+	tags := []string{superListTag}
+	switch e := entity.(type) {
+	case *Super:
+		tags = append(tags, superTag(e.ID))
+		var groupIDs []uint64
+		err := tx.Model((*GroupSuper)(nil)).Column("group_id").Where("super_id = ?", e.ID).Select(&groupIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range groupIDs {
+			tags = append(tags, groupTag(id))
+		}
+	case *Group:
+		tags = append(tags, groupTag(e.ID))
+		var superIDs []uint64
+		err := tx.Model((*GroupSuper)(nil)).Column("super_id").Where("group_id = ?", e.ID).Select(&superIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, super := range e.Supers {
+			superIDs = append(superIDs, super.ID)
+		}
+		for _, id := range superIDs {
+			if id != 0 {
+				tags = append(tags, superTag(id))
+			}
+		}
+	}
+	return tags, nil
+}
+
+// clone copies the Super, so the cached one is not changed by who reads it
+func (s *Super) clone() *Super {
+	c := *s
+	c.Aliases = append(make([]string, 0, len(s.Aliases)), s.Aliases...)
+	c.Groups = append([]Group(nil), s.Groups...)
+	c.GroupsList = append(make([]string, 0, len(s.GroupsList)), s.GroupsList...)
+	return &c
+}
+
+// clone copies the Group, so the cached one is not changed by who reads it
+func (g *Group) clone() *Group {
+	c := *g
+	c.Supers = append([]Super(nil), g.Supers...)
+	c.SupersList = append(make([]string, 0, len(g.SupersList)), g.SupersList...)
+	return &c
+}
+
+func cloneSupers(supers []Super) []Super {
+	c := make([]Super, 0, len(supers))
+	for i := range supers {
+		c = append(c, *supers[i].clone())
+	}
+	return c
+}
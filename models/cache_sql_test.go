@@ -0,0 +1,86 @@
+// +build sql
+
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCacheInvalidation(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+	c := enableCache(t)
+
+	for _, name := range []string{"a", "b", "c"} {
+		_, err := (&Super{Type: "HERO", Name: name}).Create(d)
+		require.NoError(t, err)
+	}
+	_, err := (&Group{Name: "g", Supers: []Super{{Name: "a"}, {Name: "b"}}}).Create(d)
+	require.NoError(t, err)
+
+	getSuper := func(name string) *Super {
+		super, err := new(Super).GetByNameOrUUID(d, name)
+		require.NoError(t, err)
+		return super
+	}
+
+	a := getSuper("a")
+	assert.Equal(t, 1, a.RelativesCount)
+	hits := c.Stats().Hits
+	a.GroupsList[0] = "changed by the reader"
+	assert.Equal(t, []string{"g"}, getSuper("a").GroupsList, "cached, and not changed by the reader")
+	assert.Equal(t, hits+1, c.Stats().Hits)
+	getSuper("c")
+	assert.Len(t, new(Super).ReadAll(d), 3)
+	group, err := new(Group).GetByName(d, "g")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, group.SupersList)
+
+	t.Run("a member joins the Group", func(t *testing.T) {
+		_, err := (&Group{Name: "g", Supers: []Super{{Name: "a"}, {Name: "b"}, {Name: "c"}}}).UpdateByName(d, "g", AnyVersion)
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, getSuper("a").RelativesCount, "a Super already in the Group")
+		assert.Equal(t, []string{"g"}, getSuper("c").GroupsList, "the Super which joined")
+		group, err := new(Group).GetByName(d, "g")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, group.SupersList)
+	})
+
+	t.Run("a member is deleted", func(t *testing.T) {
+		getSuper("a")
+		require.NoError(t, new(Super).DeleteByNameOrUUID(d, "c"))
+
+		assert.Equal(t, 1, getSuper("a").RelativesCount, "relatives of the deleted Super")
+		assert.Len(t, new(Super).ReadAll(d), 2)
+		_, err := new(Super).GetByNameOrUUID(d, "c")
+		assert.IsType(t, &ErrorSuperNotFound{}, err)
+	})
+
+	t.Run("a member is renamed", func(t *testing.T) {
+		_, err := new(Group).GetByName(d, "g")
+		require.NoError(t, err)
+		_, err = (&Super{Type: "HERO", Name: "b2"}).UpdateByNameOrUUID(d, "b", AnyVersion)
+		require.NoError(t, err)
+
+		group, err := new(Group).GetByName(d, "g")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b2"}, group.SupersList)
+		_, err = new(Super).GetByNameOrUUID(d, "b")
+		assert.IsType(t, &ErrorSuperNotFound{}, err)
+	})
+
+	t.Run("the Group is deleted", func(t *testing.T) {
+		getSuper("a")
+		require.NoError(t, new(Group).DeleteByName(d, "g", AnyVersion))
+
+		assert.Equal(t, []string{}, getSuper("a").GroupsList)
+		assert.Equal(t, 0, getSuper("a").RelativesCount)
+		_, err := new(Group).GetByName(d, "g")
+		assert.IsType(t, &ErrorGroupNotFound{}, err)
+	})
+
+	assert.NotZero(t, c.Stats().Invalidations)
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/config"
+)
+
+// enableCache replaces Cache with an enabled one, until the test ends
+func enableCache(t *testing.T) *ReadCache {
+	previous := Cache
+	Cache = NewReadCache(config.Cache{Size: 100, TTL: config.Duration{Duration: time.Minute}, ListTTL: config.Duration{Duration: time.Minute}})
+	t.Cleanup(func() { Cache = previous })
+	return Cache
+}
+
+func TestReadCacheDisabled(t *testing.T) {
+	c := NewReadCache(config.Cache{Disabled: true, Size: 100})
+	assert.False(t, c.Enabled())
+	assert.False(t, c.cacheable(pg.Connect(&pg.Options{})))
+}
+
+func TestReadCacheCacheable(t *testing.T) {
+	c := enableCache(t)
+	db := pg.Connect(&pg.Options{})
+	defer db.Close()
+
+	assert.True(t, c.cacheable(db))
+	assert.True(t, c.cacheable(db.WithContext(context.Background())))
+	assert.False(t, c.cacheable(db.WithContext(WithoutCache(context.Background()))))
+	assert.False(t, c.cacheable(&pg.Tx{}), "in a transaction")
+	assert.False(t, c.cacheable((*pg.DB)(nil)))
+}
+
+func TestReadCacheSinceReplica(t *testing.T) {
+	c := enableCache(t)
+	primary := pg.Connect(&pg.Options{Addr: "primary:5432"})
+	replica := pg.Connect(&pg.Options{Addr: "replica:5432"})
+	defer primary.Close()
+
+	previous := Replicas
+	Replicas = NewReplicaSet([]*pg.DB{replica}, 5*time.Second, time.Second)
+	defer func() { Replicas = previous }()
+	defer Replicas.Close()
+
+	assert.WithinDuration(t, time.Now(), c.since(primary), time.Second)
+	assert.WithinDuration(t, time.Now().Add(-6*time.Second), c.since(replica.WithContext(context.Background())), time.Second,
+		"the replica may lag behind the primary")
+}
+
+func TestSuperListKey(t *testing.T) {
+	assert.Equal(t, "supers?", superListKey(&Super{}, false, 0, 0))
+	assert.Equal(t,
+		superListKey(&Super{Type: "HERO", UUID: "47C0DF01-A47D-497F-808D-181021F01C76"}, false, 10, 20),
+		superListKey(&Super{Type: "hero", UUID: "47c0df01-a47d-497f-808d-181021f01c76"}, false, 10, 20),
+		"type and uuid are compared ignoring case")
+	assert.NotEqual(t, superListKey(&Super{Name: "Batman"}, false, 0, 0), superListKey(&Super{Name: "batman"}, false, 0, 0))
+	assert.NotEqual(t, superListKey(&Super{}, false, 0, 0), superListKey(&Super{}, true, 0, 0))
+	assert.Equal(t, "supers?deleted=true&limit=10&name=a%26b", superListKey(&Super{Name: "a&b"}, true, 10, 0))
+
+	assert.Equal(t,
+		superListKey(&Super{Publisher: "DC Comics", Alignment: "good", Aliases: []string{"Insider", "matches malone"}}, false, 0, 0),
+		superListKey(&Super{Publisher: "dc comics", Alignment: "GOOD", Aliases: []string{"Matches Malone", "insider"}}, false, 0, 0),
+		"the profile is compared ignoring case, and the aliases in any order")
+	assert.Equal(t, "supers?min_combat=50&min_strength=10",
+		superListKey(&Super{MinPowerstats: PowerstatsFilter{Strength: 10, Combat: 50}}, false, 0, 0))
+	assert.Equal(t, "supers?types=ANTIHERO&types=HERO",
+		superListKey(&Super{Types: []string{"HERO", "ANTIHERO"}}, false, 0, 0), "the types are in any order")
+	assert.Equal(t,
+		superListKey(&Super{Universe: "Marvel"}, false, 0, 0), superListKey(&Super{Universe: "marvel"}, false, 0, 0),
+		"the universe is compared ignoring case")
+}
+
+func TestSuperTags(t *testing.T) {
+	super := Super{ID: 1, Groups: []Group{{ID: 2}, {ID: 3}}}
+	assert.Equal(t, []string{"super:1", "group:2", "group:3"}, superTags(&super))
+}
+
+func TestCloneSuper(t *testing.T) {
+	super := &Super{Name: "a", Aliases: []string{"x"}, Groups: []Group{{Name: "g"}}, GroupsList: []string{"g"}}
+	clone := super.clone()
+	clone.Name = "b"
+	clone.Aliases[0] = "y"
+	clone.Groups[0].Name = "h"
+	clone.GroupsList[0] = "h"
+
+	assert.Equal(t, "a", super.Name)
+	assert.Equal(t, []string{"x"}, super.Aliases)
+	assert.Equal(t, "g", super.Groups[0].Name)
+	assert.Equal(t, []string{"g"}, super.GroupsList)
+
+	assert.Equal(t, []string{}, (&Super{}).clone().GroupsList, "empty array instead of null")
+}
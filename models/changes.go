@@ -0,0 +1,142 @@
+package models
+
+import (
+	"context"
+
+	"github.com/go-pg/pg/v9"
+
+	"github.com/tcarreira/superhero/events"
+)
+
+// Events is where every committed change to Supers and Groups is published
+var Events = events.NewBus(events.DefaultReplaySize)
+
+// eventActions maps audit actions to the event type suffix
+var eventActions = map[string]string{
+	AuditActionCreate:  "created",
+	AuditActionUpdate:  "updated",
+	AuditActionDelete:  "deleted",
+	AuditActionRestore: "restored",
+	AuditActionRevert:  "reverted",
+	AuditActionPurge:   "purged",
+}
+
+// changeSet records the changes made by a transaction: the audit log and the outbox are written in the transaction,
+// while the events are only published to Events (and the cached reads invalidated) after it is committed
+type changeSet struct {
+	tx        *pg.Tx
+	events    []events.Event
+	cacheTags []string
+}
+
+// record a change to an entity. before is nil when creating, after is nil when deleting
+func (c *changeSet) record(action, entity string, before, after auditable) error {
+	if err := recordAudit(c.tx, action, entity, before, after); err != nil {
+		return err
+	}
+	for _, e := range []auditable{before, after} {
+		if e == nil {
+			continue
+		}
+		tags, err := cacheTags(c.tx, e)
+		if err != nil {
+			return err
+		}
+		c.cacheTags = append(c.cacheTags, tags...)
+	}
+	for _, e := range changeEvents(c.tx.Context(), action, entity, before, after) {
+		if err := c.tx.Insert(newOutboxEvent(e)); err != nil {
+			return err
+		}
+		c.events = append(c.events, e)
+	}
+	return nil
+}
+
+// runInTransaction runs fn in a transaction, publishing the changes it recorded once committed
+func runInTransaction(db *pg.DB, fn func(tx *pg.Tx, changes *changeSet) error) error {
+	changes := &changeSet{}
+
+	err := db.RunInTransaction(func(tx *pg.Tx) error {
+		changes.tx = tx
+		changes.events = nil
+		changes.cacheTags = nil
+		return fn(tx, changes)
+	})
+	if err != nil {
+		return err
+	}
+
+	Cache.Invalidate(changes.cacheTags...)
+
+	for _, e := range changes.events {
+		Events.Publish(e)
+	}
+	return nil
+}
+
+// changeEvents are the events describing a change: one for the entity and, for Groups,
+// one for each Super added to or removed from it
+func changeEvents(ctx context.Context, action, entity string, before, after auditable) []events.Event {
+	e := events.Event{
+		Type:      entity + "." + eventActions[action],
+		Entity:    entity,
+		Actor:     ActorFromContext(ctx),
+		RequestID: RequestIDFromContext(ctx),
+	}
+
+	var beforeMembers, afterMembers []string
+	if before != nil {
+		e.EntityID, _ = before.auditIdentity()
+		e.Data = before.auditSnapshot()
+		beforeMembers = groupMembers(before)
+	}
+	if after != nil {
+		e.EntityID, _ = after.auditIdentity()
+		e.Data = after.auditSnapshot()
+		afterMembers = groupMembers(after)
+	}
+	result := []events.Event{e}
+
+	group := e.Data["name"]
+	for _, name := range missingFrom(afterMembers, beforeMembers) {
+		m := e
+		m.Type = events.GroupMemberAdded
+		m.Data = map[string]interface{}{"group": group, "super": name}
+		result = append(result, m)
+	}
+	for _, name := range missingFrom(beforeMembers, afterMembers) {
+		m := e
+		m.Type = events.GroupMemberRemoved
+		m.Data = map[string]interface{}{"group": group, "super": name}
+		result = append(result, m)
+	}
+
+	return result
+}
+
+// groupMembers are the names of the Supers in a Group (none for other entities)
+func groupMembers(entity auditable) []string {
+	if g, ok := entity.(*Group); ok {
+		return g.SupersList
+	}
+	return nil
+}
+
+// missingFrom returns the values in list which are not in other
+func missingFrom(list, other []string) []string {
+	missing := make([]string, 0)
+	for _, value := range list {
+		found := false
+		for _, o := range other {
+			if o == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, value)
+		}
+	}
+	return missing
+}
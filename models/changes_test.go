@@ -0,0 +1,47 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/events"
+)
+
+func TestChangeEvents(t *testing.T) {
+	ctx := WithRequestID(WithActor(context.Background(), "someone"), "req1")
+
+	t.Run("super", func(t *testing.T) {
+		super := &Super{UUID: "47c0df01-a47d-497f-808d-181021f01c76", Name: "name1", Version: 2}
+
+		result := changeEvents(ctx, AuditActionDelete, AuditEntitySuper, super, nil)
+
+		if assert.Len(t, result, 1) {
+			assert.Equal(t, events.SuperDeleted, result[0].Type)
+			assert.Equal(t, AuditEntitySuper, result[0].Entity)
+			assert.Equal(t, super.UUID, result[0].EntityID)
+			assert.Equal(t, "someone", result[0].Actor)
+			assert.Equal(t, "req1", result[0].RequestID)
+			assert.Equal(t, "name1", result[0].Data["name"])
+		}
+	})
+
+	t.Run("group memberships", func(t *testing.T) {
+		before := &Group{ID: 7, Name: "group1", SupersList: []string{"a", "b"}}
+		after := &Group{ID: 7, Name: "group1", SupersList: []string{"b", "c"}}
+
+		result := changeEvents(ctx, AuditActionUpdate, AuditEntityGroup, before, after)
+
+		if assert.Len(t, result, 3) {
+			assert.Equal(t, events.GroupUpdated, result[0].Type)
+			assert.Equal(t, "7", result[0].EntityID)
+
+			assert.Equal(t, events.GroupMemberAdded, result[1].Type)
+			assert.Equal(t, map[string]interface{}{"group": "group1", "super": "c"}, result[1].Data)
+
+			assert.Equal(t, events.GroupMemberRemoved, result[2].Type)
+			assert.Equal(t, map[string]interface{}{"group": "group1", "super": "a"}, result[2].Data)
+		}
+	})
+}
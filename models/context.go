@@ -0,0 +1,34 @@
+package models
+
+import "context"
+
+type contextKey string
+
+const (
+	actorContextKey     contextKey = "actor"
+	requestIDContextKey contextKey = "request_id"
+)
+
+// WithActor returns a context which identifies who is making the changes (eg: for the audit log).
+// Use it with db.WithContext(ctx)
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey, actor)
+}
+
+// ActorFromContext returns who is making the changes ("" if unknown)
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorContextKey).(string)
+	return actor
+}
+
+// WithRequestID returns a context which carries the ID of the request causing the changes.
+// Use it with db.WithContext(ctx)
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+// RequestIDFromContext returns the ID of the request causing the changes ("" if unknown)
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey).(string)
+	return requestID
+}
@@ -1,42 +1,102 @@
 package models
 
 import (
-	"log"
+	"context"
+	"fmt"
 	"os"
 	"time"
 
 	"github.com/go-pg/pg/v9"
 	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/tcarreira/superhero/config"
+	"github.com/tcarreira/superhero/logging"
+	"github.com/tcarreira/superhero/metrics"
+	"github.com/tcarreira/superhero/tracing"
 )
 
-func getEnv(key, fallback string) string {
-	value, exists := os.LookupEnv(key)
-	if !exists {
-		value = fallback
+// SetupDatabase creates a DB connection (configured by the environment, see config.FromEnv) and waits for
+// availability, exiting if the database is not available. User is responsible for defer db.Close()
+func SetupDatabase() *pg.DB {
+	cfg, err := config.FromEnv()
+	if err != nil {
+		logging.Logger().Fatal().Err(err).Msg("Invalid configuration")
 	}
-	return value
+	db, err := OpenDatabase(cfg.Database)
+	if err != nil {
+		logging.Logger().Fatal().Err(err).Msg("Could not connect to the database")
+	}
+	return db
 }
 
-// SetupDatabase creates a DB connection and waits for availability. User is responsible for defer db.Close()
-func SetupDatabase() *pg.DB {
-	newDB := pg.Connect(&pg.Options{
-		Addr:     getEnv("DB_HOST", "localhost") + ":" + getEnv("DB_PORT", "5432"),
-		User:     getEnv("DB_USER", "postgres"),
-		Password: getEnv("DB_PASS", "password"),
-		Database: getEnv("DB_NAME", "postgres"),
-	})
-
-	// wait for database to be ready
-	maxTries := 30
-	for i := 0; i < maxTries; i++ {
-		_, err := newDB.Exec("SELECT 1")
-		if err != nil {
-			log.Println("Waiting for Database to be available #count", i, "/", maxTries)
-			time.Sleep(1 * time.Second)
-		}
+// OpenDatabase creates a DB connection (configured by cfg) and waits for availability, up to cfg.StartupTimeout.
+// User is responsible for defer db.Close()
+func OpenDatabase(cfg config.Database) (*pg.DB, error) {
+	opts, err := cfg.PGOptions()
+	if err != nil {
+		return nil, err
+	}
+	return ConnectDatabase(opts, cfg.StartupTimeout.Duration)
+}
+
+// ConnectDatabase creates a DB connection and waits for availability, up to timeout (0: a single attempt).
+// It is closed if the database is not available. User is responsible for defer db.Close()
+func ConnectDatabase(opts *pg.Options, timeout time.Duration) (*pg.DB, error) {
+	newDB := pg.Connect(opts)
+	metrics.ObserveDB(newDB)
+	newDB.AddQueryHook(tracing.QueryHook{})
+	newDB.AddQueryHook(logging.SlowQueryHook{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := waitForDatabase(ctx, newDB); err != nil {
+		newDB.Close()
+		return nil, fmt.Errorf("database %s is not available after %s: %v", opts.Addr, timeout, err)
 	}
 
-	return newDB
+	logging.Logger().Info().
+		Str("addr", opts.Addr).
+		Str("database", opts.Database).
+		Bool("tls", opts.TLSConfig != nil).
+		Msg("Connected to the database")
+	return newDB, nil
+}
+
+// Backoff between the attempts to connect to the database: doubled after every attempt, up to the maximum
+const (
+	minConnectBackoff = 100 * time.Millisecond
+	maxConnectBackoff = 5 * time.Second
+)
+
+// waitForDatabase tries a query, with exponential backoff, until it succeeds or ctx is done. Every attempt is
+// bounded by the dial and read timeouts of db. The error is the one of the last attempt
+func waitForDatabase(ctx context.Context, db *pg.DB) error {
+	backoff := minConnectBackoff
+	for attempt := 1; ; attempt++ {
+		_, err := db.Exec("SELECT 1")
+		if err == nil {
+			return nil
+		}
+
+		deadline, _ := ctx.Deadline()
+		if time.Until(deadline) < backoff {
+			return err
+		}
+		logging.Logger().Warn().Err(err).
+			Str("addr", db.Options().Addr).
+			Int("attempt", attempt).
+			Dur("retry_in", backoff).
+			Msg("Waiting for the database to be available")
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
 }
 
 // SetupEmptyTestDatabase should be used only by tests
@@ -55,19 +115,36 @@ func CreateSchema(db *pg.DB) {
 	if _, err := db.Exec("CREATE EXTENSION IF NOT EXISTS pgcrypto;"); err != nil {
 		panic(err)
 	}
+	// the types of the columns
+	if _, err := db.Exec(superTypeEnum); err != nil {
+		panic(err)
+	}
 
 	for _, model := range []interface{}{
 		(*Super)(nil),
 		(*Group)(nil),
 		(*GroupSuper)(nil),
+		(*SuperRelatives)(nil),
+		(*RelativesStale)(nil),
+		(*IdempotencyKey)(nil),
+		(*AuditEntry)(nil),
+		(*SuperVersion)(nil),
+		(*OutboxEvent)(nil),
+		(*OutboxDispatch)(nil),
+		(*Webhook)(nil),
+		(*WebhookDelivery)(nil),
+		(*WebhookAttempt)(nil),
 	} {
-		log.Printf("Creating table for %T\n", model)
+		logging.Logger().Info().Str("model", fmt.Sprintf("%T", model)).Msg("Creating table")
 		err := db.CreateTable(model, &orm.CreateTableOptions{IfNotExists: true})
 		if err != nil {
-			log.Println(err)
+			logging.Logger().Error().Err(err).Msg("Could not create the table")
 			os.Exit(2)
 		}
 	}
+
+	// tables are created in their latest form. Running the migrations will only register them
+	Migrate(db)
 }
 
 // DropSchema should be used only by tests
@@ -76,16 +153,34 @@ func DropSchema(db *pg.DB) {
 		(*Super)(nil),
 		(*Group)(nil),
 		(*GroupSuper)(nil),
+		(*SuperRelatives)(nil),
+		(*RelativesStale)(nil),
+		(*IdempotencyKey)(nil),
+		(*AuditEntry)(nil),
+		(*SuperVersion)(nil),
+		(*OutboxEvent)(nil),
+		(*OutboxDispatch)(nil),
+		(*Webhook)(nil),
+		(*WebhookDelivery)(nil),
+		(*WebhookAttempt)(nil),
+		(*SchemaMigration)(nil),
 	} {
 		err := db.DropTable(model, &orm.DropTableOptions{IfExists: true})
 		if err != nil {
-			log.Println(err)
+			logging.Logger().Error().Err(err).Msg("Could not drop the table")
 			os.Exit(2)
 		}
 	}
+	if _, err := db.Exec("DROP TYPE IF EXISTS superhero_super_type"); err != nil {
+		logging.Logger().Error().Err(err).Msg("Could not drop the type")
+		os.Exit(2)
+	}
 }
 
-// Migrate performs database migrations (not implemented)
+// Migrate performs pending database migrations. Intended to be called by an admin command
 func Migrate(db *pg.DB) {
-	log.Println("This will perform Database migrations")
+	if err := applyMigrations(db); err != nil {
+		logging.Logger().Error().Err(err).Msg("Could not migrate the database")
+		os.Exit(2)
+	}
 }
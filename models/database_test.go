@@ -3,7 +3,9 @@
 package models
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/go-pg/pg/v9"
 	"github.com/stretchr/testify/assert"
@@ -25,3 +27,33 @@ func TestDatabaseSimpleSelect(t *testing.T) {
 func TestDBCreateSchema(t *testing.T) {
 	_ = SetupEmptyTestDatabase()
 }
+
+func TestDBMigrate(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	version, err := SchemaVersion(d)
+	assert.NoError(t, err)
+	assert.Equal(t, LatestSchemaVersion(), version)
+
+	// migrating again is a no-op
+	Migrate(d)
+	version, err = SchemaVersion(d)
+	assert.NoError(t, err)
+	assert.Equal(t, LatestSchemaVersion(), version)
+}
+
+func TestReplicaSetCheckPrimary(t *testing.T) {
+	d := SetupDatabase()
+	defer d.Close()
+
+	// the primary is a replica which doesn't lag
+	rs := NewReplicaSet([]*pg.DB{d}, time.Second, time.Second)
+	rs.Check(context.Background())
+
+	status := rs.Status()
+	if assert.Len(t, status, 1) {
+		assert.True(t, status[0].Healthy, status[0].Error)
+		assert.Zero(t, status[0].LagSeconds)
+	}
+	assert.Same(t, d, rs.Reader(nil))
+}
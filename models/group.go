@@ -5,6 +5,9 @@ import (
 	"strings"
 
 	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/tcarreira/superhero/metrics"
 )
 
 // Group represents a group of supers
@@ -13,10 +16,11 @@ type Group struct {
 	ID         uint64   `json:"-" pg:",pk"`
 	Name       string   `json:"name" example:"group1" pg:",unique,notnull"`
 	Supers     []Super  `json:"-" pg:"many2many:superhero_group_supers,joinFK:super_id"`
-	SupersList []string `json:"supers,nilasempty" example:"name1" pg:"-" `
+	SupersList []string `json:"supers,nilasempty" example:"name1" pg:"-" ` // refs (see Super.Ref)
+	Version    int64    `json:"-" pg:",notnull,default:1"`
 }
 
-// UnmarshalJSON will instantiate a Group from a JSON, where Supers is a []string of Super names
+// UnmarshalJSON will instantiate a Group from a JSON, where Supers is a []string of Super refs (or uuids)
 func (g *Group) UnmarshalJSON(data []byte) error {
 	type Alias Group
 	aux := &struct {
@@ -74,56 +78,102 @@ func (e *ErrorGroupNotFound) Error() string {
 	return e.s
 }
 
-// Create a group with a list of Supers
-func (g *Group) Create(db *pg.DB) (*Group, error) {
-	if err := db.Insert(g); err != nil {
-		pgErr, ok := err.(pg.Error)
-		if ok {
-			if pgErr.IntegrityViolation() {
-				return g, &ErrorGroupAlreadyExists{err.Error()}
-			}
-		}
-		panic(err)
-	}
+// ErrorGroupVersionMismatch Group was changed meanwhile (version is not the expected) - extends error
+type ErrorGroupVersionMismatch struct {
+	s string
+}
 
-	g.SupersList = make([]string, 0) // empty array instead of null
+func (e *ErrorGroupVersionMismatch) Error() string {
+	return e.s
+}
 
+// resolveSupers gets the Supers by ref (or uuid). Errors are collected for the ones which can't be found
+func resolveSupers(db orm.DB, supers []Super) ([]*Super, []string) {
 	var minorErrors []string
-	for i := range g.Supers {
-		var err error
-		s := &(g.Supers[i])
+	resolved := make([]*Super, 0, len(supers))
 
-		// Get Super from DB
-		superName := s.Name // we need to keep the name in case of entity not found
-		s, err = s.GetByNameOrUUID(db, superName)
+	for i := range supers {
+		superName := supers[i].Name // we need to keep the name in case of entity not found
+		s, err := new(Super).GetByNameOrUUID(db, superName)
 		if err != nil {
 			minorErrors = append(minorErrors,
 				"(super:'"+superName+"') "+err.Error(),
 			)
-		} else {
-			if err = db.Insert(&GroupSuper{
-				GroupID: g.ID,
-				Group:   g,
-				SuperID: s.ID,
-				Super:   s,
-			}); err != nil {
-				minorErrors = append(minorErrors, err.Error())
-			} else {
-				// Really committed the transaction
-				g.SupersList = append(g.SupersList, s.Name)
+			continue
+		}
+		resolved = append(resolved, s)
+	}
+
+	return resolved, minorErrors
+}
+
+// insertGroupSupers adds the Supers to the Group (repeated ones are ignored) and fills g.SupersList
+func (g *Group) insertGroupSupers(tx *pg.Tx, supers []*Super) error {
+	g.SupersList = make([]string, 0) // empty array instead of null
+
+	for _, s := range supers {
+		res, err := tx.Model(&GroupSuper{GroupID: g.ID, SuperID: s.ID}).
+			OnConflict("DO NOTHING").
+			Insert()
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() > 0 {
+			g.SupersList = append(g.SupersList, s.Ref())
+		}
+	}
+
+	return nil
+}
+
+// Create a group with a list of Supers
+func (g *Group) Create(db *pg.DB) (*Group, error) {
+	db, span := traceDB(db, "Group.Create")
+	defer span.End()
+
+	// resolve the Supers before starting the transaction
+	supers, minorErrors := resolveSupers(db, g.Supers)
+
+	err := runInTransaction(db, func(tx *pg.Tx, changes *changeSet) error {
+		if err := tx.Insert(g); err != nil {
+			return err
+		}
+		if err := g.insertGroupSupers(tx, supers); err != nil {
+			return err
+		}
+		return changes.record(AuditActionCreate, AuditEntityGroup, nil, g)
+	})
+	if err != nil {
+		pgErr, ok := err.(pg.Error)
+		if ok {
+			if pgErr.IntegrityViolation() {
+				return g, &ErrorGroupAlreadyExists{err.Error()}
 			}
 		}
+		panic(err)
 	}
 
 	if len(minorErrors) > 0 {
+		metrics.GroupMembershipFailures.WithLabelValues("create").Add(float64(len(minorErrors)))
 		return g, &ErrorGroupSuperRelation{strings.Join(minorErrors, " | ")}
 	}
 
 	return g, nil
 }
 
-// GetByName gets a group by its name
-func (g *Group) GetByName(db *pg.DB, name string) (*Group, error) {
+// GetByName gets a group by its name (or from Cache)
+func (g *Group) GetByName(db orm.DB, name string) (*Group, error) {
+	cacheable := Cache.cacheable(db)
+	if cacheable {
+		if cached, ok := Cache.get("group", groupKey(name)); ok {
+			return cached.(*Group).clone(), nil
+		}
+	}
+	since := Cache.since(db)
+
+	db, span := traceORM(db, "Group.GetByName")
+	defer span.End()
+
 	group := Group{}
 
 	err := db.Model(&group).
@@ -138,17 +188,71 @@ func (g *Group) GetByName(db *pg.DB, name string) (*Group, error) {
 		return &group, err
 	}
 
-	// create the Super Names List as []string
+	// create the Super Refs List as []string
 	group.SupersList = make([]string, 0) // empty array instead of null
 	for _, super := range group.Supers {
-		group.SupersList = append(group.SupersList, super.Name)
+		group.SupersList = append(group.SupersList, super.Ref())
 	}
 
+	if cacheable {
+		Cache.Set(groupKey(name), group.clone(), Cache.ttl, since, groupTag(group.ID))
+	}
 	return &group, nil
 }
 
+// ReadPage gets at most limit Groups (0 for no limit), skipping offset, ordered by name. Their Supers are not loaded
+func (g *Group) ReadPage(db orm.DB, limit, offset int) ([]Group, error) {
+	db, span := traceORM(db, "Group.ReadPage")
+	defer span.End()
+
+	groups := make([]Group, 0)
+
+	q := db.Model(&groups).Order("g.name")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	if offset > 0 {
+		q = q.Offset(offset)
+	}
+
+	err := q.Select()
+	return groups, err
+}
+
+// ReadPageWithSupers is ReadPage, with the names of the (non deleted) Supers of each Group in SupersList
+func (g *Group) ReadPageWithSupers(db orm.DB, limit, offset int) ([]Group, error) {
+	db, span := traceORM(db, "Group.ReadPageWithSupers")
+	defer span.End()
+
+	groups, err := g.ReadPage(db, limit, offset)
+	if err != nil || len(groups) == 0 {
+		return groups, err
+	}
+
+	ids := make([]uint64, 0, len(groups))
+	for _, group := range groups {
+		ids = append(ids, group.ID)
+	}
+	supers, err := SupersByGroupIDs(db, ids)
+	if err != nil {
+		return groups, err
+	}
+
+	for i := range groups {
+		groups[i].SupersList = make([]string, 0, len(supers[groups[i].ID])) // empty array instead of null
+		for _, super := range supers[groups[i].ID] {
+			groups[i].SupersList = append(groups[i].SupersList, super.Ref())
+		}
+	}
+
+	return groups, nil
+}
+
 // GetAllBySuper gets a list of Groups which Super is part of
-func (g *Group) GetAllBySuper(db *pg.DB, super Super) ([]Group, error) {
+func (g *Group) GetAllBySuper(db orm.DB, super Super) ([]Group, error) {
+	db, span := traceORM(db, "Group.GetAllBySuper")
+	defer span.End()
+
 	var results []Group
 
 	err := db.Model(&results).
@@ -164,3 +268,101 @@ func (g *Group) GetAllBySuper(db *pg.DB, super Super) ([]Group, error) {
 
 	return results, nil
 }
+
+// lockByName gets the Group (with its Supers), locking it until the transaction ends
+func (g *Group) lockByName(tx *pg.Tx, name string) (*Group, error) {
+	err := tx.Model((*Group)(nil)).
+		Column("id").
+		Where("name = ?", name).
+		For("UPDATE").
+		Select(pg.Scan(new(uint64)))
+	if err != nil {
+		if err == pg.ErrNoRows {
+			return &Group{}, &ErrorGroupNotFound{err.Error()}
+		}
+		return &Group{}, err
+	}
+
+	return g.GetByName(tx, name)
+}
+
+// UpdateByName renames the Group and replaces its Supers with the ones in g.
+// The version is checked and incremented by the same UPDATE statement, unless version is AnyVersion
+func (g *Group) UpdateByName(db *pg.DB, name string, version int64) (*Group, error) {
+	db, span := traceDB(db, "Group.UpdateByName")
+	defer span.End()
+
+	// resolve the Supers before starting the transaction
+	supers, minorErrors := resolveSupers(db, g.Supers)
+
+	err := runInTransaction(db, func(tx *pg.Tx, changes *changeSet) error {
+		before, err := g.lockByName(tx, name)
+		if err != nil {
+			return err
+		}
+
+		q := tx.Model(g).
+			Set("name = ?name").
+			Set("version = g.version + 1").
+			Where("g.id = ?", before.ID)
+		if version != AnyVersion {
+			q = q.Where("g.version = ?", version)
+		}
+
+		res, err := q.Returning("*").Update()
+		if err != nil {
+			pgErr, ok := err.(pg.Error)
+			if ok && pgErr.IntegrityViolation() {
+				return &ErrorGroupAlreadyExists{err.Error()}
+			}
+			return err
+		}
+		if res.RowsAffected() < 1 {
+			return &ErrorGroupVersionMismatch{"Group was modified meanwhile - version mismatch"}
+		}
+
+		if _, err := tx.Model(&GroupSuper{}).Where("group_id = ?", g.ID).Delete(); err != nil {
+			return err
+		}
+		if err := g.insertGroupSupers(tx, supers); err != nil {
+			return err
+		}
+
+		return changes.record(AuditActionUpdate, AuditEntityGroup, before, g)
+	})
+	if err != nil {
+		return g, err
+	}
+
+	if len(minorErrors) > 0 {
+		metrics.GroupMembershipFailures.WithLabelValues("update").Add(float64(len(minorErrors)))
+		return g, &ErrorGroupSuperRelation{strings.Join(minorErrors, " | ")}
+	}
+
+	return g, nil
+}
+
+// DeleteByName deletes the Group (and its memberships), only if it is still at version
+func (g *Group) DeleteByName(db *pg.DB, name string, version int64) error {
+	db, span := traceDB(db, "Group.DeleteByName")
+	defer span.End()
+
+	return runInTransaction(db, func(tx *pg.Tx, changes *changeSet) error {
+		before, err := g.lockByName(tx, name)
+		if err != nil {
+			return err
+		}
+		if version != AnyVersion && version != before.Version {
+			return &ErrorGroupVersionMismatch{"Group was modified meanwhile - version mismatch"}
+		}
+
+		if _, err := tx.Model(&GroupSuper{}).Where("group_id = ?", before.ID).Delete(); err != nil {
+			return err
+		}
+		if _, err := tx.Model(before).WherePK().Delete(); err != nil {
+			return err
+		}
+
+		return changes.record(AuditActionDelete, AuditEntityGroup, before, nil)
+	})
+}
@@ -5,7 +5,10 @@ package models
 import (
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/metrics"
 )
 
 func assertSupers(t *testing.T, expected, actual []Super) {
@@ -79,6 +82,14 @@ func TestGroup_Create(t *testing.T) {
 		assert.Error(t, err)
 	})
 
+	t.Run("TestGroup_Create - unknown Supers are counted as membership failures", func(t *testing.T) {
+		failures := testutil.ToFloat64(metrics.GroupMembershipFailures.WithLabelValues("create"))
+
+		_, err = (&Group{Name: "group2", Supers: []Super{{Name: "t1"}, {Name: "nobody"}}}).Create(d)
+		assert.IsType(t, &ErrorGroupSuperRelation{}, err)
+		assert.Equal(t, failures+1, testutil.ToFloat64(metrics.GroupMembershipFailures.WithLabelValues("create")))
+	})
+
 }
 
 func TestGroup_GetByName(t *testing.T) {
@@ -196,3 +207,79 @@ func TestGroup_GetAllBySuper(t *testing.T) {
 	})
 
 }
+
+func TestGroup_UpdateByName(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	supers := []Super{
+		{Type: "HERO", Name: "s1"},
+		{Type: "HERO", Name: "s2"},
+	}
+	for i := range supers {
+		supers[i].Create(d)
+	}
+	group := Group{Name: "group1", Supers: supers[0:1]}
+	group.Create(d)
+
+	t.Run("TestGroup_UpdateByName - rename and replace supers", func(t *testing.T) {
+		update := Group{Name: "group1-renamed", Supers: []Super{{Name: "s2"}}}
+		got, err := update.UpdateByName(d, "group1", 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "group1-renamed", got.Name)
+		assert.Equal(t, []string{"s2"}, got.SupersList)
+		assert.EqualValues(t, 2, got.Version)
+
+		fromDB, err := new(Group).GetByName(d, "group1-renamed")
+		assert.NoError(t, err)
+		assertSupers(t, []Super{{Type: "HERO", Name: "s2"}}, fromDB.Supers)
+	})
+
+	t.Run("TestGroup_UpdateByName - stale version", func(t *testing.T) {
+		update := Group{Name: "group1-renamed"}
+		_, err := update.UpdateByName(d, "group1-renamed", 1)
+		assert.IsType(t, &ErrorGroupVersionMismatch{}, err)
+	})
+
+	t.Run("TestGroup_UpdateByName - unknown super", func(t *testing.T) {
+		update := Group{Name: "group1-renamed", Supers: []Super{{Name: "s1"}, {Name: "sX"}}}
+		got, err := update.UpdateByName(d, "group1-renamed", AnyVersion)
+		assert.IsType(t, &ErrorGroupSuperRelation{}, err)
+		assert.Equal(t, []string{"s1"}, got.SupersList)
+	})
+
+	t.Run("TestGroup_UpdateByName - not found", func(t *testing.T) {
+		update := Group{Name: "whatever"}
+		_, err := update.UpdateByName(d, "groupX", AnyVersion)
+		assert.IsType(t, &ErrorGroupNotFound{}, err)
+	})
+}
+
+func TestGroup_DeleteByName(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	super := Super{Type: "HERO", Name: "s1"}
+	super.Create(d)
+	group := Group{Name: "group1", Supers: []Super{super}}
+	group.Create(d)
+
+	t.Run("TestGroup_DeleteByName - stale version", func(t *testing.T) {
+		err := new(Group).DeleteByName(d, "group1", 2)
+		assert.IsType(t, &ErrorGroupVersionMismatch{}, err)
+	})
+
+	t.Run("TestGroup_DeleteByName - current version", func(t *testing.T) {
+		err := new(Group).DeleteByName(d, "group1", 1)
+		assert.NoError(t, err)
+
+		// the Super is kept, without groups
+		got, err := new(Super).GetByNameOrUUID(d, "s1")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{}, got.GroupsList)
+	})
+
+	t.Run("TestGroup_DeleteByName - not found", func(t *testing.T) {
+		err := new(Group).DeleteByName(d, "group1", AnyVersion)
+		assert.IsType(t, &ErrorGroupNotFound{}, err)
+	})
+}
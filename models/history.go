@@ -0,0 +1,157 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+)
+
+// SuperVersion is a version of a Super, valid from ValidFrom until ValidTo (zero while it is the current version).
+// Every write to a Super adds a new version (deleting it too)
+type SuperVersion struct {
+	tableName struct{}               `pg:"superhero_supers_history,alias:sh"`
+	SuperID   uint64                 `pg:",pk"`
+	Version   int64                  `pg:",pk"`
+	ValidFrom time.Time              `pg:",notnull,default:now()"`
+	ValidTo   time.Time              // NULL for the current version
+	Deleted   bool                   `pg:",notnull,use_zero"`
+	Data      map[string]interface{} `pg:",notnull"` // Super as in JSON (see Super.auditSnapshot)
+}
+
+// ErrorSuperVersionNotFound Super Version Not Found - extends error
+type ErrorSuperVersionNotFound struct {
+	s string
+}
+
+func (e *ErrorSuperVersionNotFound) Error() string {
+	return e.s
+}
+
+// recordSuperVersion closes the current version of the Super and adds s as the new one.
+// db should be the transaction writing the Super
+func recordSuperVersion(db orm.DB, s *Super) error {
+	_, err := db.Model((*SuperVersion)(nil)).
+		Set("valid_to = now()").
+		Where("super_id = ?", s.ID).
+		Where("valid_to IS NULL").
+		Update()
+	if err != nil {
+		return err
+	}
+
+	return db.Insert(&SuperVersion{
+		SuperID: s.ID,
+		Version: s.Version,
+		Deleted: !s.DeletedAt.IsZero(),
+		Data:    s.auditSnapshot(),
+	})
+}
+
+// super rebuilds the Super as it was at this version. Groups are not versioned (empty list)
+func (v *SuperVersion) super() (*Super, error) {
+	super := Super{}
+
+	data, err := json.Marshal(v.Data)
+	if err != nil {
+		return &super, err
+	}
+	if err := json.Unmarshal(data, &super); err != nil {
+		return &super, err
+	}
+
+	super.ID = v.SuperID
+	super.Version = v.Version
+	super.Type = NormalizeType(super.Type) // old versions may be VILAN
+	if super.Universe == "" {
+		super.Universe = DefaultUniverse // old versions have no universe
+	}
+	super.GroupsList = make([]string, 0) // empty array instead of null
+	super.Aliases = emptyIfNil(super.Aliases)
+
+	return &super, nil
+}
+
+// GetByNameOrUUIDAsOf gets the Super with (ref OR uuid) == idStr, as it was at asOf.
+// The ref is matched against the universe and name the Super had at asOf. Groups are not versioned (empty list)
+func (s *Super) GetByNameOrUUIDAsOf(db orm.DB, idStr string, asOf time.Time) (*Super, error) {
+	db, span := traceORM(db, "Super.GetByNameOrUUIDAsOf")
+	defer span.End()
+
+	version := SuperVersion{}
+	universe, name := splitSuperRef(idStr)
+
+	err := db.Model(&version).
+		WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			return q.WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+				// old versions have no universe
+				return q.Where("coalesce(sh.data->>'universe', ?) = ?", DefaultUniverse, universe).
+					Where("sh.data->>'name' = ?", name), nil
+			}).
+				WhereOr("upper(sh.data->>'uuid') = ?", strings.ToUpper(idStr)), nil
+		}).
+		Where("sh.valid_from <= ?", asOf).
+		WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			return q.Where("sh.valid_to IS NULL").
+				WhereOr("sh.valid_to > ?", asOf), nil
+		}).
+		Where("NOT sh.deleted").
+		Limit(1).
+		Select()
+	if err != nil {
+		if err == pg.ErrNoRows {
+			return &Super{}, &ErrorSuperNotFound{"Super Not Found at " + asOf.Format(time.RFC3339)}
+		}
+		return &Super{}, err
+	}
+
+	return version.super()
+}
+
+// RevertByNameOrUUID sets every editable field of the Super (ref OR uuid) == idStr back to how it was at toVersion.
+// The revert is a new version. The current version is checked, unless version is AnyVersion
+func (s *Super) RevertByNameOrUUID(db *pg.DB, idStr string, toVersion int64, version int64) (*Super, error) {
+	db, span := traceDB(db, "Super.RevertByNameOrUUID")
+	defer span.End()
+
+	reverted := &Super{}
+
+	err := runInTransaction(db, func(tx *pg.Tx, changes *changeSet) error {
+		before, err := s.lockByNameOrUUID(tx, idStr)
+		if err != nil {
+			return err
+		}
+
+		old := SuperVersion{}
+		err = tx.Model(&old).
+			Where("sh.super_id = ?", before.ID).
+			Where("sh.version = ?", toVersion).
+			Where("NOT sh.deleted").
+			Select()
+		if err != nil {
+			if err == pg.ErrNoRows {
+				return &ErrorSuperVersionNotFound{"Super has no such version to revert to"}
+			}
+			return err
+		}
+
+		reverted, err = old.super()
+		if err != nil {
+			return err
+		}
+		if err := reverted.update(tx, before.ID, version); err != nil {
+			return err
+		}
+		if err := recordSuperVersion(tx, reverted); err != nil {
+			return err
+		}
+		return changes.record(AuditActionRevert, AuditEntitySuper, before, reverted)
+	})
+	if err != nil {
+		return reverted, err
+	}
+
+	return reverted.GetByNameOrUUID(db, reverted.UUID)
+}
@@ -0,0 +1,87 @@
+// +build sql
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuper_History(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	super := Super{Type: "HERO", Name: "h1", Power: 10}
+	super.Create(d)
+	afterCreate := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	update := Super{Type: "HERO", Name: "h1-renamed", Power: 100}
+	update.UpdateByNameOrUUID(d, "h1", AnyVersion)
+	afterUpdate := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	t.Run("TestSuper_History - as of before the update", func(t *testing.T) {
+		got, err := new(Super).GetByNameOrUUIDAsOf(d, "h1", afterCreate)
+		assert.NoError(t, err)
+		assert.Equal(t, "h1", got.Name)
+		assert.EqualValues(t, 10, got.Power)
+		assert.EqualValues(t, 1, got.Version)
+		assert.Equal(t, super.UUID, got.UUID)
+
+		// the new name did not exist yet
+		_, err = new(Super).GetByNameOrUUIDAsOf(d, "h1-renamed", afterCreate)
+		assert.IsType(t, &ErrorSuperNotFound{}, err)
+	})
+
+	t.Run("TestSuper_History - as of after the update (by uuid)", func(t *testing.T) {
+		got, err := new(Super).GetByNameOrUUIDAsOf(d, super.UUID, afterUpdate)
+		assert.NoError(t, err)
+		assert.Equal(t, "h1-renamed", got.Name)
+		assert.EqualValues(t, 100, got.Power)
+		assert.EqualValues(t, 2, got.Version)
+	})
+
+	t.Run("TestSuper_History - as of before creation", func(t *testing.T) {
+		_, err := new(Super).GetByNameOrUUIDAsOf(d, super.UUID, afterCreate.Add(-time.Hour))
+		assert.IsType(t, &ErrorSuperNotFound{}, err)
+	})
+
+	t.Run("TestSuper_History - revert", func(t *testing.T) {
+		got, err := new(Super).RevertByNameOrUUID(d, "h1-renamed", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, "h1", got.Name)
+		assert.EqualValues(t, 10, got.Power)
+		assert.EqualValues(t, 3, got.Version)
+
+		entries, err := new(Super).History(d, super.UUID)
+		assert.NoError(t, err)
+		assert.Equal(t, AuditActionRevert, entries[len(entries)-1].Action)
+	})
+
+	t.Run("TestSuper_History - revert with stale version", func(t *testing.T) {
+		_, err := new(Super).RevertByNameOrUUID(d, "h1", 2, 2)
+		assert.IsType(t, &ErrorSuperVersionMismatch{}, err)
+	})
+
+	t.Run("TestSuper_History - revert to unknown version", func(t *testing.T) {
+		_, err := new(Super).RevertByNameOrUUID(d, "h1", 42, AnyVersion)
+		assert.IsType(t, &ErrorSuperVersionNotFound{}, err)
+	})
+
+	t.Run("TestSuper_History - deleted Super is not found as of now", func(t *testing.T) {
+		assert.NoError(t, new(Super).DeleteByNameOrUUID(d, "h1"))
+
+		_, err := new(Super).GetByNameOrUUIDAsOf(d, "h1", time.Now())
+		assert.IsType(t, &ErrorSuperNotFound{}, err)
+
+		got, err := new(Super).GetByNameOrUUIDAsOf(d, "h1", afterCreate)
+		assert.NoError(t, err)
+		assert.Equal(t, "h1", got.Name)
+
+		count, err := d.Model((*SuperVersion)(nil)).Where("super_id = ?", super.ID).Count()
+		assert.NoError(t, err)
+		assert.Equal(t, 4, count) // create, update, revert, delete
+	})
+}
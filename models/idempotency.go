@@ -0,0 +1,124 @@
+package models
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+)
+
+// IdempotencyKey stores the response for a request sent with an Idempotency-Key header,
+// so a retried request gets the original response instead of being processed again
+type IdempotencyKey struct {
+	tableName    struct{}    `pg:"superhero_idempotency_keys,alias:ik"`
+	Key          string      `pg:",pk"`
+	RequestHash  string      `pg:",notnull"`
+	StatusCode   int         `pg:",use_zero"` // 0 while the original request is still being processed
+	Header       http.Header `pg:"type:jsonb"`
+	ResponseBody []byte
+	CreatedAt    time.Time `pg:",notnull,default:now()"`
+	ExpiresAt    time.Time `pg:",notnull"`
+}
+
+// ErrorIdempotencyKeyNotFound Idempotency Key Not Found (or expired) - extends error
+type ErrorIdempotencyKeyNotFound struct {
+	s string
+}
+
+func (e *ErrorIdempotencyKeyNotFound) Error() string {
+	return e.s
+}
+
+// Completed tells if the response of the original request was already saved
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
+
+// Reserve saves the key as in-progress (valid for ttl). Returns false if the key is already taken.
+// An expired key is taken over, as if it never existed
+func (k *IdempotencyKey) Reserve(db *pg.DB, ttl time.Duration) (bool, error) {
+	db, span := traceDB(db, "IdempotencyKey.Reserve")
+	defer span.End()
+
+	k.StatusCode = 0
+	k.Header = nil
+	k.ResponseBody = nil
+	k.CreatedAt = time.Now()
+	k.ExpiresAt = k.CreatedAt.Add(ttl)
+
+	res, err := db.Model(k).
+		OnConflict("(key) DO UPDATE").
+		Set("request_hash = EXCLUDED.request_hash").
+		Set("status_code = EXCLUDED.status_code").
+		Set("header = EXCLUDED.header").
+		Set("response_body = EXCLUDED.response_body").
+		Set("created_at = EXCLUDED.created_at").
+		Set("expires_at = EXCLUDED.expires_at").
+		Where("ik.expires_at < now()").
+		Insert()
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+// GetByKey gets a non-expired Idempotency Key
+func (k *IdempotencyKey) GetByKey(db *pg.DB, key string) (*IdempotencyKey, error) {
+	db, span := traceDB(db, "IdempotencyKey.GetByKey")
+	defer span.End()
+
+	idempotencyKey := IdempotencyKey{}
+
+	err := db.Model(&idempotencyKey).
+		Where("key = ?", key).
+		Where("expires_at >= now()").
+		Select()
+	if err != nil {
+		if err == pg.ErrNoRows {
+			return &idempotencyKey, &ErrorIdempotencyKeyNotFound{err.Error()}
+		}
+		return &idempotencyKey, err
+	}
+
+	return &idempotencyKey, nil
+}
+
+// SaveResponse stores the response of the original request, to be replayed on retries
+func (k *IdempotencyKey) SaveResponse(db *pg.DB, statusCode int, header http.Header, body []byte) error {
+	db, span := traceDB(db, "IdempotencyKey.SaveResponse")
+	defer span.End()
+
+	k.StatusCode = statusCode
+	k.Header = header
+	k.ResponseBody = body
+
+	_, err := db.Model(k).
+		Column("status_code", "header", "response_body").
+		WherePK().
+		Update()
+	return err
+}
+
+// Release deletes a reserved key (eg: the request failed), so it can be retried
+func (k *IdempotencyKey) Release(db *pg.DB) error {
+	db, span := traceDB(db, "IdempotencyKey.Release")
+	defer span.End()
+
+	_, err := db.Model(k).WherePK().Delete()
+	return err
+}
+
+// PurgeExpiredIdempotencyKeys deletes every expired Idempotency Key
+func PurgeExpiredIdempotencyKeys(db *pg.DB) (int, error) {
+	db, span := traceDB(db, "PurgeExpiredIdempotencyKeys")
+	defer span.End()
+
+	res, err := db.Model((*IdempotencyKey)(nil)).
+		Where("expires_at < now()").
+		Delete()
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
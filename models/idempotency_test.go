@@ -0,0 +1,74 @@
+// +build sql
+
+package models
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	key := &IdempotencyKey{Key: "key1", RequestHash: "hash1"}
+
+	t.Run("TestIdempotencyKey - reserve a new key", func(t *testing.T) {
+		reserved, err := key.Reserve(d, time.Hour)
+		assert.NoError(t, err)
+		assert.True(t, reserved)
+
+		got, err := new(IdempotencyKey).GetByKey(d, "key1")
+		assert.NoError(t, err)
+		assert.Equal(t, "hash1", got.RequestHash)
+		assert.False(t, got.Completed())
+	})
+
+	t.Run("TestIdempotencyKey - reserve a taken key", func(t *testing.T) {
+		other := &IdempotencyKey{Key: "key1", RequestHash: "hash2"}
+		reserved, err := other.Reserve(d, time.Hour)
+		assert.NoError(t, err)
+		assert.False(t, reserved)
+	})
+
+	t.Run("TestIdempotencyKey - save response", func(t *testing.T) {
+		header := http.Header{"Content-Type": []string{"application/json"}}
+		err := key.SaveResponse(d, http.StatusCreated, header, []byte(`{"name":"a"}`))
+		assert.NoError(t, err)
+
+		got, err := new(IdempotencyKey).GetByKey(d, "key1")
+		assert.NoError(t, err)
+		assert.True(t, got.Completed())
+		assert.Equal(t, http.StatusCreated, got.StatusCode)
+		assert.Equal(t, header, got.Header)
+		assert.Equal(t, `{"name":"a"}`, string(got.ResponseBody))
+	})
+
+	t.Run("TestIdempotencyKey - release", func(t *testing.T) {
+		assert.NoError(t, key.Release(d))
+
+		_, err := new(IdempotencyKey).GetByKey(d, "key1")
+		assert.IsType(t, &ErrorIdempotencyKeyNotFound{}, err)
+	})
+
+	t.Run("TestIdempotencyKey - expired key is taken over", func(t *testing.T) {
+		expired := &IdempotencyKey{Key: "key2", RequestHash: "hash1"}
+		reserved, err := expired.Reserve(d, -time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, reserved)
+
+		_, err = new(IdempotencyKey).GetByKey(d, "key2")
+		assert.IsType(t, &ErrorIdempotencyKeyNotFound{}, err)
+
+		other := &IdempotencyKey{Key: "key2", RequestHash: "hash2"}
+		reserved, err = other.Reserve(d, time.Hour)
+		assert.NoError(t, err)
+		assert.True(t, reserved)
+
+		purged, err := PurgeExpiredIdempotencyKeys(d)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, purged)
+	})
+}
@@ -0,0 +1,251 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/tcarreira/superhero/logging"
+)
+
+// SchemaMigration records a migration which was already applied to the database
+type SchemaMigration struct {
+	tableName   struct{}  `pg:"superhero_schema_migrations"`
+	Version     int       `pg:",pk"`
+	Description string    `pg:",notnull"`
+	AppliedAt   time.Time `pg:",notnull,default:now()"`
+}
+
+// migration is a single schema change. Every migration must be idempotent,
+// because CreateSchema creates the tables already in their latest form and
+// then runs every migration anyway (eg: ADD COLUMN IF NOT EXISTS)
+type migration struct {
+	version     int
+	description string
+	up          func(tx *pg.Tx) error
+}
+
+// execStatements returns a migration function which executes every statement in order
+func execStatements(statements ...string) func(tx *pg.Tx) error {
+	return func(tx *pg.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// createTables returns a migration function which creates the tables for models (if not exist)
+func createTables(models ...interface{}) func(tx *pg.Tx) error {
+	return func(tx *pg.Tx) error {
+		for _, model := range models {
+			err := tx.CreateTable(model, &orm.CreateTableOptions{IfNotExists: true})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// migrations is the ordered list of every schema change. Append only!
+var migrations = []migration{
+	{
+		version:     1,
+		description: "add version column to supers and groups (optimistic concurrency)",
+		up: execStatements(
+			"ALTER TABLE superhero_supers ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1",
+			"ALTER TABLE superhero_groups ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1",
+		),
+	},
+	{
+		version:     2,
+		description: "create idempotency keys table",
+		up:          createTables((*IdempotencyKey)(nil)),
+	},
+	{
+		version:     3,
+		description: "soft delete supers (name is unique among non deleted supers)",
+		up: execStatements(
+			"ALTER TABLE superhero_supers ADD COLUMN IF NOT EXISTS deleted_at timestamptz",
+			"ALTER TABLE superhero_supers DROP CONSTRAINT IF EXISTS superhero_supers_name_key",
+			"CREATE UNIQUE INDEX IF NOT EXISTS superhero_supers_name_key ON superhero_supers (name) WHERE deleted_at IS NULL",
+		),
+	},
+	{
+		version:     4,
+		description: "create audit log table (append only)",
+		up: func(tx *pg.Tx) error {
+			if err := createTables((*AuditEntry)(nil))(tx); err != nil {
+				return err
+			}
+			return execStatements(
+				"CREATE INDEX IF NOT EXISTS superhero_audit_log_entity_idx ON superhero_audit_log (entity, entity_id)",
+				`CREATE OR REPLACE FUNCTION superhero_audit_log_append_only() RETURNS trigger AS $$
+				BEGIN
+					RAISE EXCEPTION 'superhero_audit_log is append only';
+				END;
+				$$ LANGUAGE plpgsql`,
+				"DROP TRIGGER IF EXISTS superhero_audit_log_append_only ON superhero_audit_log",
+				`CREATE TRIGGER superhero_audit_log_append_only BEFORE UPDATE OR DELETE ON superhero_audit_log
+				FOR EACH ROW EXECUTE PROCEDURE superhero_audit_log_append_only()`,
+			)(tx)
+		},
+	},
+	{
+		version:     5,
+		description: "create supers history table (current rows are their first version)",
+		up: func(tx *pg.Tx) error {
+			if err := createTables((*SuperVersion)(nil))(tx); err != nil {
+				return err
+			}
+			return execStatements(
+				"CREATE INDEX IF NOT EXISTS superhero_supers_history_valid_idx ON superhero_supers_history (valid_from, valid_to)",
+				`INSERT INTO superhero_supers_history (super_id, version, valid_from, deleted, data)
+				SELECT s.id, s.version, coalesce(s.deleted_at, now()), s.deleted_at IS NOT NULL, jsonb_build_object(
+					'uuid', s.uuid, 'type', s.type, 'name', s.name, 'fullname', s.full_name,
+					'intelligence', s.intelligence::text, 'power', s.power::text,
+					'occupation', s.occupation, 'image_url', s.image_url, 'version', s.version
+				)
+				FROM superhero_supers AS s
+				ON CONFLICT DO NOTHING`,
+			)(tx)
+		},
+	},
+	{
+		version:     6,
+		description: "create outbox and webhooks tables",
+		up: func(tx *pg.Tx) error {
+			err := createTables(
+				(*OutboxEvent)(nil),
+				(*OutboxDispatch)(nil),
+				(*Webhook)(nil),
+				(*WebhookDelivery)(nil),
+				(*WebhookAttempt)(nil),
+			)(tx)
+			if err != nil {
+				return err
+			}
+			return execStatements(
+				"CREATE INDEX IF NOT EXISTS superhero_outbox_created_at_idx ON superhero_outbox (created_at)",
+				"CREATE INDEX IF NOT EXISTS superhero_webhook_deliveries_due_idx ON superhero_webhook_deliveries (status, next_attempt_at)",
+				"CREATE INDEX IF NOT EXISTS superhero_webhook_deliveries_webhook_idx ON superhero_webhook_deliveries (webhook_id, id)",
+				"CREATE INDEX IF NOT EXISTS superhero_webhook_attempts_delivery_idx ON superhero_webhook_attempts (delivery_id)",
+			)(tx)
+		},
+	},
+	{
+		version:     7,
+		description: "precompute relatives_count of supers (maintained by triggers)",
+		up: func(tx *pg.Tx) error {
+			if err := createTables((*SuperRelatives)(nil), (*RelativesStale)(nil))(tx); err != nil {
+				return err
+			}
+			statements := append([]string{
+				// the memberships of a Super (the primary key, by group_id first, serves those of a Group)
+				"CREATE INDEX IF NOT EXISTS superhero_group_supers_super_idx ON superhero_group_supers (super_id)",
+			}, relativesTriggers...)
+			statements = append(statements, "SELECT superhero_recount_relatives(NULL)")
+			return execStatements(statements...)(tx)
+		},
+	},
+	{
+		version:     8,
+		description: "add the powerstats and profile of the SuperHeroAPI to supers",
+		up: execStatements(
+			`ALTER TABLE superhero_supers
+				ADD COLUMN IF NOT EXISTS strength bigint,
+				ADD COLUMN IF NOT EXISTS speed bigint,
+				ADD COLUMN IF NOT EXISTS durability bigint,
+				ADD COLUMN IF NOT EXISTS combat bigint,
+				ADD COLUMN IF NOT EXISTS aliases text[],
+				ADD COLUMN IF NOT EXISTS publisher text,
+				ADD COLUMN IF NOT EXISTS alignment text,
+				ADD COLUMN IF NOT EXISTS first_appearance text,
+				ADD COLUMN IF NOT EXISTS gender text,
+				ADD COLUMN IF NOT EXISTS race text,
+				ADD COLUMN IF NOT EXISTS height_cm bigint,
+				ADD COLUMN IF NOT EXISTS weight_kg bigint,
+				ADD COLUMN IF NOT EXISTS base text,
+				ADD COLUMN IF NOT EXISTS group_affiliation text,
+				ADD COLUMN IF NOT EXISTS relatives text`,
+			"CREATE INDEX IF NOT EXISTS superhero_supers_publisher_idx ON superhero_supers (upper(publisher))",
+		),
+	},
+	{
+		version:     9,
+		description: "store the type of supers as an enum (VILAN is VILLAIN), adding NEUTRAL and ANTIHERO",
+		up: execStatements(
+			superTypeEnum,
+			`ALTER TABLE superhero_supers ALTER COLUMN type TYPE superhero_super_type
+				USING (CASE upper(type::text) WHEN 'VILAN' THEN 'VILLAIN' ELSE upper(type::text) END)::superhero_super_type`,
+		),
+	},
+	{
+		version:     10,
+		description: "scope the names of supers by universe (existing supers are in the default one)",
+		up: execStatements(
+			"ALTER TABLE superhero_supers ADD COLUMN IF NOT EXISTS universe text NOT NULL DEFAULT '"+DefaultUniverse+"'",
+			"DROP INDEX IF EXISTS superhero_supers_name_key",
+			// also serves the universe filter
+			"CREATE UNIQUE INDEX IF NOT EXISTS superhero_supers_universe_name_key ON superhero_supers (universe, name) WHERE deleted_at IS NULL",
+		),
+	},
+	{
+		version:     11,
+		description: "store the API version of webhooks (existing webhooks keep the v1 types)",
+		up: execStatements(
+			"ALTER TABLE superhero_webhooks ADD COLUMN IF NOT EXISTS api_version text NOT NULL DEFAULT '" + APIVersion1 + "'",
+		),
+	},
+}
+
+// LatestSchemaVersion is the schema version expected by this build
+func LatestSchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].version
+}
+
+// SchemaVersion returns the last migration applied to the database
+func SchemaVersion(db *pg.DB) (int, error) {
+	var version int
+	_, err := db.QueryOne(pg.Scan(&version), "SELECT coalesce(max(version), 0) FROM superhero_schema_migrations")
+	return version, err
+}
+
+// applyMigrations runs every pending migration, each one in its own transaction
+func applyMigrations(db *pg.DB) error {
+	err := db.CreateTable((*SchemaMigration)(nil), &orm.CreateTableOptions{IfNotExists: true})
+	if err != nil {
+		return err
+	}
+
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		logging.Logger().Info().Int("version", m.version).Str("description", m.description).Msg("Applying migration")
+		err := db.RunInTransaction(func(tx *pg.Tx) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			return tx.Insert(&SchemaMigration{Version: m.version, Description: m.description})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v9"
+
+	"github.com/tcarreira/superhero/events"
+)
+
+// OutboxEvent is an event written in the same transaction as the change it describes (transactional outbox).
+// Consumers (eg: webhooks) read it after the commit, so nothing is ever sent for a rolled back change
+type OutboxEvent struct {
+	tableName struct{}  `pg:"superhero_outbox,alias:ob"`
+	ID        uint64    `pg:",pk"`
+	CreatedAt time.Time `pg:",notnull,default:now()"`
+	Type      string    `pg:",notnull"`
+	Entity    string    `pg:",notnull"`
+	EntityID  string    `pg:",notnull"`
+	Actor     string
+	RequestID string
+	Data      map[string]interface{} `pg:",notnull"`
+}
+
+// OutboxDispatch marks an OutboxEvent as processed by a consumer
+type OutboxDispatch struct {
+	tableName    struct{}  `pg:"superhero_outbox_dispatches,alias:obd"`
+	OutboxID     uint64    `pg:",pk"`
+	Consumer     string    `pg:",pk"`
+	DispatchedAt time.Time `pg:",notnull,default:now()"`
+}
+
+// newOutboxEvent creates the outbox entry for an event
+func newOutboxEvent(e events.Event) *OutboxEvent {
+	return &OutboxEvent{
+		Type:      e.Type,
+		Entity:    e.Entity,
+		EntityID:  e.EntityID,
+		Actor:     e.Actor,
+		RequestID: e.RequestID,
+		Data:      e.Data,
+	}
+}
+
+// Event converts it back to an event. The outbox ID is the event ID.
+// Its types are stored ones (v2): consumers send it as the API version they were written for (see EventAs)
+func (o *OutboxEvent) Event() events.Event {
+	return events.Event{
+		ID:        o.ID,
+		Type:      o.Type,
+		Entity:    o.Entity,
+		EntityID:  o.EntityID,
+		Time:      o.CreatedAt,
+		Actor:     o.Actor,
+		RequestID: o.RequestID,
+		Data:      o.Data,
+	}
+}
+
+// ProcessOutbox claims up to limit events not yet processed by consumer (oldest first) and calls handle with them.
+// Claiming and handle run in the same transaction: if handle fails, the events are claimed again later.
+// Concurrent calls for the same consumer never get the same events. Returns how many were processed
+func ProcessOutbox(db *pg.DB, consumer string, limit int, handle func(tx *pg.Tx, events []OutboxEvent) error) (int, error) {
+	db, span := traceDB(db, "ProcessOutbox")
+	defer span.End()
+
+	claimed := make([]OutboxEvent, 0)
+
+	err := db.RunInTransaction(func(tx *pg.Tx) error {
+		claimed = claimed[:0]
+
+		_, err := tx.Query(&claimed, `
+			WITH claimed AS (
+				INSERT INTO superhero_outbox_dispatches (outbox_id, consumer)
+				SELECT ob.id, ?0 FROM superhero_outbox AS ob
+				WHERE NOT EXISTS (
+					SELECT 1 FROM superhero_outbox_dispatches AS obd
+					WHERE obd.outbox_id = ob.id AND obd.consumer = ?0
+				)
+				ORDER BY ob.id
+				LIMIT ?1
+				ON CONFLICT DO NOTHING
+				RETURNING outbox_id
+			)
+			SELECT ob.* FROM superhero_outbox AS ob
+			JOIN claimed ON claimed.outbox_id = ob.id
+			ORDER BY ob.id`,
+			consumer, limit)
+		if err != nil {
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+
+		return handle(tx, claimed)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(claimed), nil
+}
+
+// PurgeOutbox deletes the outbox events (and their dispatches) created before olderThan
+func PurgeOutbox(db *pg.DB, olderThan time.Time) (int, error) {
+	db, span := traceDB(db, "PurgeOutbox")
+	defer span.End()
+
+	purged := 0
+
+	err := db.RunInTransaction(func(tx *pg.Tx) error {
+		old := tx.Model((*OutboxEvent)(nil)).
+			Column("ob.id").
+			Where("ob.created_at < ?", olderThan)
+
+		if _, err := tx.Model((*OutboxDispatch)(nil)).Where("outbox_id IN (?)", old).Delete(); err != nil {
+			return err
+		}
+
+		res, err := tx.Model((*OutboxEvent)(nil)).Where("created_at < ?", olderThan).Delete()
+		if err != nil {
+			return err
+		}
+		purged = res.RowsAffected()
+		return nil
+	})
+
+	return purged, err
+}
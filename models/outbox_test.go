@@ -0,0 +1,73 @@
+// +build sql
+
+package models
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/events"
+)
+
+func TestProcessOutbox(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	read := func(consumer string) []OutboxEvent {
+		var result []OutboxEvent
+		_, err := ProcessOutbox(d, consumer, 10, func(tx *pg.Tx, outbox []OutboxEvent) error {
+			result = outbox
+			return nil
+		})
+		assert.NoError(t, err)
+		return result
+	}
+
+	super := Super{Type: "HERO", Name: "outbox1"}
+	if _, err := super.Create(d); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("TestProcessOutbox - committed changes are in the outbox", func(t *testing.T) {
+		outbox := read("consumer1")
+
+		if assert.Len(t, outbox, 1) {
+			e := outbox[0].Event()
+			assert.Equal(t, events.SuperCreated, e.Type)
+			assert.Equal(t, super.UUID, e.EntityID)
+			assert.Equal(t, "outbox1", e.Data["name"])
+		}
+		assert.Empty(t, read("consumer1"))
+	})
+
+	t.Run("TestProcessOutbox - rolled back changes are not", func(t *testing.T) {
+		duplicate := Super{Type: "HERO", Name: "outbox1"}
+		_, err := duplicate.Create(d)
+		assert.IsType(t, &ErrorSuperAlreadyExists{}, err)
+
+		assert.Empty(t, read("consumer1"))
+	})
+
+	t.Run("TestProcessOutbox - each consumer reads every event", func(t *testing.T) {
+		assert.Len(t, read("consumer2"), 1)
+	})
+
+	t.Run("TestProcessOutbox - failed handling is retried", func(t *testing.T) {
+		group := Group{Name: "outbox-group", Supers: []Super{{Name: "outbox1"}}}
+		if _, err := group.Create(d); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := ProcessOutbox(d, "consumer1", 10, func(tx *pg.Tx, outbox []OutboxEvent) error {
+			return &ErrorWebhookNotFound{"failed"}
+		})
+		assert.Error(t, err)
+
+		outbox := read("consumer1")
+		if assert.Len(t, outbox, 2) {
+			assert.Equal(t, events.GroupCreated, outbox[0].Type)
+			assert.Equal(t, events.GroupMemberAdded, outbox[1].Type)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"github.com/go-pg/pg/v9"
+)
+
+// SuperRelatives is the precomputed relatives_count of a Super: how many other (non deleted) Supers are in
+// the same Groups. It is kept up to date by triggers (see migrations): the changes of group memberships, and
+// the Supers deleted or restored, mark Groups and Supers as stale (see RelativesStale), which are recounted
+// when their transaction commits. Supers without a row have no relatives
+type SuperRelatives struct {
+	tableName      struct{} `pg:"superhero_super_relatives"`
+	SuperID        uint64   `pg:",pk,type:bigint"` // not serial
+	RelativesCount int      `pg:",notnull,use_zero"`
+}
+
+// RelativesStale is a Group (its Supers) or a Super whose relatives_count must be recounted, on commit
+type RelativesStale struct {
+	tableName struct{} `pg:"superhero_relatives_stale"`
+	ID        uint64   `pg:",pk"`
+	GroupID   uint64
+	SuperID   uint64
+}
+
+// relativesTriggers maintain superhero_super_relatives (see SuperRelatives). Recounts are serialized by an
+// advisory lock, so each one sees the memberships committed by the previous ones
+var relativesTriggers = []string{
+	// recounts the Supers of ids (every Super, if NULL), removing the counts of the Supers which no longer exist
+	`CREATE OR REPLACE FUNCTION superhero_recount_relatives(ids bigint[]) RETURNS integer AS $$
+	DECLARE
+		counted integer;
+	BEGIN
+		INSERT INTO superhero_super_relatives (super_id, relatives_count)
+		SELECT s.id, count(DISTINCT relatives.id)
+		FROM superhero_supers AS s
+		LEFT JOIN superhero_group_supers AS s2g ON s.id = s2g.super_id
+		LEFT JOIN superhero_group_supers AS g2s ON s2g.group_id = g2s.group_id AND g2s.super_id != s.id
+		LEFT JOIN superhero_supers AS relatives ON g2s.super_id = relatives.id AND relatives.deleted_at IS NULL
+		WHERE ids IS NULL OR s.id = ANY(ids)
+		GROUP BY s.id
+		ON CONFLICT (super_id) DO UPDATE SET relatives_count = EXCLUDED.relatives_count;
+		GET DIAGNOSTICS counted = ROW_COUNT;
+
+		DELETE FROM superhero_super_relatives AS sr
+		WHERE (ids IS NULL OR sr.super_id = ANY(ids))
+			AND NOT EXISTS (SELECT 1 FROM superhero_supers AS s WHERE s.id = sr.super_id);
+		RETURN counted;
+	END;
+	$$ LANGUAGE plpgsql`,
+
+	// recounts the stale Supers (and the Supers of the stale Groups). Fired on commit by every stale mark:
+	// the first one recounts them all, the others find their mark gone (by primary key, as the table is
+	// full of the marks deleted by this transaction)
+	`CREATE OR REPLACE FUNCTION superhero_relatives_recount() RETURNS trigger AS $$
+	DECLARE
+		ids bigint[];
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM superhero_relatives_stale WHERE id = NEW.id) THEN
+			RETURN NULL;
+		END IF;
+		PERFORM pg_advisory_xact_lock(hashtext('superhero_super_relatives'));
+
+		WITH stale AS (
+			DELETE FROM superhero_relatives_stale RETURNING group_id, super_id
+		)
+		SELECT array_agg(affected.id) INTO ids FROM (
+			SELECT stale.super_id AS id FROM stale WHERE stale.super_id IS NOT NULL
+			UNION
+			SELECT gs.super_id FROM superhero_group_supers AS gs
+			WHERE gs.group_id IN (SELECT stale.group_id FROM stale)
+		) AS affected;
+
+		IF ids IS NOT NULL THEN
+			PERFORM superhero_recount_relatives(ids);
+		END IF;
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql`,
+	"DROP TRIGGER IF EXISTS superhero_relatives_recount ON superhero_relatives_stale",
+	`CREATE CONSTRAINT TRIGGER superhero_relatives_recount AFTER INSERT ON superhero_relatives_stale
+	DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE PROCEDURE superhero_relatives_recount()`,
+
+	// a membership changes the count of its Super and of the Supers of its Group
+	`CREATE OR REPLACE FUNCTION superhero_group_supers_stale() RETURNS trigger AS $$
+	BEGIN
+		IF TG_OP IN ('DELETE', 'UPDATE') THEN
+			INSERT INTO superhero_relatives_stale (group_id, super_id) VALUES (OLD.group_id, OLD.super_id);
+		END IF;
+		IF TG_OP IN ('INSERT', 'UPDATE') THEN
+			INSERT INTO superhero_relatives_stale (group_id, super_id) VALUES (NEW.group_id, NEW.super_id);
+		END IF;
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql`,
+	"DROP TRIGGER IF EXISTS superhero_group_supers_stale ON superhero_group_supers",
+	`CREATE TRIGGER superhero_group_supers_stale AFTER INSERT OR UPDATE OR DELETE ON superhero_group_supers
+	FOR EACH ROW EXECUTE PROCEDURE superhero_group_supers_stale()`,
+
+	// a Super deleted (or restored) changes the count of the Supers of its Groups
+	`CREATE OR REPLACE FUNCTION superhero_supers_stale() RETURNS trigger AS $$
+	BEGIN
+		INSERT INTO superhero_relatives_stale (group_id)
+		SELECT gs.group_id FROM superhero_group_supers AS gs WHERE gs.super_id = NEW.id;
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql`,
+	"DROP TRIGGER IF EXISTS superhero_supers_stale ON superhero_supers",
+	`CREATE TRIGGER superhero_supers_stale AFTER UPDATE OF deleted_at ON superhero_supers
+	FOR EACH ROW WHEN (OLD.deleted_at IS DISTINCT FROM NEW.deleted_at) EXECUTE PROCEDURE superhero_supers_stale()`,
+}
+
+// RecountRelatives rebuilds the relatives_count of every Super (eg: after changing the memberships with the
+// triggers disabled), returning how many Supers were counted
+func RecountRelatives(db *pg.DB) (int, error) {
+	db, span := traceDB(db, "RecountRelatives")
+	defer span.End()
+
+	var counted int
+	err := db.RunInTransaction(func(tx *pg.Tx) error {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('superhero_super_relatives'))"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM superhero_relatives_stale"); err != nil {
+			return err
+		}
+		_, err := tx.QueryOne(pg.Scan(&counted), "SELECT superhero_recount_relatives(NULL)")
+		return err
+	})
+	return counted, err
+}
@@ -0,0 +1,199 @@
+// +build sql
+
+package models
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// relativesOf is the relatives_count of every Super (by name), as read by the API
+func relativesOf(t *testing.T, db *pg.DB) map[string]int {
+	counts := make(map[string]int)
+	for _, super := range new(Super).ReadAll(db) {
+		counts[super.Name] = super.RelativesCount
+	}
+	for _, super := range new(Super).ReadAllDeleted(db) {
+		counts[super.Name] = super.RelativesCount
+	}
+	return counts
+}
+
+func TestRelativesCountMaintained(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	for _, name := range []string{"a", "b", "c", "d"} {
+		_, err := (&Super{Type: "HERO", Name: name}).Create(d)
+		require.NoError(t, err)
+	}
+	newGroup := func(name string, supers ...string) *Group {
+		g := &Group{Name: name}
+		for _, s := range supers {
+			g.Supers = append(g.Supers, Super{Name: s})
+		}
+		return g
+	}
+
+	_, err := newGroup("g1", "a", "b", "c").Create(d)
+	require.NoError(t, err)
+	_, err = newGroup("g2", "a", "d").Create(d)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 3, "b": 2, "c": 2, "d": 1}, relativesOf(t, d), "create")
+
+	_, err = newGroup("g1", "a", "b").UpdateByName(d, "g1", AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 2, "b": 1, "c": 0, "d": 1}, relativesOf(t, d), "update")
+
+	require.NoError(t, new(Super).DeleteByNameOrUUID(d, "b"))
+	assert.Equal(t, map[string]int{"a": 1, "b": 1, "c": 0, "d": 1}, relativesOf(t, d), "soft delete: b still counts a")
+
+	_, err = new(Super).RestoreByNameOrUUID(d, "b")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 2, "b": 1, "c": 0, "d": 1}, relativesOf(t, d), "restore")
+
+	require.NoError(t, new(Group).DeleteByName(d, "g2", AnyVersion))
+	assert.Equal(t, map[string]int{"a": 1, "b": 1, "c": 0, "d": 0}, relativesOf(t, d), "delete group")
+
+	require.NoError(t, new(Super).DeleteByNameOrUUID(d, "b"))
+	purged, err := PurgeDeletedSupers(d, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.Equal(t, map[string]int{"a": 0, "c": 0, "d": 0}, relativesOf(t, d), "purge")
+
+	var orphans int
+	_, err = d.QueryOne(pg.Scan(&orphans), "SELECT count(*) FROM superhero_super_relatives WHERE super_id NOT IN (SELECT id FROM superhero_supers)")
+	assert.NoError(t, err)
+	assert.Zero(t, orphans)
+}
+
+func TestRecountRelatives(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	for _, name := range []string{"a", "b"} {
+		_, err := (&Super{Type: "HERO", Name: name}).Create(d)
+		require.NoError(t, err)
+	}
+	_, err := (&Group{Name: "g", Supers: []Super{{Name: "a"}, {Name: "b"}}}).Create(d)
+	require.NoError(t, err)
+
+	_, err = d.Exec("UPDATE superhero_super_relatives SET relatives_count = 42")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 42, "b": 42}, relativesOf(t, d))
+
+	counted, err := RecountRelatives(d)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, counted)
+	assert.Equal(t, map[string]int{"a": 1, "b": 1}, relativesOf(t, d))
+}
+
+//    ____                  _                          _
+//   |  _ \                | |                        | |
+//   | |_) | ___ _ __   ___| |__  _ __ ___   __ _ _ __| | _____
+//   |  _ < / _ \ '_ \ / __| '_ \| '_ ` _ \ / _` | '__| |/ / __|
+//   | |_) |  __/ | | | (__| | | | | | | | | (_| | |  |   <\__ \
+//   |____/ \___|_| |_|\___|_| |_|_| |_| |_|\__,_|_|  |_|\_\___/
+//
+
+// Seeded dataset: benchSupers Supers, each one in benchGroupsPerSuper of the benchGroups Groups
+// (100k memberships, ~100 Supers per Group)
+const (
+	benchSupers         = 10000
+	benchGroups         = 1000
+	benchGroupsPerSuper = 10
+)
+
+var (
+	benchOnce sync.Once
+	benchDB   *pg.DB
+)
+
+// seededDatabase is the database of the benchmarks, seeded once (the memberships through the triggers)
+func seededDatabase(b *testing.B) *pg.DB {
+	benchOnce.Do(func() {
+		d := SetupEmptyTestDatabase()
+		statements := []string{
+			fmt.Sprintf(`INSERT INTO superhero_supers (type, name)
+				SELECT 'HERO', 'super' || i FROM generate_series(1, %d) AS i`, benchSupers),
+			fmt.Sprintf(`INSERT INTO superhero_groups (name)
+				SELECT 'group' || i FROM generate_series(1, %d) AS i`, benchGroups),
+			// distinct groups per Super (k * 101 differ modulo benchGroups), spread over the Groups
+			fmt.Sprintf(`INSERT INTO superhero_group_supers (group_id, super_id)
+				SELECT 1 + (s.id * 7 + k * 101) %% %d, s.id FROM superhero_supers AS s, generate_series(0, %d) AS k`,
+				benchGroups, benchGroupsPerSuper-1),
+			"ANALYZE",
+		}
+		for _, statement := range statements {
+			if _, err := d.Exec(statement); err != nil {
+				panic(err)
+			}
+		}
+		benchDB = d
+	})
+	return benchDB
+}
+
+// withRelativesCountJoin is how relatives_count was computed on every read, before SuperRelatives
+func withRelativesCountJoin(q *orm.Query) (*orm.Query, error) {
+	return q.
+		Column("s.*").ColumnExpr("count(distinct relatives.id) AS relatives_count").
+		Join("LEFT JOIN superhero_group_supers AS s2g ON s.id = s2g.super_id").
+		Join("LEFT JOIN superhero_group_supers AS g2s ON s2g.group_id = g2s.group_id").
+		Join("LEFT JOIN superhero_supers AS relatives ON g2s.super_id = relatives.id AND g2s.super_id != s.id AND relatives.deleted_at IS NULL").
+		Group("s.id"), nil
+}
+
+// benchmarkReadPage reads a page of limit Supers (0: all of them) with their relatives_count, as GET /supers
+func benchmarkReadPage(b *testing.B, relativesCount func(*orm.Query) (*orm.Query, error), limit int) {
+	d := seededDatabase(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		supers := make([]Super, 0)
+		q := d.Model(&supers).Apply(relativesCount).Order("s.id")
+		if limit > 0 {
+			q = q.Limit(limit)
+		}
+		if err := q.Select(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadPageJoin(b *testing.B)        { benchmarkReadPage(b, withRelativesCountJoin, 100) }
+func BenchmarkReadPagePrecomputed(b *testing.B) { benchmarkReadPage(b, withRelativesCount, 100) }
+func BenchmarkReadAllJoin(b *testing.B)         { benchmarkReadPage(b, withRelativesCountJoin, 0) }
+func BenchmarkReadAllPrecomputed(b *testing.B)  { benchmarkReadPage(b, withRelativesCount, 0) }
+
+// BenchmarkUpdateGroup replaces the Supers of a Group: the cost of maintaining relatives_count on writes
+func BenchmarkUpdateGroup(b *testing.B) {
+	d := seededDatabase(b)
+	groups := []*Group{{Name: "group1"}, {Name: "group1"}}
+	for i := 1; i <= 100; i++ {
+		groups[i%2].Supers = append(groups[i%2].Supers, Super{Name: fmt.Sprintf("super%d", i)})
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := groups[i%2].UpdateByName(d, "group1", AnyVersion); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRecountRelatives(b *testing.B) {
+	d := seededDatabase(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := RecountRelatives(d); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
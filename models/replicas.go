@@ -0,0 +1,214 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+
+	"github.com/tcarreira/superhero/logging"
+	"github.com/tcarreira/superhero/metrics"
+	"github.com/tcarreira/superhero/tracing"
+)
+
+// ReplicaSet routes the reads to the read replicas of the database: round-robin over the healthy ones
+// (answering, streaming from the primary and lagging at most maxLag behind it, when checked every interval),
+// or to the primary if none is healthy. Replicas are unhealthy until checked (see Check and Run)
+type ReplicaSet struct {
+	replicas []*replica
+	maxLag   time.Duration
+	interval time.Duration
+	next     uint32
+}
+
+// replica is a read replica and the result of its last check
+type replica struct {
+	db      *pg.DB
+	healthy int32 // atomic: 1 if healthy
+
+	mu     sync.Mutex
+	status ReplicaStatus
+}
+
+// ReplicaStatus is the result of the last check of a read replica
+type ReplicaStatus struct {
+	Addr       string    `json:"addr"`
+	Healthy    bool      `json:"healthy"`
+	LagSeconds float64   `json:"lag_seconds"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// Replicas are the read replicas of the database used by the APIs: none, unless configured (see serve)
+var Replicas = NewReplicaSet(nil, 0, 0)
+
+// NewReplicaSet routes the reads to dbs, while they lag at most maxLag (checked every interval, see Run)
+func NewReplicaSet(dbs []*pg.DB, maxLag, interval time.Duration) *ReplicaSet {
+	rs := &ReplicaSet{maxLag: maxLag, interval: interval}
+	for _, db := range dbs {
+		rs.replicas = append(rs.replicas, &replica{db: db, status: ReplicaStatus{Addr: db.Options().Addr}})
+	}
+	return rs
+}
+
+// ConnectReplicas creates the connections to the read replicas (without waiting for them: they are read from
+// once they are checked). User is responsible for defer rs.Close()
+func ConnectReplicas(options []*pg.Options, maxLag, interval time.Duration) *ReplicaSet {
+	dbs := make([]*pg.DB, 0, len(options))
+	for _, opts := range options {
+		db := pg.Connect(opts)
+		db.AddQueryHook(metrics.QueryHook{})
+		db.AddQueryHook(tracing.QueryHook{})
+		db.AddQueryHook(logging.SlowQueryHook{})
+		dbs = append(dbs, db)
+	}
+	return NewReplicaSet(dbs, maxLag, interval)
+}
+
+// Len is the number of read replicas (healthy or not)
+func (rs *ReplicaSet) Len() int {
+	return len(rs.replicas)
+}
+
+// ReadYourWritesWindow is how long a client must read from the primary after a write, to read it: a replica
+// lags up to maxLag, and it may have been checked up to interval ago
+func (rs *ReplicaSet) ReadYourWritesWindow() time.Duration {
+	return rs.maxLag + rs.interval
+}
+
+// Reader is the database to read from: the next healthy replica, or primary
+func (rs *ReplicaSet) Reader(primary *pg.DB) *pg.DB {
+	if n := len(rs.replicas); n > 0 {
+		// every replica tried takes a turn, so the healthy ones are read from evenly
+		for i := 0; i < n; i++ {
+			r := rs.replicas[int(atomic.AddUint32(&rs.next, 1))%n]
+			if atomic.LoadInt32(&r.healthy) == 1 {
+				metrics.DBReads.WithLabelValues("replica").Inc()
+				return r.db
+			}
+		}
+	}
+	metrics.DBReads.WithLabelValues("primary").Inc()
+	return primary
+}
+
+// isReplica tells whether db is (a copy of, eg: WithContext) one of the replicas
+func (rs *ReplicaSet) isReplica(db *pg.DB) bool {
+	for _, r := range rs.replicas {
+		if r.db.Options() == db.Options() {
+			return true
+		}
+	}
+	return false
+}
+
+// replicaLagQuery is the replication lag of a replica, in seconds: 0 if it replayed everything it received
+// (eg: the primary is idle, so the last replayed transaction is old) or if it is not a replica.
+// A replica disconnected from the primary has replayed everything it received too, so it also tells whether the
+// replica is streaming from the primary (its status is only visible with pg_read_all_stats: without it, a running
+// WAL receiver is trusted)
+const replicaLagQuery = `SELECT
+	CASE
+		WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END,
+	NOT pg_is_in_recovery() OR EXISTS (
+		SELECT 1 FROM pg_stat_wal_receiver WHERE COALESCE(status, 'streaming') = 'streaming'
+	)`
+
+// replicaCheckTimeout bounds the check of a replica
+const replicaCheckTimeout = 2 * time.Second
+
+// Check checks every replica (concurrently): whether it answers and how much it lags
+func (rs *ReplicaSet) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range rs.replicas {
+		wg.Add(1)
+		go func(r *replica) {
+			defer wg.Done()
+			r.check(ctx, rs.maxLag)
+		}(r)
+	}
+	wg.Wait()
+}
+
+func (r *replica) check(ctx context.Context, maxLag time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	defer cancel()
+
+	var lag float64
+	var streaming bool
+	_, err := r.db.WithContext(ctx).QueryOne(pg.Scan(&lag, &streaming), replicaLagQuery)
+
+	status := ReplicaStatus{Addr: r.db.Options().Addr, LagSeconds: lag, CheckedAt: time.Now()}
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status.Error = replicaLagError(lag, streaming, maxLag)
+	}
+	status.Healthy = status.Error == ""
+
+	var healthy int32
+	if status.Healthy {
+		healthy = 1
+	}
+	if atomic.SwapInt32(&r.healthy, healthy) != healthy {
+		if status.Healthy {
+			logging.Logger().Info().Str("replica", status.Addr).Msg("Reading from the replica")
+		} else {
+			logging.Logger().Warn().Str("replica", status.Addr).Str("error", status.Error).Msg("Not reading from the replica")
+		}
+	}
+	metrics.DBReplicaHealthy.WithLabelValues(status.Addr).Set(float64(healthy))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+// replicaLagError tells why a replica lagging lag seconds is not read from ("" if it is)
+func replicaLagError(lag float64, streaming bool, maxLag time.Duration) string {
+	switch {
+	case !streaming:
+		return "not streaming from the primary"
+	case lag > maxLag.Seconds():
+		return fmt.Sprintf("lagging %.1fs behind the primary (max %s)", lag, maxLag)
+	}
+	return ""
+}
+
+// Run checks the replicas every interval, until ctx is done
+func (rs *ReplicaSet) Run(ctx context.Context) {
+	ticker := time.NewTicker(rs.interval)
+	defer ticker.Stop()
+
+	for {
+		rs.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status is the result of the last check of every replica
+func (rs *ReplicaSet) Status() []ReplicaStatus {
+	status := make([]ReplicaStatus, 0, len(rs.replicas))
+	for _, r := range rs.replicas {
+		r.mu.Lock()
+		status = append(status, r.status)
+		r.mu.Unlock()
+	}
+	return status
+}
+
+// Close closes the connections to the replicas
+func (rs *ReplicaSet) Close() {
+	for _, r := range rs.replicas {
+		r.db.Close()
+	}
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// unreachableReplicas are replicas where nothing listens
+func unreachableReplicas(n int) *ReplicaSet {
+	dbs := make([]*pg.DB, n)
+	for i := range dbs {
+		dbs[i] = pg.Connect(&pg.Options{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond})
+	}
+	return NewReplicaSet(dbs, 5*time.Second, time.Second)
+}
+
+func TestReplicaSetReader(t *testing.T) {
+	primary := pg.Connect(&pg.Options{Addr: "127.0.0.1:1"})
+	defer primary.Close()
+
+	assert.Same(t, primary, Replicas.Reader(primary), "no replicas")
+
+	rs := unreachableReplicas(3)
+	defer rs.Close()
+	assert.Same(t, primary, rs.Reader(primary), "replicas are unhealthy until checked")
+	assert.Equal(t, 6*time.Second, rs.ReadYourWritesWindow())
+
+	rs.replicas[0].healthy = 1
+	rs.replicas[2].healthy = 1
+	read := map[*pg.DB]int{}
+	for i := 0; i < 10; i++ {
+		read[rs.Reader(primary)]++
+	}
+	assert.Equal(t, map[*pg.DB]int{rs.replicas[0].db: 5, rs.replicas[2].db: 5}, read, "round-robin over the healthy replicas")
+}
+
+func TestReplicaSetCheck(t *testing.T) {
+	rs := unreachableReplicas(2)
+	defer rs.Close()
+	rs.replicas[0].healthy = 1
+
+	rs.Check(context.Background())
+
+	primary := pg.Connect(&pg.Options{Addr: "127.0.0.1:1"})
+	defer primary.Close()
+	assert.Same(t, primary, rs.Reader(primary), "the primary is read from, while the replicas are down")
+	for _, status := range rs.Status() {
+		assert.Equal(t, "127.0.0.1:1", status.Addr)
+		assert.False(t, status.Healthy)
+		assert.NotEmpty(t, status.Error)
+		assert.False(t, status.CheckedAt.IsZero())
+	}
+}
+
+func TestReplicaLagError(t *testing.T) {
+	assert.Empty(t, replicaLagError(0, true, 5*time.Second))
+	assert.Empty(t, replicaLagError(4.5, true, 5*time.Second))
+	assert.Equal(t, "lagging 6.0s behind the primary (max 5s)", replicaLagError(6, true, 5*time.Second))
+	assert.Equal(t, "not streaming from the primary", replicaLagError(0, false, 5*time.Second), "disconnected: nothing left to replay")
+}
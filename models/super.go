@@ -1,10 +1,14 @@
 package models
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/go-pg/pg/v9"
 	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/tcarreira/superhero/metrics"
 )
 
 // SuperInterface interface for Super
@@ -16,24 +20,91 @@ type SuperInterface interface {
 	Delete(db *pg.DB) error
 }
 
-// Super represents either a SuperHero or a SuperVilan
+// Super represents a SuperHero, a SuperVillain, a neutral or an anti-hero (see Types), with the powerstats and
+// profile of the SuperHeroAPI (flattened: biography, appearance, work and connections).
+// Its name is unique in its universe (eg: marvel, dc), so it is found by its ref (see Ref).
+// The v1 APIs (and their Swagger docs) name the types as V1Type does
 // swagger:model Super
 type Super struct {
-	tableName      struct{} `json:"-" pg:"superhero_supers,alias:s"` // json tag for swaggo bug
-	ID             uint64   `json:"-" pg:",pk"`
-	UUID           string   `json:"uuid" example:"47c0df01-a47d-497f-808d-181021f01c76" form:"uuid" pg:",notnull,type:uuid,default:gen_random_uuid()"`
-	Type           string   `json:"type" example:"HERO" enums:"HERO,VILAN" form:"type"`
-	Name           string   `json:"name" form:"name" example:"SuperHero1" pg:",unique,notnull"`
-	FullName       string   `json:"fullname" example:"SuperHero1's Full Name"`
-	Intelligence   int64    `json:"intelligence,string" example:"90"`
-	Power          int64    `json:"power,string" example:"80"`
-	Occupation     string   `json:"occupation" example:"Programmer"`
-	ImageURL       string   `json:"image_url" example:"https://http.cat/200"`
-	Groups         []Group  `json:"-" pg:"many2many:superhero_group_supers,joinFK:group_id"`
-	GroupsList     []string `json:"groups,nilasempty" example:"group1,group2" pg:"-"`
-	RelativesCount int      `json:"relatives_count,string" pg:"-"`
+	tableName        struct{}         `json:"-" pg:"superhero_supers,alias:s"` // json tag for swaggo bug
+	ID               uint64           `json:"-" pg:",pk"`
+	UUID             string           `json:"uuid" example:"47c0df01-a47d-497f-808d-181021f01c76" form:"uuid" pg:",notnull,type:uuid,default:gen_random_uuid()"`
+	Type             string           `json:"type" example:"HERO" enums:"HERO,VILAN" form:"type" pg:",type:superhero_super_type"`
+	Name             string           `json:"name" form:"name" example:"SuperHero1" pg:",notnull"` // unique in the universe among non deleted (see migrations)
+	Universe         string           `json:"universe" example:"default" form:"universe" pg:",notnull,default:'default'"`
+	FullName         string           `json:"fullname" example:"SuperHero1's Full Name"`
+	Intelligence     int64            `json:"intelligence,string" example:"90"`
+	Strength         int64            `json:"strength,string" example:"26"`
+	Speed            int64            `json:"speed,string" example:"27"`
+	Durability       int64            `json:"durability,string" example:"50"`
+	Power            int64            `json:"power,string" example:"80"`
+	Combat           int64            `json:"combat,string" example:"100"`
+	Aliases          []string         `json:"aliases" example:"Insider,Matches Malone" form:"alias" pg:",array"`
+	Publisher        string           `json:"publisher" example:"DC Comics" form:"publisher"`
+	Alignment        string           `json:"alignment" example:"good" enums:"good,bad,neutral" form:"alignment"`
+	FirstAppearance  string           `json:"first_appearance" example:"Detective Comics #27"`
+	Gender           string           `json:"gender" example:"Male" form:"gender"`
+	Race             string           `json:"race" example:"Human" form:"race"`
+	HeightCM         int64            `json:"height_cm,string" example:"188"`
+	WeightKG         int64            `json:"weight_kg,string" example:"95"`
+	Occupation       string           `json:"occupation" example:"Programmer"`
+	Base             string           `json:"base" example:"Batcave, Gotham City"`
+	GroupAffiliation string           `json:"group_affiliation" example:"Batman Family, Justice League"`
+	Relatives        string           `json:"relatives" example:"Thomas Wayne (father, deceased)"` // as told by the SuperHeroAPI (not relatives_count)
+	ImageURL         string           `json:"image_url" example:"https://http.cat/200"`
+	Groups           []Group          `json:"-" pg:"many2many:superhero_group_supers,joinFK:group_id"`
+	GroupsList       []string         `json:"groups,nilasempty" example:"group1,group2" pg:"-"`
+	RelativesCount   int              `json:"relatives_count,string" pg:"-"`
+	MinPowerstats    PowerstatsFilter `json:"-" pg:"-"`          // filter only (see ReadAll)
+	Types            []string         `json:"-" form:"-" pg:"-"` // filter only (see ReadAll): any of them
+	Version          int64            `json:"-" pg:",notnull,default:1"`
+	DeletedAt        time.Time        `json:"-" pg:",soft_delete"`
+}
+
+// PowerstatsFilter are the minimum powerstats of the Supers read by ReadAll (0: any)
+type PowerstatsFilter struct {
+	Intelligence int64 `form:"min_intelligence"`
+	Strength     int64 `form:"min_strength"`
+	Speed        int64 `form:"min_speed"`
+	Durability   int64 `form:"min_durability"`
+	Power        int64 `form:"min_power"`
+	Combat       int64 `form:"min_combat"`
+}
+
+// powerstat is the value of a powerstat, by its column (and JSON) name
+type powerstat struct {
+	name  string
+	value int64
+}
+
+func powerstats(intelligence, strength, speed, durability, power, combat int64) []powerstat {
+	return []powerstat{
+		{"intelligence", intelligence}, {"strength", strength}, {"speed", speed},
+		{"durability", durability}, {"power", power}, {"combat", combat},
+	}
+}
+
+func (s *Super) powerstats() []powerstat {
+	return powerstats(s.Intelligence, s.Strength, s.Speed, s.Durability, s.Power, s.Combat)
 }
 
+func (f *PowerstatsFilter) powerstats() []powerstat {
+	return powerstats(f.Intelligence, f.Strength, f.Speed, f.Durability, f.Power, f.Combat)
+}
+
+// Alignments of a Super (as the SuperHeroAPI)
+const (
+	AlignmentGood    = "good"
+	AlignmentBad     = "bad"
+	AlignmentNeutral = "neutral"
+)
+
+// maxPowerstat is the maximum of every powerstat (as the SuperHeroAPI)
+const maxPowerstat = 100
+
+// AnyVersion may be used as the expected version in order to skip the optimistic concurrency check
+const AnyVersion int64 = 0
+
 // ErrorSuperAlreadyExists Super Already Exists - extends error
 type ErrorSuperAlreadyExists struct {
 	s string
@@ -61,16 +132,43 @@ func (e *ErrorSuperInvalidFields) Error() string {
 	return e.s
 }
 
+// ErrorSuperVersionMismatch Super was changed meanwhile (version is not the expected) - extends error
+type ErrorSuperVersionMismatch struct {
+	s string
+}
+
+func (e *ErrorSuperVersionMismatch) Error() string {
+	return e.s
+}
+
 func (s *Super) validate() (*Super, error) {
 
-	// Check Type is one of HERO|VILAN (should be enum...)
-	switch strings.ToUpper(s.Type) {
-	case
-		"HERO",
-		"VILAN":
-		s.Type = strings.ToUpper(s.Type)
+	// Check Type is one of Types (VILAN is VILLAIN)
+	if s.Type = NormalizeType(s.Type); s.Type == "" {
+		return s, &ErrorSuperInvalidFields{"Type should be one of [\"HERO\", \"VILLAIN\", \"NEUTRAL\", \"ANTIHERO\"]"}
+	}
+
+	// Check Universe is a valid name ("" is the current one, or DefaultUniverse if new)
+	if s.Universe != "" {
+		if s.Universe = NormalizeUniverse(s.Universe); s.Universe == "" {
+			return s, &ErrorSuperInvalidFields{fmt.Sprintf("Universe should have up to %d lowercase letters, digits, '-' and '_' (eg: marvel)", maxUniverseLength)}
+		}
+	}
+
+	for _, stat := range s.powerstats() {
+		if stat.value < 0 || stat.value > maxPowerstat {
+			return s, &ErrorSuperInvalidFields{fmt.Sprintf("%s should be between 0 and %d", stat.name, maxPowerstat)}
+		}
+	}
+	if s.HeightCM < 0 || s.WeightKG < 0 {
+		return s, &ErrorSuperInvalidFields{"height_cm and weight_kg should not be negative"}
+	}
+
+	switch strings.ToLower(s.Alignment) {
+	case "", AlignmentGood, AlignmentBad, AlignmentNeutral:
+		s.Alignment = strings.ToLower(s.Alignment)
 	default:
-		return s, &ErrorSuperInvalidFields{"Type should be one of [\"HERO\", \"VILAN\"]"}
+		return s, &ErrorSuperInvalidFields{"Alignment should be one of [\"good\", \"bad\", \"neutral\"]"}
 	}
 
 	return s, nil
@@ -78,11 +176,26 @@ func (s *Super) validate() (*Super, error) {
 
 // Create saves the Super to database
 func (s *Super) Create(db *pg.DB) (*Super, error) {
+	db, span := traceDB(db, "Super.Create")
+	defer span.End()
+
 	if _, err := s.validate(); err != nil {
 		return s, err
 	}
+	if s.Universe == "" {
+		s.Universe = DefaultUniverse
+	}
 
-	if err := db.Insert(s); err != nil {
+	err := runInTransaction(db, func(tx *pg.Tx, changes *changeSet) error {
+		if err := tx.Insert(s); err != nil {
+			return err
+		}
+		if err := recordSuperVersion(tx, s); err != nil {
+			return err
+		}
+		return changes.record(AuditActionCreate, AuditEntitySuper, nil, s)
+	})
+	if err != nil {
 		pgErr, ok := err.(pg.Error)
 		if ok {
 			if pgErr.IntegrityViolation() {
@@ -91,25 +204,34 @@ func (s *Super) Create(db *pg.DB) (*Super, error) {
 		}
 		panic(err)
 	}
+	metrics.SupersCreated.WithLabelValues(s.Type).Inc()
 
 	s.GroupsList = make([]string, 0) // empty array instead of null
+	s.Aliases = emptyIfNil(s.Aliases)
 
 	return s, nil
 }
 
-// GetByNameOrUUID query DB for Super with (name OR uuid) == idStr
-func (s *Super) GetByNameOrUUID(db *pg.DB, idStr string) (*Super, error) {
+// GetByNameOrUUID query DB for Super with (ref OR uuid) == idStr (or Cache). The ref is "universe:name", or only
+// the name in DefaultUniverse (see Ref)
+func (s *Super) GetByNameOrUUID(db orm.DB, idStr string) (*Super, error) {
+	cacheable := Cache.cacheable(db)
+	if cacheable {
+		if cached, ok := Cache.get("super", superKey(idStr)); ok {
+			return cached.(*Super).clone(), nil
+		}
+	}
+	since := Cache.since(db)
+
+	db, span := traceORM(db, "Super.GetByNameOrUUID")
+	defer span.End()
+
 	super := Super{}
 
 	err := db.Model(&super).
 		Relation("Groups").
-		Column("s.*").ColumnExpr("count(distinct relatives.id) AS relatives_count").
-		Join("LEFT JOIN superhero_group_supers AS s2g ON s.id = s2g.super_id").
-		Join("LEFT JOIN superhero_group_supers AS g2s ON s2g.group_id = g2s.group_id").
-		Join("LEFT JOIN superhero_supers AS relatives ON g2s.super_id = relatives.id AND g2s.super_id != s.id").
-		Where("s.name = ?", idStr).
-		WhereOr("upper(s.uuid::text) = ?", strings.ToUpper(idStr)).
-		Group("s.id").
+		Apply(withRelativesCount).
+		WhereGroup(whereNameOrUUID(idStr)).
 		Select(&super)
 
 	if err != nil {
@@ -121,10 +243,14 @@ func (s *Super) GetByNameOrUUID(db *pg.DB, idStr string) (*Super, error) {
 
 	// create the Group Names List as []string
 	super.GroupsList = make([]string, 0) // empty array instead of null
+	super.Aliases = emptyIfNil(super.Aliases)
 	for _, group := range super.Groups {
 		super.GroupsList = append(super.GroupsList, group.Name)
 	}
 
+	if cacheable {
+		Cache.Set(superKey(idStr), super.clone(), Cache.ttl, since, superTags(&super)...)
+	}
 	return &super, nil
 }
 
@@ -134,13 +260,53 @@ func (s *Super) Read(db *pg.DB) *Super {
 }
 
 // ReadAll read all Super from database (by ANDing super fields as filters)
-func (s *Super) ReadAll(db *pg.DB) []Super {
+func (s *Super) ReadAll(db orm.DB) []Super {
+	return s.readAll(db, false, 0, 0)
+}
+
+// ReadAllDeleted read all soft deleted Super from database (by ANDing super fields as filters)
+func (s *Super) ReadAllDeleted(db orm.DB) []Super {
+	return s.readAll(db, true, 0, 0)
+}
+
+// ReadPage is ReadAll (or ReadAllDeleted) for a page of at most limit Supers (0 for no limit), skipping offset
+func (s *Super) ReadPage(db orm.DB, deleted bool, limit, offset int) []Super {
+	return s.readAll(db, deleted, limit, offset)
+}
+
+// withRelativesCount selects the Supers with their relatives_count:
+// how many other (non deleted) Supers are in the same groups. It is precomputed (see SuperRelatives)
+func withRelativesCount(q *orm.Query) (*orm.Query, error) {
+	return q.
+		Column("s.*").ColumnExpr("coalesce(sr.relatives_count, 0) AS relatives_count").
+		Join("LEFT JOIN superhero_super_relatives AS sr ON sr.super_id = s.id"), nil
+}
+
+func (s *Super) readAll(db orm.DB, deleted bool, limit, offset int) []Super {
+	cacheable := Cache.cacheable(db)
+	key := superListKey(s, deleted, limit, offset)
+	if cacheable {
+		if cached, ok := Cache.get("supers", key); ok {
+			return cloneSupers(cached.([]Super))
+		}
+	}
+	since := Cache.since(db)
+
+	db, span := traceORM(db, "Super.ReadAll")
+	defer span.End()
 
 	filter := func(q *orm.Query) (*orm.Query, error) {
 
 		// Specs state filter only by Name and UUID
+		// compared as text: unknown types match no Super (instead of an invalid enum value)
 		if s.Type != "" {
-			q = q.Where("upper(s.type) = ?", strings.ToUpper(s.Type))
+			q = q.Where("s.type::text = ?", NormalizeType(s.Type))
+		}
+		if len(s.Types) > 0 {
+			q = q.Where("s.type::text IN (?)", pg.In(s.Types))
+		}
+		if s.Universe != "" {
+			q = q.Where("s.universe = ?", strings.ToLower(s.Universe))
 		}
 		if s.Name != "" {
 			// must match case
@@ -151,7 +317,22 @@ func (s *Super) ReadAll(db *pg.DB) []Super {
 			q = q.Where("upper(s.uuid::text) = ?", strings.ToUpper(s.UUID))
 		}
 
-		// TODO: add other fields
+		// the profile is matched ignoring case
+		for _, field := range []struct{ column, value string }{
+			{"publisher", s.Publisher}, {"alignment", s.Alignment}, {"gender", s.Gender}, {"race", s.Race},
+		} {
+			if field.value != "" {
+				q = q.Where("upper(s.?) = ?", pg.Ident(field.column), strings.ToUpper(field.value))
+			}
+		}
+		for _, alias := range s.Aliases {
+			q = q.Where("EXISTS (SELECT 1 FROM unnest(s.aliases) AS alias WHERE upper(alias) = ?)", strings.ToUpper(alias))
+		}
+		for _, stat := range s.MinPowerstats.powerstats() {
+			if stat.value > 0 {
+				q = q.Where("s.? >= ?", pg.Ident(stat.name), stat.value)
+			}
+		}
 
 		return q, nil
 	}
@@ -159,14 +340,23 @@ func (s *Super) ReadAll(db *pg.DB) []Super {
 	// supersResult=[] instead of supersResult=nil
 	supersResult := make([]Super, 0)
 
-	err := db.Model(&supersResult).
+	q := db.Model(&supersResult)
+	if deleted {
+		q = q.Deleted()
+	}
+
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	if offset > 0 {
+		q = q.Offset(offset)
+	}
+
+	err := q.
 		Relation("Groups").
-		Column("s.*").ColumnExpr("count(distinct relatives.id) AS relatives_count").
-		Join("LEFT JOIN superhero_group_supers AS s2g ON s.id = s2g.super_id").
-		Join("LEFT JOIN superhero_group_supers AS g2s ON s2g.group_id = g2s.group_id").
-		Join("LEFT JOIN superhero_supers AS relatives ON g2s.super_id = relatives.id AND g2s.super_id != s.id").
+		Apply(withRelativesCount).
 		Apply(filter).
-		Group("s.id").
+		Order("s.id").
 		Select()
 	if err != nil {
 		panic(err)
@@ -174,6 +364,7 @@ func (s *Super) ReadAll(db *pg.DB) []Super {
 
 	for i := range supersResult {
 		supersResult[i].GroupsList = make([]string, 0) // make empty array instead of null
+		supersResult[i].Aliases = emptyIfNil(supersResult[i].Aliases)
 		// create the Group Names List as []string
 		for _, group := range supersResult[i].Groups {
 			supersResult[i].GroupsList = append(supersResult[i].GroupsList, group.Name)
@@ -181,36 +372,282 @@ func (s *Super) ReadAll(db *pg.DB) []Super {
 
 	}
 
+	if cacheable {
+		Cache.Set(key, cloneSupers(supersResult), Cache.listTTL, since, superListTag)
+	}
 	return supersResult
 
 }
 
+// emptyIfNil makes a nil list an empty array in JSON, instead of null
+func emptyIfNil(list []string) []string {
+	if list == nil {
+		return make([]string, 0)
+	}
+	return list
+}
+
 // Update a Super on database
 func (s *Super) Update(db *pg.DB) *Super {
 	return s
 }
 
-// DeleteByNameOrUUID deletes Super from database, using name or uuid
-func (s *Super) DeleteByNameOrUUID(db *pg.DB, idStr string) error {
+// whereNameOrUUID filters Supers by (ref OR uuid) == idStr (see GetByNameOrUUID)
+func whereNameOrUUID(idStr string) func(q *orm.Query) (*orm.Query, error) {
+	universe, name := splitSuperRef(idStr)
+	return func(q *orm.Query) (*orm.Query, error) {
+		return q.WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			return q.Where("s.universe = ?", universe).Where("s.name = ?", name), nil
+		}).
+			WhereOr("upper(s.uuid::text) = ?", strings.ToUpper(idStr)), nil
+	}
+}
 
-	res, err := db.Model(&Super{}).
-		Where("name = ?", idStr).
-		WhereOr("upper(uuid::text) = ?", strings.ToUpper(idStr)).
-		Delete()
+// lockByNameOrUUID selects the non deleted Super with (name OR uuid) == idStr, locking it until the transaction ends
+func (s *Super) lockByNameOrUUID(tx *pg.Tx, idStr string) (*Super, error) {
+	super := Super{}
 
+	err := tx.Model(&super).
+		WhereGroup(whereNameOrUUID(idStr)).
+		For("UPDATE").
+		Select()
 	if err != nil {
 		if err == pg.ErrNoRows {
-			return &ErrorSuperNotFound{err.Error()}
+			return &super, &ErrorSuperNotFound{err.Error()}
+		}
+		return &super, err
+	}
+
+	return &super, nil
+}
+
+// update replaces every editable field of the Super with id, with the ones from s, incrementing its version.
+// The version is checked by the same UPDATE statement, unless version is AnyVersion
+func (s *Super) update(tx *pg.Tx, id uint64, version int64) error {
+	q := tx.Model(s).
+		Set("type = ?type").
+		Set("universe = ?universe").
+		Set("name = ?name").
+		Set("full_name = ?full_name").
+		Set("intelligence = ?intelligence").
+		Set("strength = ?strength").
+		Set("speed = ?speed").
+		Set("durability = ?durability").
+		Set("power = ?power").
+		Set("combat = ?combat").
+		Set("aliases = ?aliases").
+		Set("publisher = ?publisher").
+		Set("alignment = ?alignment").
+		Set("first_appearance = ?first_appearance").
+		Set("gender = ?gender").
+		Set("race = ?race").
+		Set("height_cm = ?height_cm").
+		Set("weight_kg = ?weight_kg").
+		Set("occupation = ?occupation").
+		Set("base = ?base").
+		Set("group_affiliation = ?group_affiliation").
+		Set("relatives = ?relatives").
+		Set("image_url = ?image_url").
+		Set("version = s.version + 1").
+		Where("s.id = ?", id)
+	if version != AnyVersion {
+		q = q.Where("s.version = ?", version)
+	}
+
+	res, err := q.Returning("*").Update()
+	if err != nil {
+		pgErr, ok := err.(pg.Error)
+		if ok && pgErr.IntegrityViolation() {
+			return &ErrorSuperAlreadyExists{err.Error()}
 		}
 		return err
 	}
 	if res.RowsAffected() < 1 {
-		return &ErrorSuperNotFound{"Can't delete Super - Not Found"}
+		return &ErrorSuperVersionMismatch{"Super was modified meanwhile - version mismatch"}
 	}
 
 	return nil
 }
 
+// UpdateByNameOrUUID replaces every editable field of the Super (name OR uuid) == idStr with the ones from s.
+// The version is checked and incremented by the same UPDATE statement, unless version is AnyVersion
+func (s *Super) UpdateByNameOrUUID(db *pg.DB, idStr string, version int64) (*Super, error) {
+	db, span := traceDB(db, "Super.UpdateByNameOrUUID")
+	defer span.End()
+
+	if _, err := s.validate(); err != nil {
+		return s, err
+	}
+
+	err := runInTransaction(db, func(tx *pg.Tx, changes *changeSet) error {
+		before, err := s.lockByNameOrUUID(tx, idStr)
+		if err != nil {
+			return err
+		}
+		if s.Universe == "" {
+			s.Universe = before.Universe // clients without universes keep it
+		}
+
+		if err := s.update(tx, before.ID, version); err != nil {
+			return err
+		}
+		if err := recordSuperVersion(tx, s); err != nil {
+			return err
+		}
+		return changes.record(AuditActionUpdate, AuditEntitySuper, before, s)
+	})
+	if err != nil {
+		return s, err
+	}
+
+	return s.GetByNameOrUUID(db, s.UUID)
+}
+
+// DeleteByNameOrUUID (soft) deletes Super from database, using name or uuid
+func (s *Super) DeleteByNameOrUUID(db *pg.DB, idStr string) error {
+	return s.DeleteByNameOrUUIDAtVersion(db, idStr, AnyVersion)
+}
+
+// DeleteByNameOrUUIDAtVersion (soft) deletes Super from database, using name or uuid, only if it is still at version.
+// Its group memberships are kept, so they are back when the Super is restored
+func (s *Super) DeleteByNameOrUUIDAtVersion(db *pg.DB, idStr string, version int64) error {
+	db, span := traceDB(db, "Super.DeleteByNameOrUUIDAtVersion")
+	defer span.End()
+
+	return runInTransaction(db, func(tx *pg.Tx, changes *changeSet) error {
+		before, err := s.lockByNameOrUUID(tx, idStr)
+		if err != nil {
+			return err
+		}
+		if version != AnyVersion && version != before.Version {
+			return &ErrorSuperVersionMismatch{"Super was modified meanwhile - version mismatch"}
+		}
+
+		// a deleted Super is a new version (see history)
+		deleted := Super{}
+		_, err = tx.Model(&deleted).
+			Set("deleted_at = now()").
+			Set("version = s.version + 1").
+			Where("s.id = ?", before.ID).
+			Returning("*").
+			Update()
+		if err != nil {
+			return err
+		}
+
+		if err := recordSuperVersion(tx, &deleted); err != nil {
+			return err
+		}
+		return changes.record(AuditActionDelete, AuditEntitySuper, before, nil)
+	})
+}
+
+// RestoreByNameOrUUID undeletes a soft deleted Super, using name or uuid.
+// If there are many deleted Supers with the same name, the most recently deleted is restored
+func (s *Super) RestoreByNameOrUUID(db *pg.DB, idStr string) (*Super, error) {
+	db, span := traceDB(db, "Super.RestoreByNameOrUUID")
+	defer span.End()
+
+	super := Super{}
+
+	err := runInTransaction(db, func(tx *pg.Tx, changes *changeSet) error {
+		err := tx.Model(&super).
+			Deleted().
+			WhereGroup(whereNameOrUUID(idStr)).
+			OrderExpr("s.deleted_at DESC").
+			Limit(1).
+			For("UPDATE").
+			Select()
+		if err != nil {
+			if err == pg.ErrNoRows {
+				return &ErrorSuperNotFound{"Can't restore Super - Not Found in deleted Supers"}
+			}
+			return err
+		}
+
+		_, err = tx.Model(&super).
+			Set("deleted_at = NULL").
+			Set("version = s.version + 1").
+			Where("s.id = ?", super.ID).
+			Deleted().
+			Returning("*").
+			Update()
+		if err != nil {
+			pgErr, ok := err.(pg.Error)
+			if ok && pgErr.IntegrityViolation() {
+				return &ErrorSuperAlreadyExists{err.Error()}
+			}
+			return err
+		}
+
+		if err := recordSuperVersion(tx, &super); err != nil {
+			return err
+		}
+		return changes.record(AuditActionRestore, AuditEntitySuper, nil, &super)
+	})
+	if err != nil {
+		return &super, err
+	}
+
+	return super.GetByNameOrUUID(db, super.UUID)
+}
+
+// PurgeDeletedSupers permanently removes Supers (with their group memberships and history) soft deleted before olderThan.
+// The audit log is kept
+func PurgeDeletedSupers(db *pg.DB, olderThan time.Time) (int, error) {
+	db, span := traceDB(db, "PurgeDeletedSupers")
+	defer span.End()
+
+	var purged []Super
+
+	err := runInTransaction(db, func(tx *pg.Tx, changes *changeSet) error {
+		err := tx.Model(&purged).
+			Deleted().
+			Where("s.deleted_at < ?", olderThan).
+			For("UPDATE").
+			Select()
+		if err != nil || len(purged) == 0 {
+			return err
+		}
+
+		ids := make([]uint64, 0, len(purged))
+		for i := range purged {
+			ids = append(ids, purged[i].ID)
+		}
+
+		// recorded before their memberships are removed (see cacheTags)
+		for i := range purged {
+			if err := changes.record(AuditActionPurge, AuditEntitySuper, &purged[i], nil); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Model((*GroupSuper)(nil)).
+			Where("super_id IN (?)", pg.In(ids)).
+			Delete()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Model((*SuperVersion)(nil)).
+			Where("super_id IN (?)", pg.In(ids)).
+			Delete()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Model((*Super)(nil)).
+			Where("id IN (?)", pg.In(ids)).
+			ForceDelete()
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+	return len(purged), nil
+}
+
 // Delete a super from database
 func (s *Super) Delete(db *pg.DB) {
 
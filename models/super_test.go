@@ -5,6 +5,7 @@ package models
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -52,7 +53,7 @@ func TestSuper_Create(t *testing.T) {
 		got, err = super2.Create(d)
 
 		assert.NoError(t, err)
-		assert.Equal(t, "VILAN", got.Type) // converts Hero -> HERO
+		assert.Equal(t, "VILLAIN", got.Type) // converts Vilan -> VILLAIN
 		assert.Equal(t, "super2", got.Name)
 		assert.Less(t, uint64(0), got.ID)                                 // got a new id from database
 		assert.Equal(t, "47c0df01-a47d-497f-808d-181021f01c76", got.UUID) // got new UUID from database
@@ -100,7 +101,7 @@ func TestSuper_GetByNameOrUUID_byUUID(t *testing.T) {
 	t.Run("TestSuper_GetByNameOrUUID - by UUID", func(t *testing.T) {
 		got, err = got.GetByNameOrUUID(d, "40000001-a47d-497f-808d-181021f01c76")
 		assert.NoError(t, err)
-		assert.Equal(t, "VILAN", got.Type)
+		assert.Equal(t, "VILLAIN", got.Type)
 		assert.Equal(t, "super2", got.Name)
 	})
 
@@ -206,7 +207,7 @@ func TestSuper_ReadAll(t *testing.T) {
 			"h1": {"HERO", "h1"},
 			"h2": {"HERO", "h2"},
 			"h3": {"HERO", "h3"},
-			"v1": {"VILAN", "v1"},
+			"v1": {"VILLAIN", "v1"},
 		}
 
 		for _, super := range got {
@@ -252,7 +253,7 @@ func TestSuper_ReadAll(t *testing.T) {
 			Type string
 			Name string
 		}{
-			"v1": {"VILAN", "v1"},
+			"v1": {"VILLAIN", "v1"},
 		}
 
 		for _, super := range got {
@@ -351,3 +352,358 @@ func TestSuper_GroupsRelatives(t *testing.T) {
 	})
 
 }
+
+func TestSuper_UpdateByNameOrUUID(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	supers := []Super{
+		{Type: "HERO", Name: "u1", UUID: "40000003-a47d-497f-808d-181021f01c76"},
+		{Type: "HERO", Name: "u2"},
+	}
+	for i := range supers {
+		supers[i].Create(d)
+	}
+
+	t.Run("TestSuper_UpdateByNameOrUUID - new super has version 1", func(t *testing.T) {
+		got, err := new(Super).GetByNameOrUUID(d, "u1")
+		assert.NoError(t, err)
+		assert.EqualValues(t, 1, got.Version)
+	})
+
+	t.Run("TestSuper_UpdateByNameOrUUID - update by name at version", func(t *testing.T) {
+		update := Super{Type: "vilan", Name: "u1-renamed", Power: 100}
+		got, err := update.UpdateByNameOrUUID(d, "u1", 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "VILLAIN", got.Type)
+		assert.Equal(t, "u1-renamed", got.Name)
+		assert.EqualValues(t, 100, got.Power)
+		assert.Equal(t, "40000003-a47d-497f-808d-181021f01c76", got.UUID) // uuid is kept
+		assert.EqualValues(t, 2, got.Version)
+	})
+
+	t.Run("TestSuper_UpdateByNameOrUUID - stale version", func(t *testing.T) {
+		update := Super{Type: "HERO", Name: "u1-renamed"}
+		_, err := update.UpdateByNameOrUUID(d, "40000003-a47d-497f-808d-181021f01c76", 1)
+		assert.IsType(t, &ErrorSuperVersionMismatch{}, err)
+	})
+
+	t.Run("TestSuper_UpdateByNameOrUUID - any version", func(t *testing.T) {
+		update := Super{Type: "HERO", Name: "u1-renamed"}
+		got, err := update.UpdateByNameOrUUID(d, "40000003-a47d-497f-808d-181021f01c76", AnyVersion)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 3, got.Version)
+	})
+
+	t.Run("TestSuper_UpdateByNameOrUUID - rename to an existing name", func(t *testing.T) {
+		update := Super{Type: "HERO", Name: "u2"}
+		_, err := update.UpdateByNameOrUUID(d, "u1-renamed", AnyVersion)
+		assert.IsType(t, &ErrorSuperAlreadyExists{}, err)
+	})
+
+	t.Run("TestSuper_UpdateByNameOrUUID - not found", func(t *testing.T) {
+		update := Super{Type: "HERO", Name: "whatever"}
+		_, err := update.UpdateByNameOrUUID(d, "does-not-exist", 1)
+		assert.IsType(t, &ErrorSuperNotFound{}, err)
+	})
+
+	t.Run("TestSuper_UpdateByNameOrUUID - invalid type", func(t *testing.T) {
+		update := Super{Type: "Something", Name: "u2"}
+		_, err := update.UpdateByNameOrUUID(d, "u2", AnyVersion)
+		assert.IsType(t, &ErrorSuperInvalidFields{}, err)
+	})
+}
+
+func TestSuper_DeleteByNameOrUUIDAtVersion(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	super := Super{Type: "HERO", Name: "d1"}
+	super.Create(d)
+
+	t.Run("TestSuper_DeleteByNameOrUUIDAtVersion - stale version", func(t *testing.T) {
+		err := new(Super).DeleteByNameOrUUIDAtVersion(d, "d1", 2)
+		assert.IsType(t, &ErrorSuperVersionMismatch{}, err)
+	})
+
+	t.Run("TestSuper_DeleteByNameOrUUIDAtVersion - current version", func(t *testing.T) {
+		err := new(Super).DeleteByNameOrUUIDAtVersion(d, "d1", 1)
+		assert.NoError(t, err)
+	})
+
+	t.Run("TestSuper_DeleteByNameOrUUIDAtVersion - not found", func(t *testing.T) {
+		err := new(Super).DeleteByNameOrUUIDAtVersion(d, "d1", 1)
+		assert.IsType(t, &ErrorSuperNotFound{}, err)
+	})
+}
+
+func TestSuper_SoftDelete(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	supers := []Super{
+		{Type: "HERO", Name: "sd1", UUID: "40000004-a47d-497f-808d-181021f01c76"},
+		{Type: "HERO", Name: "sd2"},
+	}
+	for i := range supers {
+		supers[i].Create(d)
+	}
+	group := Group{Name: "g1", Supers: supers}
+	group.Create(d)
+
+	t.Run("TestSuper_SoftDelete - deleted Super is hidden", func(t *testing.T) {
+		err := new(Super).DeleteByNameOrUUID(d, "sd1")
+		assert.NoError(t, err)
+
+		_, err = new(Super).GetByNameOrUUID(d, "sd1")
+		assert.IsType(t, &ErrorSuperNotFound{}, err)
+
+		assertSupers(t, []Super{{Type: "HERO", Name: "sd2"}}, new(Super).ReadAll(d))
+
+		remaining, err := new(Super).GetByNameOrUUID(d, "sd2")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, remaining.RelativesCount)
+
+		g, err := new(Group).GetByName(d, "g1")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"sd2"}, g.SupersList)
+	})
+
+	t.Run("TestSuper_SoftDelete - list deleted only", func(t *testing.T) {
+		assertSupers(t, []Super{{Type: "HERO", Name: "sd1"}}, new(Super).ReadAllDeleted(d))
+	})
+
+	t.Run("TestSuper_SoftDelete - restore", func(t *testing.T) {
+		got, err := new(Super).RestoreByNameOrUUID(d, "40000004-a47d-497f-808d-181021f01c76")
+		assert.NoError(t, err)
+		assert.Equal(t, "sd1", got.Name)
+		assert.Equal(t, []string{"g1"}, got.GroupsList) // memberships are back
+		assert.Equal(t, 1, got.RelativesCount)
+
+		_, err = new(Super).RestoreByNameOrUUID(d, "sd1")
+		assert.IsType(t, &ErrorSuperNotFound{}, err)
+	})
+
+	t.Run("TestSuper_SoftDelete - name can be reused after delete", func(t *testing.T) {
+		assert.NoError(t, new(Super).DeleteByNameOrUUID(d, "sd1"))
+
+		again := Super{Type: "VILAN", Name: "sd1"}
+		_, err := again.Create(d)
+		assert.NoError(t, err)
+
+		// restoring would duplicate the name
+		_, err = new(Super).RestoreByNameOrUUID(d, "40000004-a47d-497f-808d-181021f01c76")
+		assert.IsType(t, &ErrorSuperAlreadyExists{}, err)
+	})
+
+	t.Run("TestSuper_SoftDelete - purge", func(t *testing.T) {
+		purged, err := PurgeDeletedSupers(d, time.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, purged)
+
+		purged, err = PurgeDeletedSupers(d, time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, purged)
+
+		assert.Equal(t, 0, len(new(Super).ReadAllDeleted(d)))
+
+		count, err := d.Model((*GroupSuper)(nil)).Count()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count) // only sd2 membership is left
+	})
+}
+
+func TestSuper_Profile(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	batman := Super{
+		Type:             "HERO",
+		Name:             "Batman",
+		Intelligence:     100,
+		Strength:         26,
+		Speed:            27,
+		Durability:       50,
+		Power:            47,
+		Combat:           100,
+		Aliases:          []string{"Insider", "Matches Malone"},
+		Publisher:        "DC Comics",
+		Alignment:        "Good",
+		FirstAppearance:  "Detective Comics #27",
+		Gender:           "Male",
+		Race:             "Human",
+		HeightCM:         188,
+		WeightKG:         95,
+		Base:             "Batcave, Gotham City",
+		GroupAffiliation: "Batman Family, Justice League",
+		Relatives:        "Thomas Wayne (father, deceased)",
+	}
+	joker := Super{Type: "VILAN", Name: "Joker", Strength: 10, Combat: 80, Publisher: "DC Comics", Alignment: "bad"}
+	deadpool := Super{Type: "HERO", Name: "Deadpool", Strength: 32, Combat: 100, Publisher: "Marvel Comics", Alignment: "neutral"}
+	for _, super := range []*Super{&batman, &joker, &deadpool} {
+		_, err := super.Create(d)
+		assert.NoError(t, err)
+	}
+
+	t.Run("TestSuper_Profile - stored", func(t *testing.T) {
+		got, err := new(Super).GetByNameOrUUID(d, "Batman")
+		assert.NoError(t, err)
+		assert.EqualValues(t, 26, got.Strength)
+		assert.EqualValues(t, 100, got.Combat)
+		assert.Equal(t, []string{"Insider", "Matches Malone"}, got.Aliases)
+		assert.Equal(t, "good", got.Alignment) // lowercased
+		assert.Equal(t, "Detective Comics #27", got.FirstAppearance)
+		assert.EqualValues(t, 188, got.HeightCM)
+		assert.Equal(t, "Thomas Wayne (father, deceased)", got.Relatives)
+
+		got, err = new(Super).GetByNameOrUUID(d, "Joker")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{}, got.Aliases) // empty array instead of null
+	})
+
+	t.Run("TestSuper_Profile - powerstats out of range", func(t *testing.T) {
+		_, err := (&Super{Type: "HERO", Name: "Superman", Strength: 101}).Create(d)
+		assert.IsType(t, &ErrorSuperInvalidFields{}, err)
+	})
+
+	t.Run("TestSuper_Profile - filters", func(t *testing.T) {
+		names := func(supers []Super) []string {
+			list := make([]string, 0, len(supers))
+			for _, super := range supers {
+				list = append(list, super.Name)
+			}
+			return list
+		}
+
+		assert.Equal(t, []string{"Batman", "Joker"}, names((&Super{Publisher: "dc comics"}).ReadAll(d)))
+		assert.Equal(t, []string{"Joker"}, names((&Super{Alignment: "BAD"}).ReadAll(d)))
+		assert.Equal(t, []string{"Batman"}, names((&Super{Aliases: []string{"matches malone"}}).ReadAll(d)))
+		assert.Equal(t, []string{}, names((&Super{Aliases: []string{"insider", "joker"}}).ReadAll(d)))
+		assert.Equal(t, []string{"Batman", "Deadpool"},
+			names((&Super{MinPowerstats: PowerstatsFilter{Strength: 20, Combat: 100}}).ReadAll(d)))
+		assert.Equal(t, []string{"Deadpool"},
+			names((&Super{Publisher: "Marvel Comics", MinPowerstats: PowerstatsFilter{Combat: 90}}).ReadAll(d)))
+	})
+}
+
+func TestSuper_Types(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	for _, super := range []*Super{
+		{Type: "HERO", Name: "Batman"},
+		{Type: "VILAN", Name: "Joker"},
+		{Type: "neutral", Name: "Catwoman"},
+		{Type: "ANTIHERO", Name: "Deadpool"},
+	} {
+		_, err := super.Create(d)
+		assert.NoError(t, err)
+	}
+
+	names := func(supers []Super) []string {
+		list := make([]string, 0, len(supers))
+		for _, super := range supers {
+			list = append(list, super.Name)
+		}
+		return list
+	}
+
+	t.Run("TestSuper_Types - stored as the enum", func(t *testing.T) {
+		got, err := new(Super).GetByNameOrUUID(d, "Catwoman")
+		assert.NoError(t, err)
+		assert.Equal(t, "NEUTRAL", got.Type)
+
+		_, err = (&Super{Type: "SIDEKICK", Name: "Robin"}).Create(d)
+		assert.IsType(t, &ErrorSuperInvalidFields{}, err)
+	})
+
+	t.Run("TestSuper_Types - filters", func(t *testing.T) {
+		assert.Equal(t, []string{"Joker"}, names((&Super{Type: "VILLAIN"}).ReadAll(d)))
+		assert.Equal(t, []string{"Joker"}, names((&Super{Type: "vilan"}).ReadAll(d)))
+		assert.Equal(t, []string{}, names((&Super{Type: "SIDEKICK"}).ReadAll(d)))
+		assert.Equal(t, []string{"Catwoman", "Deadpool"}, names((&Super{Types: []string{"NEUTRAL", "ANTIHERO"}}).ReadAll(d)))
+
+		v1Heroes := &Super{Type: "HERO"}
+		v1Heroes.FilterByV1Type()
+		assert.Equal(t, []string{"Batman", "Catwoman", "Deadpool"}, names(v1Heroes.ReadAll(d)))
+	})
+}
+
+func TestSuper_Universes(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	for _, super := range []*Super{
+		{Type: "HERO", Name: "Captain Marvel", Universe: "Marvel", Occupation: "pilot"},
+		{Type: "HERO", Name: "Captain Marvel", Universe: "dc", Occupation: "reporter"},
+		{Type: "HERO", Name: "Batman"},
+	} {
+		_, err := super.Create(d)
+		assert.NoError(t, err)
+	}
+
+	t.Run("TestSuper_Universes - unique in the universe", func(t *testing.T) {
+		_, err := (&Super{Type: "VILAN", Name: "Captain Marvel", Universe: "marvel"}).Create(d)
+		assert.IsType(t, &ErrorSuperAlreadyExists{}, err)
+
+		_, err = (&Super{Type: "HERO", Name: "Batman", Universe: "DC Comics"}).Create(d)
+		assert.IsType(t, &ErrorSuperInvalidFields{}, err)
+	})
+
+	t.Run("TestSuper_Universes - by ref", func(t *testing.T) {
+		got, err := new(Super).GetByNameOrUUID(d, "marvel:Captain Marvel")
+		assert.NoError(t, err)
+		assert.Equal(t, "pilot", got.Occupation)
+		assert.Equal(t, "marvel", got.Universe)
+
+		got, err = new(Super).GetByNameOrUUID(d, "DC:Captain Marvel")
+		assert.NoError(t, err)
+		assert.Equal(t, "reporter", got.Occupation)
+
+		got, err = new(Super).GetByNameOrUUID(d, "Batman")
+		assert.NoError(t, err)
+		assert.Equal(t, DefaultUniverse, got.Universe)
+
+		_, err = new(Super).GetByNameOrUUID(d, "Captain Marvel")
+		assert.IsType(t, &ErrorSuperNotFound{}, err, "not in the default universe")
+	})
+
+	t.Run("TestSuper_Universes - filter", func(t *testing.T) {
+		supers := (&Super{Name: "Captain Marvel", Universe: "DC"}).ReadAll(d)
+		if assert.Len(t, supers, 1) {
+			assert.Equal(t, "reporter", supers[0].Occupation)
+		}
+		assert.Len(t, (&Super{Name: "Captain Marvel"}).ReadAll(d), 2)
+	})
+
+	t.Run("TestSuper_Universes - update keeps the universe", func(t *testing.T) {
+		got, err := (&Super{Type: "HERO", Name: "Captain Marvel", Occupation: "soldier"}).
+			UpdateByNameOrUUID(d, "marvel:Captain Marvel", AnyVersion)
+		assert.NoError(t, err)
+		assert.Equal(t, "marvel", got.Universe)
+		assert.Equal(t, "soldier", got.Occupation)
+
+		_, err = (&Super{Type: "HERO", Name: "Captain Marvel", Universe: "dc"}).
+			UpdateByNameOrUUID(d, "marvel:Captain Marvel", AnyVersion)
+		assert.IsType(t, &ErrorSuperAlreadyExists{}, err)
+	})
+
+	t.Run("TestSuper_Universes - groups have refs", func(t *testing.T) {
+		group := Group{Name: "Shazam Family", Supers: []Super{{Name: "dc:Captain Marvel"}, {Name: "Batman"}}}
+		_, err := group.Create(d)
+		assert.NoError(t, err)
+
+		got, err := new(Group).GetByName(d, "Shazam Family")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"dc:Captain Marvel", "Batman"}, got.SupersList)
+	})
+}
+
+func TestSuper_UniverseDefault(t *testing.T) {
+	d := SetupEmptyTestDatabase()
+
+	// rows inserted without a universe (eg: seeded by SQL) are in the default one, as the migrated ones
+	_, err := d.Exec("INSERT INTO superhero_supers (type, name) VALUES ('HERO', 'Seeded')")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	got, err := new(Super).GetByNameOrUUID(d, "Seeded")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultUniverse, got.Universe)
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"context"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tcarreira/superhero/tracing"
+)
+
+// traceDB starts the span of a models method (eg: Super.Create), as a child of the span in the context of db.
+// The queries on the returned db are children of the new span
+func traceDB(db *pg.DB, name string) (*pg.DB, trace.Span) {
+	if db == nil {
+		_, span := tracing.StartChild(context.Background(), name)
+		return db, span
+	}
+	ctx, span := tracing.StartChild(db.Context(), "models."+name)
+	return db.WithContext(ctx), span
+}
+
+// traceORM is traceDB for the methods which may also run in a transaction.
+// The queries of a transaction stay children of the span where it began
+func traceORM(db orm.DB, name string) (orm.DB, trace.Span) {
+	if pgDB, ok := db.(*pg.DB); ok {
+		return traceDB(pgDB, name)
+	}
+	_, span := tracing.StartChild(db.Context(), "models."+name)
+	return db, span
+}
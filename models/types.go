@@ -0,0 +1,116 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/tcarreira/superhero/events"
+)
+
+// Types of Super (as /api/v2 names them), stored as the superhero_super_type enum
+const (
+	TypeHero     = "HERO"
+	TypeVillain  = "VILLAIN"
+	TypeNeutral  = "NEUTRAL"
+	TypeAntihero = "ANTIHERO"
+)
+
+// Types are every type of Super, in the order of the enum
+var Types = []string{TypeHero, TypeVillain, TypeNeutral, TypeAntihero}
+
+// TypeVilanV1 is how the v1 APIs (/api/v1, its GraphQL and superhero.v1 gRPC) name a villain.
+// It is still accepted as VILLAIN, and old versions of Supers (see History) may have it
+const TypeVilanV1 = "VILAN"
+
+// V1Types are every type of Super, as the v1 APIs name them
+var V1Types = []string{TypeHero, TypeVilanV1}
+
+// API versions, naming the types of Supers as above (see TypeAs)
+const (
+	APIVersion1 = "v1"
+	APIVersion2 = "v2"
+)
+
+// superTypeEnum creates the superhero_super_type enum (if not exists)
+const superTypeEnum = `DO $$ BEGIN
+	CREATE TYPE superhero_super_type AS ENUM ('HERO', 'VILLAIN', 'NEUTRAL', 'ANTIHERO');
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$`
+
+// NormalizeType is the type t (case-insensitive, VILAN is VILLAIN), or "" if there is no such type
+func NormalizeType(t string) string {
+	t = strings.ToUpper(t)
+	if t == TypeVilanV1 {
+		return TypeVillain
+	}
+	for _, valid := range Types {
+		if t == valid {
+			return t
+		}
+	}
+	return ""
+}
+
+// V1Type is the type t as the v1 APIs name it: VILLAIN is VILAN, and the types v1 does not have (NEUTRAL and
+// ANTIHERO) fall back to HERO
+func V1Type(t string) string {
+	switch NormalizeType(t) {
+	case TypeVillain:
+		return TypeVilanV1
+	case "":
+		return t
+	default:
+		return TypeHero
+	}
+}
+
+// TypeAs is the type t (as stored) as the API version names it: V1Type for v1, normalized for v2 (old versions of
+// Supers may be VILAN)
+func TypeAs(version, t string) string {
+	if version == APIVersion1 {
+		return V1Type(t)
+	}
+	if normalized := NormalizeType(t); normalized != "" {
+		return normalized
+	}
+	return t
+}
+
+// EventAs is a copy of the event, with the type of a Super as the API version names it (see TypeAs)
+func EventAs(e events.Event, version string) events.Event {
+	e.APIVersion = version
+	if t, ok := e.Data["type"].(string); ok && e.Entity == AuditEntitySuper {
+		data := make(map[string]interface{}, len(e.Data))
+		for key, value := range e.Data {
+			data[key] = value
+		}
+		data["type"] = TypeAs(version, t)
+		e.Data = data
+	}
+	return e
+}
+
+// FromV1Type is the type t sent by a v1 client (HERO or VILAN, case-insensitive). current is the type of the
+// Super being replaced ("" if none): it is kept if t is how v1 names it, so a v1 client replacing (or
+// reverting) a NEUTRAL or ANTIHERO Super does not make it a HERO
+func FromV1Type(t, current string) (string, error) {
+	switch strings.ToUpper(t) {
+	case TypeHero, TypeVilanV1:
+		if current != "" && V1Type(current) == strings.ToUpper(t) {
+			return current, nil
+		}
+		return NormalizeType(t), nil
+	default:
+		return t, &ErrorSuperInvalidFields{"Type should be one of [\"HERO\", \"VILAN\"]"}
+	}
+}
+
+// FilterByV1Type makes the filter s.Type (as a v1 client sends it) list the Supers a v1 client sees as that type:
+// HERO lists the fallbacks too (see V1Type). Other types are left as they are
+func (s *Super) FilterByV1Type() {
+	switch strings.ToUpper(s.Type) {
+	case TypeHero:
+		s.Type, s.Types = "", []string{TypeHero, TypeNeutral, TypeAntihero}
+	case TypeVilanV1:
+		s.Type, s.Types = "", []string{TypeVillain}
+	}
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeType(t *testing.T) {
+	for _, typ := range Types {
+		assert.Equal(t, typ, NormalizeType(typ))
+	}
+	assert.Equal(t, TypeVillain, NormalizeType("Vilan"))
+	assert.Equal(t, TypeAntihero, NormalizeType("antihero"))
+	assert.Equal(t, "", NormalizeType("SIDEKICK"))
+	assert.Equal(t, "", NormalizeType(""))
+}
+
+func TestV1Type(t *testing.T) {
+	assert.Equal(t, "HERO", V1Type(TypeHero))
+	assert.Equal(t, "VILAN", V1Type(TypeVillain))
+	assert.Equal(t, "VILAN", V1Type("VILAN"))
+	assert.Equal(t, "HERO", V1Type(TypeNeutral), "falls back to HERO")
+	assert.Equal(t, "HERO", V1Type(TypeAntihero), "falls back to HERO")
+	assert.Equal(t, "SIDEKICK", V1Type("SIDEKICK"))
+}
+
+func TestFromV1Type(t *testing.T) {
+	for _, test := range []struct {
+		t, current, expected string
+	}{
+		{"HERO", "", TypeHero},
+		{"vilan", "", TypeVillain},
+		{"HERO", TypeVillain, TypeHero},
+		{"VILAN", TypeAntihero, TypeVillain},
+		{"HERO", TypeNeutral, TypeNeutral},
+		{"hero", TypeAntihero, TypeAntihero},
+		{"VILAN", TypeVillain, TypeVillain},
+	} {
+		typ, err := FromV1Type(test.t, test.current)
+		if assert.NoError(t, err, test) {
+			assert.Equal(t, test.expected, typ, test)
+		}
+	}
+
+	for _, typ := range []string{"VILLAIN", "NEUTRAL", "ANTIHERO", "SIDEKICK", ""} {
+		_, err := FromV1Type(typ, "")
+		assert.IsType(t, &ErrorSuperInvalidFields{}, err, typ)
+	}
+}
+
+func TestSuperFilterByV1Type(t *testing.T) {
+	for _, test := range []struct {
+		t             string
+		expectedType  string
+		expectedTypes []string
+	}{
+		{"HERO", "", []string{TypeHero, TypeNeutral, TypeAntihero}},
+		{"vilan", "", []string{TypeVillain}},
+		{"", "", nil},
+		{"NEUTRAL", "NEUTRAL", nil},
+	} {
+		filter := &Super{Type: test.t}
+		filter.FilterByV1Type()
+		assert.Equal(t, test.expectedType, filter.Type, test.t)
+		assert.Equal(t, test.expectedTypes, filter.Types, test.t)
+	}
+}
+
+func TestTypeAs(t *testing.T) {
+	assert.Equal(t, TypeVilanV1, TypeAs(APIVersion1, TypeVillain))
+	assert.Equal(t, TypeHero, TypeAs(APIVersion1, TypeAntihero))
+	assert.Equal(t, TypeVillain, TypeAs(APIVersion2, TypeVilanV1))
+	assert.Equal(t, TypeAntihero, TypeAs(APIVersion2, TypeAntihero))
+}
+
+func TestEventAs(t *testing.T) {
+	o := &OutboxEvent{Entity: AuditEntitySuper, Data: map[string]interface{}{"type": TypeNeutral, "name": "Deadpool"}}
+	assert.Equal(t, TypeNeutral, o.Event().Data["type"], "the outbox has the stored types")
+
+	v1 := EventAs(o.Event(), APIVersion1)
+	assert.Equal(t, APIVersion1, v1.APIVersion)
+	assert.Equal(t, TypeHero, v1.Data["type"])
+	assert.Equal(t, TypeNeutral, o.Data["type"], "the outbox is not changed")
+
+	v2 := EventAs(o.Event(), APIVersion2)
+	assert.Equal(t, APIVersion2, v2.APIVersion)
+	assert.Equal(t, TypeNeutral, v2.Data["type"])
+
+	o.Data["type"] = TypeVillain
+	assert.Equal(t, TypeVilanV1, EventAs(o.Event(), APIVersion1).Data["type"])
+	assert.Equal(t, TypeVillain, EventAs(o.Event(), APIVersion2).Data["type"])
+
+	o = &OutboxEvent{Entity: AuditEntityGroup, Data: map[string]interface{}{"type": "VILLAIN"}}
+	assert.Equal(t, "VILLAIN", EventAs(o.Event(), APIVersion1).Data["type"])
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultUniverse is the universe of the Supers created without one (and of the ones created before universes)
+const DefaultUniverse = "default"
+
+// universeSeparator separates the universe from the name, in the ref of a Super (see Ref)
+const universeSeparator = ":"
+
+// maxUniverseLength is the maximum length of the name of a universe
+const maxUniverseLength = 64
+
+// universePattern is a valid (lowercased) universe name: letters, digits, '-' and '_' (eg: marvel, dc, earth-616)
+var universePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// NormalizeUniverse is the universe u (case-insensitive), or "" if it is not a valid universe name
+func NormalizeUniverse(u string) string {
+	u = strings.ToLower(u)
+	if len(u) > maxUniverseLength || !universePattern.MatchString(u) {
+		return ""
+	}
+	return u
+}
+
+// splitSuperRef is the universe and the name of the ref of a Super ("universe:name", or a name in DefaultUniverse).
+// The ref is only split if what is before the first ':' is a valid universe name
+func splitSuperRef(ref string) (string, string) {
+	if i := strings.Index(ref, universeSeparator); i > 0 {
+		if universe := NormalizeUniverse(ref[:i]); universe != "" {
+			return universe, ref[i+len(universeSeparator):]
+		}
+	}
+	return DefaultUniverse, ref
+}
+
+// Ref is how the Super is found by name (see GetByNameOrUUID): "universe:name", or only its name in DefaultUniverse
+// (unless the name itself would be split as a ref)
+func (s *Super) Ref() string {
+	universe := s.Universe
+	if universe == "" {
+		universe = DefaultUniverse
+	}
+	if universe == DefaultUniverse {
+		if _, name := splitSuperRef(s.Name); name == s.Name {
+			return s.Name
+		}
+	}
+	return universe + universeSeparator + s.Name
+}
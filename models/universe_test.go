@@ -0,0 +1,66 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-pg/pg/v9/orm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuperUniverseColumnDefault(t *testing.T) {
+	// CreateSchema creates the column with this default, as migration 10 adds it
+	field := orm.GetTable(reflect.TypeOf(Super{})).FieldsMap["universe"]
+	if assert.NotNil(t, field) {
+		assert.Equal(t, "'"+DefaultUniverse+"'", string(field.Default))
+	}
+}
+
+func TestNormalizeUniverse(t *testing.T) {
+	assert.Equal(t, "marvel", NormalizeUniverse("Marvel"))
+	assert.Equal(t, "earth-616", NormalizeUniverse("earth-616"))
+	assert.Equal(t, "dc_rebirth", NormalizeUniverse("dc_rebirth"))
+	assert.Equal(t, "", NormalizeUniverse(""))
+	assert.Equal(t, "", NormalizeUniverse("DC Comics"))
+	assert.Equal(t, "", NormalizeUniverse("-marvel"))
+	assert.Equal(t, "", NormalizeUniverse("marvel:dc"))
+	assert.Equal(t, "", NormalizeUniverse(strings.Repeat("a", maxUniverseLength+1)))
+}
+
+func TestSplitSuperRef(t *testing.T) {
+	for _, test := range []struct {
+		ref, universe, name string
+	}{
+		{"Batman", DefaultUniverse, "Batman"},
+		{"Marvel:Captain Marvel", "marvel", "Captain Marvel"},
+		{"dc:Captain Marvel", "dc", "Captain Marvel"},
+		{"default:Batman", DefaultUniverse, "Batman"},
+		{"dc:Mr: Freeze", "dc", "Mr: Freeze"},
+		{"Mr. X: the return", DefaultUniverse, "Mr. X: the return"},
+		{":Batman", DefaultUniverse, ":Batman"},
+	} {
+		universe, name := splitSuperRef(test.ref)
+		assert.Equal(t, test.universe, universe, test.ref)
+		assert.Equal(t, test.name, name, test.ref)
+	}
+}
+
+func TestSuperRef(t *testing.T) {
+	assert.Equal(t, "Batman", (&Super{Universe: DefaultUniverse, Name: "Batman"}).Ref())
+	assert.Equal(t, "Batman", (&Super{Name: "Batman"}).Ref())
+	assert.Equal(t, "marvel:Captain Marvel", (&Super{Universe: "marvel", Name: "Captain Marvel"}).Ref())
+	assert.Equal(t, "default:Mr: Freeze", (&Super{Universe: DefaultUniverse, Name: "Mr: Freeze"}).Ref(),
+		"the name would be split as a ref")
+	assert.Equal(t, "Mr. X: the return", (&Super{Name: "Mr. X: the return"}).Ref())
+
+	for _, super := range []*Super{
+		{Universe: "dc", Name: "Captain Marvel"},
+		{Universe: DefaultUniverse, Name: "Mr: Freeze"},
+		{Universe: DefaultUniverse, Name: "Batman"},
+	} {
+		universe, name := splitSuperRef(super.Ref())
+		assert.Equal(t, super.Universe, universe, super.Ref())
+		assert.Equal(t, super.Name, name, super.Ref())
+	}
+}
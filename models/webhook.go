@@ -0,0 +1,487 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
+
+	"github.com/tcarreira/superhero/events"
+)
+
+// WebhooksConsumer is the outbox consumer which queues the webhook deliveries
+const WebhooksConsumer = "webhooks"
+
+// Webhook delivery status
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// AllowPrivateWebhookTargets lets Webhooks target loopback, link-local and private addresses (eg: a receiver in the
+// same network). Off by default (see serve), so Webhooks can't reach the internal services of the server (SSRF)
+var AllowPrivateWebhookTargets = false
+
+// privateNetworks are the loopback, link-local, private and unspecified networks (IPv4 and IPv6)
+var privateNetworks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12", "192.168.0.0/16",
+		"::/128", "::1/128", "fc00::/7", "fe80::/10",
+	} {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// errPrivateWebhookTarget is the error of a Webhook targeting a private address (see AllowPrivateWebhookTargets)
+var errPrivateWebhookTarget = errors.New("webhooks can't target loopback, link-local or private addresses")
+
+// CheckWebhookTarget fails if a Webhook may not connect to ip (see AllowPrivateWebhookTargets)
+func CheckWebhookTarget(ip net.IP) error {
+	if ip == nil {
+		return errors.New("invalid webhook target address")
+	}
+	if AllowPrivateWebhookTargets {
+		return nil
+	}
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return errPrivateWebhookTarget
+		}
+	}
+	return nil
+}
+
+// Webhook is a subscription of an external endpoint to the events of Supers and Groups
+// swagger:model Webhook
+type Webhook struct {
+	tableName           struct{}   `json:"-" pg:"superhero_webhooks,alias:wh"` // json tag for swaggo bug
+	ID                  uint64     `json:"id" pg:",pk"`
+	URL                 string     `json:"url" example:"https://example.com/hooks/superhero" pg:",notnull"`
+	Secret              string     `json:"secret,omitempty" example:"3f2a9c..." pg:",notnull"`
+	Entities            []string   `json:"entities" example:"super" pg:",array"`      // empty for every entity
+	Types               []string   `json:"types" example:"super.created" pg:",array"` // empty for every type
+	APIVersion          string     `json:"api_version" example:"v2" pg:",notnull"`    // the API it was registered with: its payloads name the types of Supers as it does
+	Enabled             bool       `json:"enabled" pg:",notnull,use_zero"`
+	ConsecutiveFailures int        `json:"consecutive_failures" pg:",notnull,use_zero"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"` // set when disabled after repeated failures
+	CreatedAt           time.Time  `json:"created_at" pg:",notnull,default:now()"`
+}
+
+// WebhookDelivery is an event to be delivered to a Webhook
+// swagger:model WebhookDelivery
+type WebhookDelivery struct {
+	tableName      struct{}   `json:"-" pg:"superhero_webhook_deliveries,alias:wd"` // json tag for swaggo bug
+	ID             uint64     `json:"id" pg:",pk"`
+	WebhookID      uint64     `json:"webhook_id" pg:",notnull"`
+	EventID        uint64     `json:"event_id" pg:",notnull"` // OutboxEvent ID
+	EventType      string     `json:"event_type" example:"super.created" pg:",notnull"`
+	Payload        []byte     `json:"-" pg:",notnull"`
+	Status         string     `json:"status" example:"pending" enums:"pending,delivered,failed" pg:",notnull"`
+	Attempts       int        `json:"attempts" pg:",notnull,use_zero"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" pg:",notnull,default:now()"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at" pg:",notnull,default:now()"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// WebhookAttempt records each attempt to deliver a WebhookDelivery
+// swagger:model WebhookAttempt
+type WebhookAttempt struct {
+	tableName  struct{}  `json:"-" pg:"superhero_webhook_attempts,alias:wa"` // json tag for swaggo bug
+	ID         uint64    `json:"id" pg:",pk"`
+	DeliveryID uint64    `json:"delivery_id" pg:",notnull"`
+	WebhookID  uint64    `json:"webhook_id" pg:",notnull"`
+	Attempt    int       `json:"attempt" pg:",notnull"`
+	StatusCode int       `json:"status_code,omitempty"` // 0 when no response was received
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms" pg:",notnull,use_zero"`
+	CreatedAt  time.Time `json:"created_at" pg:",notnull,default:now()"`
+}
+
+// ErrorWebhookNotFound Webhook Not Found - extends error
+type ErrorWebhookNotFound struct {
+	s string
+}
+
+func (e *ErrorWebhookNotFound) Error() string {
+	return e.s
+}
+
+// ErrorWebhookInvalidFields Webhook Invalid Fields - extends error
+type ErrorWebhookInvalidFields struct {
+	s string
+}
+
+func (e *ErrorWebhookInvalidFields) Error() string {
+	return e.s
+}
+
+// Succeeded tells if the endpoint accepted the delivery (2xx)
+func (a *WebhookAttempt) Succeeded() bool {
+	return a.Error == "" && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// Matches tells if the Webhook is subscribed to the event
+func (w *Webhook) Matches(e events.Event) bool {
+	return events.Filter{Entities: w.Entities, Types: w.Types}.Match(e)
+}
+
+// newWebhookSecret generates a random secret for signing the deliveries
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// validate the Webhook, normalizing its filters
+func (w *Webhook) validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &ErrorWebhookInvalidFields{"url should be an absolute http(s) URL"}
+	}
+	// the addresses of names are only known (and checked) when delivering (see CheckWebhookTarget)
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		host = "127.0.0.1"
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if err := CheckWebhookTarget(ip); err != nil {
+			return &ErrorWebhookInvalidFields{"url: " + err.Error()}
+		}
+	}
+
+	if w.APIVersion != "" && w.APIVersion != APIVersion1 && w.APIVersion != APIVersion2 {
+		return &ErrorWebhookInvalidFields{"api_version should be one of [\"v1\", \"v2\"]"}
+	}
+	for i := range w.Entities {
+		w.Entities[i] = strings.ToLower(strings.TrimSpace(w.Entities[i]))
+		if w.Entities[i] != AuditEntitySuper && w.Entities[i] != AuditEntityGroup {
+			return &ErrorWebhookInvalidFields{"entities should be one of [\"super\", \"group\"]"}
+		}
+	}
+	for i := range w.Types {
+		w.Types[i] = strings.ToLower(strings.TrimSpace(w.Types[i]))
+		if !isEventType(w.Types[i]) {
+			return &ErrorWebhookInvalidFields{"unknown event type: " + w.Types[i]}
+		}
+	}
+	if w.Entities == nil {
+		w.Entities = make([]string, 0)
+	}
+	if w.Types == nil {
+		w.Types = make([]string, 0)
+	}
+
+	return nil
+}
+
+func isEventType(t string) bool {
+	for _, valid := range events.Types {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Create a Webhook. A secret is generated when none is given, and its API version is v1 when not set
+func (w *Webhook) Create(db orm.DB) error {
+	db, span := traceORM(db, "Webhook.Create")
+	defer span.End()
+
+	if err := w.validate(); err != nil {
+		return err
+	}
+	if w.Secret == "" {
+		w.Secret = newWebhookSecret()
+	}
+	if w.APIVersion == "" {
+		w.APIVersion = APIVersion1
+	}
+	w.ConsecutiveFailures = 0
+	w.DisabledAt = nil
+
+	return db.Insert(w)
+}
+
+// GetByID gets a Webhook
+func (w *Webhook) GetByID(db orm.DB, id uint64) (*Webhook, error) {
+	db, span := traceORM(db, "Webhook.GetByID")
+	defer span.End()
+
+	webhook := Webhook{}
+
+	err := db.Model(&webhook).Where("wh.id = ?", id).Select()
+	if err != nil {
+		if err == pg.ErrNoRows {
+			return &webhook, &ErrorWebhookNotFound{"Webhook Not Found"}
+		}
+		return &webhook, err
+	}
+
+	return &webhook, nil
+}
+
+// ReadAll gets every Webhook
+func (w *Webhook) ReadAll(db orm.DB) ([]Webhook, error) {
+	db, span := traceORM(db, "Webhook.ReadAll")
+	defer span.End()
+
+	webhooks := make([]Webhook, 0)
+	err := db.Model(&webhooks).Order("wh.id").Select()
+	return webhooks, err
+}
+
+// UpdateByID replaces the url, filters and enabled flag of the Webhook (and the secret, when given).
+// Enabling a Webhook resets its failures, resuming its pending deliveries
+func (w *Webhook) UpdateByID(db orm.DB, id uint64) (*Webhook, error) {
+	db, span := traceORM(db, "Webhook.UpdateByID")
+	defer span.End()
+
+	if err := w.validate(); err != nil {
+		return w, err
+	}
+	w.ID = id
+
+	q := db.Model(w).
+		Set("url = ?url").
+		Set("entities = ?entities").
+		Set("types = ?types").
+		Set("enabled = ?enabled").
+		WherePK()
+	if w.Secret != "" {
+		q = q.Set("secret = ?secret")
+	}
+	if w.Enabled {
+		q = q.Set("consecutive_failures = 0").Set("disabled_at = NULL")
+	}
+
+	res, err := q.Returning("*").Update()
+	if err != nil {
+		return w, err
+	}
+	if res.RowsAffected() < 1 {
+		return w, &ErrorWebhookNotFound{"Webhook Not Found"}
+	}
+
+	return w, nil
+}
+
+// DeleteByID deletes the Webhook, with its deliveries
+func (w *Webhook) DeleteByID(db *pg.DB, id uint64) error {
+	db, span := traceDB(db, "Webhook.DeleteByID")
+	defer span.End()
+
+	return db.RunInTransaction(func(tx *pg.Tx) error {
+		if _, err := tx.Model((*WebhookAttempt)(nil)).Where("webhook_id = ?", id).Delete(); err != nil {
+			return err
+		}
+		if _, err := tx.Model((*WebhookDelivery)(nil)).Where("webhook_id = ?", id).Delete(); err != nil {
+			return err
+		}
+
+		res, err := tx.Model((*Webhook)(nil)).Where("id = ?", id).Delete()
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() < 1 {
+			return &ErrorWebhookNotFound{"Webhook Not Found"}
+		}
+		return nil
+	})
+}
+
+// Deliveries gets the most recent deliveries of the Webhook (newest first)
+func (w *Webhook) Deliveries(db orm.DB, id uint64, limit int) ([]WebhookDelivery, error) {
+	db, span := traceORM(db, "Webhook.Deliveries")
+	defer span.End()
+
+	deliveries := make([]WebhookDelivery, 0)
+	err := db.Model(&deliveries).
+		Where("wd.webhook_id = ?", id).
+		Order("wd.id DESC").
+		Limit(limit).
+		Select()
+	return deliveries, err
+}
+
+// ReadAttempts gets every attempt of the delivery (oldest first)
+func (d *WebhookDelivery) ReadAttempts(db orm.DB) ([]WebhookAttempt, error) {
+	db, span := traceORM(db, "WebhookDelivery.ReadAttempts")
+	defer span.End()
+
+	attempts := make([]WebhookAttempt, 0)
+	err := db.Model(&attempts).
+		Where("wa.delivery_id = ?", d.ID).
+		Order("wa.id").
+		Select()
+	return attempts, err
+}
+
+// queueWebhookDeliveries creates a delivery of each event for every enabled Webhook subscribed to it, with the
+// payload of its API version
+func queueWebhookDeliveries(tx *pg.Tx, outbox []OutboxEvent) error {
+	webhooks := make([]Webhook, 0)
+	if err := tx.Model(&webhooks).Where("wh.enabled").Select(); err != nil {
+		return err
+	}
+
+	deliveries := make([]WebhookDelivery, 0)
+	for _, o := range outbox {
+		e := o.Event()
+		payloads := make(map[string][]byte) // by API version
+
+		for _, w := range webhooks {
+			if w.Matches(e) {
+				payload, ok := payloads[w.APIVersion]
+				if !ok {
+					var err error
+					if payload, err = json.Marshal(EventAs(e, w.APIVersion)); err != nil {
+						return err
+					}
+					payloads[w.APIVersion] = payload
+				}
+				deliveries = append(deliveries, WebhookDelivery{
+					WebhookID: w.ID,
+					EventID:   e.ID,
+					EventType: e.Type,
+					Payload:   payload,
+					Status:    WebhookDeliveryPending,
+				})
+			}
+		}
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+	_, err := tx.Model(&deliveries).Insert()
+	return err
+}
+
+// QueueWebhookDeliveries reads up to limit new events from the outbox, queueing their deliveries.
+// Returns how many events were read
+func QueueWebhookDeliveries(db *pg.DB, limit int) (int, error) {
+	db, span := traceDB(db, "QueueWebhookDeliveries")
+	defer span.End()
+
+	return ProcessOutbox(db, WebhooksConsumer, limit, queueWebhookDeliveries)
+}
+
+// ClaimWebhookDeliveries gets up to limit pending deliveries which are due, of enabled Webhooks.
+// They are not due again for lease, so concurrent workers do not deliver them twice
+func ClaimWebhookDeliveries(db *pg.DB, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	db, span := traceDB(db, "ClaimWebhookDeliveries")
+	defer span.End()
+
+	deliveries := make([]WebhookDelivery, 0)
+
+	due := db.Model((*WebhookDelivery)(nil)).
+		Column("wd.id").
+		Join("JOIN superhero_webhooks AS wh ON wh.id = wd.webhook_id").
+		Where("wh.enabled").
+		Where("wd.status = ?", WebhookDeliveryPending).
+		Where("wd.next_attempt_at <= now()").
+		Order("wd.next_attempt_at").
+		Limit(limit).
+		For("UPDATE OF wd SKIP LOCKED")
+
+	_, err := db.Query(&deliveries, `
+		UPDATE superhero_webhook_deliveries AS wd
+		SET next_attempt_at = now() + ? * interval '1 second'
+		WHERE wd.id IN (?)
+		RETURNING wd.*`,
+		lease.Seconds(), due)
+
+	return deliveries, err
+}
+
+// Renew leases the claimed delivery again (see ClaimWebhookDeliveries), right before attempting it. It is not (false)
+// when it was claimed again meanwhile (after its lease expired), or when its Webhook is no longer enabled (eg: disabled
+// after the failures of the previous deliveries of a batch)
+func (d *WebhookDelivery) Renew(db *pg.DB, lease time.Duration) (bool, error) {
+	db, span := traceDB(db, "WebhookDelivery.Renew")
+	defer span.End()
+
+	_, err := db.QueryOne(d, `
+		UPDATE superhero_webhook_deliveries AS wd
+		SET next_attempt_at = now() + ? * interval '1 second'
+		FROM superhero_webhooks AS wh
+		WHERE wd.id = ? AND wd.status = ? AND wd.next_attempt_at = ? AND wh.id = wd.webhook_id AND wh.enabled
+		RETURNING wd.*`,
+		lease.Seconds(), d.ID, WebhookDeliveryPending, d.NextAttemptAt)
+	if err == pg.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RecordAttempt saves an attempt to deliver d. When it failed, the delivery is retried at retryAt,
+// or marked as failed when retryAt is zero. The Webhook is disabled after disableAfter consecutive failures
+func (d *WebhookDelivery) RecordAttempt(db *pg.DB, attempt *WebhookAttempt, retryAt time.Time, disableAfter int) error {
+	db, span := traceDB(db, "WebhookDelivery.RecordAttempt")
+	defer span.End()
+
+	return db.RunInTransaction(func(tx *pg.Tx) error {
+		d.Attempts++
+		attempt.DeliveryID = d.ID
+		attempt.WebhookID = d.WebhookID
+		attempt.Attempt = d.Attempts
+		if err := tx.Insert(attempt); err != nil {
+			return err
+		}
+
+		d.LastStatusCode = attempt.StatusCode
+		d.LastError = attempt.Error
+		q := tx.Model(d).Column("attempts", "last_status_code", "last_error")
+
+		if attempt.Succeeded() {
+			now := time.Now()
+			d.Status = WebhookDeliveryDelivered
+			d.DeliveredAt = &now
+			if _, err := q.Column("status", "delivered_at").WherePK().Update(); err != nil {
+				return err
+			}
+
+			_, err := tx.Model((*Webhook)(nil)).
+				Set("consecutive_failures = 0").
+				Where("id = ?", d.WebhookID).
+				Update()
+			return err
+		}
+
+		if retryAt.IsZero() {
+			d.Status = WebhookDeliveryFailed
+		} else {
+			d.NextAttemptAt = retryAt
+		}
+		if _, err := q.Column("status", "next_attempt_at").WherePK().Update(); err != nil {
+			return err
+		}
+
+		_, err := tx.Model((*Webhook)(nil)).
+			Set("consecutive_failures = consecutive_failures + 1").
+			Set("enabled = enabled AND consecutive_failures + 1 < ?", disableAfter).
+			Set("disabled_at = CASE WHEN enabled AND consecutive_failures + 1 >= ? THEN now() ELSE disabled_at END", disableAfter).
+			Where("id = ?", d.WebhookID).
+			Update()
+		return err
+	})
+}
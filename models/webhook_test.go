@@ -0,0 +1,63 @@
+package models
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/events"
+)
+
+func TestWebhook_validate(t *testing.T) {
+	for _, invalid := range []Webhook{
+		{URL: "not a url"},
+		{URL: "ftp://example.com/hook"},
+		{URL: "/relative/hook"},
+		{URL: "https://example.com/hook", Entities: []string{"planet"}},
+		{URL: "https://example.com/hook", Types: []string{"super.exploded"}},
+		{URL: "http://127.0.0.1:8080/hook"},
+		{URL: "http://localhost/hook"},
+		{URL: "http://169.254.169.254/latest/meta-data/"},
+		{URL: "http://10.1.2.3/hook"},
+		{URL: "http://[::1]/hook"},
+		{URL: "http://[::ffff:192.168.0.1]/hook"},
+		{URL: "https://example.com/hook", APIVersion: "v3"},
+	} {
+		err := invalid.validate()
+		assert.IsType(t, &ErrorWebhookInvalidFields{}, err, invalid)
+	}
+
+	w := Webhook{URL: "https://example.com/hook", Entities: []string{" Super "}}
+	assert.NoError(t, w.validate())
+	assert.Equal(t, []string{"super"}, w.Entities)
+	assert.Equal(t, []string{}, w.Types)
+}
+
+func TestCheckWebhookTarget(t *testing.T) {
+	assert.NoError(t, CheckWebhookTarget(net.ParseIP("93.184.216.34")))
+	assert.NoError(t, CheckWebhookTarget(net.ParseIP("2606:2800:220:1:248:1893:25c8:1946")))
+	for _, private := range []string{"127.0.0.1", "10.0.0.1", "172.16.5.4", "192.168.1.1", "169.254.169.254", "0.0.0.0", "::1", "fd00::1", "fe80::1"} {
+		assert.Error(t, CheckWebhookTarget(net.ParseIP(private)), private)
+	}
+	assert.Error(t, CheckWebhookTarget(nil))
+
+	AllowPrivateWebhookTargets = true
+	t.Cleanup(func() { AllowPrivateWebhookTargets = false })
+	assert.NoError(t, CheckWebhookTarget(net.ParseIP("127.0.0.1")))
+	assert.NoError(t, (&Webhook{URL: "http://localhost:8080/hook"}).validate())
+}
+
+func TestWebhook_Matches(t *testing.T) {
+	e := events.Event{Type: events.SuperCreated, Entity: AuditEntitySuper}
+
+	assert.True(t, (&Webhook{}).Matches(e))
+	assert.True(t, (&Webhook{Types: []string{events.SuperCreated}}).Matches(e))
+	assert.False(t, (&Webhook{Entities: []string{AuditEntityGroup}}).Matches(e))
+}
+
+func TestWebhookAttempt_Succeeded(t *testing.T) {
+	assert.True(t, (&WebhookAttempt{StatusCode: 204}).Succeeded())
+	assert.False(t, (&WebhookAttempt{StatusCode: 500}).Succeeded())
+	assert.False(t, (&WebhookAttempt{Error: "connection refused"}).Succeeded())
+}
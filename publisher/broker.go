@@ -0,0 +1,56 @@
+package publisher
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Message is a message published to a broker
+type Message struct {
+	Exchange    string
+	RoutingKey  string
+	ContentType string
+	MessageID   string
+	Type        string
+	Timestamp   time.Time
+	Headers     map[string]interface{}
+	Body        []byte
+}
+
+// Broker publishes messages. Publish only returns once the broker has accepted the message
+type Broker interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// FakeBroker is an in-memory Broker, for tests
+type FakeBroker struct {
+	mu       sync.Mutex
+	messages []Message
+	Err      error // returned by Publish, when set
+}
+
+// Publish records the message (or fails with Err)
+func (b *FakeBroker) Publish(ctx context.Context, msg Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.Err != nil {
+		return b.Err
+	}
+	b.messages = append(b.messages, msg)
+	return nil
+}
+
+// Close does nothing
+func (b *FakeBroker) Close() error {
+	return nil
+}
+
+// Messages returns every published message
+func (b *FakeBroker) Messages() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Message(nil), b.messages...)
+}
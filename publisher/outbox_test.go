@@ -0,0 +1,62 @@
+// +build sql
+
+package publisher
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/events"
+	"github.com/tcarreira/superhero/models"
+)
+
+func TestPublisherRunOnce(t *testing.T) {
+	d := models.SetupEmptyTestDatabase()
+	broker := &FakeBroker{}
+	p := NewPublisher(d, broker)
+	ctx := context.Background()
+
+	super := models.Super{Type: "HERO", Name: "amqp1"}
+	if _, err := super.Create(d); err != nil {
+		t.Fatal(err)
+	}
+	group := models.Group{Name: "amqp-group", Supers: []models.Super{{Name: "amqp1"}}}
+	if _, err := group.Create(d); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("TestPublisherRunOnce - publishes the selected events", func(t *testing.T) {
+		assert.NoError(t, p.RunOnce(ctx))
+
+		messages := broker.Messages()
+		if assert.Len(t, messages, 3) {
+			assert.Equal(t, events.SuperCreated, messages[0].RoutingKey)
+			assert.Equal(t, events.GroupCreated, messages[1].RoutingKey)
+			assert.Equal(t, events.GroupMemberAdded, messages[2].RoutingKey)
+			assert.Equal(t, DefaultExchange, messages[0].Exchange)
+		}
+
+		assert.NoError(t, p.RunOnce(ctx))
+		assert.Len(t, broker.Messages(), 3)
+	})
+
+	t.Run("TestPublisherRunOnce - retries when the broker fails", func(t *testing.T) {
+		if err := new(models.Super).DeleteByNameOrUUID(d, "amqp1"); err != nil {
+			t.Fatal(err)
+		}
+
+		broker.Err = errors.New("broker is down")
+		assert.Error(t, p.RunOnce(ctx))
+
+		broker.Err = nil
+		assert.NoError(t, p.RunOnce(ctx))
+
+		messages := broker.Messages()
+		if assert.Len(t, messages, 4) {
+			assert.Equal(t, events.SuperDeleted, messages[3].RoutingKey)
+		}
+	})
+}
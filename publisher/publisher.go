@@ -0,0 +1,155 @@
+// Package publisher publishes the domain events of Supers and Groups to a message broker (AMQP).
+// Events are read from the outbox, so they are only published for committed changes
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+
+	"github.com/tcarreira/superhero/events"
+	"github.com/tcarreira/superhero/logging"
+	"github.com/tcarreira/superhero/models"
+)
+
+// OutboxConsumer is the outbox consumer name of the publisher
+const OutboxConsumer = "amqp"
+
+// Default Publisher settings
+const (
+	DefaultExchange     = "superhero.events"
+	DefaultPollInterval = time.Second
+	DefaultBatchSize    = 100
+	DefaultTimeout      = 10 * time.Second
+)
+
+// DefaultTypes are the event types published by default
+var DefaultTypes = []string{
+	events.SuperCreated,
+	events.SuperDeleted,
+	events.GroupCreated,
+	events.GroupMemberAdded,
+	events.GroupMemberRemoved,
+}
+
+// Publisher publishes the outbox events to an exchange, with the event type as routing key
+type Publisher struct {
+	DB           *pg.DB
+	Broker       Broker
+	Exchange     string
+	Types        []string // only these event types are published
+	PollInterval time.Duration
+	BatchSize    int
+	Timeout      time.Duration // for each message to be confirmed
+}
+
+// NewPublisher creates a Publisher with the default settings
+func NewPublisher(db *pg.DB, broker Broker) *Publisher {
+	return &Publisher{
+		DB:           db,
+		Broker:       broker,
+		Exchange:     DefaultExchange,
+		Types:        DefaultTypes,
+		PollInterval: DefaultPollInterval,
+		BatchSize:    DefaultBatchSize,
+		Timeout:      DefaultTimeout,
+	}
+}
+
+// FromEnv creates a Publisher for AMQP_URL, publishing to AMQP_EXCHANGE (default "superhero.events").
+// Returns nil when AMQP_URL is not set (publishing is optional)
+func FromEnv(db *pg.DB) *Publisher {
+	url := os.Getenv("AMQP_URL")
+	if url == "" {
+		return nil
+	}
+
+	p := NewPublisher(db, NewAMQPBroker(url))
+	if exchange := os.Getenv("AMQP_EXCHANGE"); exchange != "" {
+		p.Exchange = exchange
+	}
+	return p
+}
+
+// message builds the message for an event, naming the types of Supers as v2 does (see models.EventAs)
+func (p *Publisher) message(e events.Event) (Message, error) {
+	e = models.EventAs(e, models.APIVersion2)
+	body, err := json.Marshal(e)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Exchange:    p.Exchange,
+		RoutingKey:  e.Type,
+		ContentType: "application/json",
+		MessageID:   strconv.FormatUint(e.ID, 10),
+		Type:        e.Type,
+		Timestamp:   e.Time,
+		Headers: map[string]interface{}{
+			"entity":      e.Entity,
+			"entity_id":   e.EntityID,
+			"api_version": e.APIVersion,
+		},
+		Body: body,
+	}, nil
+}
+
+// Run publishes the outbox events every PollInterval, until ctx is done. The broker is closed at the end
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.PollInterval)
+	defer ticker.Stop()
+	defer p.Broker.Close()
+
+	for {
+		if err := p.RunOnce(ctx); err != nil {
+			logging.Logger().Error().Err(err).Msg("AMQP publisher")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce publishes every new outbox event. When publishing fails, the batch is published again later
+// (so a message may be published more than once, with the same MessageID)
+func (p *Publisher) RunOnce(ctx context.Context) error {
+	filter := events.Filter{Types: p.Types}
+
+	for {
+		n, err := models.ProcessOutbox(p.DB, OutboxConsumer, p.BatchSize, func(tx *pg.Tx, outbox []models.OutboxEvent) error {
+			for _, o := range outbox {
+				e := o.Event()
+				if !filter.Match(e) {
+					continue
+				}
+
+				msg, err := p.message(e)
+				if err != nil {
+					return err
+				}
+
+				publishCtx, cancel := context.WithTimeout(ctx, p.Timeout)
+				err = p.Broker.Publish(publishCtx, msg)
+				cancel()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if n < p.BatchSize {
+			return nil
+		}
+	}
+}
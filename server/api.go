@@ -1,8 +1,12 @@
 package server
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-pg/pg/v9"
@@ -11,6 +15,9 @@ import (
 	"github.com/swaggo/gin-swagger/swaggerFiles"
 	_ "github.com/tcarreira/superhero/docs" // swagger import side effects
 
+	"github.com/tcarreira/superhero/config"
+	"github.com/tcarreira/superhero/logging"
+	"github.com/tcarreira/superhero/metrics"
 	"github.com/tcarreira/superhero/models"
 )
 
@@ -48,7 +55,11 @@ type SuperHandler interface {
 	SupersGETFiltersHandler(c *gin.Context)
 	SupersGETByIDHandler(c *gin.Context)
 	SupersPUTHandler(c *gin.Context)
+	SupersPATCHHandler(c *gin.Context)
 	SupersDeleteHandler(c *gin.Context)
+	SupersRestorePOSTHandler(c *gin.Context)
+	SupersHistoryGETHandler(c *gin.Context)
+	SupersRevertPOSTHandler(c *gin.Context)
 }
 
 // SuperAPI implements SuperHandler interface
@@ -58,12 +69,22 @@ type SuperAPI struct {
 }
 
 func (api *SuperAPI) handleSuperCreate(c *gin.Context, super *models.Super) {
-	if _, err := super.Create(api.DB); err != nil {
+	if err := requestVersion(c).fromClient(super, ""); err != nil {
+		api.handleSuperWriteError(c, err)
+		return
+	}
+
+	if _, err := super.Create(requestDB(c, api.DB)); err != nil {
 		if _, ok := err.(*models.ErrorSuperAlreadyExists); ok {
 			c.JSON(http.StatusConflict, errorResponseJSON{
 				"Super already exists - update it instead",
 				err.Error(),
 			})
+		} else if _, ok := err.(*models.ErrorSuperInvalidFields); ok {
+			c.JSON(http.StatusBadRequest, errorResponseJSON{
+				"Invalid Super fields",
+				err.Error(),
+			})
 		} else {
 			c.JSON(http.StatusInternalServerError, errorResponseJSON{
 				"Unexpected error",
@@ -71,7 +92,7 @@ func (api *SuperAPI) handleSuperCreate(c *gin.Context, super *models.Super) {
 			})
 		}
 	} else {
-		c.JSON(http.StatusCreated, super)
+		c.JSON(http.StatusCreated, requestVersion(c).super(super))
 	}
 }
 
@@ -99,8 +120,10 @@ type exampleSuperHeroVilanJSON struct {
 // @Accept  json
 // @Produce  json
 // @Param super body exampleSuperHeroVilanJSON true "super hero name"
+// @Param Idempotency-Key header string false "Unique key, so the request can be safely retried"
 // @Success 201 {object} models.Super "Super was created"
 // @Failure 409 {object} errorResponseJSON "Super already exists"
+// @Failure 422 {object} errorResponseJSON "Idempotency-Key was used with a different request"
 // @Failure 500 {object} errorResponseJSON "Unexpected error"
 // @Router /super-hero [post]
 func (api *SuperAPI) SuperHeroPOSTHandler(c *gin.Context) {
@@ -109,7 +132,7 @@ func (api *SuperAPI) SuperHeroPOSTHandler(c *gin.Context) {
 	if !ok {
 		return
 	}
-	super.Type = "HERO"
+	super.Type = models.TypeHero
 
 	api.handleSuperCreate(c, super)
 }
@@ -121,8 +144,10 @@ func (api *SuperAPI) SuperHeroPOSTHandler(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param super body exampleSuperHeroVilanJSON true "super vilan name"
+// @Param Idempotency-Key header string false "Unique key, so the request can be safely retried"
 // @Success 201 {object} models.Super "Super was created"
 // @Failure 409 {object} errorResponseJSON "Super already exists"
+// @Failure 422 {object} errorResponseJSON "Idempotency-Key was used with a different request"
 // @Router /super-vilan [post]
 func (api *SuperAPI) SuperVilanPOSTHandler(c *gin.Context) {
 
@@ -130,7 +155,7 @@ func (api *SuperAPI) SuperVilanPOSTHandler(c *gin.Context) {
 	if !ok {
 		return
 	}
-	super.Type = "VILAN"
+	super.Type = requestVersion(c).clientType(models.TypeVillain) // as the client sends it
 
 	api.handleSuperCreate(c, super)
 }
@@ -147,8 +172,11 @@ type exampleSuperJSON struct {
 // @Accept  json
 // @Produce  json
 // @Param super body exampleSuperJSON true "super hero (mandatory: name and type)"
+// @Param Idempotency-Key header string false "Unique key, so the request can be safely retried"
 // @Success 201 {object} models.Super "Super was created"
+// @Failure 400 {object} errorResponseJSON "Invalid Super fields"
 // @Failure 409 {object} errorResponseJSON "Super already exists"
+// @Failure 422 {object} errorResponseJSON "Idempotency-Key was used with a different request"
 // @Router /supers [post]
 func (api *SuperAPI) SupersPOSTHandler(c *gin.Context) {
 
@@ -160,14 +188,53 @@ func (api *SuperAPI) SupersPOSTHandler(c *gin.Context) {
 	api.handleSuperCreate(c, super)
 }
 
+// pageQuery reads the limit and offset query parameters (0 when missing).
+// If they are invalid, a 400 response is written and ok is false
+func pageQuery(c *gin.Context) (limit, offset int, ok bool) {
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"limit", &limit}, {"offset", &offset}} {
+		str := c.Query(param.name)
+		if str == "" {
+			continue
+		}
+		n, err := strconv.Atoi(str)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, errorResponseJSON{
+				"Could not process Payload (query parameters)",
+				param.name + " should be a non negative integer",
+			})
+			return 0, 0, false
+		}
+		*param.value = n
+	}
+	return limit, offset, true
+}
+
 // SupersGETFiltersHandler get list of Super @ /supers?type=hero...
 // ---
 // @Summary Get list of Supers
-// @Description Get list of Supers by filtering by name, uuid or type
+// @Description Get list of Supers by filtering by name, uuid, type, universe, profile or minimum powerstats. Use deleted=only to list the deleted Supers (trash)
 // @Produce json
 // @Param name query string false "Super(hero/vilan) Name (case-sensitive)"
 // @Param uuid query string false "Super(hero/vilan) UUID (case-insensitive)"
 // @Param type query string false "Super(hero/vilan) Type (HERO / VILAN) (case-insensitive)"
+// @Param universe query string false "Universe (case-insensitive)"
+// @Param publisher query string false "Publisher (case-insensitive)"
+// @Param alignment query string false "Alignment (good / bad / neutral) (case-insensitive)"
+// @Param gender query string false "Gender (case-insensitive)"
+// @Param race query string false "Race (case-insensitive)"
+// @Param alias query []string false "One of the aliases (case-insensitive). Repeat it to require every one" collectionFormat(multi)
+// @Param min_intelligence query int false "Minimum intelligence"
+// @Param min_strength query int false "Minimum strength"
+// @Param min_speed query int false "Minimum speed"
+// @Param min_durability query int false "Minimum durability"
+// @Param min_power query int false "Minimum power"
+// @Param min_combat query int false "Minimum combat"
+// @Param deleted query string false "only: list only deleted Supers" Enums(only)
+// @Param limit query int false "Maximum number of Supers (default: all)"
+// @Param offset query int false "Skip this number of Supers"
 // @Success 200 {array} models.Super "List of Supers"
 // @Failure 400 {object} errorResponseJSON "Error parsing payload"
 // @Router /supers [get]
@@ -179,19 +246,43 @@ func (api *SuperAPI) SupersGETFiltersHandler(c *gin.Context) {
 			"Could not process Payload (query parameters)",
 			err.Error(),
 		})
-	} else {
-		results := sFilter.ReadAll(api.DB)
-		c.JSON(http.StatusOK, results)
+		return
+	}
+
+	limit, offset, ok := pageQuery(c)
+	if !ok {
+		return
+	}
+
+	v := requestVersion(c)
+	v.typeFilter(&sFilter)
+
+	switch c.Query("deleted") {
+	case "":
+		results := sFilter.ReadPage(readDB(c, api.DB), false, limit, offset)
+		c.JSON(http.StatusOK, v.supers(results))
+	case "only":
+		results := sFilter.ReadPage(readDB(c, api.DB), true, limit, offset)
+		c.JSON(http.StatusOK, v.supers(results))
+	default:
+		c.JSON(http.StatusBadRequest, errorResponseJSON{
+			"Could not process Payload (query parameters)",
+			"deleted should be one of [\"only\"]",
+		})
 	}
 }
 
 // SupersGETByIDHandler a Super @ /supers/:id...
 // ---
 // @Summary Get Super
-// @Description Get a Super by name or uuid
+// @Description Get a Super by name or uuid. The ETag header holds the Super version and a hash of the response (If-None-Match is supported)
 // @Produce json
-// @Param id path string true "Super's Name or UUID"
+// @Param id path string true "Super's Name (universe:name) or UUID"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Param as_of query string false "Get the Super as it was at this moment (RFC3339). Groups are not versioned"
 // @Success 200 {object} models.Super "Super"
+// @Success 304 "Not Modified"
+// @Failure 400 {object} errorResponseJSON "Invalid as_of"
 // @Failure 404 {object} errorResponseJSON "Super Not Found"
 // @Failure 500 {object} errorResponseJSON "Unexpected Error"
 // @Router /supers/{id} [get]
@@ -199,7 +290,26 @@ func (api *SuperAPI) SupersGETByIDHandler(c *gin.Context) {
 	var super *models.Super
 	var err error
 
-	super, err = super.GetByNameOrUUID(api.DB, c.Param("id"))
+	if asOfStr := c.Query("as_of"); asOfStr != "" {
+		asOf, err := time.Parse(time.RFC3339, asOfStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponseJSON{
+				"Could not process Payload (query parameters)",
+				"as_of should be a RFC3339 timestamp (eg: 2026-01-01T00:00:00Z)",
+			})
+			return
+		}
+
+		super, err = super.GetByNameOrUUIDAsOf(readDB(c, api.DB), c.Param("id"), asOf)
+		if err != nil {
+			api.handleSuperWriteError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, requestVersion(c).super(super))
+		return
+	}
+
+	super, err = super.GetByNameOrUUID(readDB(c, api.DB), c.Param("id"))
 	if err != nil {
 		if _, ok := err.(*models.ErrorSuperNotFound); ok {
 			c.JSON(http.StatusNotFound, errorResponseJSON{
@@ -212,52 +322,286 @@ func (api *SuperAPI) SupersGETByIDHandler(c *gin.Context) {
 				err.Error(),
 			})
 		}
+		return
 	}
 
-	c.JSON(http.StatusOK, super)
+	body := requestVersion(c).super(super)
+	if notModified(c, super.Version, body) {
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+// handleSuperWriteError writes the response for an error returned by a Super write operation
+func (api *SuperAPI) handleSuperWriteError(c *gin.Context, err error) {
+	switch err.(type) {
+	case *models.ErrorSuperInvalidFields:
+		c.JSON(http.StatusBadRequest, errorResponseJSON{
+			"Invalid Super fields",
+			err.Error(),
+		})
+	case *models.ErrorSuperNotFound:
+		c.JSON(http.StatusNotFound, errorResponseJSON{
+			"Super Not Found",
+			err.Error(),
+		})
+	case *models.ErrorSuperVersionNotFound:
+		c.JSON(http.StatusNotFound, errorResponseJSON{
+			"Super Version Not Found",
+			err.Error(),
+		})
+	case *models.ErrorSuperAlreadyExists:
+		c.JSON(http.StatusConflict, errorResponseJSON{
+			"Another Super already exists with this name",
+			err.Error(),
+		})
+	case *models.ErrorSuperVersionMismatch:
+		c.JSON(http.StatusPreconditionFailed, errorResponseJSON{
+			"Super was modified meanwhile - get it again and retry",
+			err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, errorResponseJSON{
+			"Unexpected Error",
+			err.Error(),
+		})
+	}
 }
 
-// SupersPUTHandler Update Super
+// SupersPUTHandler Update Super @ /supers/:id
+// ---
+// @Summary Update a Super
+// @Description Replace the fields of a Super (by name or uuid). Fields missing from the payload are kept, so clients
+// @Description without the powerstats and profile do not erase them. Requires If-Match with the current ETag (or *)
+// @Accept  json
+// @Produce json
+// @Param id path string true "Super's Name (universe:name) or UUID"
+// @Param If-Match header string true "ETag of the Super being replaced (or *)"
+// @Param super body models.Super true "super (missing fields are kept)"
+// @Success 200 {object} models.Super "Super was updated"
+// @Failure 400 {object} errorResponseJSON "Invalid payload"
+// @Failure 404 {object} errorResponseJSON "Super Not Found"
+// @Failure 409 {object} errorResponseJSON "Another Super already exists with this name"
+// @Failure 412 {object} errorResponseJSON "Super was modified meanwhile"
+// @Failure 428 {object} errorResponseJSON "If-Match is missing"
+// @Router /supers/{id} [put]
 func (api *SuperAPI) SupersPUTHandler(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, errorResponseJSON{
-		"Not Implemented",
-		"Updating Super is out of scope for now",
-	})
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	super, err := new(models.Super).GetByNameOrUUID(currentDB(c, api.DB), c.Param("id"))
+	if err != nil {
+		api.handleSuperWriteError(c, err)
+		return
+	}
+
+	// fields missing from payload are kept from the current Super: clients written before some fields
+	// (eg: the powerstats and profile) do not know them
+	current := super.Type
+	if err := c.ShouldBindJSON(super); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponseJSON{
+			"Error processing the payload",
+			err.Error(),
+		})
+		return
+	}
+
+	// a version without every type keeps the current one, if the client did not change it (see models.FromV1Type)
+	v := requestVersion(c)
+	if super.Type != current {
+		if err := v.fromClient(super, current); err != nil {
+			api.handleSuperWriteError(c, err)
+			return
+		}
+	}
+
+	super, err = super.UpdateByNameOrUUID(requestDB(c, api.DB), c.Param("id"), version)
+	if err != nil {
+		api.handleSuperWriteError(c, err)
+		return
+	}
+
+	body := v.super(super)
+	c.Header("ETag", etag(super.Version, body))
+	c.JSON(http.StatusOK, body)
+}
+
+// SupersPATCHHandler Partially update Super @ /supers/:id
+// ---
+// @Summary Partially update a Super
+// @Description Update only the given fields of a Super (by name or uuid). Requires If-Match with the current ETag (or *)
+// @Accept  json
+// @Produce json
+// @Param id path string true "Super's Name (universe:name) or UUID"
+// @Param If-Match header string true "ETag of the Super being updated (or *)"
+// @Param super body models.Super true "fields to update"
+// @Success 200 {object} models.Super "Super was updated"
+// @Failure 400 {object} errorResponseJSON "Invalid payload"
+// @Failure 404 {object} errorResponseJSON "Super Not Found"
+// @Failure 409 {object} errorResponseJSON "Another Super already exists with this name"
+// @Failure 412 {object} errorResponseJSON "Super was modified meanwhile"
+// @Failure 428 {object} errorResponseJSON "If-Match is missing"
+// @Router /supers/{id} [patch]
+func (api *SuperAPI) SupersPATCHHandler(c *gin.Context) {
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	super, err := new(models.Super).GetByNameOrUUID(currentDB(c, api.DB), c.Param("id"))
+	if err != nil {
+		api.handleSuperWriteError(c, err)
+		return
+	}
+
+	// fields missing from payload are kept from the current Super
+	current := super.Type
+	if err := c.ShouldBindJSON(super); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponseJSON{
+			"Error processing the payload",
+			err.Error(),
+		})
+		return
+	}
+	v := requestVersion(c)
+	if super.Type != current {
+		if err := v.fromClient(super, current); err != nil {
+			api.handleSuperWriteError(c, err)
+			return
+		}
+	}
+
+	super, err = super.UpdateByNameOrUUID(requestDB(c, api.DB), c.Param("id"), version)
+	if err != nil {
+		api.handleSuperWriteError(c, err)
+		return
+	}
+
+	body := v.super(super)
+	c.Header("ETag", etag(super.Version, body))
+	c.JSON(http.StatusOK, body)
 }
 
 // SupersDeleteHandler Delete a Super @ /supers/:name...
 // ---
 // @Summary Delete a Super
-// @Description Delete a by name or uuid
+// @Description Delete a by name or uuid. Requires If-Match with the current ETag (or *)
 // @Produce json
-// @Param id path string true "Super's Name or UUID"
+// @Param id path string true "Super's Name (universe:name) or UUID"
+// @Param If-Match header string true "ETag of the Super being deleted (or *)"
 // @Success 204 "Successfully deleted"
 // @Failure 404 {object} errorResponseJSON "Super Not Found"
+// @Failure 412 {object} errorResponseJSON "Super was modified meanwhile"
+// @Failure 428 {object} errorResponseJSON "If-Match is missing"
 // @Failure 500 {object} errorResponseJSON "Unexpected Error"
 // @Router /supers/{id} [delete]
 func (api *SuperAPI) SupersDeleteHandler(c *gin.Context) {
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
 	super := new(models.Super)
-	err := super.DeleteByNameOrUUID(api.DB, c.Param("id"))
+	err := super.DeleteByNameOrUUIDAtVersion(requestDB(c, api.DB), c.Param("id"), version)
 
 	if err != nil {
-		if _, ok := err.(*models.ErrorSuperNotFound); ok {
-			// nothing was deleted - return 404
-			c.JSON(http.StatusNotFound, errorResponseJSON{
-				"Super Not Found",
-				err.Error(),
-			})
-		} else {
-			c.JSON(http.StatusInternalServerError, errorResponseJSON{
-				"Unexpected Error",
-				err.Error(),
-			})
-		}
+		// nothing was deleted
+		api.handleSuperWriteError(c, err)
 	} else {
 		// Deleted. No Content is needed
 		c.Status(http.StatusNoContent)
 	}
 }
 
+// SupersRestorePOSTHandler Restore a deleted Super @ /supers/:id/restore
+// ---
+// @Summary Restore a deleted Super
+// @Description Restore a deleted Super by name or uuid (the most recently deleted, if many have the same name)
+// @Produce json
+// @Param id path string true "Super's Name (universe:name) or UUID"
+// @Success 200 {object} models.Super "Super was restored"
+// @Failure 404 {object} errorResponseJSON "Deleted Super Not Found"
+// @Failure 409 {object} errorResponseJSON "Another Super already exists with this name"
+// @Failure 500 {object} errorResponseJSON "Unexpected Error"
+// @Router /supers/{id}/restore [post]
+func (api *SuperAPI) SupersRestorePOSTHandler(c *gin.Context) {
+	super, err := new(models.Super).RestoreByNameOrUUID(requestDB(c, api.DB), c.Param("id"))
+	if err != nil {
+		api.handleSuperWriteError(c, err)
+		return
+	}
+
+	body := requestVersion(c).super(super)
+	c.Header("ETag", etag(super.Version, body))
+	c.JSON(http.StatusOK, body)
+}
+
+// SupersRevertPOSTHandler Revert a Super to a previous version @ /supers/:id/revert?to_version=N
+// ---
+// @Summary Revert a Super to a previous version
+// @Description Set every field of a Super (by name or uuid) back to how it was at to_version. The revert is a new version. If-Match is optional
+// @Produce json
+// @Param id path string true "Super's Name (universe:name) or UUID"
+// @Param to_version query int true "Version to revert to (see history)"
+// @Param If-Match header string false "ETag of the Super being reverted"
+// @Success 200 {object} models.Super "Super was reverted"
+// @Failure 400 {object} errorResponseJSON "Invalid to_version"
+// @Failure 404 {object} errorResponseJSON "Super (or version) Not Found"
+// @Failure 409 {object} errorResponseJSON "Another Super already exists with the old name"
+// @Failure 412 {object} errorResponseJSON "Super was modified meanwhile"
+// @Router /supers/{id}/revert [post]
+func (api *SuperAPI) SupersRevertPOSTHandler(c *gin.Context) {
+	toVersion, err := strconv.ParseInt(c.Query("to_version"), 10, 64)
+	if err != nil || toVersion <= 0 {
+		c.JSON(http.StatusBadRequest, errorResponseJSON{
+			"Could not process Payload (query parameters)",
+			"to_version should be a positive integer",
+		})
+		return
+	}
+
+	version := models.AnyVersion
+	if c.GetHeader("If-Match") != "" {
+		var ok bool
+		if version, ok = requireIfMatch(c); !ok {
+			return
+		}
+	}
+
+	super, err := new(models.Super).RevertByNameOrUUID(requestDB(c, api.DB), c.Param("id"), toVersion, version)
+	if err != nil {
+		api.handleSuperWriteError(c, err)
+		return
+	}
+
+	body := requestVersion(c).super(super)
+	c.Header("ETag", etag(super.Version, body))
+	c.JSON(http.StatusOK, body)
+}
+
+// SupersHistoryGETHandler get the history of a Super @ /supers/:id/history
+// ---
+// @Summary Get the history of a Super
+// @Description Get every change to a Super (oldest first), by name or uuid. Deleted Supers are included
+// @Produce json
+// @Param id path string true "Super's Name (universe:name) or UUID"
+// @Success 200 {array} models.AuditEntry "Audit log entries"
+// @Failure 404 {object} errorResponseJSON "Super Not Found"
+// @Failure 500 {object} errorResponseJSON "Unexpected Error"
+// @Router /supers/{id}/history [get]
+func (api *SuperAPI) SupersHistoryGETHandler(c *gin.Context) {
+	entries, err := new(models.Super).History(readDB(c, api.DB), c.Param("id"))
+	if err != nil {
+		api.handleSuperWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, requestVersion(c).auditEntries(entries))
+}
+
 //////////////////////////////////////////////////////////////
 //     _____
 //    / ____|
@@ -272,6 +616,7 @@ func (api *SuperAPI) SupersDeleteHandler(c *gin.Context) {
 // GroupHandler interface for REST API for Groups
 type GroupHandler interface {
 	GroupsPOSTHandler(c *gin.Context)
+	GroupsListGETHandler(c *gin.Context)
 	GroupsGETHandler(c *gin.Context)
 	GroupsPUTHandler(c *gin.Context)
 	GroupsDeleteHandler(c *gin.Context)
@@ -290,8 +635,10 @@ type GroupAPI struct {
 // @Accept  json
 // @Produce  json
 // @Param super body models.Group true "Group definition. Supers is a list os their names"
+// @Param Idempotency-Key header string false "Unique key, so the request can be safely retried"
 // @Success 201 {object} models.Group "Group was created"
 // @Failure 409 {object} errorResponseJSON "Group name already exists"
+// @Failure 422 {object} errorResponseJSON "Idempotency-Key was used with a different request"
 // @Failure 500 {object} errorResponseJSON "Unexpected Error"
 // @Router /groups [post]
 func (api *GroupAPI) GroupsPOSTHandler(c *gin.Context) {
@@ -305,14 +652,14 @@ func (api *GroupAPI) GroupsPOSTHandler(c *gin.Context) {
 		return
 	}
 
-	if group, err := group.Create(api.DB); err != nil {
+	if group, err := group.Create(requestDB(c, api.DB)); err != nil {
 		if _, ok := err.(*models.ErrorGroupAlreadyExists); ok {
 			c.JSON(http.StatusConflict, errorResponseJSON{
 				"Group already exists - update it instead",
 				err.Error(),
 			})
 		} else if _, ok := err.(*models.ErrorGroupSuperRelation); ok {
-			log.Println("Found some non-fatal errors. Will log and ignore:", err.Error())
+			logging.Ctx(c.Request.Context()).Warn().Err(err).Msg("Group created without some of its Supers")
 			c.JSON(http.StatusCreated, group)
 		} else {
 			c.JSON(http.StatusInternalServerError, errorResponseJSON{
@@ -326,13 +673,44 @@ func (api *GroupAPI) GroupsPOSTHandler(c *gin.Context) {
 
 }
 
+// GroupsListGETHandler List Groups
+// ---
+// @Summary Get list of Groups
+// @Description Get list of Groups (ordered by name), with the names of their Supers
+// @Produce json
+// @Param limit query int false "Maximum number of Groups (default: all)"
+// @Param offset query int false "Skip this number of Groups"
+// @Success 200 {array} models.Group "List of Groups"
+// @Failure 400 {object} errorResponseJSON "Invalid limit or offset"
+// @Failure 500 {object} errorResponseJSON "Unexpected Error"
+// @Router /groups [get]
+func (api *GroupAPI) GroupsListGETHandler(c *gin.Context) {
+	limit, offset, ok := pageQuery(c)
+	if !ok {
+		return
+	}
+
+	groups, err := new(models.Group).ReadPageWithSupers(readDB(c, api.DB), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponseJSON{
+			"Unexpected Error",
+			err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
 // GroupsGETHandler Get a Group
 // ---
 // @Summary Get Group
-// @Description Get Group by name
+// @Description Get Group by name. The ETag header holds the Group version and a hash of the response (If-None-Match is supported)
 // @Produce json
 // @Param name path string true "Group Name"
+// @Param If-None-Match header string false "ETag from a previous response"
 // @Success 200 {object} models.Group "Group"
+// @Success 304 "Not Modified"
 // @Failure 404 {object} errorResponseJSON "Group Not Found"
 // @Failure 500 {object} errorResponseJSON "Unexpected Error"
 // @Router /groups/{name} [get]
@@ -340,7 +718,7 @@ func (api *GroupAPI) GroupsGETHandler(c *gin.Context) {
 	var group *models.Group
 	var err error
 
-	group, err = group.GetByName(api.DB, c.Param("name"))
+	group, err = group.GetByName(readDB(c, api.DB), c.Param("name"))
 	if err != nil {
 		if _, ok := err.(*models.ErrorGroupNotFound); ok {
 			c.JSON(http.StatusNotFound, errorResponseJSON{
@@ -350,22 +728,107 @@ func (api *GroupAPI) GroupsGETHandler(c *gin.Context) {
 			panic(err)
 		}
 	} else {
+		if notModified(c, group.Version, group) {
+			return
+		}
 		c.JSON(http.StatusOK, group)
 	}
 }
 
+// handleGroupWriteError writes the response for an error returned by a Group write operation
+func (api *GroupAPI) handleGroupWriteError(c *gin.Context, err error) {
+	switch err.(type) {
+	case *models.ErrorGroupNotFound:
+		c.JSON(http.StatusNotFound, errorResponseJSON{
+			"Group not found",
+			err.Error(),
+		})
+	case *models.ErrorGroupAlreadyExists:
+		c.JSON(http.StatusConflict, errorResponseJSON{
+			"Another Group already exists with this name",
+			err.Error(),
+		})
+	case *models.ErrorGroupVersionMismatch:
+		c.JSON(http.StatusPreconditionFailed, errorResponseJSON{
+			"Group was modified meanwhile - get it again and retry",
+			err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, errorResponseJSON{
+			"Unexpected Error",
+			err.Error(),
+		})
+	}
+}
+
 // GroupsPUTHandler Update a Group
+// ---
+// @Summary Update a Group
+// @Description Rename a Group and replace its list of Supers. Requires If-Match with the current ETag (or *)
+// @Accept  json
+// @Produce json
+// @Param name path string true "Group Name"
+// @Param If-Match header string true "ETag of the Group being replaced (or *)"
+// @Param group body models.Group true "Group definition. Supers is a list os their names"
+// @Success 200 {object} models.Group "Group was updated"
+// @Failure 400 {object} errorResponseJSON "Invalid payload"
+// @Failure 404 {object} errorResponseJSON "Group Not Found"
+// @Failure 409 {object} errorResponseJSON "Another Group already exists with this name"
+// @Failure 412 {object} errorResponseJSON "Group was modified meanwhile"
+// @Failure 428 {object} errorResponseJSON "If-Match is missing"
+// @Router /groups/{name} [put]
 func (api *GroupAPI) GroupsPUTHandler(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, errorResponseJSON{
-		"Not implemented", "out of the scope for this exercise",
-	})
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	group := models.Group{}
+	if err := c.ShouldBindJSON(&group); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponseJSON{
+			"Error processing the payload",
+			err.Error(),
+		})
+		return
+	}
+
+	if group, err := group.UpdateByName(requestDB(c, api.DB), c.Param("name"), version); err != nil {
+		if _, ok := err.(*models.ErrorGroupSuperRelation); ok {
+			logging.Ctx(c.Request.Context()).Warn().Err(err).Msg("Group updated without some of its Supers")
+			c.Header("ETag", etag(group.Version, group))
+			c.JSON(http.StatusOK, group)
+		} else {
+			api.handleGroupWriteError(c, err)
+		}
+	} else {
+		c.Header("ETag", etag(group.Version, group))
+		c.JSON(http.StatusOK, group)
+	}
 }
 
 // GroupsDeleteHandler Delete a Group
+// ---
+// @Summary Delete a Group
+// @Description Delete a Group by name (Supers are kept). Requires If-Match with the current ETag (or *)
+// @Produce json
+// @Param name path string true "Group Name"
+// @Param If-Match header string true "ETag of the Group being deleted (or *)"
+// @Success 204 "Successfully deleted"
+// @Failure 404 {object} errorResponseJSON "Group Not Found"
+// @Failure 412 {object} errorResponseJSON "Group was modified meanwhile"
+// @Failure 428 {object} errorResponseJSON "If-Match is missing"
+// @Router /groups/{name} [delete]
 func (api *GroupAPI) GroupsDeleteHandler(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, errorResponseJSON{
-		"Not implemented", "out of the scope for this exercise",
-	})
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	if err := new(models.Group).DeleteByName(requestDB(c, api.DB), c.Param("name"), version); err != nil {
+		api.handleGroupWriteError(c, err)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
 }
 
 //    _____             _
@@ -379,11 +842,43 @@ func (api *GroupAPI) GroupsDeleteHandler(c *gin.Context) {
 
 func setRoutes(r *gin.Engine, db *pg.DB) *gin.Engine {
 
+	r.Use(tracingMiddleware())
+	r.Use(requestContextMiddleware())
+	r.Use(metricsMiddleware())
+
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"data": "hello world"})
 	})
 
-	v1 := r.Group("/api/v1")
+	{
+		api := HealthAPI{
+			DB:       db,
+			Replicas: models.Replicas,
+			Cache:    models.Cache,
+			Workers:  BackgroundWorkers,
+			Router:   r,
+		}
+
+		r.GET("/healthz", api.HealthzGETHandler)
+		r.GET("/readyz", api.ReadyzGETHandler)
+		r.GET("/health", api.HealthGETHandler)
+	}
+
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	setAPIRoutes(r, db, apiV1)
+	setAPIRoutes(r, db, apiV2)
+
+	return r
+}
+
+// setAPIRoutes sets the routes of a version of the API (/api/v1, /api/v2): the same handlers, translating the
+// types of Supers (see apiVersion)
+func setAPIRoutes(r *gin.Engine, db *pg.DB, v *apiVersion) {
+	routes := r.Group("/api/" + v.name)
+	routes.Use(readYourWritesMiddleware())
+	routes.Use(idempotencyMiddleware(db, idempotencyTTL()))
+	routes.Use(apiVersionMiddleware(v))
 	{
 		// Supers
 		{
@@ -392,20 +887,24 @@ func setRoutes(r *gin.Engine, db *pg.DB) *gin.Engine {
 				Router: r,
 			}
 
-			v1.POST("/super-hero", api.SuperHeroPOSTHandler)
-			v1.POST("/super-vilan", api.SuperVilanPOSTHandler)
+			routes.POST("/super-hero", api.SuperHeroPOSTHandler)
+			routes.POST(v.villainPath, api.SuperVilanPOSTHandler)
 
-			supers := v1.Group("/supers")
+			supers := routes.Group("/supers")
 			{
 				supers.POST("", api.SupersPOSTHandler)
 				supers.GET("", api.SupersGETFiltersHandler)
 				supers.GET("/:id", api.SupersGETByIDHandler)
 				supers.PUT("/:id", api.SupersPUTHandler)
+				supers.PATCH("/:id", api.SupersPATCHHandler)
 				supers.DELETE("/:id", api.SupersDeleteHandler)
+				supers.POST("/:id/restore", api.SupersRestorePOSTHandler)
+				supers.GET("/:id/history", api.SupersHistoryGETHandler)
+				supers.POST("/:id/revert", api.SupersRevertPOSTHandler)
 			}
 		}
 
-		groups := v1.Group("/groups")
+		groups := routes.Group("/groups")
 		{
 			api := GroupAPI{
 				DB:     db,
@@ -413,13 +912,57 @@ func setRoutes(r *gin.Engine, db *pg.DB) *gin.Engine {
 			}
 
 			groups.POST("/", api.GroupsPOSTHandler)
+			groups.GET("/", api.GroupsListGETHandler)
 			groups.GET("/:name", api.GroupsGETHandler)
 			groups.PUT("/:name", api.GroupsPUTHandler)
 			groups.DELETE("/:name", api.GroupsDeleteHandler)
 		}
-	}
 
-	return r
+		{
+			api := AuditAPI{
+				DB:     db,
+				Router: r,
+			}
+
+			routes.GET("/audit", api.AuditGETHandler)
+		}
+
+		{
+			api := EventAPI{
+				Bus:    models.Events,
+				Router: r,
+			}
+
+			routes.GET("/events", api.EventsGETHandler)
+			routes.GET("/events/ws", api.EventsWebSocketHandler)
+		}
+
+		webhooks := routes.Group("/webhooks")
+		{
+			api := WebhookAPI{
+				DB:     db,
+				Router: r,
+			}
+
+			webhooks.POST("", api.WebhooksPOSTHandler)
+			webhooks.GET("", api.WebhooksGETHandler)
+			webhooks.GET("/:id", api.WebhooksGETByIDHandler)
+			webhooks.PUT("/:id", api.WebhooksPUTHandler)
+			webhooks.DELETE("/:id", api.WebhooksDeleteHandler)
+			webhooks.GET("/:id/deliveries", api.WebhooksDeliveriesGETHandler)
+		}
+
+		{
+			api := GraphQLAPI{
+				DB:     db,
+				Router: r,
+				Schema: graphQLSchema,
+			}
+
+			routes.POST("/graphql", api.GraphQLHandler)
+			routes.GET("/graphql", api.GraphQLHandler)
+		}
+	}
 }
 
 //     _____
@@ -431,23 +974,17 @@ func setRoutes(r *gin.Engine, db *pg.DB) *gin.Engine {
 //
 //
 
-// SetupRouter setup a default gin.Engine and setup Routes but do not run
+// SetupRouter setup a gin.Engine (logging every request, recovering from panics) and setup Routes but do not run
 func SetupRouter(db *pg.DB) *gin.Engine {
-	r := gin.Default()
+	r := gin.New()
+	r.Use(accessLogMiddleware(), recoveryMiddleware())
 	r = setRoutes(r, db)
 
 	return r
 }
 
-// RunHTTPServer setup routes and start http server
-func RunHTTPServer(db *pg.DB) {
-	r := SetupRouter(db)
-	r.Run()
-}
-
-// RunHTTPServerWithSwagger setup routes (with /swagger) and start http server
-func RunHTTPServerWithSwagger(db *pg.DB) {
-	r := SetupRouter(db)
+// setSwaggerRoutes serves the Swagger UI (/swagger/index.html) and GraphiQL (/api/v1/graphiql, /api/v2/graphiql)
+func setSwaggerRoutes(r *gin.Engine) {
 	// s.Router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	r.GET("/swagger/*any", ginSwagger.CustomWrapHandler(
 		&ginSwagger.Config{
@@ -455,6 +992,82 @@ func RunHTTPServerWithSwagger(db *pg.DB) {
 		},
 		swaggerFiles.Handler,
 	))
+	r.GET("/graphiql", func(c *gin.Context) {
+		c.Redirect(http.StatusFound, "/api/"+apiV1.name+"/graphiql")
+	})
+	for _, v := range []*apiVersion{apiV1, apiV2} {
+		r.GET("/api/"+v.name+"/graphiql", graphiQLHandler)
+	}
+}
+
+// NewHTTPServer setup routes (with /swagger, if enabled) and the http.Server, as configured
+func NewHTTPServer(db *pg.DB, cfg config.Server) *http.Server {
+	r := SetupRouter(db)
+	if cfg.Swagger {
+		setSwaggerRoutes(r)
+	}
+
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           r,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout.Duration,
+		ReadTimeout:       cfg.ReadTimeout.Duration,
+		WriteTimeout:      cfg.WriteTimeout.Duration,
+		IdleTimeout:       cfg.IdleTimeout.Duration,
+	}
+}
+
+// RunHTTPServer setup routes and start http server (https, if a certificate is configured), until ctx is done.
+// Then, it stops accepting connections and waits (at most cfg.ShutdownTimeout) for the in-flight requests
+func RunHTTPServer(ctx context.Context, db *pg.DB, cfg config.Server) error {
+	lis, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return err
+	}
+
+	return ServeHTTP(ctx, NewHTTPServer(db, cfg), lis, cfg)
+}
+
+// ServeHTTP serves srv on lis until ctx is done, then shuts it down gracefully (see RunHTTPServer).
+// The event streams are closed on shutdown, so their clients reconnect elsewhere (and get a reset, from another epoch)
+func ServeHTTP(ctx context.Context, srv *http.Server, lis net.Listener, cfg config.Server) error {
+	srv.RegisterOnShutdown(models.Events.CloseSubscriptions)
+
+	if cfg.TLS() {
+		// the clients authenticated by a certificate are its actor (see requestActor)
+		clientTLS, err := cfg.ClientTLSConfig()
+		if err != nil {
+			return err
+		}
+		if clientTLS != nil {
+			srv.TLSConfig = clientTLS
+		}
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		if cfg.TLS() {
+			logging.Logger().Info().Str("addr", lis.Addr().String()).Msg("Listening and serving HTTPS")
+			served <- srv.ServeTLS(lis, cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			logging.Logger().Info().Str("addr", lis.Addr().String()).Msg("Listening and serving HTTP")
+			served <- srv.Serve(lis)
+		}
+	}()
 
-	r.Run()
+	select {
+	case err := <-served:
+		return err // failed before shutdown
+	case <-ctx.Done():
+	}
+
+	logging.Logger().Info().Stringer("timeout", cfg.ShutdownTimeout.Duration).Msg("Shutting down HTTP server: draining requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close() // cut the remaining requests
+		return fmt.Errorf("HTTP server shutdown: %v", err)
+	}
+	return nil
 }
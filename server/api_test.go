@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -39,3 +40,90 @@ func TestGoHelloWorld(t *testing.T) {
 	)
 
 }
+
+func TestSupersGETInvalidDeletedFilter(t *testing.T) {
+	router := setupTestRouter()
+
+	w := performRequest(router, "GET", "/api/v1/supers?deleted=maybe")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSupersGETInvalidAsOf(t *testing.T) {
+	router := setupTestRouter()
+
+	w := performRequest(router, "GET", "/api/v1/supers/name1?as_of=yesterday")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSupersRevertInvalidToVersion(t *testing.T) {
+	router := setupTestRouter()
+
+	for _, path := range []string{
+		"/api/v1/supers/name1/revert",
+		"/api/v1/supers/name1/revert?to_version=abc",
+		"/api/v1/supers/name1/revert?to_version=0",
+	} {
+		w := performRequest(router, "POST", path)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+}
+
+func TestInvalidPageQuery(t *testing.T) {
+	router := setupTestRouter()
+
+	w := performRequest(router, "GET", "/api/v1/supers?limit=-1")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performRequest(router, "GET", "/api/v1/groups/?offset=first")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateSuperInvalidType(t *testing.T) {
+	router := setupTestRouter()
+
+	req, _ := http.NewRequest("POST", "/api/v1/supers", strings.NewReader(`{"type": "SIDEKICK", "name": "Robin"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateSuperInvalidProfile(t *testing.T) {
+	router := setupTestRouter()
+
+	for _, body := range []string{
+		`{"type": "HERO", "name": "Batman", "strength": "101"}`,
+		`{"type": "HERO", "name": "Batman", "combat": "-1"}`,
+		`{"type": "HERO", "name": "Batman", "weight_kg": "-95"}`,
+		`{"type": "HERO", "name": "Batman", "alignment": "chaotic"}`,
+	} {
+		req, _ := http.NewRequest("POST", "/api/v1/supers", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestCreateSuperInvalidUniverse(t *testing.T) {
+	router := setupTestRouter()
+
+	req, _ := http.NewRequest("POST", "/api/v1/super-hero", strings.NewReader(`{"name": "Captain Marvel", "universe": "Marvel Comics"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSupersGETInvalidPowerstatsFilter(t *testing.T) {
+	router := setupTestRouter()
+
+	w := performRequest(router, "GET", "/api/v1/supers?min_strength=strong")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
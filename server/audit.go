@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v9"
+
+	"github.com/tcarreira/superhero/models"
+)
+
+// defaultAuditLimit is how many entries of the audit log are listed when no limit is given (it only grows)
+const defaultAuditLimit = 100
+
+// AuditHandler interface for REST API for the Audit Log
+type AuditHandler interface {
+	AuditGETHandler(c *gin.Context)
+}
+
+// AuditAPI implements AuditHandler interface
+type AuditAPI struct {
+	DB     *pg.DB
+	Router *gin.Engine
+}
+
+// AuditGETHandler get the audit log @ /audit?entity=super&id=...
+// ---
+// @Summary Get the audit log
+// @Description Get the changes to Supers and Groups (oldest first), optionally filtered by entity and id, a page at a time
+// @Produce json
+// @Param entity query string false "Entity type" Enums(super, group)
+// @Param id query string false "Entity id (Super UUID or Group ID) or name at the time of the change"
+// @Param limit query int false "Maximum number of entries (default: 100)"
+// @Param offset query int false "Skip this number of entries"
+// @Success 200 {array} models.AuditEntry "Audit log entries"
+// @Failure 400 {object} errorResponseJSON "Invalid filters, limit or offset"
+// @Failure 500 {object} errorResponseJSON "Unexpected Error"
+// @Router /audit [get]
+func (api *AuditAPI) AuditGETHandler(c *gin.Context) {
+	filter := models.AuditEntry{
+		Entity:   strings.ToLower(c.Query("entity")),
+		EntityID: c.Query("id"),
+	}
+
+	switch filter.Entity {
+	case "", models.AuditEntitySuper, models.AuditEntityGroup:
+	default:
+		c.JSON(http.StatusBadRequest, errorResponseJSON{
+			"Could not process Payload (query parameters)",
+			"entity should be one of [\"super\", \"group\"]",
+		})
+		return
+	}
+
+	limit, offset, ok := pageQuery(c)
+	if !ok {
+		return
+	}
+	if limit == 0 {
+		limit = defaultAuditLimit
+	}
+
+	entries, err := filter.ReadPage(readDB(c, api.DB), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponseJSON{
+			"Unexpected Error",
+			err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, requestVersion(c).auditEntries(entries))
+}
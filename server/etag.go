@@ -0,0 +1,100 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tcarreira/superhero/models"
+)
+
+// etagVersionSeparator separates the entity version from the hash of the response, in an ETag
+const etagVersionSeparator = "-"
+
+// etag formats an entity version and the response body as a (strong) ETag: "version-hash".
+// The hash also changes with what the version does not cover (eg: groups, relatives_count, the Supers of a Group)
+func etag(version int64, body interface{}) string {
+	tag := strconv.FormatInt(version, 10)
+	if data, err := json.Marshal(body); err == nil {
+		sum := sha256.Sum256(data)
+		tag += etagVersionSeparator + hex.EncodeToString(sum[:8])
+	}
+	return `"` + tag + `"`
+}
+
+// etagMatches checks if a If-None-Match header value matches the ETag.
+// The header may be a list of ETags or "*" (matches anything)
+func etagMatches(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		// weak comparison is fine for GET
+		if strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIfMatch parses a If-Match header value: a single ETag or "*" (models.AnyVersion).
+// Only the version is checked, so "version" alone is also accepted
+func parseIfMatch(header string) (int64, bool) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return models.AnyVersion, true
+	}
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, false
+	}
+	tag := header[1 : len(header)-1]
+	if i := strings.Index(tag, etagVersionSeparator); i >= 0 {
+		tag = tag[:i]
+	}
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// requireIfMatch gets the expected version from If-Match header.
+// If it is missing (428) or invalid (400), the response is written and ok is false
+func requireIfMatch(c *gin.Context) (version int64, ok bool) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, errorResponseJSON{
+			"Precondition Required",
+			"If-Match header is required (use the ETag from GET, or *)",
+		})
+		return 0, false
+	}
+
+	version, ok = parseIfMatch(header)
+	if !ok {
+		c.JSON(http.StatusBadRequest, errorResponseJSON{
+			"Invalid If-Match header",
+			"If-Match should be a single ETag (eg: \"1\") or *",
+		})
+	}
+	return version, ok
+}
+
+// notModified sets the ETag of the response body and checks If-None-Match.
+// When it matches, 304 is written and true is returned
+func notModified(c *gin.Context, version int64, body interface{}) bool {
+	tag := etag(version, body)
+	c.Header("ETag", tag)
+
+	if header := c.GetHeader("If-None-Match"); header != "" && etagMatches(header, tag) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/models"
+)
+
+func TestEtag(t *testing.T) {
+	super := &models.Super{Type: "HERO", Name: "Batman", Version: 3}
+	tag := etag(3, super)
+	assert.Regexp(t, `^"3-[0-9a-f]{16}"$`, tag)
+	assert.Equal(t, tag, etag(3, super))
+
+	// changes the version does not cover change the ETag
+	super.RelativesCount = 1
+	assert.NotEqual(t, tag, etag(3, super))
+	assert.NotEqual(t, etag(3, super), etag(4, super))
+}
+
+func TestEtagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`"3-abc"`, `"3-abc"`))
+	assert.True(t, etagMatches(`W/"3-abc"`, `"3-abc"`))
+	assert.True(t, etagMatches(`"1-abc", "3-abc"`, `"3-abc"`))
+	assert.True(t, etagMatches(`*`, `"3-abc"`))
+	assert.False(t, etagMatches(`"3-def"`, `"3-abc"`))
+	assert.False(t, etagMatches(`"3"`, `"3-abc"`))
+}
+
+func TestParseIfMatch(t *testing.T) {
+	version, ok := parseIfMatch(`"7"`)
+	assert.True(t, ok)
+	assert.EqualValues(t, 7, version)
+
+	version, ok = parseIfMatch(`"7-5d41402abc4b2a76"`)
+	assert.True(t, ok)
+	assert.EqualValues(t, 7, version)
+
+	version, ok = parseIfMatch(" * ")
+	assert.True(t, ok)
+	assert.Equal(t, models.AnyVersion, version)
+
+	for _, header := range []string{`7`, `"abc"`, `"-1"`, `"0"`, `"1", "2"`, `W/"1"`, `"`} {
+		_, ok = parseIfMatch(header)
+		assert.False(t, ok, header)
+	}
+}
+
+func TestWritesRequireIfMatch(t *testing.T) {
+	router := setupTestRouter()
+
+	for _, tt := range []struct {
+		method string
+		path   string
+	}{
+		{"PUT", "/api/v1/supers/name1"},
+		{"PATCH", "/api/v1/supers/name1"},
+		{"DELETE", "/api/v1/supers/name1"},
+		{"PUT", "/api/v1/groups/group1"},
+		{"DELETE", "/api/v1/groups/group1"},
+	} {
+		w := performRequest(router, tt.method, tt.path)
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code, tt.method+" "+tt.path)
+	}
+}
+
+func TestWritesInvalidIfMatch(t *testing.T) {
+	router := setupTestRouter()
+
+	req, _ := http.NewRequest("DELETE", "/api/v1/supers/name1", nil)
+	req.Header.Set("If-Match", "not-an-etag")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestNotModified(t *testing.T) {
+	group := &models.Group{Name: "group1", Version: 2, SupersList: []string{"Batman"}}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/api/v1/groups/group1", nil)
+	assert.False(t, notModified(c, group.Version, group))
+	tag := w.Header().Get("ETag")
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/api/v1/groups/group1", nil)
+	c.Request.Header.Set("If-None-Match", tag)
+	assert.True(t, notModified(c, group.Version, group))
+	c.Writer.WriteHeaderNow()
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// a Super of the Group was renamed: same version, but stale
+	group.SupersList = []string{"dc:Batman"}
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/api/v1/groups/group1", nil)
+	c.Request.Header.Set("If-None-Match", tag)
+	assert.False(t, notModified(c, group.Version, group))
+}
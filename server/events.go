@@ -0,0 +1,265 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/tcarreira/superhero/events"
+	"github.com/tcarreira/superhero/models"
+)
+
+const (
+	lastEventIDHeader = "Last-Event-ID"
+	eventsHeartbeat   = 15 * time.Second
+	eventsWriteWait   = 10 * time.Second
+	eventsRetry       = 3 * time.Second // reconnection delay suggested to SSE clients
+)
+
+// eventEntities are the valid values for the entity filter
+var eventEntities = []string{models.AuditEntitySuper, models.AuditEntityGroup}
+
+var eventsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// EventHandler interface for the stream of changes to Supers and Groups
+type EventHandler interface {
+	EventsGETHandler(c *gin.Context)
+	EventsWebSocketHandler(c *gin.Context)
+}
+
+// EventAPI implements EventHandler interface
+type EventAPI struct {
+	Bus    *events.Bus
+	Router *gin.Engine
+}
+
+// splitQueryList reads a query parameter which may be repeated or comma separated
+func splitQueryList(c *gin.Context, key string) []string {
+	values := make([]string, 0)
+	for _, param := range c.QueryArray(key) {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// checkAllowed returns an error message for the first value not in allowed
+func checkAllowed(key string, values, allowed []string) string {
+	for _, value := range values {
+		found := false
+		for _, a := range allowed {
+			if value == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("%s should be one of [\"%s\"]", key, strings.Join(allowed, "\", \""))
+		}
+	}
+	return ""
+}
+
+// eventsRequest is what a stream of events is subscribed to
+type eventsRequest struct {
+	filter      events.Filter
+	epoch       string
+	lastEventID uint64
+}
+
+// parseEventsRequest reads the filters and the event to resume from (Last-Event-ID header or last_event_id: the
+// "epoch-id" of the event, see events.Event.StreamID). Writes a 400 response and returns false when they are invalid
+func parseEventsRequest(c *gin.Context) (eventsRequest, bool) {
+	filter := events.Filter{
+		Entities: splitQueryList(c, "entity"),
+		Types:    splitQueryList(c, "type"),
+	}
+
+	message := checkAllowed("entity", filter.Entities, eventEntities)
+	if message == "" {
+		message = checkAllowed("type", filter.Types, events.Types)
+	}
+	if message != "" {
+		c.JSON(http.StatusBadRequest, errorResponseJSON{
+			"Could not process Payload (query parameters)",
+			message,
+		})
+		return eventsRequest{}, false
+	}
+
+	lastIDStr := c.GetHeader(lastEventIDHeader)
+	if lastIDStr == "" {
+		lastIDStr = c.Query("last_event_id")
+	}
+
+	request := eventsRequest{filter: filter}
+	if lastIDStr != "" {
+		var err error
+		if request.epoch, request.lastEventID, err = events.ParseStreamID(lastIDStr); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponseJSON{
+				"Invalid Last-Event-ID",
+				"Last-Event-ID should be the id of a previous event",
+			})
+			return eventsRequest{}, false
+		}
+	}
+
+	return request, true
+}
+
+// writeSSE writes an event in the Server-Sent Events format, as the version v of the API
+func writeSSE(w gin.ResponseWriter, v *apiVersion, e events.Event) error {
+	data, err := json.Marshal(v.event(e))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.StreamID(), e.Type, data)
+	return err
+}
+
+// EventsGETHandler streams changes to Supers and Groups @ /events (Server-Sent Events)
+// ---
+// @Summary Stream of changes (Server-Sent Events)
+// @Description Stream every committed change to Supers and Groups, as Server-Sent Events (event type as the SSE event).
+// @Description Reconnecting with the Last-Event-ID header replays the events missed meanwhile, while they are kept in the replay buffer.
+// @Description Otherwise (eg: after a restart of the server), a "reset" event is sent first: reload, as events may have been missed
+// @Produce text/event-stream
+// @Param entity query string false "Only these entities (comma separated)" Enums(super, group)
+// @Param type query string false "Only these event types (comma separated)"
+// @Param Last-Event-ID header string false "Resume after this event (its SSE id: epoch-id)"
+// @Param last_event_id query string false "Resume after this event (when the header cannot be set)"
+// @Success 200 {object} events.Event "Stream of events"
+// @Failure 400 {object} errorResponseJSON "Invalid filters or Last-Event-ID"
+// @Router /events [get]
+func (api *EventAPI) EventsGETHandler(c *gin.Context) {
+	request, ok := parseEventsRequest(c)
+	if !ok {
+		return
+	}
+
+	sub, replay := api.Bus.Subscribe(request.filter, request.epoch, request.lastEventID)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	v := requestVersion(c)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", eventsRetry.Milliseconds())
+	for _, e := range replay {
+		if err := writeSSE(c.Writer, v, e); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				return // fell behind, or shutting down: the client reconnects with Last-Event-ID
+			}
+			if err := writeSSE(c.Writer, v, e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// EventsWebSocketHandler streams changes to Supers and Groups @ /events/ws (WebSocket)
+// ---
+// @Summary Stream of changes (WebSocket)
+// @Description Stream every committed change to Supers and Groups, as a JSON message per event.
+// @Description Reconnecting with last_event_id replays the events missed meanwhile, while they are kept in the replay buffer.
+// @Description Otherwise (eg: after a restart of the server), a "reset" event is sent first: reload, as events may have been missed
+// @Param entity query string false "Only these entities (comma separated)" Enums(super, group)
+// @Param type query string false "Only these event types (comma separated)"
+// @Param last_event_id query string false "Resume after this event (its epoch-id)"
+// @Success 101 {object} events.Event "Switching to WebSocket. Each message is an event"
+// @Failure 400 {object} errorResponseJSON "Invalid filters or last_event_id"
+// @Router /events/ws [get]
+func (api *EventAPI) EventsWebSocketHandler(c *gin.Context) {
+	request, ok := parseEventsRequest(c)
+	if !ok {
+		return
+	}
+
+	conn, err := eventsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return // the upgrader already replied with an error
+	}
+	defer conn.Close()
+
+	sub, replay := api.Bus.Subscribe(request.filter, request.epoch, request.lastEventID)
+	defer sub.Close()
+
+	// the client does not send anything: read only to handle pings and notice when it goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	v := requestVersion(c)
+	send := func(e events.Event) error {
+		conn.SetWriteDeadline(time.Now().Add(eventsWriteWait))
+		return conn.WriteJSON(v.event(e))
+	}
+
+	for _, e := range replay {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "resume with last_event_id"),
+					time.Now().Add(eventsWriteWait))
+				return
+			}
+			if err := send(e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(eventsWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/events"
+)
+
+func setupEventsServer(bus *events.Bus) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	api := EventAPI{Bus: bus, Router: r}
+	r.GET("/events", api.EventsGETHandler)
+	r.GET("/events/ws", api.EventsWebSocketHandler)
+	return httptest.NewServer(r)
+}
+
+func TestEventsGETInvalidFilters(t *testing.T) {
+	router := setupTestRouter()
+
+	for _, path := range []string{
+		"/api/v1/events?entity=planet",
+		"/api/v1/events?type=super.exploded",
+		"/api/v1/events?last_event_id=last",
+		"/api/v1/events/ws?entity=planet",
+	} {
+		w := performRequest(router, "GET", path)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+}
+
+func TestEventsGETStream(t *testing.T) {
+	bus := events.NewBus(10)
+	bus.Publish(events.Event{Type: events.SuperCreated, Entity: "super", EntityID: "uuid1"})
+	bus.Publish(events.Event{Type: events.GroupCreated, Entity: "group", EntityID: "1"})
+	bus.Publish(events.Event{Type: events.SuperDeleted, Entity: "super", EntityID: "uuid1"})
+
+	ts := setupEventsServer(bus)
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/events?entity=super", nil)
+	req.Header.Set(lastEventIDHeader, bus.Epoch()+"-1")
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	readEvent := func() []string {
+		var event []string
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok || (line == "" && len(event) > 0) {
+					return event
+				}
+				if strings.HasPrefix(line, "id:") || strings.HasPrefix(line, "event:") {
+					event = append(event, line)
+				}
+			case <-time.After(5 * time.Second):
+				return event
+			}
+		}
+	}
+
+	// replayed after Last-Event-ID, without the group event
+	assert.Equal(t, []string{"id: " + bus.Epoch() + "-3", "event: super.deleted"}, readEvent())
+
+	bus.Publish(events.Event{Type: events.GroupDeleted, Entity: "group", EntityID: "1"})
+	bus.Publish(events.Event{Type: events.SuperRestored, Entity: "super", EntityID: "uuid1"})
+
+	assert.Equal(t, []string{"id: " + bus.Epoch() + "-5", "event: super.restored"}, readEvent())
+}
+
+func TestEventsGETResetsAnotherEpoch(t *testing.T) {
+	bus := events.NewBus(10)
+	bus.Publish(events.Event{Type: events.SuperCreated, Entity: "super", EntityID: "uuid1"})
+
+	ts := setupEventsServer(bus)
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/events", nil)
+	req.Header.Set(lastEventIDHeader, "previous-7")
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	var event []string
+	for len(event) < 2 && scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "id:") || strings.HasPrefix(line, "event:") {
+			event = append(event, line)
+		}
+	}
+	assert.Equal(t, []string{"id: " + bus.Epoch() + "-1", "event: reset"}, event)
+}
+
+func TestEventsWebSocketStream(t *testing.T) {
+	bus := events.NewBus(10)
+	bus.Publish(events.Event{Type: events.SuperCreated, Entity: "super", EntityID: "uuid1"})
+	bus.Publish(events.Event{Type: events.SuperUpdated, Entity: "super", EntityID: "uuid1"})
+
+	ts := setupEventsServer(bus)
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/events/ws?type=super.updated,super.deleted&last_event_id=" + bus.Epoch() + "-1"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var e events.Event
+	if assert.NoError(t, conn.ReadJSON(&e)) {
+		assert.Equal(t, uint64(2), e.ID)
+		assert.Equal(t, events.SuperUpdated, e.Type)
+	}
+
+	bus.Publish(events.Event{Type: events.SuperCreated, Entity: "super", EntityID: "uuid2"})
+	bus.Publish(events.Event{Type: events.SuperDeleted, Entity: "super", EntityID: "uuid1"})
+
+	if assert.NoError(t, conn.ReadJSON(&e)) {
+		assert.Equal(t, uint64(4), e.ID)
+		assert.Equal(t, "uuid1", e.EntityID)
+	}
+
+	t.Run("reset after a restart", func(t *testing.T) {
+		url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/events/ws?last_event_id=2"
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		var e events.Event
+		if assert.NoError(t, conn.ReadJSON(&e)) {
+			assert.Equal(t, events.Reset, e.Type)
+			assert.Equal(t, bus.Epoch()+"-4", e.StreamID())
+		}
+	})
+}
@@ -0,0 +1,769 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v9"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+
+	"github.com/tcarreira/superhero/models"
+)
+
+// GraphQLHandler interface for the GraphQL API
+type GraphQLHandler interface {
+	GraphQLHandler(c *gin.Context)
+}
+
+// GraphQLAPI implements GraphQLHandler interface
+type GraphQLAPI struct {
+	DB     *pg.DB
+	Router *gin.Engine
+	Schema graphql.Schema
+}
+
+// graphQLRequestJSON is a GraphQL request (POST body, or GET query parameters)
+type graphQLRequestJSON struct {
+	Query         string                 `json:"query" example:"{ supers(type: \"HERO\") { name groups { name } } }"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// graphQLResponseJSON is a GraphQL response
+type graphQLResponseJSON struct {
+	Data   interface{}   `json:"data"`
+	Errors []interface{} `json:"errors,omitempty"`
+}
+
+// graphQLError is a GraphQL error with a code in its extensions (NOT_FOUND, ALREADY_EXISTS, INVALID_ARGUMENT,
+// FAILED_PRECONDITION)
+type graphQLError struct {
+	message string
+	code    string
+}
+
+func (e *graphQLError) Error() string {
+	return e.message
+}
+
+func (e *graphQLError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+// graphQLModelError maps the errors of models to GraphQL errors
+func graphQLModelError(err error) error {
+	switch err.(type) {
+	case *models.ErrorSuperNotFound, *models.ErrorGroupNotFound:
+		return &graphQLError{err.Error(), "NOT_FOUND"}
+	case *models.ErrorSuperAlreadyExists, *models.ErrorGroupAlreadyExists:
+		return &graphQLError{err.Error(), "ALREADY_EXISTS"}
+	case *models.ErrorSuperInvalidFields:
+		return &graphQLError{err.Error(), "INVALID_ARGUMENT"}
+	case *models.ErrorSuperVersionMismatch, *models.ErrorGroupVersionMismatch:
+		return &graphQLError{err.Error(), "FAILED_PRECONDITION"}
+	}
+	return err
+}
+
+//    _                     _
+//   | |                   | |
+//   | |     ___   __ _  __| | ___ _ __ ___
+//   | |    / _ \ / _` |/ _` |/ _ \ '__/ __|
+//   | |___| (_) | (_| | (_| |  __/ |  \__ \
+//   |______\___/ \__,_|\__,_|\___|_|  |___/
+//
+
+// graphQLContextKey is the key of the graphQLContext in the request context
+type graphQLContextKey struct{}
+
+// graphQLContext holds the database, the version of the API and the loaders of a GraphQL request
+type graphQLContext struct {
+	db            *pg.DB
+	version       *apiVersion
+	groupsBySuper *batchLoader
+	supersByGroup *batchLoader
+}
+
+// batchLoader loads a relation for many IDs at once: the IDs known (primed) by the time the first one
+// is needed are loaded together, so nested lists do not need a query per row
+type batchLoader struct {
+	fetch   func(ids []uint64) (map[uint64]interface{}, error)
+	pending map[uint64]bool
+	results map[uint64]interface{}
+}
+
+func newBatchLoader(fetch func(ids []uint64) (map[uint64]interface{}, error)) *batchLoader {
+	return &batchLoader{
+		fetch:   fetch,
+		pending: make(map[uint64]bool),
+		results: make(map[uint64]interface{}),
+	}
+}
+
+// prime registers IDs to be loaded with the next batch
+func (l *batchLoader) prime(ids ...uint64) {
+	for _, id := range ids {
+		if _, loaded := l.results[id]; !loaded {
+			l.pending[id] = true
+		}
+	}
+}
+
+// load gets the result for id, loading every pending ID if it was not loaded yet
+func (l *batchLoader) load(id uint64) (interface{}, error) {
+	if result, loaded := l.results[id]; loaded {
+		return result, nil
+	}
+
+	l.pending[id] = true
+	ids := make([]uint64, 0, len(l.pending))
+	for pendingID := range l.pending {
+		ids = append(ids, pendingID)
+	}
+	l.pending = make(map[uint64]bool)
+
+	results, err := l.fetch(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, loadedID := range ids {
+		l.results[loadedID] = results[loadedID]
+	}
+
+	return l.results[id], nil
+}
+
+// newGraphQLContext creates the loaders for a request. Loading a level primes the next one,
+// so `supers { groups { supers { ... } } }` makes a constant number of queries per level
+func newGraphQLContext(db *pg.DB, version *apiVersion) *graphQLContext {
+	gc := &graphQLContext{db: db, version: version}
+
+	gc.groupsBySuper = newBatchLoader(func(ids []uint64) (map[uint64]interface{}, error) {
+		groups, err := models.GroupsBySuperIDs(db, ids)
+		results := make(map[uint64]interface{})
+		for id, list := range groups {
+			results[id] = list
+			for _, g := range list {
+				gc.supersByGroup.prime(g.ID)
+			}
+		}
+		return results, err
+	})
+
+	gc.supersByGroup = newBatchLoader(func(ids []uint64) (map[uint64]interface{}, error) {
+		supers, err := models.SupersByGroupIDs(db, ids)
+		results := make(map[uint64]interface{})
+		for id, list := range supers {
+			results[id] = list
+			for _, s := range list {
+				gc.groupsBySuper.prime(s.ID)
+			}
+		}
+		return results, err
+	})
+
+	return gc
+}
+
+func graphQLContextFrom(p graphql.ResolveParams) *graphQLContext {
+	return p.Context.Value(graphQLContextKey{}).(*graphQLContext)
+}
+
+//     _____      _
+//    / ____|    | |
+//   | (___   ___| |__   ___ _ __ ___   __ _
+//    \___ \ / __| '_ \ / _ \ '_ ` _ \ / _` |
+//    ____) | (__| | | |  __/ | | | | | (_| |
+//   |_____/ \___|_| |_|\___|_| |_| |_|\__,_|
+//
+
+// sourceSuper gets the Super being resolved
+func sourceSuper(p graphql.ResolveParams) *models.Super {
+	switch s := p.Source.(type) {
+	case *models.Super:
+		return s
+	case models.Super:
+		return &s
+	}
+	return nil
+}
+
+// sourceGroup gets the Group being resolved
+func sourceGroup(p graphql.ResolveParams) *models.Group {
+	switch g := p.Source.(type) {
+	case *models.Group:
+		return g
+	case models.Group:
+		return &g
+	}
+	return nil
+}
+
+// superField is a field resolved from the Super
+func superField(t graphql.Output, description string, value func(s *models.Super) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type:        t,
+		Description: description,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return value(sourceSuper(p)), nil
+		},
+	}
+}
+
+// pageArgs are the pagination arguments of lists
+var pageArgs = graphql.FieldConfigArgument{
+	"limit":  &graphql.ArgumentConfig{Type: graphql.Int, Description: "Maximum number of results (default: all)"},
+	"offset": &graphql.ArgumentConfig{Type: graphql.Int, Description: "Skip this number of results"},
+}
+
+// pageFromArgs reads the pagination arguments
+func pageFromArgs(p graphql.ResolveParams) (int, int, error) {
+	limit, _ := p.Args["limit"].(int)
+	offset, _ := p.Args["offset"].(int)
+	if limit < 0 || offset < 0 {
+		return 0, 0, &graphQLError{"limit and offset must not be negative", "INVALID_ARGUMENT"}
+	}
+	return limit, offset, nil
+}
+
+// versionArg is the expected version of a write (there is no "*", unlike If-Match)
+var versionArg = &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int), Description: "Current version (see the version field)"}
+
+// versionFromArgs reads the expected version of a write
+func versionFromArgs(p graphql.ResolveParams) (int64, error) {
+	version, _ := p.Args["version"].(int)
+	if version <= 0 {
+		return 0, &graphQLError{"version must be positive", "INVALID_ARGUMENT"}
+	}
+	return int64(version), nil
+}
+
+// newGraphQLSchema builds the schema over Supers, Groups and their memberships
+func newGraphQLSchema() (graphql.Schema, error) {
+	var superType, groupType *graphql.Object
+
+	superType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Super",
+		Description: "A SuperHero, SuperVillain, neutral or anti-hero",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"uuid": superField(graphql.NewNonNull(graphql.String), "", func(s *models.Super) interface{} { return s.UUID }),
+				"type": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.String),
+					Description: "HERO or VILAN (v1). HERO, VILLAIN, NEUTRAL or ANTIHERO (v2)",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return graphQLContextFrom(p).version.clientType(sourceSuper(p).Type), nil
+					},
+				},
+				"universe": superField(graphql.NewNonNull(graphql.String), "The name is unique in the universe", func(s *models.Super) interface{} {
+					return s.Universe
+				}),
+				"name": superField(graphql.NewNonNull(graphql.String), "", func(s *models.Super) interface{} { return s.Name }),
+				"fullName": superField(graphql.String, "", func(s *models.Super) interface{} {
+					return s.FullName
+				}),
+				"intelligence": superField(graphql.Int, "", func(s *models.Super) interface{} { return s.Intelligence }),
+				"strength":     superField(graphql.Int, "", func(s *models.Super) interface{} { return s.Strength }),
+				"speed":        superField(graphql.Int, "", func(s *models.Super) interface{} { return s.Speed }),
+				"durability":   superField(graphql.Int, "", func(s *models.Super) interface{} { return s.Durability }),
+				"power":        superField(graphql.Int, "", func(s *models.Super) interface{} { return s.Power }),
+				"combat":       superField(graphql.Int, "", func(s *models.Super) interface{} { return s.Combat }),
+				"aliases": superField(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))), "", func(s *models.Super) interface{} {
+					if s.Aliases == nil {
+						return []string{}
+					}
+					return s.Aliases
+				}),
+				"publisher":       superField(graphql.String, "", func(s *models.Super) interface{} { return s.Publisher }),
+				"alignment":       superField(graphql.String, "good, bad or neutral", func(s *models.Super) interface{} { return s.Alignment }),
+				"firstAppearance": superField(graphql.String, "", func(s *models.Super) interface{} { return s.FirstAppearance }),
+				"gender":          superField(graphql.String, "", func(s *models.Super) interface{} { return s.Gender }),
+				"race":            superField(graphql.String, "", func(s *models.Super) interface{} { return s.Race }),
+				"heightCm":        superField(graphql.Int, "", func(s *models.Super) interface{} { return s.HeightCM }),
+				"weightKg":        superField(graphql.Int, "", func(s *models.Super) interface{} { return s.WeightKG }),
+				"occupation":      superField(graphql.String, "", func(s *models.Super) interface{} { return s.Occupation }),
+				"base":            superField(graphql.String, "", func(s *models.Super) interface{} { return s.Base }),
+				"groupAffiliation": superField(graphql.String, "", func(s *models.Super) interface{} {
+					return s.GroupAffiliation
+				}),
+				"relatives": superField(graphql.String, "As told by the SuperHeroAPI (see relativesCount)", func(s *models.Super) interface{} {
+					return s.Relatives
+				}),
+				"imageUrl": superField(graphql.String, "", func(s *models.Super) interface{} { return s.ImageURL }),
+				"relativesCount": superField(graphql.Int, "How many other Supers are in the same Groups", func(s *models.Super) interface{} {
+					return s.RelativesCount
+				}),
+				"version": superField(graphql.NewNonNull(graphql.Int), "Incremented by every update", func(s *models.Super) interface{} {
+					return s.Version
+				}),
+				"groups": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(groupType))),
+					Description: "Groups of this Super",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						groups, err := graphQLContextFrom(p).groupsBySuper.load(sourceSuper(p).ID)
+						if err != nil || groups == nil {
+							return []models.Group{}, err
+						}
+						return groups, nil
+					},
+				},
+			}
+		}),
+	})
+
+	groupType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Group",
+		Description: "A Group of Supers",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"name": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return sourceGroup(p).Name, nil
+					},
+				},
+				"version": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.Int),
+					Description: "Incremented by every update",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return sourceGroup(p).Version, nil
+					},
+				},
+				"supers": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(superType))),
+					Description: "Supers in this Group",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						supers, err := graphQLContextFrom(p).supersByGroup.load(sourceGroup(p).ID)
+						if err != nil || supers == nil {
+							return []models.Super{}, err
+						}
+						return supers, nil
+					},
+				},
+			}
+		}),
+	})
+
+	queryArgs := graphql.FieldConfigArgument{
+		"type":    &graphql.ArgumentConfig{Type: graphql.String, Description: "HERO / VILAN (v1), HERO / VILLAIN / NEUTRAL / ANTIHERO (v2) (case-insensitive)"},
+		"name":    &graphql.ArgumentConfig{Type: graphql.String, Description: "Name (case-sensitive)"},
+		"uuid":    &graphql.ArgumentConfig{Type: graphql.String, Description: "UUID (case-insensitive)"},
+		"deleted": &graphql.ArgumentConfig{Type: graphql.Boolean, Description: "List only the deleted Supers (trash)"},
+
+		"universe":        &graphql.ArgumentConfig{Type: graphql.String, Description: "Universe (case-insensitive)"},
+		"publisher":       &graphql.ArgumentConfig{Type: graphql.String, Description: "Publisher (case-insensitive)"},
+		"alignment":       &graphql.ArgumentConfig{Type: graphql.String, Description: "good, bad or neutral (case-insensitive)"},
+		"gender":          &graphql.ArgumentConfig{Type: graphql.String, Description: "Gender (case-insensitive)"},
+		"race":            &graphql.ArgumentConfig{Type: graphql.String, Description: "Race (case-insensitive)"},
+		"alias":           &graphql.ArgumentConfig{Type: graphql.String, Description: "One of the aliases (case-insensitive)"},
+		"minIntelligence": &graphql.ArgumentConfig{Type: graphql.Int, Description: "Minimum intelligence"},
+		"minStrength":     &graphql.ArgumentConfig{Type: graphql.Int, Description: "Minimum strength"},
+		"minSpeed":        &graphql.ArgumentConfig{Type: graphql.Int, Description: "Minimum speed"},
+		"minDurability":   &graphql.ArgumentConfig{Type: graphql.Int, Description: "Minimum durability"},
+		"minPower":        &graphql.ArgumentConfig{Type: graphql.Int, Description: "Minimum power"},
+		"minCombat":       &graphql.ArgumentConfig{Type: graphql.Int, Description: "Minimum combat"},
+	}
+	for name, arg := range pageArgs {
+		queryArgs[name] = arg
+	}
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"supers": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(superType))),
+				Description: "List Supers, filtered by type, name, uuid, universe, profile and minimum powerstats",
+				Args:        queryArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					limit, offset, err := pageFromArgs(p)
+					if err != nil {
+						return nil, err
+					}
+					filter := models.Super{}
+					filter.Type, _ = p.Args["type"].(string)
+					filter.Name, _ = p.Args["name"].(string)
+					filter.UUID, _ = p.Args["uuid"].(string)
+					filter.Universe, _ = p.Args["universe"].(string)
+					filter.Publisher, _ = p.Args["publisher"].(string)
+					filter.Alignment, _ = p.Args["alignment"].(string)
+					filter.Gender, _ = p.Args["gender"].(string)
+					filter.Race, _ = p.Args["race"].(string)
+					if alias, ok := p.Args["alias"].(string); ok {
+						filter.Aliases = []string{alias}
+					}
+					for arg, min := range map[string]*int64{
+						"minIntelligence": &filter.MinPowerstats.Intelligence,
+						"minStrength":     &filter.MinPowerstats.Strength,
+						"minSpeed":        &filter.MinPowerstats.Speed,
+						"minDurability":   &filter.MinPowerstats.Durability,
+						"minPower":        &filter.MinPowerstats.Power,
+						"minCombat":       &filter.MinPowerstats.Combat,
+					} {
+						if value, ok := p.Args[arg].(int); ok {
+							*min = int64(value)
+						}
+					}
+					deleted, _ := p.Args["deleted"].(bool)
+
+					gc := graphQLContextFrom(p)
+					gc.version.typeFilter(&filter)
+					supers := filter.ReadPage(gc.db, deleted, limit, offset)
+					for _, s := range supers {
+						gc.groupsBySuper.prime(s.ID)
+					}
+					return supers, nil
+				},
+			},
+			"super": &graphql.Field{
+				Type:        superType,
+				Description: "Get a Super by name or uuid",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String), Description: "Name (universe:name) or UUID"},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					super, err := new(models.Super).GetByNameOrUUID(graphQLContextFrom(p).db, p.Args["id"].(string))
+					if _, notFound := err.(*models.ErrorSuperNotFound); notFound {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, err
+					}
+					return super, nil
+				},
+			},
+			"groups": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(groupType))),
+				Description: "List Groups, by name",
+				Args:        pageArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					limit, offset, err := pageFromArgs(p)
+					if err != nil {
+						return nil, err
+					}
+
+					gc := graphQLContextFrom(p)
+					groups, err := new(models.Group).ReadPage(gc.db, limit, offset)
+					if err != nil {
+						return nil, err
+					}
+					for _, g := range groups {
+						gc.supersByGroup.prime(g.ID)
+					}
+					return groups, nil
+				},
+			},
+			"group": &graphql.Field{
+				Type:        groupType,
+				Description: "Get a Group by name",
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					group, err := new(models.Group).GetByName(graphQLContextFrom(p).db, p.Args["name"].(string))
+					if _, notFound := err.(*models.ErrorGroupNotFound); notFound {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, err
+					}
+					return group, nil
+				},
+			},
+		},
+	})
+
+	superInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "SuperInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"type":             &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String), Description: "HERO or VILAN (v1). HERO, VILLAIN, NEUTRAL or ANTIHERO (v2)"},
+			"universe":         &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "The default universe, if not set"},
+			"name":             &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"fullName":         &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"intelligence":     &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"strength":         &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"speed":            &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"durability":       &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"power":            &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"combat":           &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"aliases":          &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"publisher":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"alignment":        &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "good, bad or neutral"},
+			"firstAppearance":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"gender":           &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"race":             &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"heightCm":         &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"weightKg":         &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"occupation":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"base":             &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"groupAffiliation": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"relatives":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"imageUrl":         &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createSuper": &graphql.Field{
+				Type: graphql.NewNonNull(superType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(superInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					input := p.Args["input"].(map[string]interface{})
+					super := &models.Super{}
+					super.Type, _ = input["type"].(string)
+					super.Universe, _ = input["universe"].(string)
+					super.Name, _ = input["name"].(string)
+					super.FullName, _ = input["fullName"].(string)
+					super.Publisher, _ = input["publisher"].(string)
+					super.Alignment, _ = input["alignment"].(string)
+					super.FirstAppearance, _ = input["firstAppearance"].(string)
+					super.Gender, _ = input["gender"].(string)
+					super.Race, _ = input["race"].(string)
+					super.Occupation, _ = input["occupation"].(string)
+					super.Base, _ = input["base"].(string)
+					super.GroupAffiliation, _ = input["groupAffiliation"].(string)
+					super.Relatives, _ = input["relatives"].(string)
+					super.ImageURL, _ = input["imageUrl"].(string)
+					for field, value := range map[string]*int64{
+						"intelligence": &super.Intelligence,
+						"strength":     &super.Strength,
+						"speed":        &super.Speed,
+						"durability":   &super.Durability,
+						"power":        &super.Power,
+						"combat":       &super.Combat,
+						"heightCm":     &super.HeightCM,
+						"weightKg":     &super.WeightKG,
+					} {
+						if n, ok := input[field].(int); ok {
+							*value = int64(n)
+						}
+					}
+					if aliases, ok := input["aliases"].([]interface{}); ok {
+						for _, alias := range aliases {
+							super.Aliases = append(super.Aliases, alias.(string))
+						}
+					}
+
+					gc := graphQLContextFrom(p)
+					if err := gc.version.fromClient(super, ""); err != nil {
+						return nil, graphQLModelError(err)
+					}
+					if _, err := super.Create(gc.db); err != nil {
+						return nil, graphQLModelError(err)
+					}
+					return super, nil
+				},
+			},
+			"deleteSuper": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Delete a Super by name or uuid, if it is still at version",
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String), Description: "Name (universe:name) or UUID"},
+					"version": versionArg,
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					version, err := versionFromArgs(p)
+					if err != nil {
+						return nil, err
+					}
+					if err := new(models.Super).DeleteByNameOrUUIDAtVersion(graphQLContextFrom(p).db, p.Args["id"].(string), version); err != nil {
+						return nil, graphQLModelError(err)
+					}
+					return true, nil
+				},
+			},
+			"createGroup": &graphql.Field{
+				Type: graphql.NewNonNull(groupType),
+				Args: graphql.FieldConfigArgument{
+					"name":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"supers": &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String)), Description: "Names of the Supers"},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					group := &models.Group{Name: p.Args["name"].(string)}
+					names, _ := p.Args["supers"].([]interface{})
+					for _, name := range names {
+						group.Supers = append(group.Supers, models.Super{Name: name.(string)})
+					}
+
+					if _, err := group.Create(graphQLContextFrom(p).db); err != nil {
+						if _, ok := err.(*models.ErrorGroupSuperRelation); !ok {
+							return nil, graphQLModelError(err)
+						}
+					}
+					return group, nil
+				},
+			},
+			"deleteGroup": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Delete a Group by name (Supers are kept), if it is still at version",
+				Args: graphql.FieldConfigArgument{
+					"name":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"version": versionArg,
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					version, err := versionFromArgs(p)
+					if err != nil {
+						return nil, err
+					}
+					err = new(models.Group).DeleteByName(graphQLContextFrom(p).db, p.Args["name"].(string), version)
+					if err != nil {
+						return nil, graphQLModelError(err)
+					}
+					return true, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: mutation,
+	})
+}
+
+// mustGraphQLSchema builds the schema, which is static
+func mustGraphQLSchema() graphql.Schema {
+	schema, err := newGraphQLSchema()
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// graphQLSchema is the schema of the GraphQL API
+var graphQLSchema = mustGraphQLSchema()
+
+//    _    _                 _ _
+//   | |  | |               | | |
+//   | |__| | __ _ _ __   __| | | ___ _ __
+//   |  __  |/ _` | '_ \ / _` | |/ _ \ '__|
+//   | |  | | (_| | | | | (_| | |  __/ |
+//   |_|  |_|\__,_|_| |_|\__,_|_|\___|_|
+//
+
+// isMutation tells if the operation to be executed is a mutation (unparseable requests are left to the executor)
+func isMutation(query, operationName string) bool {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return false
+	}
+	for _, definition := range doc.Definitions {
+		op, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName == "" || (op.Name != nil && op.Name.Value == operationName) {
+			if op.Operation == ast.OperationTypeMutation {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GraphQLHandler executes a GraphQL request @ /graphql
+// ---
+// @Summary GraphQL API
+// @Description Query Supers, Groups and their memberships (and create/delete them) with GraphQL.
+// @Description Mutations are only accepted with POST. Errors have a code in their extensions (NOT_FOUND, ALREADY_EXISTS, INVALID_ARGUMENT, FAILED_PRECONDITION)
+// @Accept json
+// @Produce json
+// @Param request body graphQLRequestJSON false "GraphQL request (POST)"
+// @Param query query string false "GraphQL query (GET)"
+// @Param variables query string false "JSON encoded variables (GET)"
+// @Param operationName query string false "Operation to execute (GET)"
+// @Success 200 {object} graphQLResponseJSON "GraphQL response (may include errors)"
+// @Failure 400 {object} errorResponseJSON "Invalid request"
+// @Failure 405 {object} errorResponseJSON "Mutations require POST"
+// @Router /graphql [post]
+func (api *GraphQLAPI) GraphQLHandler(c *gin.Context) {
+	request := graphQLRequestJSON{}
+
+	if c.Request.Method == http.MethodGet {
+		request.Query = c.Query("query")
+		request.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				c.JSON(http.StatusBadRequest, errorResponseJSON{
+					"Could not process Payload (query parameters)",
+					"variables should be a JSON object",
+				})
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponseJSON{
+			"Error processing the payload",
+			err.Error(),
+		})
+		return
+	}
+
+	if strings.TrimSpace(request.Query) == "" {
+		c.JSON(http.StatusBadRequest, errorResponseJSON{
+			"Error processing the payload",
+			"query is required",
+		})
+		return
+	}
+	if c.Request.Method == http.MethodGet && isMutation(request.Query, request.OperationName) {
+		c.Header("Allow", http.MethodPost)
+		c.JSON(http.StatusMethodNotAllowed, errorResponseJSON{
+			"Mutations require POST",
+			"send the mutation with POST",
+		})
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), graphQLContextKey{}, newGraphQLContext(requestDB(c, api.DB), requestVersion(c)))
+	result := graphql.Do(graphql.Params{
+		Schema:         api.Schema,
+		RequestString:  request.Query,
+		VariableValues: request.Variables,
+		OperationName:  request.OperationName,
+		Context:        ctx,
+	})
+
+	c.JSON(http.StatusOK, result)
+}
+
+// graphiQLPage is GraphiQL, pointing to the GraphQL API at {{url}}
+const graphiQLPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>GraphiQL - superhero</title>
+  <link rel="stylesheet" href="https://unpkg.com/graphiql@1.0.6/graphiql.min.css" />
+</head>
+<body style="margin: 0;">
+  <div id="graphiql" style="height: 100vh;"></div>
+  <script crossorigin src="https://unpkg.com/react@16/umd/react.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/react-dom@16/umd/react-dom.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/graphiql@1.0.6/graphiql.min.js"></script>
+  <script>
+    const fetcher = GraphiQL.createFetcher
+      ? GraphiQL.createFetcher({ url: '{{url}}' })
+      : (params) => fetch('{{url}}', {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify(params),
+        }).then((response) => response.json());
+    ReactDOM.render(React.createElement(GraphiQL, { fetcher }), document.getElementById('graphiql'));
+  </script>
+</body>
+</html>`
+
+// graphiQLHandler serves GraphiQL (development only) for the GraphQL API next to it:
+// /api/v2/graphiql queries /api/v2/graphql
+func graphiQLHandler(c *gin.Context) {
+	url := strings.TrimSuffix(c.Request.URL.Path, "graphiql") + "graphql"
+	page := strings.Replace(graphiQLPage, "{{url}}", template.JSEscapeString(url), -1)
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+}
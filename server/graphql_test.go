@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type graphQLTestResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func performGraphQL(t *testing.T, body string) (int, graphQLTestResponse) {
+	router := setupTestRouter()
+
+	req, _ := http.NewRequest("POST", "/api/v1/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	response := graphQLTestResponse{}
+	if w.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	}
+	return w.Code, response
+}
+
+func TestGraphQLInvalidRequest(t *testing.T) {
+	code, _ := performGraphQL(t, `{"query": ""}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = performGraphQL(t, `not json`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, response := performGraphQL(t, `{"query": "{ supers { name "}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, response.Errors)
+
+	code, response = performGraphQL(t, `{"query": "{ supers { unknownField } }"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, response.Errors)
+}
+
+func TestGraphQLErrorCodes(t *testing.T) {
+	code, response := performGraphQL(t, `{
+		"query": "mutation($input: SuperInput!) { createSuper(input: $input) { uuid } }",
+		"variables": {"input": {"type": "SIDEKICK", "name": "Robin"}}
+	}`)
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, response.Errors, 1) {
+		assert.Equal(t, "INVALID_ARGUMENT", response.Errors[0].Extensions["code"])
+	}
+
+	code, response = performGraphQL(t, `{"query": "{ supers(limit: -1) { name } }"}`)
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, response.Errors, 1) {
+		assert.Equal(t, "INVALID_ARGUMENT", response.Errors[0].Extensions["code"])
+	}
+}
+
+func TestGraphQLDeleteRequiresVersion(t *testing.T) {
+	for _, query := range []string{`mutation { deleteSuper(id: \"Batman\") }`, `mutation { deleteGroup(name: \"Avengers\") }`} {
+		code, response := performGraphQL(t, `{"query": "`+query+`"}`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.NotEmpty(t, response.Errors, query)
+	}
+
+	for _, query := range []string{`mutation { deleteSuper(id: \"Batman\", version: 0) }`, `mutation { deleteGroup(name: \"Avengers\", version: -1) }`} {
+		code, response := performGraphQL(t, `{"query": "`+query+`"}`)
+		assert.Equal(t, http.StatusOK, code)
+		if assert.Len(t, response.Errors, 1, query) {
+			assert.Equal(t, "INVALID_ARGUMENT", response.Errors[0].Extensions["code"])
+		}
+	}
+}
+
+func TestGraphQLMutationRequiresPOST(t *testing.T) {
+	router := setupTestRouter()
+
+	query := url.Values{"query": {`mutation { deleteGroup(name: "Avengers", version: 1) }`}}
+	w := performRequest(router, "GET", "/api/v1/graphql?"+query.Encode())
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	query = url.Values{
+		"query":         {`query Q { supers { name } } mutation M { deleteGroup(name: "Avengers", version: 1) }`},
+		"operationName": {"M"},
+	}
+	w = performRequest(router, "GET", "/api/v1/graphql?"+query.Encode())
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	query = url.Values{"query": {`{ supers { name } }`}, "variables": {`not json`}}
+	w = performRequest(router, "GET", "/api/v1/graphql?"+query.Encode())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGraphiQLOnlyWithSwagger(t *testing.T) {
+	router := setupTestRouter()
+
+	w := performRequest(router, "GET", "/graphiql")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGraphiQLQueriesItsVersion(t *testing.T) {
+	router := setupTestRouter()
+	setSwaggerRoutes(router)
+
+	w := performRequest(router, "GET", "/api/v2/graphiql")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "'/api/v2/graphql'")
+	assert.NotContains(t, w.Body.String(), "/api/v1/graphql")
+
+	w = performRequest(router, "GET", "/graphiql")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/api/v1/graphiql", w.Header().Get("Location"))
+}
+
+func TestBatchLoader(t *testing.T) {
+	fetches := make([][]uint64, 0)
+	loader := newBatchLoader(func(ids []uint64) (map[uint64]interface{}, error) {
+		fetches = append(fetches, ids)
+		results := make(map[uint64]interface{})
+		for _, id := range ids {
+			if id != 3 {
+				results[id] = id * 10
+			}
+		}
+		return results, nil
+	})
+
+	loader.prime(1, 2, 3)
+	for _, id := range []uint64{1, 2, 3} {
+		result, err := loader.load(id)
+		assert.NoError(t, err)
+		if id == 3 {
+			assert.Nil(t, result)
+		} else {
+			assert.Equal(t, id*10, result)
+		}
+	}
+	assert.Len(t, fetches, 1, "primed ids are loaded together")
+	assert.ElementsMatch(t, []uint64{1, 2, 3}, fetches[0])
+
+	loader.prime(2, 4)
+	result, err := loader.load(5)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(50), result)
+	assert.Len(t, fetches, 2)
+	assert.ElementsMatch(t, []uint64{4, 5}, fetches[1], "loaded ids are not fetched again")
+}
@@ -0,0 +1,270 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v9"
+
+	"github.com/tcarreira/superhero/models"
+)
+
+// healthCheckTimeout bounds each dependency check, so a stuck database does not stall the probes
+const healthCheckTimeout = 2 * time.Second
+
+// Health statuses
+const (
+	healthOK          = "ok"
+	healthUnavailable = "unavailable"
+)
+
+// Workers tracks the background workers (webhooks dispatcher, AMQP publisher), for the readiness probe
+type Workers struct {
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+// BackgroundWorkers are the workers started by serve
+var BackgroundWorkers = &Workers{}
+
+// Run runs a worker (blocking), tracking whether it is running
+func (w *Workers) Run(ctx context.Context, name string, worker func(ctx context.Context)) {
+	w.set(name, true)
+	defer w.set(name, false)
+
+	worker(ctx)
+}
+
+func (w *Workers) set(name string, running bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.running == nil {
+		w.running = make(map[string]bool)
+	}
+	w.running[name] = running
+}
+
+// Status tells which workers are running (every worker which was ever started)
+func (w *Workers) Status() map[string]bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	status := make(map[string]bool, len(w.running))
+	for name, running := range w.running {
+		status[name] = running
+	}
+	return status
+}
+
+// HealthHandler interface for the health probes
+type HealthHandler interface {
+	HealthzGETHandler(c *gin.Context)
+	ReadyzGETHandler(c *gin.Context)
+	HealthGETHandler(c *gin.Context)
+}
+
+// HealthAPI implements HealthHandler interface
+type HealthAPI struct {
+	DB       *pg.DB
+	Replicas *models.ReplicaSet
+	Cache    *models.ReadCache
+	Workers  *Workers
+	Router   *gin.Engine
+}
+
+// healthCheck is the result of checking a dependency
+type healthCheck struct {
+	Status    string                 `json:"status"`
+	LatencyMS float64                `json:"latency_ms"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// healthReport is the result of checking every dependency
+type healthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]healthCheck `json:"checks"`
+}
+
+// check runs fn (bounded by healthCheckTimeout), measuring its latency
+func check(ctx context.Context, fn func(ctx context.Context, details map[string]interface{}) error) healthCheck {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	details := make(map[string]interface{})
+	start := time.Now()
+	err := fn(ctx, details)
+
+	result := healthCheck{
+		Status:    healthOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if len(details) > 0 {
+		result.Details = details
+	}
+	if err != nil {
+		result.Status = healthUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// errNoDatabase is the error of the database checks when the server runs without a database
+var errNoDatabase = errors.New("no database")
+
+func (api *HealthAPI) checkDatabase(ctx context.Context, details map[string]interface{}) error {
+	if api.DB == nil {
+		return errNoDatabase
+	}
+	_, err := api.DB.WithContext(ctx).Exec("SELECT 1")
+	return err
+}
+
+func (api *HealthAPI) checkMigrations(ctx context.Context, details map[string]interface{}) error {
+	if api.DB == nil {
+		return errNoDatabase
+	}
+
+	version, err := models.SchemaVersion(api.DB.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	return checkSchemaVersion(version, models.LatestSchemaVersion(), details)
+}
+
+// checkSchemaVersion fails if the schema is older than expected. A newer one is only a detail: it is how the
+// previous release runs during a rolling upgrade (the new one migrated it)
+func checkSchemaVersion(version, expected int, details map[string]interface{}) error {
+	details["version"] = version
+	details["expected"] = expected
+
+	if version < expected {
+		return fmt.Errorf("schema at version %d, expected %d (run: admin migrate)", version, expected)
+	}
+	if version > expected {
+		details["newer"] = fmt.Sprintf("schema at version %d is newer than this release (%d)", version, expected)
+	}
+	return nil
+}
+
+func (api *HealthAPI) checkWorkers(ctx context.Context, details map[string]interface{}) error {
+	if api.Workers == nil {
+		return nil
+	}
+
+	var stopped []string
+	for name, running := range api.Workers.Status() {
+		details[name] = running
+		if !running {
+			stopped = append(stopped, name)
+		}
+	}
+	if len(stopped) > 0 {
+		sort.Strings(stopped)
+		return fmt.Errorf("stopped: %v", stopped)
+	}
+	return nil
+}
+
+// checkReplicas reports the last checks of the read replicas (see models.ReplicaSet.Run). It never fails:
+// the primary is read from, while no replica is healthy
+func (api *HealthAPI) checkReplicas(ctx context.Context, details map[string]interface{}) error {
+	for _, status := range api.Replicas.Status() {
+		details[status.Addr] = status
+	}
+	return nil
+}
+
+// checkCache reports the counters of the cache of the reads (see models.Cache). It never fails
+func (api *HealthAPI) checkCache(ctx context.Context, details map[string]interface{}) error {
+	stats := api.Cache.Stats()
+	details["entries"] = stats.Entries
+	details["size"] = stats.Size
+	details["hits"] = stats.Hits
+	details["misses"] = stats.Misses
+	details["evictions"] = stats.Evictions
+	details["invalidations"] = stats.Invalidations
+	details["rejected"] = stats.Rejected
+	return nil
+}
+
+// report checks every dependency (concurrently)
+func (api *HealthAPI) report(ctx context.Context) healthReport {
+	checks := map[string]func(ctx context.Context, details map[string]interface{}) error{
+		"database":   api.checkDatabase,
+		"migrations": api.checkMigrations,
+		"workers":    api.checkWorkers,
+	}
+	if api.Replicas != nil && api.Replicas.Len() > 0 {
+		checks["replicas"] = api.checkReplicas
+	}
+	if api.Cache != nil && api.Cache.Enabled() {
+		checks["cache"] = api.checkCache
+	}
+
+	report := healthReport{Status: healthOK, Checks: make(map[string]healthCheck, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, fn := range checks {
+		wg.Add(1)
+		go func(name string, fn func(ctx context.Context, details map[string]interface{}) error) {
+			defer wg.Done()
+			result := check(ctx, fn)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != healthOK {
+				report.Status = healthUnavailable
+			}
+		}(name, fn)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// statusCode is 200 when everything is ok, 503 otherwise
+func (r *healthReport) statusCode() int {
+	if r.Status != healthOK {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// HealthzGETHandler liveness probe @ /healthz: the process is serving requests (no dependency is checked)
+func (api *HealthAPI) HealthzGETHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": healthOK})
+}
+
+// ReadyzGETHandler readiness probe @ /readyz: 200 if the database is reachable, the schema is migrated
+// and the background workers are running. 503 with the failed checks, otherwise
+func (api *HealthAPI) ReadyzGETHandler(c *gin.Context) {
+	report := api.report(c.Request.Context())
+
+	failed := make(map[string]string)
+	for name, result := range report.Checks {
+		if result.Status != healthOK {
+			failed[name] = result.Error
+		}
+	}
+
+	if len(failed) > 0 {
+		c.JSON(report.statusCode(), gin.H{"status": report.Status, "failed": failed})
+		return
+	}
+	c.JSON(report.statusCode(), gin.H{"status": report.Status})
+}
+
+// HealthGETHandler detailed health report @ /health: the status, latency and details of every dependency.
+// 503 if any of them is unavailable
+func (api *HealthAPI) HealthGETHandler(c *gin.Context) {
+	report := api.report(c.Request.Context())
+	c.JSON(report.statusCode(), report)
+}
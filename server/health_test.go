@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/config"
+	"github.com/tcarreira/superhero/models"
+)
+
+func TestHealthzGET(t *testing.T) {
+	router := setupTestRouter()
+
+	w := performRequest(router, "GET", "/healthz")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+}
+
+func TestReadyzGETWithoutDatabase(t *testing.T) {
+	router := setupTestRouter()
+
+	w := performRequest(router, "GET", "/readyz")
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var response struct {
+		Status string
+		Failed map[string]string
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "unavailable", response.Status)
+	assert.Equal(t, "no database", response.Failed["database"])
+	assert.Equal(t, "no database", response.Failed["migrations"])
+}
+
+func TestCheckSchemaVersion(t *testing.T) {
+	details := map[string]interface{}{}
+	assert.NoError(t, checkSchemaVersion(6, 6, details))
+	assert.Equal(t, map[string]interface{}{"version": 6, "expected": 6}, details)
+
+	details = map[string]interface{}{}
+	assert.EqualError(t, checkSchemaVersion(5, 6, details), "schema at version 5, expected 6 (run: admin migrate)")
+
+	details = map[string]interface{}{}
+	assert.NoError(t, checkSchemaVersion(7, 6, details), "the previous release, during a rolling upgrade")
+	assert.Equal(t, "schema at version 7 is newer than this release (6)", details["newer"])
+}
+
+func TestHealthGETWithoutDatabase(t *testing.T) {
+	router := setupTestRouter()
+
+	w := performRequest(router, "GET", "/health")
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var report healthReport
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, "unavailable", report.Status)
+	assert.Equal(t, "unavailable", report.Checks["database"].Status)
+	assert.Equal(t, "no database", report.Checks["database"].Error)
+	assert.Contains(t, w.Body.String(), `"latency_ms":`)
+}
+
+func TestWorkersStatus(t *testing.T) {
+	workers := &Workers{}
+	api := &HealthAPI{Workers: workers}
+	assert.Equal(t, healthOK, check(context.Background(), api.checkWorkers).Status, "no workers were started")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		workers.Run(ctx, "webhooks", func(ctx context.Context) {
+			close(started)
+			<-ctx.Done()
+		})
+	}()
+
+	<-started
+	assert.Equal(t, map[string]bool{"webhooks": true}, workers.Status())
+	result := check(context.Background(), api.checkWorkers)
+	assert.Equal(t, healthOK, result.Status)
+	assert.Equal(t, true, result.Details["webhooks"])
+
+	cancel()
+	<-done
+	assert.Equal(t, map[string]bool{"webhooks": false}, workers.Status())
+	result = check(context.Background(), api.checkWorkers)
+	assert.Equal(t, healthUnavailable, result.Status)
+	assert.Equal(t, "stopped: [webhooks]", result.Error)
+}
+
+func TestHealthCache(t *testing.T) {
+	w := performRequest(setupTestRouter(), "GET", "/health")
+	assert.NotContains(t, w.Body.String(), `"cache"`, "disabled")
+
+	previous := models.Cache
+	models.Cache = models.NewReadCache(config.Default().Cache)
+	defer func() { models.Cache = previous }()
+
+	w = performRequest(setupTestRouter(), "GET", "/health")
+	assert.Contains(t, w.Body.String(), `"cache":{"status":"ok"`)
+	assert.Contains(t, w.Body.String(), `"size":10000`)
+}
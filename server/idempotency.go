@@ -0,0 +1,200 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v9"
+
+	"github.com/tcarreira/superhero/logging"
+	"github.com/tcarreira/superhero/models"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	idempotencyKeyMaxLength   = 255
+	defaultIdempotencyTTL     = 24 * time.Hour
+	idempotentResponseKey     = "idempotent_response" // gin context key (see storeIdempotentResponse)
+)
+
+// idempotentHeaders are the headers of a response which are replayed. The others (eg: X-Request-ID, or the
+// read-your-writes cookie) are about the request, so they are set by the middlewares of the retry
+var idempotentHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// idempotentHeader is a copy of the idempotentHeaders of header
+func idempotentHeader(header http.Header) http.Header {
+	c := make(http.Header)
+	for _, name := range idempotentHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			c[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+	return c
+}
+
+// idempotencyTTL is how long a response is kept for replaying (IDEMPOTENCY_TTL, eg: "24h")
+func idempotencyTTL() time.Duration {
+	value, exists := os.LookupEnv("IDEMPOTENCY_TTL")
+	if !exists {
+		return defaultIdempotencyTTL
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		logging.Logger().Warn().Str("value", value).Stringer("default", defaultIdempotencyTTL).Msg("Invalid IDEMPOTENCY_TTL: using the default")
+		return defaultIdempotencyTTL
+	}
+	return ttl
+}
+
+// idempotencyRequestHash identifies a request by its method, path and body,
+// so a key reused for a different request can be detected
+func idempotencyRequestHash(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body, while writing it
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// storeIdempotentResponse stores body (as JSON) for replaying, instead of the response being written (eg: without
+// secrets, which should not be kept)
+func storeIdempotentResponse(c *gin.Context, body interface{}) {
+	stored, err := json.Marshal(body)
+	if err != nil {
+		logging.Ctx(c.Request.Context()).Error().Err(err).Msg("Could not marshal the response to store for Idempotency-Key")
+		return
+	}
+	c.Set(idempotentResponseKey, stored)
+}
+
+// replayIdempotentResponse writes the response stored for a previous request
+func replayIdempotentResponse(c *gin.Context, key *models.IdempotencyKey) {
+	for name, values := range idempotentHeader(key.Header) { // responses stored before idempotentHeaders had every header
+		c.Writer.Header()[name] = values
+	}
+	c.Header(idempotencyReplayedHeader, "true")
+	c.Status(key.StatusCode)
+	c.Writer.Write(key.ResponseBody)
+}
+
+// idempotencyMiddleware makes POST requests with an Idempotency-Key header safe to retry:
+// the first response is stored and replayed for every retry with the same key and payload
+func idempotencyMiddleware(db *pg.DB, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyStr := c.GetHeader(idempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || keyStr == "" {
+			c.Next()
+			return
+		}
+
+		if len(keyStr) > idempotencyKeyMaxLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponseJSON{
+				"Invalid Idempotency-Key",
+				"Idempotency-Key is too long",
+			})
+			return
+		}
+
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, errorResponseJSON{
+				"Error reading the payload",
+				err.Error(),
+			})
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		key := &models.IdempotencyKey{
+			Key:         keyStr,
+			RequestHash: idempotencyRequestHash(c.Request.Method, c.Request.URL.Path, body),
+		}
+
+		reserved, err := key.Reserve(db, ttl)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponseJSON{
+				"Unexpected Error",
+				err.Error(),
+			})
+			return
+		}
+
+		if !reserved {
+			existing, err := key.GetByKey(db, keyStr)
+			switch {
+			case err != nil:
+				// it expired meanwhile. Let the client retry
+				c.AbortWithStatusJSON(http.StatusConflict, errorResponseJSON{
+					"Idempotency-Key could not be used - retry",
+					err.Error(),
+				})
+			case existing.RequestHash != key.RequestHash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, errorResponseJSON{
+					"Idempotency-Key was already used with a different request",
+					"use a new Idempotency-Key for a different request",
+				})
+			case !existing.Completed():
+				c.AbortWithStatusJSON(http.StatusConflict, errorResponseJSON{
+					"A request with this Idempotency-Key is still being processed",
+					"retry later",
+				})
+			default:
+				replayIdempotentResponse(c, existing)
+				c.Abort()
+			}
+			return
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				key.Release(db)
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		c.Next()
+
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			// do not keep server errors: the client should be able to retry them
+			if err := key.Release(db); err != nil {
+				logging.Ctx(c.Request.Context()).Error().Err(err).Str("idempotency_key", keyStr).Msg("Could not release Idempotency-Key")
+			}
+			return
+		}
+
+		stored := recorder.body.Bytes()
+		if response, exists := c.Get(idempotentResponseKey); exists {
+			stored = response.([]byte)
+		}
+		if err := key.SaveResponse(db, c.Writer.Status(), idempotentHeader(c.Writer.Header()), stored); err != nil {
+			logging.Ctx(c.Request.Context()).Error().Err(err).Str("idempotency_key", keyStr).Msg("Could not save the response for Idempotency-Key")
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyRequestHash(t *testing.T) {
+	hash := idempotencyRequestHash("POST", "/api/v1/supers", []byte(`{"name":"a"}`))
+
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, idempotencyRequestHash("POST", "/api/v1/supers", []byte(`{"name":"a"}`)))
+	assert.NotEqual(t, hash, idempotencyRequestHash("POST", "/api/v1/supers", []byte(`{"name":"b"}`)))
+	assert.NotEqual(t, hash, idempotencyRequestHash("POST", "/api/v1/groups/", []byte(`{"name":"a"}`)))
+}
+
+func TestIdempotencyTTL(t *testing.T) {
+	defer os.Unsetenv("IDEMPOTENCY_TTL")
+
+	os.Unsetenv("IDEMPOTENCY_TTL")
+	assert.Equal(t, defaultIdempotencyTTL, idempotencyTTL())
+
+	os.Setenv("IDEMPOTENCY_TTL", "90m")
+	assert.Equal(t, 90*time.Minute, idempotencyTTL())
+
+	os.Setenv("IDEMPOTENCY_TTL", "forever")
+	assert.Equal(t, defaultIdempotencyTTL, idempotencyTTL())
+}
+
+func TestIdempotencyMiddlewareWithoutDatabase(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(idempotencyMiddleware(nil, time.Minute)) // requests below must never reach the database
+	r.POST("/x", func(c *gin.Context) { c.String(http.StatusCreated, "created") })
+	r.GET("/x", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	t.Run("POST without Idempotency-Key", func(t *testing.T) {
+		w := performRequest(r, "POST", "/x")
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("GET with Idempotency-Key is ignored", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/x", nil)
+		req.Header.Set(idempotencyKeyHeader, "key1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("POST with a too long Idempotency-Key", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/x", nil)
+		req.Header.Set(idempotencyKeyHeader, strings.Repeat("k", idempotencyKeyMaxLength+1))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestResponseRecorder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	var recorded string
+	r.Use(func(c *gin.Context) {
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		recorded = recorder.body.String()
+	})
+	r.GET("/x", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"data": "x"}) })
+
+	w := performRequest(r, "GET", "/x")
+	assert.Equal(t, `{"data":"x"}`, w.Body.String())
+	assert.Equal(t, w.Body.String(), recorded)
+}
+
+func TestStoreIdempotentResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	var stored interface{}
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		stored, _ = c.Get(idempotentResponseKey)
+	})
+	r.POST("/x", func(c *gin.Context) {
+		storeIdempotentResponse(c, gin.H{"secret": ""})
+		c.JSON(http.StatusCreated, gin.H{"secret": "s3cr3t"})
+	})
+
+	w := performRequest(r, "POST", "/x")
+	assert.Equal(t, `{"secret":"s3cr3t"}`, w.Body.String())
+	assert.Equal(t, []byte(`{"secret":""}`), stored)
+}
+
+func TestIdempotentHeader(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("Location", "/api/v1/super-hero/1")
+	header.Set("ETag", `"1-0123456789abcdef"`)
+	header.Set("X-Request-ID", "first")
+	header.Set("Set-Cookie", "read_primary_until=1600000000000")
+	header.Set("X-Read-Primary-Until", "1600000000000")
+
+	assert.Equal(t, http.Header{
+		"Content-Type": {"application/json; charset=utf-8"},
+		"Location":     {"/api/v1/super-hero/1"},
+		"Etag":         {`"1-0123456789abcdef"`},
+	}, idempotentHeader(header))
+}
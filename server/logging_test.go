@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tcarreira/superhero/config"
+	"github.com/tcarreira/superhero/logging"
+)
+
+// setupLoggedRouter is a test router with the access log and recovery of SetupRouter, logging JSON to a buffer
+func setupLoggedRouter(t *testing.T, level string) (*gin.Engine, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logging.SetupWriter(config.Log{Level: level, Format: config.FormatJSON}, &buf)
+	t.Cleanup(func() {
+		logging.SetupWriter(config.Default().Log, os.Stderr)
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(accessLogMiddleware(), recoveryMiddleware())
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+	return setRoutes(r, nil), &buf
+}
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var line map[string]interface{}
+		require.NoError(t, dec.Decode(&line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestAccessLog(t *testing.T) {
+	router, buf := setupLoggedRouter(t, config.LevelDebug)
+
+	req, _ := http.NewRequest("GET", "/api/v1/supers/name1?as_of=yesterday", nil)
+	req.Header.Set(requestIDHeader, "req-1")
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	lines := logLines(t, buf)
+	require.Len(t, lines, 1)
+	line := lines[0]
+	assert.Equal(t, "request", line["message"])
+	assert.Equal(t, "req-1", line[logging.RequestIDField])
+	assert.Equal(t, "/api/v1/supers/:id", line["route"])
+	assert.Equal(t, 400.0, line["status"])
+	assert.Equal(t, config.Redacted, line["headers"].(map[string]interface{})["Authorization"])
+	assert.NotContains(t, buf.String(), "secret")
+
+	t.Run("info", func(t *testing.T) {
+		router, buf := setupLoggedRouter(t, config.LevelInfo)
+
+		performRequest(router, "GET", "/healthz")
+		w := performRequest(router, "GET", "/")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		lines := logLines(t, buf)
+		require.Len(t, lines, 1, "probes are logged at debug level")
+		assert.Equal(t, "/", lines[0]["route"])
+		assert.Equal(t, w.Header().Get(requestIDHeader), lines[0][logging.RequestIDField], "generated request ID")
+		assert.NotContains(t, lines[0], "headers")
+	})
+}
+
+func TestRecovery(t *testing.T) {
+	router, buf := setupLoggedRouter(t, config.LevelInfo)
+
+	w := performRequest(router, "GET", "/panic")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	lines := logLines(t, buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "panic serving request", lines[0]["message"])
+	assert.Equal(t, "boom", lines[0]["panic"])
+	assert.Equal(t, "error", lines[1]["level"])
+	assert.Equal(t, 500.0, lines[1]["status"])
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/metrics"
+)
+
+func TestMetricsByRouteTemplate(t *testing.T) {
+	router := setupTestRouter()
+
+	before := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "/api/v1/supers/:id", "400"))
+	performRequest(router, "GET", "/api/v1/supers/name1?as_of=yesterday")
+	performRequest(router, "GET", "/api/v1/supers/name2?as_of=yesterday")
+	performRequest(router, "GET", "/nowhere")
+
+	assert.Equal(t, before+2, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "/api/v1/supers/:id", "400")))
+
+	w := performRequest(router, "GET", "/metrics")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `superhero_http_requests_total{code="404",method="GET",route="unmatched"}`)
+	assert.Contains(t, w.Body.String(), `superhero_http_request_duration_seconds_count{method="GET",route="/api/v1/supers/:id"}`)
+	assert.NotContains(t, w.Body.String(), "name1", "raw paths are not labels")
+}
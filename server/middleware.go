@@ -0,0 +1,260 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v9"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tcarreira/superhero/logging"
+	"github.com/tcarreira/superhero/metrics"
+	"github.com/tcarreira/superhero/models"
+	"github.com/tcarreira/superhero/tracing"
+)
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	requestIDMaxLength = 128
+	actorHeader        = "X-Actor"
+	anonymousActor     = "anonymous"
+)
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestActor is who is making the request: the subject of its client certificate, when verified (see
+// config.Server.TLSClientCAFile), or else whoever it says it is in X-Actor (advisory: it is not authenticated)
+func requestActor(c *gin.Context) string {
+	if state := c.Request.TLS; state != nil && len(state.VerifiedChains) > 0 {
+		if name := state.VerifiedChains[0][0].Subject.CommonName; name != "" {
+			return name
+		}
+	}
+	if actor := c.GetHeader(actorHeader); actor != "" {
+		return actor
+	}
+	return anonymousActor
+}
+
+// requestContextMiddleware identifies every request (X-Request-ID, generated if missing)
+// and who is making it (see requestActor), so the models can record it (eg: audit log) and it is in every log line
+func requestContextMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if requestID == "" || len(requestID) > requestIDMaxLength {
+			requestID = newRequestID()
+		}
+		actor := requestActor(c)
+
+		c.Header(requestIDHeader, requestID)
+		ctx := models.WithActor(c.Request.Context(), actor)
+		ctx = models.WithRequestID(ctx, requestID)
+		ctx = logging.WithRequestID(ctx, requestID)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// requestDB binds db to the request context (actor, request ID, cancellation)
+func requestDB(c *gin.Context, db *pg.DB) *pg.DB {
+	if db == nil {
+		return nil
+	}
+	return db.WithContext(c.Request.Context())
+}
+
+// currentDB is requestDB to read the current Super, not a cached one (which may be stale, if another process
+// changed it), to update it
+func currentDB(c *gin.Context, db *pg.DB) *pg.DB {
+	if db == nil {
+		return nil
+	}
+	return db.WithContext(models.WithoutCache(c.Request.Context()))
+}
+
+// readDB is requestDB for the handlers which only read: a read replica (see models.Replicas), unless the client
+// wrote recently, as the replicas may not have its write yet (see readYourWritesMiddleware)
+func readDB(c *gin.Context, db *pg.DB) *pg.DB {
+	if db == nil {
+		return nil
+	}
+	if !readsPrimary(c) {
+		db = models.Replicas.Reader(db)
+	}
+	return db.WithContext(c.Request.Context())
+}
+
+// readPrimaryUntil is when (unix milliseconds) the client of a write may read from the replicas again.
+// It is set by the response to the write (cookie and header), and sent back by the client (either of them)
+const readPrimaryUntil = "X-Read-Primary-Until"
+
+// readPrimaryCookie is the cookie of readPrimaryUntil (for browsers)
+const readPrimaryCookie = "superhero_read_primary_until"
+
+// readYourWritesMiddleware makes the clients which write (POST, PUT, PATCH, DELETE) read from the primary for
+// a while, until the replicas have their writes (see models.ReplicaSet.ReadYourWritesWindow)
+func readYourWritesMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if window := models.Replicas.ReadYourWritesWindow(); models.Replicas.Len() > 0 {
+				// before the handler, which writes the response headers. A failed write only costs primary reads
+				until := strconv.FormatInt(time.Now().Add(window).UnixNano()/int64(time.Millisecond), 10)
+				c.Header(readPrimaryUntil, until)
+				http.SetCookie(c.Writer, &http.Cookie{
+					Name:     readPrimaryCookie,
+					Value:    until,
+					Path:     "/",
+					MaxAge:   int(window.Seconds()) + 1,
+					HttpOnly: true,
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+		}
+		c.Next()
+	}
+}
+
+// readsPrimary tells whether the client wrote recently (see readYourWritesMiddleware). Instants beyond the
+// window are ignored, so a client can't pin its reads to the primary
+func readsPrimary(c *gin.Context) bool {
+	value := c.GetHeader(readPrimaryUntil)
+	if value == "" {
+		value, _ = c.Cookie(readPrimaryCookie)
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	until := time.Unix(0, ms*int64(time.Millisecond))
+	now := time.Now()
+	return until.After(now) && !until.After(now.Add(models.Replicas.ReadYourWritesWindow()))
+}
+
+// unmatchedRoute labels the requests which match no route, so raw paths do not become metric labels
+const unmatchedRoute = "unmatched"
+
+// metricsMiddleware counts the requests and observes their latency, by route template (eg: /api/v1/supers/:id)
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// tracingMiddleware records a span for every request (named by its route template), as a child of the trace
+// context propagated by the client (traceparent header), if any. The span is in the context of the request
+func tracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		ctx := tracing.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracing.Tracer().Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(tracing.DefaultServiceName, route, c.Request)...),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.String("http.request_id", models.RequestIDFromContext(c.Request.Context())))
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(status))
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
+
+// quietRoutes are polled (eg: by probes and Prometheus), so their requests are only logged in debug
+var quietRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/health":  true,
+	"/metrics": true,
+}
+
+// accessLogMiddleware logs every request, with its request ID (see requestContextMiddleware).
+// The headers are only logged in debug, with the secret ones redacted (eg: Authorization)
+func accessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		logger := logging.Ctx(c.Request.Context())
+		status := c.Writer.Status()
+		event := logger.Info()
+		switch {
+		case status >= http.StatusInternalServerError:
+			event = logger.Error()
+		case quietRoutes[c.FullPath()]:
+			event = logger.Debug()
+		}
+		if !event.Enabled() {
+			return
+		}
+
+		event = event.
+			Str("method", c.Request.Method).
+			Str("route", c.FullPath()).
+			Str("path", c.Request.URL.Path).
+			Int("status", status).
+			Int("bytes", c.Writer.Size()).
+			Float64("duration_ms", float64(time.Since(start).Microseconds())/1000).
+			Str("client_ip", c.ClientIP()).
+			Str("user_agent", c.Request.UserAgent())
+		if logger.GetLevel() <= zerolog.DebugLevel {
+			event = event.Interface("headers", logging.RedactHeaders(c.Request.Header))
+		}
+		if len(c.Errors) > 0 {
+			event = event.Str("error", c.Errors.String())
+		}
+		event.Msg("request")
+	}
+}
+
+// recoveryMiddleware responds 500 to a panic (the models panic on unexpected database errors), logging it
+func recoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				logging.Ctx(c.Request.Context()).Error().
+					Interface("panic", r).
+					Str("stack", string(debug.Stack())).
+					Msg("panic serving request")
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/models"
+)
+
+func TestRequestContextMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(requestContextMiddleware())
+
+	var actor, requestID string
+	r.GET("/x", func(c *gin.Context) {
+		actor = models.ActorFromContext(c.Request.Context())
+		requestID = models.RequestIDFromContext(c.Request.Context())
+	})
+
+	t.Run("generates a request ID", func(t *testing.T) {
+		w := performRequest(r, "GET", "/x")
+
+		assert.Len(t, requestID, 32)
+		assert.Equal(t, requestID, w.Header().Get(requestIDHeader))
+		assert.Equal(t, anonymousActor, actor)
+	})
+
+	t.Run("keeps the given request ID and actor", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/x", nil)
+		req.Header.Set(requestIDHeader, "my-request")
+		req.Header.Set(actorHeader, "someone")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, "my-request", requestID)
+		assert.Equal(t, "my-request", w.Header().Get(requestIDHeader))
+		assert.Equal(t, "someone", actor)
+	})
+
+	t.Run("the actor of a verified client certificate is its subject", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/x", nil)
+		req.Header.Set(actorHeader, "someone")
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+			{Subject: pkix.Name{CommonName: "backoffice"}},
+		}}}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, "backoffice", actor)
+	})
+}
+
+func TestAuditGETInvalidEntity(t *testing.T) {
+	router := setupTestRouter()
+
+	w := performRequest(router, "GET", "/api/v1/audit?entity=planet")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAuditGETInvalidPage(t *testing.T) {
+	router := setupTestRouter()
+
+	for _, path := range []string{"/api/v1/audit?limit=all", "/api/v1/audit?offset=-1"} {
+		w := performRequest(router, "GET", path)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tcarreira/superhero/models"
+)
+
+// withReplicas configures unreachable (so unhealthy) read replicas, for the duration of the test
+func withReplicas(t *testing.T) {
+	replica := pg.Connect(&pg.Options{Addr: "127.0.0.1:1"})
+	previous := models.Replicas
+	models.Replicas = models.NewReplicaSet([]*pg.DB{replica}, 5*time.Second, time.Second)
+	t.Cleanup(func() {
+		models.Replicas = previous
+		replica.Close()
+	})
+}
+
+func TestReadYourWrites(t *testing.T) {
+	withReplicas(t)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(readYourWritesMiddleware())
+	r.POST("/write", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	r.GET("/read", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"primary": readsPrimary(c)}) })
+
+	w := performRequest(r, "GET", "/read")
+	assert.Empty(t, w.Header().Get(readPrimaryUntil), "reads don't pin the client to the primary")
+	assert.JSONEq(t, `{"primary": false}`, w.Body.String())
+
+	w = performRequest(r, "POST", "/write")
+	until := w.Header().Get(readPrimaryUntil)
+	ms, err := strconv.ParseInt(until, 10, 64)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Now().Add(6*time.Second).UnixNano()/int64(time.Millisecond), ms, 1000)
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, readPrimaryCookie, cookies[0].Name)
+		assert.Equal(t, until, cookies[0].Value)
+		assert.True(t, cookies[0].HttpOnly)
+	}
+
+	read := func(header, cookie string) string {
+		req, _ := http.NewRequest("GET", "/read", nil)
+		if header != "" {
+			req.Header.Set(readPrimaryUntil, header)
+		}
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: readPrimaryCookie, Value: cookie})
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	assert.JSONEq(t, `{"primary": true}`, read(until, ""), "header")
+	assert.JSONEq(t, `{"primary": true}`, read("", until), "cookie")
+
+	past := strconv.FormatInt(time.Now().Add(-time.Second).UnixNano()/int64(time.Millisecond), 10)
+	assert.JSONEq(t, `{"primary": false}`, read(past, ""), "after the window")
+	future := strconv.FormatInt(time.Now().Add(time.Hour).UnixNano()/int64(time.Millisecond), 10)
+	assert.JSONEq(t, `{"primary": false}`, read(future, ""), "beyond the window")
+	assert.JSONEq(t, `{"primary": false}`, read("soon", ""))
+}
+
+func TestReadYourWritesWithoutReplicas(t *testing.T) {
+	router := setupTestRouter()
+
+	w := performRequest(router, "POST", "/api/v1/supers")
+	assert.Empty(t, w.Header().Get(readPrimaryUntil))
+	assert.Empty(t, w.Result().Cookies())
+}
+
+func TestHealthReplicas(t *testing.T) {
+	withReplicas(t)
+	models.Replicas.Check(context.Background())
+	router := setupTestRouter()
+
+	w := performRequest(router, "GET", "/health")
+	assert.Contains(t, w.Body.String(), `"replicas":{"status":"ok"`, "the primary is read from, while the replicas are down")
+	assert.Contains(t, w.Body.String(), `"127.0.0.1:1":{"addr":"127.0.0.1:1","healthy":false`)
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/config"
+)
+
+// serveSlowHandler serves a handler which takes delay to respond. started receives a value when a request arrives
+func serveSlowHandler(t *testing.T, delay, shutdownTimeout time.Duration) (string, chan struct{}, context.CancelFunc, chan error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{}, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		time.Sleep(delay)
+		w.Write([]byte("done"))
+	})}
+
+	cfg := config.Default().Server
+	cfg.ShutdownTimeout = config.Duration{Duration: shutdownTimeout}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- ServeHTTP(ctx, srv, lis, cfg)
+	}()
+	t.Cleanup(cancel)
+
+	return "http://" + lis.Addr().String(), started, cancel, served
+}
+
+func TestServeHTTPDrainsRequests(t *testing.T) {
+	url, started, cancel, served := serveSlowHandler(t, 200*time.Millisecond, 5*time.Second)
+
+	responses := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		responses <- string(body)
+	}()
+
+	<-started
+	cancel()
+
+	assert.Equal(t, "done", <-responses, "the in-flight request is completed")
+	assert.NoError(t, <-served)
+
+	_, err := http.Get(url)
+	assert.Error(t, err, "new connections are refused")
+}
+
+func TestServeHTTPShutdownTimeout(t *testing.T) {
+	url, started, cancel, served := serveSlowHandler(t, 10*time.Second, 50*time.Millisecond)
+
+	go http.Get(url)
+	<-started
+	cancel()
+
+	select {
+	case err := <-served:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the shutdown did not time out")
+	}
+}
+
+func TestServeHTTPListenError(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	cfg := config.Default().Server
+	cfg.Addr = lis.Addr().String()
+	assert.Error(t, RunHTTPServer(context.Background(), nil, cfg), "the address is in use")
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/tcarreira/superhero/events"
+	"github.com/tcarreira/superhero/models"
+)
+
+// apiVersion is the vocabulary of a version of the REST API (and its GraphQL). Every version shares the handlers
+// and the models: the handlers translate the types of the Supers they read (payloads and filters) and write
+// (responses, audit log and events) as the version names them. The database has the v2 vocabulary (models.Types)
+type apiVersion struct {
+	name        string
+	villainPath string // the route creating a villain by name
+
+	// superType is the type t sent by a client, for a Super of type current ("" if new)
+	superType func(t, current string) (string, error)
+	// clientType is the type t (as stored) sent to a client
+	clientType func(t string) string
+	// typeFilter sets the types of the Supers listed by the filter type
+	typeFilter func(filter *models.Super)
+}
+
+var (
+	// apiV1 keeps VILAN, and shows the types it does not have as HERO (see models.V1Type)
+	apiV1 = &apiVersion{
+		name:        models.APIVersion1,
+		villainPath: "/super-vilan",
+		superType:   models.FromV1Type,
+		clientType:  models.V1Type,
+		typeFilter:  (*models.Super).FilterByV1Type,
+	}
+
+	// apiV2 has every type (models.Types). VILAN is still accepted as VILLAIN
+	apiV2 = &apiVersion{
+		name:        models.APIVersion2,
+		villainPath: "/super-villain",
+		superType: func(t, _ string) (string, error) {
+			return t, nil // validated by the models
+		},
+		clientType: func(t string) string {
+			if normalized := models.NormalizeType(t); normalized != "" {
+				return normalized // old versions of Supers may be VILAN
+			}
+			return t
+		},
+		typeFilter: func(*models.Super) {},
+	}
+)
+
+const apiVersionKey = "api_version"
+
+// apiVersionMiddleware sets the version of the API of every request
+func apiVersionMiddleware(v *apiVersion) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(apiVersionKey, v)
+		c.Next()
+	}
+}
+
+// requestVersion is the version of the API of the request (v1, unless set by apiVersionMiddleware)
+func requestVersion(c *gin.Context) *apiVersion {
+	if v, ok := c.Get(apiVersionKey); ok {
+		return v.(*apiVersion)
+	}
+	return apiV1
+}
+
+// fromClient translates the type of a Super sent by a client, replacing one of type current ("" if new)
+func (v *apiVersion) fromClient(super *models.Super, current string) error {
+	t, err := v.superType(super.Type, current)
+	if err != nil {
+		return err
+	}
+	super.Type = t
+	return nil
+}
+
+// super is a copy of the Super, as sent to a client
+func (v *apiVersion) super(s *models.Super) *models.Super {
+	c := *s
+	c.Type = v.clientType(s.Type)
+	return &c
+}
+
+// supers are copies of the Supers, as sent to a client
+func (v *apiVersion) supers(supers []models.Super) []models.Super {
+	c := make([]models.Super, 0, len(supers))
+	for i := range supers {
+		c = append(c, *v.super(&supers[i]))
+	}
+	return c
+}
+
+// snapshot is a copy of the snapshot of a Super (as in JSON), as sent to a client
+func (v *apiVersion) snapshot(snapshot map[string]interface{}) map[string]interface{} {
+	t, ok := snapshot["type"].(string)
+	if !ok {
+		return snapshot
+	}
+	c := make(map[string]interface{}, len(snapshot))
+	for key, value := range snapshot {
+		c[key] = value
+	}
+	c["type"] = v.clientType(t)
+	return c
+}
+
+// auditEntries are copies of the entries of the audit log, as sent to a client
+func (v *apiVersion) auditEntries(entries []models.AuditEntry) []models.AuditEntry {
+	c := make([]models.AuditEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Entity == models.AuditEntitySuper {
+			entry.Before = v.snapshot(entry.Before)
+			entry.After = v.snapshot(entry.After)
+			if change, ok := entry.Diff["type"]; ok {
+				diff := make(map[string]models.AuditChange, len(entry.Diff))
+				for field, change := range entry.Diff {
+					diff[field] = change
+				}
+				if from, ok := change.From.(string); ok {
+					change.From = v.clientType(from)
+				}
+				if to, ok := change.To.(string); ok {
+					change.To = v.clientType(to)
+				}
+				diff["type"] = change
+				entry.Diff = diff
+			}
+		}
+		c = append(c, entry)
+	}
+	return c
+}
+
+// event is a copy of the event, as sent to a client
+func (v *apiVersion) event(e events.Event) events.Event {
+	return models.EventAs(e, v.name)
+}
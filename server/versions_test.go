@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/events"
+	"github.com/tcarreira/superhero/models"
+)
+
+func TestAPIVersionSuper(t *testing.T) {
+	super := &models.Super{Name: "Deadpool", Type: models.TypeAntihero}
+
+	assert.Equal(t, "HERO", apiV1.super(super).Type, "v1 falls back to HERO")
+	assert.Equal(t, "ANTIHERO", apiV2.super(super).Type)
+	assert.Equal(t, models.TypeAntihero, super.Type, "the Super is not changed")
+
+	supers := apiV1.supers([]models.Super{{Type: models.TypeVillain}, {Type: models.TypeNeutral}})
+	assert.Equal(t, "VILAN", supers[0].Type)
+	assert.Equal(t, "HERO", supers[1].Type)
+	assert.Equal(t, "VILLAIN", apiV2.super(&models.Super{Type: "VILAN"}).Type, "old versions of Supers")
+}
+
+func TestAPIVersionAuditEntries(t *testing.T) {
+	entries := []models.AuditEntry{
+		{
+			Entity: models.AuditEntitySuper,
+			Before: map[string]interface{}{"name": "Harley Quinn", "type": "VILLAIN"},
+			After:  map[string]interface{}{"name": "Harley Quinn", "type": "ANTIHERO"},
+			Diff:   map[string]models.AuditChange{"type": {From: "VILLAIN", To: "ANTIHERO"}},
+		},
+		{
+			Entity: models.AuditEntityGroup,
+			After:  map[string]interface{}{"name": "Justice League", "type": "VILLAIN"},
+		},
+	}
+
+	v1 := apiV1.auditEntries(entries)
+	assert.Equal(t, "VILAN", v1[0].Before["type"])
+	assert.Equal(t, "HERO", v1[0].After["type"])
+	assert.Equal(t, models.AuditChange{From: "VILAN", To: "HERO"}, v1[0].Diff["type"])
+	assert.Equal(t, "VILLAIN", v1[1].After["type"], "only Supers have a type")
+
+	assert.Equal(t, "VILLAIN", entries[0].Before["type"], "the entries are not changed")
+	assert.Equal(t, "VILLAIN", entries[0].Diff["type"].From, "the entries are not changed")
+	assert.Equal(t, entries, apiV2.auditEntries(entries))
+}
+
+func TestAPIVersionEvent(t *testing.T) {
+	e := events.Event{Entity: models.AuditEntitySuper, Data: map[string]interface{}{"type": "NEUTRAL"}}
+
+	assert.Equal(t, "HERO", apiV1.event(e).Data["type"])
+	assert.Equal(t, "NEUTRAL", apiV2.event(e).Data["type"])
+	assert.Equal(t, "NEUTRAL", e.Data["type"])
+}
+
+func TestCreateSuperTypeByVersion(t *testing.T) {
+	router := setupTestRouter()
+
+	for _, test := range []struct{ path, body string }{
+		{"/api/v1/supers", `{"type": "NEUTRAL", "name": "Catwoman"}`},
+		{"/api/v1/supers", `{"type": "VILLAIN", "name": "Joker"}`},
+		{"/api/v2/supers", `{"type": "SIDEKICK", "name": "Robin"}`},
+	} {
+		req, _ := http.NewRequest("POST", test.path, strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, test.body)
+	}
+}
+
+func TestVillainRoutesByVersion(t *testing.T) {
+	router := setupTestRouter()
+
+	assert.Equal(t, http.StatusNotFound, performRequest(router, "POST", "/api/v1/super-villain").Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(router, "POST", "/api/v2/super-vilan").Code)
+}
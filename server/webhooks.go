@@ -0,0 +1,286 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v9"
+
+	"github.com/tcarreira/superhero/models"
+)
+
+// webhookDeliveriesLimit is how many recent deliveries are listed for a Webhook
+const webhookDeliveriesLimit = 50
+
+// WebhookHandler interface for REST API for Webhooks
+type WebhookHandler interface {
+	WebhooksPOSTHandler(c *gin.Context)
+	WebhooksGETHandler(c *gin.Context)
+	WebhooksGETByIDHandler(c *gin.Context)
+	WebhooksPUTHandler(c *gin.Context)
+	WebhooksDeleteHandler(c *gin.Context)
+	WebhooksDeliveriesGETHandler(c *gin.Context)
+}
+
+// WebhookAPI implements WebhookHandler interface
+type WebhookAPI struct {
+	DB     *pg.DB
+	Router *gin.Engine
+}
+
+// webhookRequestJSON is the payload for creating or replacing a Webhook
+type webhookRequestJSON struct {
+	URL      string   `json:"url" example:"https://example.com/hooks/superhero" binding:"required"`
+	Secret   string   `json:"secret" example:"my-shared-secret"` // generated when empty (on create)
+	Entities []string `json:"entities" example:"super"`         // empty for every entity
+	Types    []string `json:"types" example:"super.created"`    // empty for every type
+	Enabled  *bool    `json:"enabled" example:"true"`           // default: true
+}
+
+// webhook builds the Webhook from the payload
+func (r *webhookRequestJSON) webhook() *models.Webhook {
+	w := &models.Webhook{
+		URL:      r.URL,
+		Secret:   r.Secret,
+		Entities: r.Entities,
+		Types:    r.Types,
+		Enabled:  true,
+	}
+	if r.Enabled != nil {
+		w.Enabled = *r.Enabled
+	}
+	return w
+}
+
+// webhookDeliveryJSON is a delivery with its attempts
+type webhookDeliveryJSON struct {
+	models.WebhookDelivery
+	AttemptsLog []models.WebhookAttempt `json:"attempts_log"`
+}
+
+// webhookID reads the :id parameter. Writes a 404 response and returns false when it is not a valid ID
+func webhookID(c *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, errorResponseJSON{
+			"Webhook not found",
+			"invalid Webhook id",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// bindWebhook reads the payload. Writes a 400 response and returns false when it is invalid
+func bindWebhook(c *gin.Context) (*models.Webhook, bool) {
+	payload := webhookRequestJSON{}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponseJSON{
+			"Error processing the payload",
+			err.Error(),
+		})
+		return nil, false
+	}
+	return payload.webhook(), true
+}
+
+// handleWebhookError writes the response for an error returned by a Webhook operation
+func (api *WebhookAPI) handleWebhookError(c *gin.Context, err error) {
+	switch err.(type) {
+	case *models.ErrorWebhookInvalidFields:
+		c.JSON(http.StatusBadRequest, errorResponseJSON{
+			"Invalid Webhook",
+			err.Error(),
+		})
+	case *models.ErrorWebhookNotFound:
+		c.JSON(http.StatusNotFound, errorResponseJSON{
+			"Webhook not found",
+			err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, errorResponseJSON{
+			"Unexpected Error",
+			err.Error(),
+		})
+	}
+}
+
+// WebhooksPOSTHandler Create Webhook
+// ---
+// @Summary Create a Webhook
+// @Description Subscribe an endpoint to the events of Supers and Groups. Each event is POSTed as JSON, signed with
+// @Description X-Superhero-Signature: "sha256=" + hex(HMAC-SHA256(secret, X-Superhero-Timestamp + "." + body)).
+// @Description The secret is only returned here (generated when not given), and not when replayed for an Idempotency-Key.
+// @Description The payloads name the types of Supers as the version of the API it is registered with (api_version)
+// @Accept  json
+// @Produce json
+// @Param webhook body webhookRequestJSON true "Webhook definition"
+// @Param Idempotency-Key header string false "Unique key, so the request can be safely retried"
+// @Success 201 {object} models.Webhook "Webhook was created"
+// @Failure 400 {object} errorResponseJSON "Invalid payload"
+// @Failure 500 {object} errorResponseJSON "Unexpected Error"
+// @Router /webhooks [post]
+func (api *WebhookAPI) WebhooksPOSTHandler(c *gin.Context) {
+	webhook, ok := bindWebhook(c)
+	if !ok {
+		return
+	}
+	webhook.APIVersion = requestVersion(c).name
+
+	if err := webhook.Create(requestDB(c, api.DB)); err != nil {
+		api.handleWebhookError(c, err)
+		return
+	}
+
+	stored := *webhook
+	stored.Secret = ""
+	storeIdempotentResponse(c, stored)
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// WebhooksGETHandler List Webhooks
+// ---
+// @Summary List Webhooks
+// @Description List every Webhook (without secrets)
+// @Produce json
+// @Success 200 {array} models.Webhook "Webhooks"
+// @Failure 500 {object} errorResponseJSON "Unexpected Error"
+// @Router /webhooks [get]
+func (api *WebhookAPI) WebhooksGETHandler(c *gin.Context) {
+	webhooks, err := new(models.Webhook).ReadAll(requestDB(c, api.DB))
+	if err != nil {
+		api.handleWebhookError(c, err)
+		return
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// WebhooksGETByIDHandler Get a Webhook
+// ---
+// @Summary Get a Webhook
+// @Description Get a Webhook (without secret)
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} models.Webhook "Webhook"
+// @Failure 404 {object} errorResponseJSON "Webhook Not Found"
+// @Failure 500 {object} errorResponseJSON "Unexpected Error"
+// @Router /webhooks/{id} [get]
+func (api *WebhookAPI) WebhooksGETByIDHandler(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := new(models.Webhook).GetByID(requestDB(c, api.DB), id)
+	if err != nil {
+		api.handleWebhookError(c, err)
+		return
+	}
+
+	webhook.Secret = ""
+	c.JSON(http.StatusOK, webhook)
+}
+
+// WebhooksPUTHandler Update a Webhook
+// ---
+// @Summary Update a Webhook
+// @Description Replace the url, filters and enabled flag of a Webhook (and its secret, when given).
+// @Description Enabling a Webhook which was disabled after repeated failures resumes its pending deliveries
+// @Accept  json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param webhook body webhookRequestJSON true "Webhook definition"
+// @Success 200 {object} models.Webhook "Webhook was updated"
+// @Failure 400 {object} errorResponseJSON "Invalid payload"
+// @Failure 404 {object} errorResponseJSON "Webhook Not Found"
+// @Failure 500 {object} errorResponseJSON "Unexpected Error"
+// @Router /webhooks/{id} [put]
+func (api *WebhookAPI) WebhooksPUTHandler(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	webhook, ok := bindWebhook(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := webhook.UpdateByID(requestDB(c, api.DB), id)
+	if err != nil {
+		api.handleWebhookError(c, err)
+		return
+	}
+
+	webhook.Secret = ""
+	c.JSON(http.StatusOK, webhook)
+}
+
+// WebhooksDeleteHandler Delete a Webhook
+// ---
+// @Summary Delete a Webhook
+// @Description Delete a Webhook, with its deliveries
+// @Param id path int true "Webhook ID"
+// @Success 204 "Successfully deleted"
+// @Failure 404 {object} errorResponseJSON "Webhook Not Found"
+// @Failure 500 {object} errorResponseJSON "Unexpected Error"
+// @Router /webhooks/{id} [delete]
+func (api *WebhookAPI) WebhooksDeleteHandler(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	if err := new(models.Webhook).DeleteByID(requestDB(c, api.DB), id); err != nil {
+		api.handleWebhookError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// WebhooksDeliveriesGETHandler List the deliveries of a Webhook
+// ---
+// @Summary List the deliveries of a Webhook
+// @Description List the most recent deliveries of a Webhook (newest first), with every attempt
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {array} webhookDeliveryJSON "Deliveries"
+// @Failure 404 {object} errorResponseJSON "Webhook Not Found"
+// @Failure 500 {object} errorResponseJSON "Unexpected Error"
+// @Router /webhooks/{id}/deliveries [get]
+func (api *WebhookAPI) WebhooksDeliveriesGETHandler(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	db := requestDB(c, api.DB)
+	webhook, err := new(models.Webhook).GetByID(db, id)
+	if err != nil {
+		api.handleWebhookError(c, err)
+		return
+	}
+
+	deliveries, err := webhook.Deliveries(db, webhook.ID, webhookDeliveriesLimit)
+	if err != nil {
+		api.handleWebhookError(c, err)
+		return
+	}
+
+	result := make([]webhookDeliveryJSON, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		attempts, err := delivery.ReadAttempts(db)
+		if err != nil {
+			api.handleWebhookError(c, err)
+			return
+		}
+		result = append(result, webhookDeliveryJSON{delivery, attempts})
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhooksPOSTInvalidPayload(t *testing.T) {
+	router := setupTestRouter()
+
+	req, _ := http.NewRequest("POST", "/api/v1/webhooks", strings.NewReader(`{"secret": "no url"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestWebhooksInvalidID(t *testing.T) {
+	router := setupTestRouter()
+
+	w := performRequest(router, "GET", "/api/v1/webhooks/first")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = performRequest(router, "DELETE", "/api/v1/webhooks/-1")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-pg/pg/v9"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryHook is a pg.QueryHook which records a span for every query, with its statement
+// (without the parameters' values) as the db.statement attribute
+type QueryHook struct{}
+
+// BeforeQuery starts the span of the query, as a child of the span in the context of the query (see StartChild)
+func (QueryHook) BeforeQuery(ctx context.Context, event *pg.QueryEvent) (context.Context, error) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, nil // not worth formatting the statement
+	}
+
+	statement, err := event.UnformattedQuery()
+	if err != nil {
+		statement = ""
+	}
+	operation := "QUERY"
+	if fields := strings.Fields(statement); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+
+	ctx, _ = StartChild(ctx, "pg "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(event.StartTime),
+		trace.WithAttributes(
+			semconv.DBSystemPostgres,
+			semconv.DBOperationKey.String(operation),
+			semconv.DBStatementKey.String(statement),
+		),
+	)
+	return ctx, nil
+}
+
+// AfterQuery ends the span of the query, with its error (if any)
+func (QueryHook) AfterQuery(ctx context.Context, event *pg.QueryEvent) error {
+	span := trace.SpanFromContext(ctx)
+	if event.Err != nil && event.Err != pg.ErrNoRows {
+		span.RecordError(event.Err)
+		span.SetStatus(codes.Error, event.Err.Error())
+	}
+	span.End()
+	return nil
+}
@@ -0,0 +1,160 @@
+// Package tracing sets up OpenTelemetry tracing: the spans of the HTTP requests, models methods and database queries,
+// exported by OTLP when configured (see Setup). W3C trace context is propagated on incoming and outgoing requests
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlphttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of every span of the application
+const instrumentationName = "github.com/tcarreira/superhero"
+
+// DefaultServiceName is the service name of the spans, unless OTEL_SERVICE_NAME is set
+const DefaultServiceName = "superhero"
+
+// OTLP protocols (OTEL_EXPORTER_OTLP_PROTOCOL)
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http/protobuf"
+)
+
+func init() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Tracer is the tracer of the application. Its spans are dropped unless Setup installed an exporter
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// StartChild starts a span as a child of the span in ctx. Without a recorded parent (eg: the polling workers),
+// no span is started, so they do not flood the traces with roots
+func StartChild(ctx context.Context, name string, opts ...trace.SpanOption) (context.Context, trace.Span) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// Inject propagates the trace context of ctx on the headers of an outgoing request
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Extract is ctx with the trace context propagated on the headers of an incoming request
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Config of the OTLP exporter, from the standard OpenTelemetry environment variables
+type Config struct {
+	Endpoint    string // OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT. Tracing is off if empty
+	Protocol    string // OTEL_EXPORTER_OTLP_PROTOCOL: grpc (default) or http/protobuf
+	Insecure    bool   // OTEL_EXPORTER_OTLP_INSECURE, or an http:// endpoint
+	ServiceName string // OTEL_SERVICE_NAME
+}
+
+// Enabled tells whether the spans are exported
+func (c *Config) Enabled() bool {
+	return c.Endpoint != ""
+}
+
+// ConfigFromEnv reads the exporter configuration. Headers, certificates, compression and timeout
+// (OTEL_EXPORTER_OTLP_HEADERS, ...) are read by the exporter itself
+func ConfigFromEnv(lookupEnv func(string) (string, bool)) (Config, error) {
+	get := func(name string) string {
+		value, _ := lookupEnv(name)
+		return strings.TrimSpace(value)
+	}
+
+	cfg := Config{
+		Endpoint:    get("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"),
+		Protocol:    get("OTEL_EXPORTER_OTLP_PROTOCOL"),
+		ServiceName: get("OTEL_SERVICE_NAME"),
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = get("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if cfg.Protocol == "" {
+		cfg.Protocol = ProtocolGRPC
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = DefaultServiceName
+	}
+
+	if insecure := get("OTEL_EXPORTER_OTLP_INSECURE"); insecure != "" {
+		var err error
+		if cfg.Insecure, err = strconv.ParseBool(insecure); err != nil {
+			return cfg, fmt.Errorf("OTEL_EXPORTER_OTLP_INSECURE: invalid boolean %q", insecure)
+		}
+	}
+
+	// the exporter expects host:port, while the specification allows an URL
+	if u, err := url.Parse(cfg.Endpoint); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		cfg.Endpoint = u.Host
+		cfg.Insecure = cfg.Insecure || u.Scheme == "http"
+	}
+
+	if cfg.Protocol != ProtocolGRPC && cfg.Protocol != ProtocolHTTP {
+		return cfg, fmt.Errorf("OTEL_EXPORTER_OTLP_PROTOCOL: must be %s or %s, not %q", ProtocolGRPC, ProtocolHTTP, cfg.Protocol)
+	}
+	return cfg, nil
+}
+
+// newDriver is the OTLP driver of the configured protocol
+func newDriver(cfg Config) otlp.ProtocolDriver {
+	if cfg.Protocol == ProtocolHTTP {
+		opts := []otlphttp.Option{otlphttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlphttp.WithInsecure())
+		}
+		return otlphttp.NewDriver(opts...)
+	}
+
+	opts := []otlpgrpc.Option{otlpgrpc.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlpgrpc.WithInsecure())
+	}
+	return otlpgrpc.NewDriver(opts...)
+}
+
+// Setup installs the OTLP exporter, if configured. Otherwise, spans are not recorded (no-op).
+// The returned function flushes the pending spans and stops the exporter
+func Setup(ctx context.Context, cfg Config) (func(ctx context.Context) error, error) {
+	if !cfg.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlp.NewExporter(ctx, newDriver(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("OTLP exporter: %v", err)
+	}
+
+	res, err := resource.New(ctx, resource.WithAttributes(semconv.ServiceNameKey.String(cfg.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,133 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+// recordSpans records the spans of the test in memory
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+// lookupEnv looks up the environment variables in env
+func lookupEnv(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	cfg, err := ConfigFromEnv(lookupEnv(nil))
+	assert.NoError(t, err)
+	assert.False(t, cfg.Enabled(), "no-op by default")
+	assert.Equal(t, ProtocolGRPC, cfg.Protocol)
+	assert.Equal(t, DefaultServiceName, cfg.ServiceName)
+
+	cfg, err = ConfigFromEnv(lookupEnv(map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318",
+		"OTEL_EXPORTER_OTLP_PROTOCOL": "http/protobuf",
+		"OTEL_SERVICE_NAME":           "superhero-eu",
+	}))
+	assert.NoError(t, err)
+	assert.True(t, cfg.Enabled())
+	assert.Equal(t, Config{Endpoint: "collector:4318", Protocol: ProtocolHTTP, Insecure: true, ServiceName: "superhero-eu"}, cfg)
+
+	cfg, err = ConfigFromEnv(lookupEnv(map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT":        "collector:4317",
+		"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "https://traces:4317",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "traces:4317", cfg.Endpoint, "the traces endpoint overrides the generic one")
+	assert.False(t, cfg.Insecure)
+
+	_, err = ConfigFromEnv(lookupEnv(map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "http/json"}))
+	assert.EqualError(t, err, `OTEL_EXPORTER_OTLP_PROTOCOL: must be grpc or http/protobuf, not "http/json"`)
+
+	_, err = ConfigFromEnv(lookupEnv(map[string]string{"OTEL_EXPORTER_OTLP_INSECURE": "maybe"}))
+	assert.Error(t, err)
+}
+
+func TestSetupDisabled(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestStartChild(t *testing.T) {
+	exporter := recordSpans(t)
+
+	_, span := StartChild(context.Background(), "orphan")
+	assert.False(t, span.IsRecording(), "no span without a recorded parent")
+	span.End()
+
+	ctx, parent := Tracer().Start(context.Background(), "parent")
+	_, child := StartChild(ctx, "child")
+	child.End()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "child", spans[0].Name)
+		assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent.SpanID())
+	}
+}
+
+func TestQueryHook(t *testing.T) {
+	exporter := recordSpans(t)
+	hook := QueryHook{}
+
+	event := &pg.QueryEvent{Query: "SELECT 1"}
+	ctx, err := hook.BeforeQuery(context.Background(), event)
+	assert.NoError(t, err)
+	assert.NoError(t, hook.AfterQuery(ctx, event))
+	assert.Empty(t, exporter.GetSpans(), "the queries outside of a span are not traced")
+
+	parentCtx, parent := Tracer().Start(context.Background(), "parent")
+	event = &pg.QueryEvent{Query: "select * from supers where name = ?", Params: []interface{}{"Batman"}, Err: errors.New("boom")}
+	ctx, err = hook.BeforeQuery(parentCtx, event)
+	assert.NoError(t, err)
+	assert.NoError(t, hook.AfterQuery(ctx, event))
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 2) {
+		query := spans[0]
+		assert.Equal(t, "pg SELECT", query.Name)
+		assert.Equal(t, parent.SpanContext().SpanID(), query.Parent.SpanID())
+		assert.Contains(t, query.Attributes, semconv.DBStatementKey.String("select * from supers where name = ?"))
+		assert.Contains(t, query.Attributes, semconv.DBSystemPostgres)
+		assert.Equal(t, codes.Error, query.StatusCode)
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	recordSpans(t)
+
+	ctx, span := Tracer().Start(context.Background(), "client")
+	defer span.End()
+
+	header := http.Header{}
+	Inject(ctx, header)
+	assert.Regexp(t, "^00-"+span.SpanContext().TraceID().String()+"-"+span.SpanContext().SpanID().String()+"-01$", header.Get("traceparent"))
+
+	extracted := Extract(context.Background(), header)
+	_, server := Tracer().Start(extracted, "server")
+	defer server.End()
+	assert.Equal(t, span.SpanContext().TraceID(), server.SpanContext().TraceID())
+}
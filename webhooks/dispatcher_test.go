@@ -0,0 +1,224 @@
+// +build sql
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcarreira/superhero/events"
+	"github.com/tcarreira/superhero/models"
+)
+
+// receiver is an httptest server recording the verified events it receives
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	received []events.Event
+}
+
+func newReceiver(t *testing.T, secret string) *receiver {
+	r := &receiver{status: http.StatusOK}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		timestamp, _ := strconv.ParseInt(req.Header.Get(TimestampHeader), 10, 64)
+		assert.True(t, Verify(secret, timestamp, body, req.Header.Get(SignatureHeader)))
+
+		e := events.Event{}
+		assert.NoError(t, json.Unmarshal(body, &e))
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.received = append(r.received, e)
+		w.WriteHeader(r.status)
+	}))
+	return r
+}
+
+func TestDispatcher(t *testing.T) {
+	d := models.SetupEmptyTestDatabase()
+	models.AllowPrivateWebhookTargets = true // the receiver is on 127.0.0.1
+	t.Cleanup(func() { models.AllowPrivateWebhookTargets = false })
+	ctx := context.Background()
+
+	hooks := newReceiver(t, "secret")
+	defer hooks.Close()
+
+	webhook := &models.Webhook{URL: hooks.URL, Secret: "secret", Types: []string{events.SuperCreated}, Enabled: true}
+	if err := webhook.Create(d); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := NewDispatcher(d)
+	dispatcher.BackoffBase = 0 // retry on the next run
+	dispatcher.MaxAttempts = 3
+	dispatcher.DisableAfter = 4
+
+	t.Run("TestDispatcher - delivers committed changes", func(t *testing.T) {
+		super := models.Super{Type: "HERO", Name: "webhook1"}
+		if _, err := super.Create(d); err != nil {
+			t.Fatal(err)
+		}
+		duplicate := models.Super{Type: "HERO", Name: "webhook1"}
+		duplicate.Create(d) // rolled back: never delivered
+
+		assert.NoError(t, dispatcher.RunOnce(ctx))
+
+		if assert.Len(t, hooks.received, 1) {
+			assert.Equal(t, events.SuperCreated, hooks.received[0].Type)
+			assert.Equal(t, super.UUID, hooks.received[0].EntityID)
+		}
+
+		deliveries, err := webhook.Deliveries(d, webhook.ID, 10)
+		assert.NoError(t, err)
+		if assert.Len(t, deliveries, 1) {
+			assert.Equal(t, models.WebhookDeliveryDelivered, deliveries[0].Status)
+			assert.Equal(t, 1, deliveries[0].Attempts)
+		}
+	})
+
+	t.Run("TestDispatcher - retries failed deliveries", func(t *testing.T) {
+		hooks.status = http.StatusInternalServerError
+		super := models.Super{Type: "HERO", Name: "webhook2"}
+		if _, err := super.Create(d); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 4; i++ {
+			assert.NoError(t, dispatcher.RunOnce(ctx))
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		deliveries, _ := webhook.Deliveries(d, webhook.ID, 1)
+		if assert.Len(t, deliveries, 1) {
+			assert.Equal(t, models.WebhookDeliveryFailed, deliveries[0].Status)
+			assert.Equal(t, 3, deliveries[0].Attempts)
+
+			attempts, err := deliveries[0].ReadAttempts(d)
+			assert.NoError(t, err)
+			assert.Len(t, attempts, 3)
+			assert.Equal(t, http.StatusInternalServerError, attempts[2].StatusCode)
+		}
+	})
+
+	t.Run("TestDispatcher - disables after repeated failures", func(t *testing.T) {
+		super := models.Super{Type: "HERO", Name: "webhook3"}
+		if _, err := super.Create(d); err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, dispatcher.RunOnce(ctx))
+
+		got, err := webhook.GetByID(d, webhook.ID)
+		assert.NoError(t, err)
+		assert.False(t, got.Enabled)
+		assert.NotNil(t, got.DisabledAt)
+
+		received := len(hooks.received)
+		assert.NoError(t, dispatcher.RunOnce(ctx))
+		assert.Len(t, hooks.received, received)
+	})
+
+	t.Run("TestDispatcher - enabling resumes deliveries", func(t *testing.T) {
+		hooks.status = http.StatusOK
+		webhook.Enabled = true
+		webhook.Secret = ""
+		if _, err := webhook.UpdateByID(d, webhook.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.NoError(t, dispatcher.RunOnce(ctx))
+
+		deliveries, _ := webhook.Deliveries(d, webhook.ID, 1)
+		if assert.Len(t, deliveries, 1) {
+			assert.Equal(t, models.WebhookDeliveryDelivered, deliveries[0].Status)
+		}
+	})
+}
+
+func TestDispatcherAPIVersions(t *testing.T) {
+	models.AllowPrivateWebhookTargets = true // the receivers are on 127.0.0.1
+	t.Cleanup(func() { models.AllowPrivateWebhookTargets = false })
+	d := models.SetupEmptyTestDatabase()
+
+	v1Hooks := newReceiver(t, "secret")
+	defer v1Hooks.Close()
+	v2Hooks := newReceiver(t, "secret")
+	defer v2Hooks.Close()
+
+	for _, webhook := range []*models.Webhook{
+		{URL: v1Hooks.URL, Secret: "secret", Types: []string{events.SuperCreated}, Enabled: true},
+		{URL: v2Hooks.URL, Secret: "secret", Types: []string{events.SuperCreated}, Enabled: true, APIVersion: models.APIVersion2},
+	} {
+		if err := webhook.Create(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, super := range []models.Super{{Type: models.TypeVillain, Name: "villain"}, {Type: models.TypeNeutral, Name: "neutral"}} {
+		if _, err := super.Create(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.NoError(t, NewDispatcher(d).RunOnce(context.Background()))
+
+	typesOf := func(r *receiver) []string {
+		types := make([]string, 0)
+		for _, e := range r.received {
+			types = append(types, e.APIVersion+":"+e.Data["type"].(string))
+		}
+		return types
+	}
+	assert.Equal(t, []string{"v1:VILAN", "v1:HERO"}, typesOf(v1Hooks))
+	assert.Equal(t, []string{"v2:VILLAIN", "v2:NEUTRAL"}, typesOf(v2Hooks))
+}
+
+func TestDeliveryRenew(t *testing.T) {
+	d := models.SetupEmptyTestDatabase()
+
+	webhook := &models.Webhook{URL: "https://example.com/hooks", Enabled: true}
+	if err := webhook.Create(d); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&models.Super{Type: "HERO", Name: "renewed"}).Create(d); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.QueueWebhookDeliveries(d, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := models.ClaimWebhookDeliveries(d, 10, 0) // the lease expires right away
+	if !assert.NoError(t, err) || !assert.Len(t, claimed, 1) {
+		return
+	}
+	time.Sleep(time.Millisecond)
+	claimedAgain, err := models.ClaimWebhookDeliveries(d, 10, time.Minute) // by another instance
+	if !assert.NoError(t, err) || !assert.Len(t, claimedAgain, 1) {
+		return
+	}
+
+	renewed, err := claimed[0].Renew(d, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, renewed, "claimed again meanwhile")
+
+	renewed, err = claimedAgain[0].Renew(d, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, renewed)
+
+	webhook.Enabled = false
+	if _, err := webhook.UpdateByID(d, webhook.ID); err != nil {
+		t.Fatal(err)
+	}
+	renewed, err = claimedAgain[0].Renew(d, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, renewed, "the Webhook was disabled")
+}
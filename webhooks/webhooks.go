@@ -0,0 +1,255 @@
+// Package webhooks delivers the events of Supers and Groups to the registered Webhooks.
+// Events are read from the outbox, so they are only delivered for committed changes
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tcarreira/superhero/logging"
+	"github.com/tcarreira/superhero/models"
+	"github.com/tcarreira/superhero/tracing"
+)
+
+// Headers sent with each delivery
+const (
+	SignatureHeader = "X-Superhero-Signature" // "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body))
+	TimestampHeader = "X-Superhero-Timestamp" // unix time of the attempt
+	EventHeader     = "X-Superhero-Event"     // event type
+	DeliveryHeader  = "X-Superhero-Delivery"  // delivery ID (the same for every retry)
+)
+
+// Default Dispatcher settings
+const (
+	DefaultPollInterval = 2 * time.Second
+	DefaultBatchSize    = 100
+	DefaultTimeout      = 10 * time.Second
+	DefaultMaxAttempts  = 8
+	DefaultDisableAfter = 20
+	DefaultBackoffBase  = 10 * time.Second
+	DefaultBackoffMax   = time.Hour
+)
+
+// Sign returns the signature of a delivery body, as sent in SignatureHeader
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature received in SignatureHeader (for receivers)
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Dispatcher queues the deliveries of new outbox events and delivers the due ones
+type Dispatcher struct {
+	DB           *pg.DB
+	Client       *http.Client
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int // a delivery fails after this many attempts
+	DisableAfter int // a Webhook is disabled after this many consecutive failed attempts
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+}
+
+// newClient is the HTTP client of the deliveries. The address of each connection is checked when dialing (after
+// resolving names, and for redirects too), so Webhooks can't reach private addresses (see models.CheckWebhookTarget)
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return models.CheckWebhookTarget(net.ParseIP(host))
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // the proxy would connect to the target instead
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: DefaultTimeout, Transport: transport}
+}
+
+// NewDispatcher creates a Dispatcher with the default settings
+func NewDispatcher(db *pg.DB) *Dispatcher {
+	return &Dispatcher{
+		DB:           db,
+		Client:       newClient(),
+		PollInterval: DefaultPollInterval,
+		BatchSize:    DefaultBatchSize,
+		MaxAttempts:  DefaultMaxAttempts,
+		DisableAfter: DefaultDisableAfter,
+		BackoffBase:  DefaultBackoffBase,
+		BackoffMax:   DefaultBackoffMax,
+	}
+}
+
+// Backoff is how long to wait before retrying after the attempt-th failed attempt (exponential, up to BackoffMax)
+func (d *Dispatcher) Backoff(attempt int) time.Duration {
+	wait := d.BackoffBase
+	for i := 1; i < attempt && wait < d.BackoffMax; i++ {
+		wait *= 2
+	}
+	if wait > d.BackoffMax {
+		wait = d.BackoffMax
+	}
+	return wait
+}
+
+// Run processes the outbox and deliveries every PollInterval, until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.RunOnce(ctx); err != nil {
+			logging.Logger().Error().Err(err).Msg("Webhooks dispatcher")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce queues the deliveries of the new outbox events, then delivers the ones which are due
+func (d *Dispatcher) RunOnce(ctx context.Context) error {
+	for {
+		n, err := models.QueueWebhookDeliveries(d.DB, d.BatchSize)
+		if err != nil {
+			return err
+		}
+		if n < d.BatchSize {
+			break
+		}
+	}
+
+	// a delivery is not claimed again while it may still be in progress: the lease covers one attempt, so each
+	// delivery of the batch is leased again right before it is attempted
+	lease := d.Client.Timeout + d.PollInterval
+	if d.Client.Timeout == 0 {
+		lease = DefaultTimeout + d.PollInterval
+	}
+
+	deliveries, err := models.ClaimWebhookDeliveries(d.DB, d.BatchSize, lease)
+	if err != nil {
+		return err
+	}
+
+	webhooks := make(map[uint64]*models.Webhook)
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			return nil // the claimed deliveries are retried when their lease expires
+		}
+
+		delivery := &deliveries[i]
+		renewed, err := delivery.Renew(d.DB, lease)
+		if err != nil {
+			return err
+		}
+		if !renewed {
+			continue // claimed again (after the lease expired), or its Webhook was disabled meanwhile
+		}
+
+		webhook, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			if webhook, err = (&models.Webhook{}).GetByID(d.DB, delivery.WebhookID); err != nil {
+				if _, deleted := err.(*models.ErrorWebhookNotFound); deleted {
+					continue // deleted meanwhile, with its deliveries
+				}
+				return err
+			}
+			webhooks[webhook.ID] = webhook
+		}
+
+		attempt := d.deliver(ctx, webhook, delivery)
+
+		var retryAt time.Time
+		if !attempt.Succeeded() && delivery.Attempts+1 < d.MaxAttempts {
+			retryAt = time.Now().Add(d.Backoff(delivery.Attempts + 1))
+		}
+		if err := delivery.RecordAttempt(d.DB, attempt, retryAt, d.DisableAfter); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deliver sends the delivery to the Webhook once. Each attempt is the root of a trace, propagated to the receiver
+func (d *Dispatcher) deliver(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) *models.WebhookAttempt {
+	ctx, span := tracing.Tracer().Start(ctx, "webhook "+delivery.EventType,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.Int64("webhook.id", int64(webhook.ID)),
+			attribute.Int64("webhook.delivery_id", int64(delivery.ID)),
+			attribute.Int("webhook.attempt", delivery.Attempts+1),
+		),
+	)
+	defer span.End()
+
+	attempt := &models.WebhookAttempt{}
+	start := time.Now()
+	defer func() {
+		attempt.DurationMs = time.Since(start).Milliseconds()
+		if !attempt.Succeeded() {
+			span.SetStatus(codes.Error, attempt.Error)
+		}
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req = req.WithContext(ctx)
+
+	timestamp := start.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "superhero-webhooks")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(delivery.ID, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, delivery.Payload))
+	tracing.Inject(ctx, req.Header)
+	span.SetAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...)
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	attempt.StatusCode = resp.StatusCode
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+	if !attempt.Succeeded() {
+		attempt.Error = fmt.Sprintf("unexpected response: %s", resp.Status)
+	}
+	return attempt
+}
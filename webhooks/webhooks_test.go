@@ -0,0 +1,122 @@
+package webhooks
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/tcarreira/superhero/models"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	signature := Sign("secret", 1600000000, body)
+
+	assert.Regexp(t, "^sha256=[0-9a-f]{64}$", signature)
+	assert.True(t, Verify("secret", 1600000000, body, signature))
+	assert.False(t, Verify("other", 1600000000, body, signature))
+	assert.False(t, Verify("secret", 1600000001, body, signature))
+	assert.False(t, Verify("secret", 1600000000, []byte(`{"id":2}`), signature))
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil)
+	d.BackoffBase = time.Second
+	d.BackoffMax = 10 * time.Second
+
+	assert.Equal(t, time.Second, d.Backoff(1))
+	assert.Equal(t, 2*time.Second, d.Backoff(2))
+	assert.Equal(t, 8*time.Second, d.Backoff(4))
+	assert.Equal(t, 10*time.Second, d.Backoff(5))
+	assert.Equal(t, 10*time.Second, d.Backoff(100))
+}
+
+func TestDeliver(t *testing.T) {
+	models.AllowPrivateWebhookTargets = true // the receiver is on 127.0.0.1
+	t.Cleanup(func() { models.AllowPrivateWebhookTargets = false })
+
+	status := http.StatusOK
+	var received *http.Request
+	var receivedBody []byte
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+
+	d := NewDispatcher(nil)
+	webhook := &models.Webhook{ID: 1, URL: receiver.URL, Secret: "secret"}
+	delivery := &models.WebhookDelivery{ID: 42, WebhookID: 1, EventType: "super.created", Payload: []byte(`{"id":7}`)}
+
+	t.Run("signed delivery", func(t *testing.T) {
+		attempt := d.deliver(context.Background(), webhook, delivery)
+
+		assert.True(t, attempt.Succeeded())
+		assert.Equal(t, http.StatusOK, attempt.StatusCode)
+		assert.Equal(t, http.MethodPost, received.Method)
+		assert.Equal(t, `{"id":7}`, string(receivedBody))
+		assert.Equal(t, "super.created", received.Header.Get(EventHeader))
+		assert.Equal(t, "42", received.Header.Get(DeliveryHeader))
+
+		timestamp, err := strconv.ParseInt(received.Header.Get(TimestampHeader), 10, 64)
+		assert.NoError(t, err)
+		assert.True(t, Verify("secret", timestamp, receivedBody, received.Header.Get(SignatureHeader)))
+	})
+
+	t.Run("trace context", func(t *testing.T) {
+		exporter := tracetest.NewInMemoryExporter()
+		previous := otel.GetTracerProvider()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+		defer otel.SetTracerProvider(previous)
+
+		d.deliver(context.Background(), webhook, delivery)
+
+		spans := exporter.GetSpans()
+		if assert.Len(t, spans, 1) {
+			assert.Equal(t, "webhook super.created", spans[0].Name)
+			assert.Contains(t, received.Header.Get("traceparent"), spans[0].SpanContext.TraceID().String())
+		}
+	})
+
+	t.Run("rejected delivery", func(t *testing.T) {
+		status = http.StatusServiceUnavailable
+		attempt := d.deliver(context.Background(), webhook, delivery)
+
+		assert.False(t, attempt.Succeeded())
+		assert.Equal(t, http.StatusServiceUnavailable, attempt.StatusCode)
+		assert.Contains(t, attempt.Error, "503")
+	})
+
+	t.Run("unreachable endpoint", func(t *testing.T) {
+		unreachable := &models.Webhook{ID: 2, URL: "http://127.0.0.1:1/hook", Secret: "secret"}
+		attempt := d.deliver(context.Background(), unreachable, delivery)
+
+		assert.False(t, attempt.Succeeded())
+		assert.Equal(t, 0, attempt.StatusCode)
+		assert.NotEmpty(t, attempt.Error)
+	})
+
+	t.Run("private target", func(t *testing.T) {
+		models.AllowPrivateWebhookTargets = false
+		defer func() { models.AllowPrivateWebhookTargets = true }()
+		received = nil
+
+		// a new Dispatcher: d keeps its (already checked) connection to the receiver
+		attempt := NewDispatcher(nil).deliver(context.Background(), webhook, delivery)
+
+		assert.False(t, attempt.Succeeded())
+		assert.Contains(t, attempt.Error, "private addresses")
+		assert.Nil(t, received)
+	})
+}